@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/context"
+	"dst-admin-go/internal/pkg/response"
+	"dst-admin-go/internal/service/announce"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnnounceHandler struct {
+	announceService *announce.AnnounceService
+}
+
+func NewAnnounceHandler(announceService *announce.AnnounceService) *AnnounceHandler {
+	return &AnnounceHandler{
+		announceService: announceService,
+	}
+}
+
+func (h *AnnounceHandler) RegisterRoute(router *gin.RouterGroup) {
+	group := router.Group("/api/announce")
+	{
+		group.GET("", h.GetAnnounceList)
+		group.POST("", h.CreateAnnounce)
+		group.PUT("", h.UpdateAnnounce)
+		group.DELETE("", h.DeleteAnnounce)
+		group.POST("/preview", h.PreviewAnnounce)
+		group.POST("/send", h.SendAnnounce)
+	}
+}
+
+// GetAnnounceList 获取公告列表
+// @Summary 获取公告列表
+// @Description 获取当前集群的定时公告列表
+// @Tags announce
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.Announce}
+// @Router /api/announce [get]
+func (h *AnnounceHandler) GetAnnounceList(ctx *gin.Context) {
+	clusterName := context.GetClusterName(ctx)
+	response.OkWithData(h.announceService.GetAnnounceList(clusterName), ctx)
+}
+
+// CreateAnnounce 创建公告
+// @Summary 创建公告
+// @Description 创建定时公告，intervalUnit 可选 s、m、h、d，method 可选 announce、system，frequency 为 0 时不限次数
+// @Tags announce
+// @Accept json
+// @Produce json
+// @Param announce body model.Announce true "公告"
+// @Success 200 {object} response.Response{data=model.Announce}
+// @Router /api/announce [post]
+func (h *AnnounceHandler) CreateAnnounce(ctx *gin.Context) {
+	var announce model.Announce
+	if err := ctx.ShouldBindJSON(&announce); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	announce.ClusterName = context.GetClusterName(ctx)
+	if err := h.announceService.CreateAnnounce(&announce); err != nil {
+		response.FailWithMessage("创建公告失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(announce, ctx)
+}
+
+// UpdateAnnounce 更新公告
+// @Summary 更新公告
+// @Description 更新定时公告并重新开始发送
+// @Tags announce
+// @Accept json
+// @Produce json
+// @Param announce body model.Announce true "公告"
+// @Success 200 {object} response.Response{data=model.Announce}
+// @Router /api/announce [put]
+func (h *AnnounceHandler) UpdateAnnounce(ctx *gin.Context) {
+	var announce model.Announce
+	if err := ctx.ShouldBindJSON(&announce); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if err := h.announceService.UpdateAnnounce(context.GetClusterName(ctx), &announce); err != nil {
+		response.FailWithMessage("更新公告失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(announce, ctx)
+}
+
+// DeleteAnnounce 删除公告
+// @Summary 删除公告
+// @Description 删除定时公告并停止发送
+// @Tags announce
+// @Produce json
+// @Param id query int true "公告ID"
+// @Success 200 {object} response.Response
+// @Router /api/announce [delete]
+func (h *AnnounceHandler) DeleteAnnounce(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Query("id"), 10, 64)
+	if err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if err := h.announceService.DeleteAnnounce(context.GetClusterName(ctx), uint(id)); err != nil {
+		response.FailWithMessage("删除公告失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithMessage("删除公告成功", ctx)
+}
+
+// PreviewAnnounce 预览公告
+// @Summary 预览公告
+// @Description 返回公告对应的游戏命令，不发送
+// @Tags announce
+// @Accept json
+// @Produce json
+// @Param announce body model.Announce true "公告"
+// @Success 200 {object} response.Response{data=string}
+// @Router /api/announce/preview [post]
+func (h *AnnounceHandler) PreviewAnnounce(ctx *gin.Context) {
+	var announce model.Announce
+	if err := ctx.ShouldBindJSON(&announce); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	response.OkWithData(h.announceService.Preview(announce.Method, announce.Content), ctx)
+}
+
+// SendAnnounce 立即发送公告
+// @Summary 立即发送公告
+// @Description 立即向当前集群所有运行中的世界发送公告，返回发送成功的世界
+// @Tags announce
+// @Accept json
+// @Produce json
+// @Param announce body model.Announce true "公告"
+// @Success 200 {object} response.Response{data=[]string}
+// @Router /api/announce/send [post]
+func (h *AnnounceHandler) SendAnnounce(ctx *gin.Context) {
+	var announce model.Announce
+	if err := ctx.ShouldBindJSON(&announce); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	clusterName := context.GetClusterName(ctx)
+	levels, err := h.announceService.Send(clusterName, announce.Method, announce.Content)
+	if err != nil {
+		response.FailWithMessage("发送公告失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(levels, ctx)
+}
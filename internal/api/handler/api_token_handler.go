@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"dst-admin-go/internal/pkg/context"
+	"dst-admin-go/internal/pkg/response"
+	"dst-admin-go/internal/service/apiToken"
+	"dst-admin-go/internal/service/user"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ApiTokenHandler struct {
+	apiTokenService *apiToken.ApiTokenService
+}
+
+func NewApiTokenHandler(apiTokenService *apiToken.ApiTokenService) *ApiTokenHandler {
+	return &ApiTokenHandler{
+		apiTokenService: apiTokenService,
+	}
+}
+
+func (h *ApiTokenHandler) RegisterRoute(router *gin.RouterGroup) {
+	group := router.Group("/api/token")
+	{
+		group.GET("", h.GetTokenList)
+		group.POST("", h.CreateToken)
+		group.DELETE("", h.RevokeToken)
+	}
+}
+
+type createTokenRequest struct {
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	ExpireDays int      `json:"expireDays"`
+}
+
+// GetTokenList 获取令牌列表
+// @Summary 获取令牌列表
+// @Description 获取当前用户的 API 令牌，不返回令牌明文
+// @Tags token
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.ApiToken}
+// @Router /api/token [get]
+func (h *ApiTokenHandler) GetTokenList(ctx *gin.Context) {
+	current := context.GetUser(ctx)
+	response.OkWithData(h.apiTokenService.GetTokenList(current.ID), ctx)
+}
+
+// CreateToken 创建令牌
+// @Summary 创建令牌
+// @Description 创建 API 令牌，scopes 可选 read、command、game、backup、mod、*，expireDays 为 0 时永不过期，令牌明文只返回一次
+// @Tags token
+// @Accept json
+// @Produce json
+// @Param token body createTokenRequest true "令牌信息"
+// @Success 200 {object} response.Response{data=apiToken.TokenDetail}
+// @Router /api/token [post]
+func (h *ApiTokenHandler) CreateToken(ctx *gin.Context) {
+	var body createTokenRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	current := context.GetUser(ctx)
+	token, err := h.apiTokenService.CreateToken(current.ID, body.Name, body.Scopes, body.ExpireDays)
+	if err != nil {
+		response.FailWithMessage("创建令牌失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(token, ctx)
+}
+
+// RevokeToken 撤销令牌
+// @Summary 撤销令牌
+// @Description 撤销当前用户的令牌，admin 可以撤销任意用户的令牌
+// @Tags token
+// @Produce json
+// @Param id query int true "令牌ID"
+// @Success 200 {object} response.Response
+// @Router /api/token [delete]
+func (h *ApiTokenHandler) RevokeToken(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Query("id"), 10, 64)
+	if err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	current := context.GetUser(ctx)
+	userId := current.ID
+	if user.HasRole(current.Role, user.RoleAdmin) {
+		userId = 0
+	}
+	if err := h.apiTokenService.RevokeToken(userId, uint(id)); err != nil {
+		response.FailWithMessage("撤销令牌失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithMessage("撤销令牌成功", ctx)
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"dst-admin-go/internal/pkg/context"
+	"dst-admin-go/internal/pkg/response"
+	"dst-admin-go/internal/service/audit"
+	"dst-admin-go/internal/service/user"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	auditService *audit.AuditService
+}
+
+func NewAuditHandler(auditService *audit.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+func (h *AuditHandler) RegisterRoute(router *gin.RouterGroup) {
+	group := router.Group("/api/audit")
+	{
+		group.GET("", h.GetAuditPage)
+		group.GET("/export", h.ExportAudit)
+	}
+}
+
+// parseAuditFilter 解析查询条件，admin 传 all=true 时可以查询所有集群
+func parseAuditFilter(ctx *gin.Context) audit.Filter {
+	filter := audit.Filter{
+		ClusterName: context.GetClusterName(ctx),
+		LevelName:   ctx.Query("levelName"),
+		Operator:    ctx.Query("operator"),
+		Source:      ctx.Query("source"),
+		Actions:     audit.ParseActions(ctx.Query("action")),
+		Keyword:     ctx.Query("keyword"),
+	}
+	if current := context.GetUser(ctx); current != nil && user.HasRole(current.Role, user.RoleAdmin) && ctx.Query("all") == "true" {
+		filter.ClusterName = ""
+	}
+	if value := ctx.Query("success"); value != "" {
+		success, err := strconv.ParseBool(value)
+		if err == nil {
+			filter.Success = &success
+		}
+	}
+	if start, err := time.ParseInLocation("2006-01-02 15:04:05", ctx.Query("start"), time.Local); err == nil {
+		filter.Start = start
+	}
+	if end, err := time.ParseInLocation("2006-01-02 15:04:05", ctx.Query("end"), time.Local); err == nil {
+		filter.End = end
+	}
+	return filter
+}
+
+// GetAuditPage 分页查询审计日志
+// @Summary 分页查询审计日志
+// @Description 分页查询当前集群的审计日志，action 支持逗号分隔的名称或数字，start、end 格式为 2006-01-02 15:04:05，admin 传 all=true 可以查询所有集群
+// @Tags audit
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Param levelName query string false "世界名称"
+// @Param operator query string false "操作人"
+// @Param source query string false "来源 web、token、system"
+// @Param action query string false "操作类型"
+// @Param success query bool false "是否成功"
+// @Param keyword query string false "关键字"
+// @Param start query string false "开始时间"
+// @Param end query string false "结束时间"
+// @Param all query bool false "是否查询所有集群"
+// @Success 200 {object} response.Response{data=response.Page}
+// @Router /api/audit [get]
+func (h *AuditHandler) GetAuditPage(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(ctx.DefaultQuery("size", "10"))
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = 10
+	}
+	records, total := h.auditService.GetAuditPage(parseAuditFilter(ctx), page, size)
+	response.OkWithPage(records, total, int64(page), int64(size), ctx)
+}
+
+// ExportAudit 导出审计日志
+// @Summary 导出审计日志
+// @Description 按查询条件导出审计日志为 CSV 文件，查询条件同 /api/audit
+// @Tags audit
+// @Produce text/csv
+// @Success 200 {file} file
+// @Router /api/audit/export [get]
+func (h *AuditHandler) ExportAudit(ctx *gin.Context) {
+	filename := "audit_" + time.Now().Format("20060102150405") + ".csv"
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition", "attachment; filename="+filename)
+	if err := h.auditService.ExportCSV(ctx.Writer, parseAuditFilter(ctx)); err != nil {
+		log.Println("[Audit]导出审计日志失败", err)
+	}
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/context"
+	"dst-admin-go/internal/pkg/response"
+	"dst-admin-go/internal/service/autoCheck"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AutoCheckHandler struct {
+	autoCheckService *autoCheck.AutoCheckService
+}
+
+func NewAutoCheckHandler(autoCheckService *autoCheck.AutoCheckService) *AutoCheckHandler {
+	return &AutoCheckHandler{
+		autoCheckService: autoCheckService,
+	}
+}
+
+func (h *AutoCheckHandler) RegisterRoute(router *gin.RouterGroup) {
+	check := router.Group("/api/auto/check")
+	{
+		check.GET("", h.GetAutoCheckList)
+		check.POST("", h.CreateAutoCheck)
+		check.PUT("", h.UpdateAutoCheck)
+		check.DELETE("", h.DeleteAutoCheck)
+		check.GET("/log", h.GetLogRecordPage)
+	}
+}
+
+// GetAutoCheckList 获取自动检测列表
+// @Summary 获取自动检测列表
+// @Description 获取当前集群的自动检测列表
+// @Tags autoCheck
+// @Produce json
+// @Success 200 {object} response.Response{data=[]model.AutoCheck}
+// @Router /api/auto/check [get]
+func (h *AutoCheckHandler) GetAutoCheckList(ctx *gin.Context) {
+	clusterName := context.GetClusterName(ctx)
+	response.OkWithData(h.autoCheckService.GetAutoCheckList(clusterName), ctx)
+}
+
+// CreateAutoCheck 创建自动检测
+// @Summary 创建自动检测
+// @Description 创建自动检测，checkType 可选 LEVEL_DOWN、GAME_UPDATE、MOD_UPDATE，interval 单位为分钟
+// @Tags autoCheck
+// @Accept json
+// @Produce json
+// @Param autoCheck body model.AutoCheck true "自动检测"
+// @Success 200 {object} response.Response{data=model.AutoCheck}
+// @Router /api/auto/check [post]
+func (h *AutoCheckHandler) CreateAutoCheck(ctx *gin.Context) {
+	var autoCheck model.AutoCheck
+	if err := ctx.ShouldBindJSON(&autoCheck); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	autoCheck.ClusterName = context.GetClusterName(ctx)
+	if err := h.autoCheckService.CreateAutoCheck(&autoCheck); err != nil {
+		response.FailWithMessage("创建自动检测失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(autoCheck, ctx)
+}
+
+// UpdateAutoCheck 更新自动检测
+// @Summary 更新自动检测
+// @Description 更新自动检测并按新配置重新启动
+// @Tags autoCheck
+// @Accept json
+// @Produce json
+// @Param autoCheck body model.AutoCheck true "自动检测"
+// @Success 200 {object} response.Response{data=model.AutoCheck}
+// @Router /api/auto/check [put]
+func (h *AutoCheckHandler) UpdateAutoCheck(ctx *gin.Context) {
+	var autoCheck model.AutoCheck
+	if err := ctx.ShouldBindJSON(&autoCheck); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if err := h.autoCheckService.UpdateAutoCheck(context.GetClusterName(ctx), &autoCheck); err != nil {
+		response.FailWithMessage("更新自动检测失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(autoCheck, ctx)
+}
+
+// DeleteAutoCheck 删除自动检测
+// @Summary 删除自动检测
+// @Description 删除自动检测并停止检测
+// @Tags autoCheck
+// @Produce json
+// @Param id query int true "自动检测ID"
+// @Success 200 {object} response.Response
+// @Router /api/auto/check [delete]
+func (h *AutoCheckHandler) DeleteAutoCheck(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Query("id"), 10, 64)
+	if err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if err := h.autoCheckService.DeleteAutoCheck(context.GetClusterName(ctx), uint(id)); err != nil {
+		response.FailWithMessage("删除自动检测失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithMessage("删除自动检测成功", ctx)
+}
+
+// GetLogRecordPage 分页查询操作记录
+// @Summary 分页查询操作记录
+// @Description 分页查询当前集群的启动、停止、自动重启和更新记录
+// @Tags autoCheck
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Success 200 {object} response.Response{data=response.Page}
+// @Router /api/auto/check/log [get]
+func (h *AutoCheckHandler) GetLogRecordPage(ctx *gin.Context) {
+	clusterName := context.GetClusterName(ctx)
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(ctx.DefaultQuery("size", "10"))
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = 10
+	}
+	records, total := h.autoCheckService.GetLogRecordPage(clusterName, page, size)
+	response.OkWithPage(records, total, int64(page), int64(size), ctx)
+}
@@ -1,11 +1,12 @@
 package handler
 
 import (
-	"dst-admin-go/internal/database"
+	"dst-admin-go/internal/middleware"
 	"dst-admin-go/internal/model"
 	"dst-admin-go/internal/pkg/context"
 	"dst-admin-go/internal/pkg/response"
 	"dst-admin-go/internal/service/backup"
+	"dst-admin-go/internal/service/user"
 	"log"
 	"net/http"
 	"strings"
@@ -30,10 +31,27 @@ func (h *BackupHandler) RegisterRoute(router *gin.RouterGroup) {
 	router.PUT("/api/game/backup", h.RenameBackup)
 	router.GET("/api/game/backup/download", h.DownloadBackup)
 	router.POST("/api/game/backup/upload", h.UploadBackup)
-	router.GET("/backup/restore", h.RestoreBackup)
+	router.POST("/api/game/backup/restore", h.RestoreBackup)
+	router.GET("/api/game/backup/verify", h.VerifyBackup)
+	router.GET("/api/game/backup/tree", h.GetBackupTree)
+	router.POST("/api/game/backup/restore/selective", h.RestoreSelective)
+	router.GET("/api/game/backup/repository", h.GetRepositoryStats)
+	router.POST("/api/game/backup/repository/gc", h.GCRepository)
 	router.POST("/api/game/backup/snapshot/setting", h.SaveBackupSnapshotsSetting)
 	router.GET("/api/game/backup/snapshot/setting", h.GetBackupSnapshotsSetting)
 	router.GET("/api/game/backup/snapshot/list", h.BackupSnapshotsList)
+	router.GET("/api/game/backup/remote", h.ListRemoteBackups)
+	router.DELETE("/api/game/backup/remote", h.DeleteRemoteBackup)
+	router.POST("/api/game/backup/remote/upload", h.UploadRemoteBackup)
+	router.POST("/api/game/backup/remote/download", h.DownloadRemoteBackup)
+	router.POST("/api/game/backup/remote/restore", h.RestoreRemoteBackup)
+	// 远程存储的设置包含密钥，只有管理员可以查看和修改
+	remote := router.Group("/api/game/backup/remote", middleware.RequireRole(user.RoleAdmin))
+	{
+		remote.GET("/setting", h.GetRemoteSetting)
+		remote.POST("/setting", h.SaveRemoteSetting)
+		remote.POST("/test", h.TestRemote)
+	}
 }
 
 // DeleteBackup 删除备份
@@ -119,22 +137,97 @@ func (h *BackupHandler) RenameBackup(ctx *gin.Context) {
 
 // RestoreBackup 恢复备份
 // @Summary 恢复备份
-// @Description 从备份文件恢复游戏存档
+// @Description 校验备份并解压到临时目录，为当前存档自动创建 (pre-restore) 备份后再替换存档目录。
+// @Description 有世界正在运行时拒绝恢复，force=true 时先停止集群
 // @Tags backup
 // @Accept json
 // @Produce json
 // @Param backupName query string true "备份文件名"
-// @Success 200 {object} response.Response
-// @Router /backup/restore [get]
+// @Param force query bool false "世界正在运行时是否强制恢复"
+// @Success 200 {object} response.Response{data=backup.RestoreResult}
+// @Router /api/game/backup/restore [post]
 func (h *BackupHandler) RestoreBackup(ctx *gin.Context) {
 	backupName := ctx.Query("backupName")
+	clusterName := context.GetClusterName(ctx)
 
-	h.backupService.RestoreBackup(ctx, backupName)
+	result, err := h.backupService.RestoreBackup(clusterName, backupName, ctx.Query("force") == "true")
+	if err != nil {
+		ctx.JSON(http.StatusOK, response.Response{
+			Code: 500,
+			Msg:  "恢复备份失败: " + err.Error(),
+			Data: result,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Response{
+		Code: 200,
+		Msg:  "restore backup success",
+		Data: result,
+	})
+}
 
+// VerifyBackup 校验备份
+// @Summary 校验备份
+// @Description 检查备份压缩包是否完整，包含清单时校验每个文件的 SHA-256，并返回清单中的存档信息
+// @Tags backup
+// @Produce json
+// @Param backupName query string true "备份文件名"
+// @Success 200 {object} response.Response{data=backup.VerifyResult}
+// @Router /api/game/backup/verify [get]
+func (h *BackupHandler) VerifyBackup(ctx *gin.Context) {
+	result, err := h.backupService.VerifyBackup(context.GetClusterName(ctx), ctx.Query("backupName"))
+	if err != nil {
+		response.FailWithMessage("校验备份失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(result, ctx)
+}
+
+// GetBackupTree 获取备份的目录结构
+// @Summary 获取备份的目录结构
+// @Description 不解压备份，返回其中的文件树、世界列表，以及按 <世界>/save/session/<session id>/<KuId>_ 识别出的玩家存档，玩家名称来自连接记录
+// @Tags backup
+// @Produce json
+// @Param backupName query string true "备份文件名"
+// @Success 200 {object} response.Response{data=backup.BackupTree}
+// @Router /api/game/backup/tree [get]
+func (h *BackupHandler) GetBackupTree(ctx *gin.Context) {
+	tree, err := h.backupService.GetBackupTree(context.GetClusterName(ctx), ctx.Query("backupName"))
+	if err != nil {
+		response.FailWithMessage("读取备份失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(tree, ctx)
+}
+
+// RestoreSelective 部分恢复备份
+// @Summary 部分恢复备份
+// @Description 只恢复选择的世界目录、配置文件或玩家存档。玩家按 KuId 在备份中查找，恢复到世界当前的 session 目录。恢复前校验备份并为当前存档创建安全备份；涉及的世界正在运行时拒绝恢复，force 为 true 时只停止这些世界
+// @Tags backup
+// @Accept json
+// @Produce json
+// @Param request body backup.SelectiveRestore true "恢复内容"
+// @Success 200 {object} response.Response{data=backup.SelectiveRestoreResult}
+// @Router /api/game/backup/restore/selective [post]
+func (h *BackupHandler) RestoreSelective(ctx *gin.Context) {
+	var request backup.SelectiveRestore
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), ctx)
+		return
+	}
+	result, err := h.backupService.RestoreSelective(context.GetClusterName(ctx), request)
+	if err != nil {
+		ctx.JSON(http.StatusOK, response.Response{
+			Code: 500,
+			Msg:  "恢复备份失败: " + err.Error(),
+			Data: result,
+		})
+		return
+	}
 	ctx.JSON(http.StatusOK, response.Response{
 		Code: 200,
 		Msg:  "restore backup success",
-		Data: nil,
+		Data: result,
 	})
 }
 
@@ -159,21 +252,32 @@ func (h *BackupHandler) UploadBackup(ctx *gin.Context) {
 
 // CreateBackup 创建备份
 // @Summary 创建备份
-// @Description 创建新的游戏存档备份
+// @Description 创建新的游戏存档备份，repository 为 true 时保存到去重的备份仓库
 // @Tags backup
 // @Accept json
 // @Produce json
 // @Param backupName query string false "备份名称"
+// @Param repository query bool false "是否保存到备份仓库"
 // @Success 200 {object} response.Response
 // @Router /api/game/backup [post]
 func (h *BackupHandler) CreateBackup(ctx *gin.Context) {
 	var body struct {
 		BackupName string `json:"backupName"`
+		Repository bool   `json:"repository"`
 	}
 	if err := ctx.ShouldBind(&body); err != nil {
 		body.BackupName = ""
 	}
 	clusterName := context.GetClusterName(ctx)
+	if body.Repository {
+		snapshot, err := h.backupService.CreateRepositoryBackup(clusterName, body.BackupName)
+		if err != nil {
+			response.FailWithMessage("创建备份失败: "+err.Error(), ctx)
+			return
+		}
+		response.OkWithData(snapshot.FileName(), ctx)
+		return
+	}
 	h.backupService.CreateBackup(clusterName, body.BackupName)
 
 	ctx.JSON(http.StatusOK, response.Response{
@@ -185,7 +289,7 @@ func (h *BackupHandler) CreateBackup(ctx *gin.Context) {
 
 // SaveBackupSnapshotsSetting 保存快照设置
 // @Summary 保存快照设置
-// @Description 保存自动快照备份的设置
+// @Description 保存当前集群自动快照备份的设置，保存后立即生效，maxAge 为快照最长保留时间(小时)
 // @Tags backup
 // @Accept json
 // @Produce json
@@ -195,46 +299,37 @@ func (h *BackupHandler) CreateBackup(ctx *gin.Context) {
 func (h *BackupHandler) SaveBackupSnapshotsSetting(ctx *gin.Context) {
 
 	var backupSnapshot model.BackupSnapshot
-	var oldBackupSnapshot model.BackupSnapshot
 	err := ctx.ShouldBind(&backupSnapshot)
 	if err != nil {
 		log.Panicln("参数错误", err)
 	}
-	db := database.Db
-	db.First(&oldBackupSnapshot)
-
-	oldBackupSnapshot.Enable = backupSnapshot.Enable
-	oldBackupSnapshot.Interval = backupSnapshot.Interval
-	oldBackupSnapshot.MaxSnapshots = backupSnapshot.MaxSnapshots
-	oldBackupSnapshot.IsCSave = backupSnapshot.IsCSave
-
-	db.Save(&oldBackupSnapshot)
+	clusterName := context.GetClusterName(ctx)
+	snapshot := h.backupService.SaveSnapshotSetting(clusterName, backupSnapshot)
 
 	ctx.JSON(http.StatusOK, response.Response{
 		Code: 200,
 		Msg:  "success",
-		Data: oldBackupSnapshot,
+		Data: snapshot,
 	})
 }
 
 // GetBackupSnapshotsSetting 获取快照设置
 // @Summary 获取快照设置
-// @Description 获取自动快照备份的设置
+// @Description 获取当前集群自动快照备份的设置
 // @Tags backup
 // @Accept json
 // @Produce json
-// @Success 200 {object} response.Response
+// @Success 200 {object} response.Response{data=model.BackupSnapshot}
 // @Router /api/game/backup/snapshot/setting [get]
 func (h *BackupHandler) GetBackupSnapshotsSetting(ctx *gin.Context) {
 
-	var oldBackupSnapshot model.BackupSnapshot
-	db := database.Db
-	db.First(&oldBackupSnapshot)
+	clusterName := context.GetClusterName(ctx)
+	snapshot := h.backupService.GetSnapshotSetting(clusterName)
 
 	ctx.JSON(http.StatusOK, response.Response{
 		Code: 200,
 		Msg:  "success",
-		Data: oldBackupSnapshot,
+		Data: snapshot,
 	})
 }
 
@@ -264,3 +359,209 @@ func (h *BackupHandler) BackupSnapshotsList(ctx *gin.Context) {
 		Data: snapshotBackupList,
 	})
 }
+
+// GetRepositoryStats 获取备份仓库统计
+// @Summary 获取备份仓库统计
+// @Description 备份仓库位于备份目录旁边（<backup>.repo），快照按内容切块去重保存。返回快照数、块数、实际占用和原始大小
+// @Tags backup
+// @Produce json
+// @Success 200 {object} response.Response{data=backup.RepoStats}
+// @Router /api/game/backup/repository [get]
+func (h *BackupHandler) GetRepositoryStats(ctx *gin.Context) {
+	stats, err := h.backupService.RepositoryStats(context.GetClusterName(ctx))
+	if err != nil {
+		response.FailWithMessage("获取备份仓库失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(stats, ctx)
+}
+
+// GCRepository 清理备份仓库
+// @Summary 清理备份仓库
+// @Description 删除没有被任何快照引用的块，删除快照时会自动清理
+// @Tags backup
+// @Produce json
+// @Success 200 {object} response.Response{data=backup.GCResult}
+// @Router /api/game/backup/repository/gc [post]
+func (h *BackupHandler) GCRepository(ctx *gin.Context) {
+	result, err := h.backupService.GCRepository(context.GetClusterName(ctx))
+	if err != nil {
+		response.FailWithMessage("清理备份仓库失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(result, ctx)
+}
+
+// GetRemoteSetting 获取远程备份设置
+// @Summary 获取远程备份设置
+// @Description 获取当前集群的远程备份存储设置，密码和私钥以 ****** 代替
+// @Tags backup
+// @Produce json
+// @Success 200 {object} response.Response{data=model.BackupTarget}
+// @Router /api/game/backup/remote/setting [get]
+func (h *BackupHandler) GetRemoteSetting(ctx *gin.Context) {
+	response.OkWithData(h.backupService.GetRemoteSetting(context.GetClusterName(ctx)), ctx)
+}
+
+// SaveRemoteSetting 保存远程备份设置
+// @Summary 保存远程备份设置
+// @Description 类型支持 s3（AWS S3、MinIO 等兼容存储）、sftp、webdav。开启后手动备份和定时快照创建完成时自动上传，并按保留数量和时间清理远程的旧备份。密码和私钥提交 ****** 时保持不变。SFTP 必须设置服务器公钥指纹 hostKey，测试连接时会返回服务器的指纹，只有开启 insecureHostKey 时才不校验
+// @Tags backup
+// @Accept json
+// @Produce json
+// @Param setting body model.BackupTarget true "远程备份设置"
+// @Success 200 {object} response.Response{data=model.BackupTarget}
+// @Router /api/game/backup/remote/setting [post]
+func (h *BackupHandler) SaveRemoteSetting(ctx *gin.Context) {
+	var setting model.BackupTarget
+	if err := ctx.ShouldBindJSON(&setting); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), ctx)
+		return
+	}
+	saved, err := h.backupService.SaveRemoteSetting(context.GetClusterName(ctx), setting)
+	if err != nil {
+		response.FailWithMessage("保存远程备份设置失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(saved, ctx)
+}
+
+// TestRemote 测试远程备份设置
+// @Summary 测试远程备份设置
+// @Description 使用提交的设置连接远程存储并返回其中的备份，不保存设置
+// @Tags backup
+// @Accept json
+// @Produce json
+// @Param setting body model.BackupTarget true "远程备份设置"
+// @Success 200 {object} response.Response{data=[]remote.Object}
+// @Router /api/game/backup/remote/test [post]
+func (h *BackupHandler) TestRemote(ctx *gin.Context) {
+	var setting model.BackupTarget
+	if err := ctx.ShouldBindJSON(&setting); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), ctx)
+		return
+	}
+	objects, err := h.backupService.TestRemote(context.GetClusterName(ctx), setting)
+	if err != nil {
+		response.FailWithMessage("连接远程存储失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(objects, ctx)
+}
+
+// ListRemoteBackups 获取远程备份列表
+// @Summary 获取远程备份列表
+// @Description 获取远程存储中当前集群的备份，按修改时间倒序
+// @Tags backup
+// @Produce json
+// @Success 200 {object} response.Response{data=[]remote.Object}
+// @Router /api/game/backup/remote [get]
+func (h *BackupHandler) ListRemoteBackups(ctx *gin.Context) {
+	objects, err := h.backupService.ListRemoteBackups(context.GetClusterName(ctx))
+	if err != nil {
+		response.FailWithMessage("获取远程备份失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(objects, ctx)
+}
+
+// UploadRemoteBackup 上传备份到远程存储
+// @Summary 上传备份到远程存储
+// @Description 将本地备份上传到远程存储，仓库快照导出为 zip 后上传，失败时按设置的次数重试
+// @Tags backup
+// @Accept json
+// @Produce json
+// @Param fileName body string true "本地备份文件名"
+// @Success 200 {object} response.Response
+// @Router /api/game/backup/remote/upload [post]
+func (h *BackupHandler) UploadRemoteBackup(ctx *gin.Context) {
+	var body struct {
+		FileName string `json:"fileName"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil || body.FileName == "" {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if err := h.backupService.PushRemote(context.GetClusterName(ctx), body.FileName); err != nil {
+		response.FailWithMessage("上传远程备份失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithMessage("上传成功", ctx)
+}
+
+// DownloadRemoteBackup 下载远程备份
+// @Summary 下载远程备份
+// @Description 将远程备份下载到本地备份目录，文件名增加 (remote) 前缀，返回本地文件名
+// @Tags backup
+// @Accept json
+// @Produce json
+// @Param fileName body string true "远程备份文件名"
+// @Success 200 {object} response.Response{data=string}
+// @Router /api/game/backup/remote/download [post]
+func (h *BackupHandler) DownloadRemoteBackup(ctx *gin.Context) {
+	var body struct {
+		FileName string `json:"fileName"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil || body.FileName == "" {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	localName, err := h.backupService.DownloadRemoteBackup(context.GetClusterName(ctx), body.FileName)
+	if err != nil {
+		response.FailWithMessage("下载远程备份失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(localName, ctx)
+}
+
+// RestoreRemoteBackup 从远程备份恢复
+// @Summary 从远程备份恢复
+// @Description 下载远程备份到本地备份目录后按恢复备份的流程校验并恢复
+// @Tags backup
+// @Accept json
+// @Produce json
+// @Param request body object true "请求体" schema-example({"fileName": "remote.zip", "force": false})
+// @Success 200 {object} response.Response{data=backup.RestoreResult}
+// @Router /api/game/backup/remote/restore [post]
+func (h *BackupHandler) RestoreRemoteBackup(ctx *gin.Context) {
+	var body struct {
+		FileName string `json:"fileName"`
+		// Force 世界正在运行时先停止集群再恢复
+		Force bool `json:"force"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil || body.FileName == "" {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	clusterName := context.GetClusterName(ctx)
+	result, err := h.backupService.RestoreRemoteBackup(clusterName, body.FileName, body.Force)
+	if err != nil {
+		ctx.JSON(http.StatusOK, response.Response{
+			Code: 500,
+			Msg:  "恢复远程备份失败: " + err.Error(),
+			Data: result,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, response.Response{
+		Code: 200,
+		Msg:  "restore backup success",
+		Data: result,
+	})
+}
+
+// DeleteRemoteBackup 删除远程备份
+// @Summary 删除远程备份
+// @Description 删除远程存储中的备份
+// @Tags backup
+// @Produce json
+// @Param fileName query string true "远程备份文件名"
+// @Success 200 {object} response.Response
+// @Router /api/game/backup/remote [delete]
+func (h *BackupHandler) DeleteRemoteBackup(ctx *gin.Context) {
+	if err := h.backupService.DeleteRemoteBackup(context.GetClusterName(ctx), ctx.Query("fileName")); err != nil {
+		response.FailWithMessage("删除远程备份失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithMessage("删除成功", ctx)
+}
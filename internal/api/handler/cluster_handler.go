@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"archive/zip"
+	"dst-admin-go/internal/middleware"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/context"
+	"dst-admin-go/internal/pkg/response"
+	"dst-admin-go/internal/service/cluster"
+	"dst-admin-go/internal/service/user"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClusterHandler struct {
+	clusterService *cluster.ClusterService
+	userService    *user.UserService
+}
+
+func NewClusterHandler(clusterService *cluster.ClusterService, userService *user.UserService) *ClusterHandler {
+	return &ClusterHandler{
+		clusterService: clusterService,
+		userService:    userService,
+	}
+}
+
+func (h *ClusterHandler) RegisterRoute(router *gin.RouterGroup) {
+	router.GET("/api/clusters", h.GetClusterList)
+	clusters := router.Group("/api/clusters", middleware.RequireRole(user.RoleAdmin))
+	{
+		clusters.POST("", h.CreateCluster)
+		clusters.POST("/clone", h.CloneCluster)
+		clusters.PUT("/rename", h.RenameCluster)
+		clusters.DELETE("", h.DeleteCluster)
+		clusters.GET("/template", h.ExportTemplate)
+		clusters.POST("/template", h.ImportTemplate)
+	}
+}
+
+type cloneClusterRequest struct {
+	SourceName  string `json:"sourceName"`
+	ClusterName string `json:"clusterName"`
+	Description string `json:"description"`
+}
+
+type renameClusterRequest struct {
+	ClusterName string `json:"clusterName"`
+	NewName     string `json:"newName"`
+}
+
+// GetClusterList 获取集群列表
+// @Summary 获取集群列表
+// @Description 获取当前用户有权限的集群，切换集群时在请求头 Cluster 中传入集群名称
+// @Tags cluster
+// @Produce json
+// @Success 200 {object} response.Response{data=[]cluster.ClusterInfo}
+// @Router /api/clusters [get]
+func (h *ClusterHandler) GetClusterList(ctx *gin.Context) {
+	current := context.GetUser(ctx)
+	list := make([]cluster.ClusterInfo, 0)
+	for _, info := range h.clusterService.GetClusterList() {
+		if current == nil {
+			break
+		}
+		if _, ok := h.userService.ClusterRole(current, info.ClusterName); ok {
+			list = append(list, info)
+		}
+	}
+	response.OkWithData(list, ctx)
+}
+
+// CreateCluster 创建集群
+// @Summary 创建集群
+// @Description 创建集群，集群名称即存档目录名，目录已存在时直接使用，需要 admin 权限
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Param cluster body model.Cluster true "集群"
+// @Success 200 {object} response.Response{data=model.Cluster}
+// @Router /api/clusters [post]
+func (h *ClusterHandler) CreateCluster(ctx *gin.Context) {
+	var body model.Cluster
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if err := h.clusterService.CreateCluster(&body); err != nil {
+		response.FailWithMessage("创建集群失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(body, ctx)
+}
+
+// CloneCluster 复制集群
+// @Summary 复制集群
+// @Description 复制集群的配置和存档，新集群的端口与原集群相同，需要 admin 权限
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Param body body cloneClusterRequest true "复制参数"
+// @Success 200 {object} response.Response{data=model.Cluster}
+// @Router /api/clusters/clone [post]
+func (h *ClusterHandler) CloneCluster(ctx *gin.Context) {
+	var body cloneClusterRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if body.SourceName == "" {
+		body.SourceName = context.GetClusterName(ctx)
+	}
+	newCluster, err := h.clusterService.CloneCluster(body.SourceName, body.ClusterName, body.Description)
+	if err != nil {
+		response.FailWithMessage("复制集群失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(newCluster, ctx)
+}
+
+// RenameCluster 重命名集群
+// @Summary 重命名集群
+// @Description 重命名集群和存档目录，集群需要先停止，需要 admin 权限
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Param body body renameClusterRequest true "重命名参数"
+// @Success 200 {object} response.Response
+// @Router /api/clusters/rename [put]
+func (h *ClusterHandler) RenameCluster(ctx *gin.Context) {
+	var body renameClusterRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if body.ClusterName == "" {
+		body.ClusterName = context.GetClusterName(ctx)
+	}
+	if err := h.clusterService.RenameCluster(body.ClusterName, body.NewName); err != nil {
+		response.FailWithMessage("重命名集群失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithMessage("重命名成功", ctx)
+}
+
+// DeleteCluster 删除集群
+// @Summary 删除集群
+// @Description 删除集群及其定时任务、自动检测、公告和授权，集群需要先停止，需要 admin 权限
+// @Tags cluster
+// @Produce json
+// @Param clusterName query string true "集群名称"
+// @Param removeFiles query bool false "是否同时删除存档目录"
+// @Success 200 {object} response.Response
+// @Router /api/clusters [delete]
+func (h *ClusterHandler) DeleteCluster(ctx *gin.Context) {
+	clusterName := ctx.Query("clusterName")
+	if clusterName == "" {
+		response.FailWithMessage("集群名称不能为空", ctx)
+		return
+	}
+	if err := h.clusterService.DeleteCluster(clusterName, ctx.Query("removeFiles") == "true"); err != nil {
+		response.FailWithMessage("删除集群失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithMessage("删除成功", ctx)
+}
+
+// ExportTemplate 导出集群模板
+// @Summary 导出集群模板
+// @Description 将集群的 cluster.ini、level.json、管理员/白名单/黑名单以及各世界的 leveldataoverride.lua、modoverrides.lua、server.ini 打包下载，saves 为 true 时同时包含各世界的存档。不包含 cluster_token.txt，需要 admin 权限
+// @Tags cluster
+// @Produce application/zip
+// @Param clusterName query string false "集群名称，默认为当前集群"
+// @Param saves query bool false "是否包含世界存档"
+// @Success 200 {file} file
+// @Router /api/clusters/template [get]
+func (h *ClusterHandler) ExportTemplate(ctx *gin.Context) {
+	clusterName := ctx.Query("clusterName")
+	if clusterName == "" {
+		clusterName = context.GetClusterName(ctx)
+	}
+	fileName := clusterName + "_template_" + time.Now().Format("20060102150405") + ".zip"
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Disposition", "attachment; filename="+fileName)
+	if err := h.clusterService.ExportTemplate(clusterName, ctx.Query("saves") == "true", ctx.Writer); err != nil {
+		log.Println("[Cluster]导出集群模板失败", clusterName, err)
+		if !ctx.Writer.Written() {
+			ctx.Header("Content-Disposition", "")
+			response.FailWithMessage("导出集群模板失败: "+err.Error(), ctx)
+		}
+	}
+}
+
+// ImportTemplate 导入集群模板
+// @Summary 导入集群模板
+// @Description 从导出的模板创建新集群，与已有集群冲突的 master_port、server_port、authentication_port、master_server_port 会改为未使用的端口。steamcmd、备份目录等本机配置从 baseCluster 复制，默认为当前集群，需要 admin 权限
+// @Tags cluster
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "模板文件"
+// @Param clusterName formData string true "新集群名称"
+// @Param description formData string false "描述"
+// @Param baseCluster formData string false "复制本机配置的集群"
+// @Success 200 {object} response.Response{data=cluster.ImportTemplateResult}
+// @Router /api/clusters/template [post]
+func (h *ClusterHandler) ImportTemplate(ctx *gin.Context) {
+	var request cluster.ImportTemplateRequest
+	if err := ctx.ShouldBind(&request); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if request.BaseCluster == "" {
+		request.BaseCluster = context.GetClusterName(ctx)
+	}
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		response.FailWithMessage("请上传模板文件", ctx)
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		response.FailWithMessage("读取模板失败: "+err.Error(), ctx)
+		return
+	}
+	defer file.Close()
+	reader, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		response.FailWithMessage("模板不是有效的压缩包: "+err.Error(), ctx)
+		return
+	}
+	result, err := h.clusterService.ImportTemplate(reader, request)
+	if err != nil {
+		response.FailWithMessage("导入集群模板失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(result, ctx)
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/context"
+	"dst-admin-go/internal/pkg/response"
+	"dst-admin-go/internal/service/audit"
+	"dst-admin-go/internal/service/console"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConsoleHandler struct {
+	console      *console.Console
+	auditService *audit.AuditService
+}
+
+func NewConsoleHandler(console *console.Console, auditService *audit.AuditService) *ConsoleHandler {
+	return &ConsoleHandler{
+		console:      console,
+		auditService: auditService,
+	}
+}
+
+func (h *ConsoleHandler) RegisterRoute(router *gin.RouterGroup) {
+	router.POST("/api/game/8level/lua", h.ExecLua)
+}
+
+type luaRequest struct {
+	LevelName string `json:"levelName"`
+	Script    string `json:"script"`
+	// Timeout 等待结果的秒数，默认 5 秒，最长 60 秒
+	Timeout int `json:"timeout"`
+}
+
+// ExecLua 执行 Lua 并返回结果
+// @Summary 执行 Lua 并返回结果
+// @Description 在世界控制台执行 Lua 脚本并等待返回值，script 为函数体，通过 return 返回结果，返回值序列化为 JSON
+// @Tags game
+// @Accept json
+// @Produce json
+// @Param lua body luaRequest true "脚本"
+// @Success 200 {object} response.Response
+// @Router /api/game/8level/lua [post]
+func (h *ConsoleHandler) ExecLua(ctx *gin.Context) {
+	var body luaRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if body.LevelName == "" {
+		response.FailWithMessage("levelName 不能为空", ctx)
+		return
+	}
+	clusterName := context.GetClusterName(ctx)
+	data, err := h.console.Exec(ctx.Request.Context(), clusterName, body.LevelName, body.Script, time.Duration(body.Timeout)*time.Second)
+	h.auditService.Record(audit.OperatorFrom(ctx), model.LogRecord{
+		Action:      model.COMMAND,
+		ClusterName: clusterName,
+		LevelName:   body.LevelName,
+		Target:      body.Script,
+	}, err)
+	if err != nil {
+		response.FailWithMessage("执行失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(data, ctx)
+}
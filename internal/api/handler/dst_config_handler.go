@@ -12,14 +12,16 @@ import (
 )
 
 type DstConfigHandler struct {
-	dstConfig dstConfig.Config
-	archive   *archive.PathResolver
+	dstConfig  dstConfig.Config
+	archive    *archive.PathResolver
+	collectMap *collect.CollectMap
 }
 
-func NewDstConfigHandler(dstConfig dstConfig.Config, archive *archive.PathResolver) *DstConfigHandler {
+func NewDstConfigHandler(dstConfig dstConfig.Config, archive *archive.PathResolver, collectMap *collect.CollectMap) *DstConfigHandler {
 	return &DstConfigHandler{
-		dstConfig: dstConfig,
-		archive:   archive,
+		dstConfig:  dstConfig,
+		archive:    archive,
+		collectMap: collectMap,
 	}
 }
 
@@ -72,7 +74,8 @@ func (h *DstConfigHandler) SaveDstConfig(ctx *gin.Context) {
 		ctx.JSON(400, gin.H{"error": "Invalid request body"})
 		return
 	}
-	err := h.dstConfig.SaveDstConfig(context.GetClusterName(ctx), config)
+	clusterName := context.GetClusterName(ctx)
+	err := h.dstConfig.SaveDstConfig(clusterName, config)
 	if err != nil {
 		ctx.JSON(http.StatusOK, response.Response{
 			Code: 500,
@@ -81,8 +84,7 @@ func (h *DstConfigHandler) SaveDstConfig(ctx *gin.Context) {
 		})
 		return
 	}
-	clusterPath := h.archive.ClusterPath(config.Cluster)
-	collect.Collector.ReCollect(clusterPath, config.Cluster)
+	h.collectMap.ReloadCollect(clusterName, clusterName)
 	ctx.JSON(http.StatusOK, response.Response{
 		Code: 200,
 		Msg:  "DstConfig saved successfully",
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"dst-admin-go/internal/middleware"
 	"dst-admin-go/internal/pkg/context"
 	"dst-admin-go/internal/pkg/response"
 	"dst-admin-go/internal/pkg/utils/fileUtils"
@@ -31,7 +32,7 @@ func NewDstMapHandler(archiveResolver *archive.PathResolver, generator *dstMap.D
 }
 
 func (d *DstMapHandler) RegisterRoute(router *gin.RouterGroup) {
-	router.GET("/api/dst/map/gen", d.GenDstMap)
+	middleware.WriteGET(router, "/api/dst/map/gen", d.GenDstMap)
 	router.GET("/api/dst/map/image", d.GetDstMapImage)
 	router.GET("/api/dst/map/has/walrusHut/plains", d.HasWalrusHutPlains)
 	router.GET("/api/dst/map/session/file", d.GetSessionFile)
@@ -2,11 +2,14 @@ package handler
 
 import (
 	"dst-admin-go/internal/middleware"
+	"dst-admin-go/internal/model"
 	"dst-admin-go/internal/pkg/context"
 	"dst-admin-go/internal/pkg/response"
-	"dst-admin-go/internal/pkg/utils/shellUtils"
 	"dst-admin-go/internal/pkg/utils/systemUtils"
+	"dst-admin-go/internal/service/announce"
 	"dst-admin-go/internal/service/archive"
+	"dst-admin-go/internal/service/audit"
+	"dst-admin-go/internal/service/autoCheck"
 	"dst-admin-go/internal/service/game"
 	"dst-admin-go/internal/service/gameArchive"
 	"dst-admin-go/internal/service/level"
@@ -15,7 +18,6 @@ import (
 	"log"
 	"net/http"
 	"runtime"
-	"strings"
 	"sync"
 	"time"
 
@@ -28,23 +30,29 @@ type GameHandler struct {
 	gameArchive      *gameArchive.GameArchive
 	levelConfigUtils *levelConfig.LevelConfigUtils
 	archive          *archive.PathResolver
+	autoCheck        *autoCheck.AutoCheckService
+	announce         *announce.AnnounceService
+	auditService     *audit.AuditService
 }
 
-func NewGameHandler(process game.Process, levelService *level.LevelService, gameArchive *gameArchive.GameArchive, levelConfigUtils *levelConfig.LevelConfigUtils, archive *archive.PathResolver) *GameHandler {
+func NewGameHandler(process game.Process, levelService *level.LevelService, gameArchive *gameArchive.GameArchive, levelConfigUtils *levelConfig.LevelConfigUtils, archive *archive.PathResolver, autoCheck *autoCheck.AutoCheckService, announce *announce.AnnounceService, auditService *audit.AuditService) *GameHandler {
 	return &GameHandler{
 		process:          process,
 		level:            levelService,
 		gameArchive:      gameArchive,
 		levelConfigUtils: levelConfigUtils,
 		archive:          archive,
+		autoCheck:        autoCheck,
+		announce:         announce,
+		auditService:     auditService,
 	}
 }
 
 func (p *GameHandler) RegisterRoute(router *gin.RouterGroup) {
-	router.GET("/api/game/8level/start", p.Start, middleware.StartBeforeMiddleware(p.archive, p.levelConfigUtils))
-	router.GET("/api/game/8level/stop", p.Stop)
-	router.GET("/api/game/8level/start/all", p.StartAll, middleware.StartBeforeMiddleware(p.archive, p.levelConfigUtils))
-	router.GET("/api/game/8level/stop/all", p.StopAll)
+	middleware.WriteGET(router, "/api/game/8level/start", p.Start, middleware.StartBeforeMiddleware(p.archive, p.levelConfigUtils))
+	middleware.WriteGET(router, "/api/game/8level/stop", p.Stop)
+	middleware.WriteGET(router, "/api/game/8level/start/all", p.StartAll, middleware.StartBeforeMiddleware(p.archive, p.levelConfigUtils))
+	middleware.WriteGET(router, "/api/game/8level/stop/all", p.StopAll)
 	router.POST("/api/game/8level/command", p.Command)
 	router.GET("/api/game/8level/status", p.Status)
 	router.GET("/api/game/archive", p.GameArchive)
@@ -69,6 +77,10 @@ func (p *GameHandler) Stop(ctx *gin.Context) {
 		return
 	}
 	err := p.process.Stop(clusterName, levelName)
+	p.autoCheck.RecordLog(audit.OperatorFrom(ctx), clusterName, levelName, model.STOP, "手动停止", err)
+	if !p.clusterRunning(clusterName) {
+		p.announce.StopCluster(clusterName)
+	}
 	if err != nil {
 		ctx.JSON(http.StatusOK, response.Response{Code: 500, Msg: "failed to stop game server: " + err.Error()})
 	} else {
@@ -93,6 +105,8 @@ func (p *GameHandler) Start(ctx *gin.Context) {
 		return
 	}
 	err := p.process.Start(clusterName, levelName)
+	p.autoCheck.RecordLog(audit.OperatorFrom(ctx), clusterName, levelName, model.RUN, "手动启动", err)
+	p.announce.StartCluster(clusterName)
 	if err != nil {
 		ctx.JSON(http.StatusOK, response.Response{Code: 500, Msg: "failed to start game server: " + err.Error()})
 	} else {
@@ -102,37 +116,53 @@ func (p *GameHandler) Start(ctx *gin.Context) {
 
 // StartAll 启动所有世界 swagger 注释
 // @Summary 启动所有世界
-// @Description 启动所有世界
+// @Description 先启动主世界，等待主世界就绪后再启动从世界，返回每个世界的启动结果
 // @Tags game
 // @Accept json
 // @Produce json
-// @Success 200 {object} response.Response
+// @Success 200 {object} response.Response{data=[]game.LevelResult}
 // @Router /api/game/start/all [get]
 func (p *GameHandler) StartAll(ctx *gin.Context) {
 	clusterName := context.GetClusterName(ctx)
-	err := p.process.StartAll(clusterName)
+	var results []game.LevelResult
+	var err error
+	if runner, ok := p.process.(game.ClusterRunner); ok {
+		results, err = runner.StartCluster(clusterName)
+	} else {
+		err = p.process.StartAll(clusterName)
+	}
+	p.autoCheck.RecordClusterLog(audit.OperatorFrom(ctx), clusterName, model.RUN, "手动启动", err)
+	p.announce.StartCluster(clusterName)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Response{Code: 500, Msg: "failed to start all game servers: " + err.Error()})
+		ctx.JSON(http.StatusOK, response.Response{Code: 500, Msg: "failed to start all game servers: " + err.Error(), Data: results})
 	} else {
-		ctx.JSON(http.StatusOK, response.Response{Code: 200, Msg: "success"})
+		ctx.JSON(http.StatusOK, response.Response{Code: 200, Msg: "success", Data: results})
 	}
 }
 
 // StopAll 停止所有世界 swagger 注释
 // @Summary 停止所有世界
-// @Description 停止所有世界
+// @Description 先停止从世界，再停止主世界，返回每个世界的停止结果
 // @Tags game
 // @Accept json
 // @Produce json
-// @Success 200 {object} response.Response
+// @Success 200 {object} response.Response{data=[]game.LevelResult}
 // @Router /api/game/stop/all [get]
 func (p *GameHandler) StopAll(ctx *gin.Context) {
 	clusterName := context.GetClusterName(ctx)
-	err := p.process.StopAll(clusterName)
+	var results []game.LevelResult
+	var err error
+	if runner, ok := p.process.(game.ClusterRunner); ok {
+		results, err = runner.StopCluster(clusterName)
+	} else {
+		err = p.process.StopAll(clusterName)
+	}
+	p.autoCheck.RecordClusterLog(audit.OperatorFrom(ctx), clusterName, model.STOP, "手动停止", err)
+	p.announce.StopCluster(clusterName)
 	if err != nil {
-		ctx.JSON(http.StatusOK, response.Response{Code: 500, Msg: "failed to stop all game servers: " + err.Error()})
+		ctx.JSON(http.StatusOK, response.Response{Code: 500, Msg: "failed to stop all game servers: " + err.Error(), Data: results})
 	} else {
-		ctx.JSON(http.StatusOK, response.Response{Code: 200, Msg: "success"})
+		ctx.JSON(http.StatusOK, response.Response{Code: 200, Msg: "success", Data: results})
 	}
 }
 
@@ -166,6 +196,12 @@ func (p *GameHandler) Command(ctx *gin.Context) {
 		return
 	}
 	err = p.process.Command(clusterName, command.LevelName, command.Command)
+	p.auditService.Record(audit.OperatorFrom(ctx), model.LogRecord{
+		Action:      model.COMMAND,
+		ClusterName: clusterName,
+		LevelName:   command.LevelName,
+		Target:      command.Command,
+	}, err)
 	if err != nil {
 		ctx.JSON(http.StatusOK, response.Response{Code: 500, Msg: "failed to run command: " + err.Error()})
 		return
@@ -200,44 +236,22 @@ func (p *GameHandler) Status(ctx *gin.Context) {
 	length := len(levelList)
 	result := make([]LevelStatus, length)
 
-	if runtime.GOOS == "windows" {
-		var wg sync.WaitGroup
-		wg.Add(length)
-		for i := range levelList {
-			go func(index int) {
-				defer func() {
-					wg.Done()
-					if r := recover(); r != nil {
-
-					}
-				}()
-				levelItem := levelList[index]
-				ps := p.process.PsAuxSpecified(clusterName, levelItem.Uuid)
-				status, _ := p.process.Status(clusterName, levelItem.Uuid)
-				result[index] = LevelStatus{
-					Ps:                ps,
-					Status:            status,
-					RunVersion:        levelItem.RunVersion,
-					LevelName:         levelItem.LevelName,
-					IsMaster:          levelItem.IsMaster,
-					Uuid:              levelItem.Uuid,
-					Leveldataoverride: levelItem.Leveldataoverride,
-					Modoverrides:      levelItem.Modoverrides,
-					ServerIni:         levelItem.ServerIni,
+	var wg sync.WaitGroup
+	wg.Add(length)
+	for i := range levelList {
+		go func(index int) {
+			defer func() {
+				wg.Done()
+				if r := recover(); r != nil {
+
 				}
-			}(i)
-		}
-		wg.Wait()
-		ctx.JSON(http.StatusOK, response.Response{
-			Code: 200,
-			Msg:  "success",
-			Data: result,
-		})
-	} else {
-		for i := range levelList {
-			levelItem := levelList[i]
-			result[i] = LevelStatus{
-				Status:            false,
+			}()
+			levelItem := levelList[index]
+			ps := p.process.PsAuxSpecified(clusterName, levelItem.Uuid)
+			status, _ := p.process.Status(clusterName, levelItem.Uuid)
+			result[index] = LevelStatus{
+				Ps:                ps,
+				Status:            status,
 				RunVersion:        levelItem.RunVersion,
 				LevelName:         levelItem.LevelName,
 				IsMaster:          levelItem.IsMaster,
@@ -246,39 +260,14 @@ func (p *GameHandler) Status(ctx *gin.Context) {
 				Modoverrides:      levelItem.Modoverrides,
 				ServerIni:         levelItem.ServerIni,
 			}
-		}
-
-		cmd := "ps -aux | grep -v grep | grep -v tail | grep -v SCREEN | grep " + clusterName + " |awk '{print $3, $4, $5, $6,$16}'"
-		info, err := shellUtils.Shell(cmd)
-		if err != nil {
-			log.Println(cmd + " error: " + err.Error())
-		} else {
-			lines := strings.Split(info, "\n")
-			for lineIndex := range lines {
-				dstPsVo := game.DstPsAux{}
-				arr := strings.Split(lines[lineIndex], " ")
-				if len(arr) > 4 {
-					dstPsVo.CpuUage = strings.Replace(arr[0], "\n", "", -1)
-					dstPsVo.MemUage = strings.Replace(arr[1], "\n", "", -1)
-					dstPsVo.VSZ = strings.Replace(arr[2], "\n", "", -1)
-					dstPsVo.RSS = strings.Replace(arr[3], "\n", "", -1)
-					for i := range result {
-						levelName := result[i].Uuid
-						if strings.Contains(arr[4], levelName) {
-							result[i].Ps = dstPsVo
-							result[i].Status = true
-						}
-					}
-				}
-
-			}
-		}
-		ctx.JSON(http.StatusOK, response.Response{
-			Code: 200,
-			Msg:  "success",
-			Data: result,
-		})
+		}(i)
 	}
+	wg.Wait()
+	ctx.JSON(http.StatusOK, response.Response{
+		Code: 200,
+		Msg:  "success",
+		Data: result,
+	})
 }
 
 // GameArchive 获取游戏存档列表
@@ -428,3 +417,17 @@ func (p *GameHandler) GetSystemInfo(clusterName string) *SystemInfo {
 	wg.Wait()
 	return &dashboardVO
 }
+
+// clusterRunning 集群是否还有运行中的世界
+func (p *GameHandler) clusterRunning(clusterName string) bool {
+	config, err := p.levelConfigUtils.GetLevelConfig(clusterName)
+	if err != nil {
+		return false
+	}
+	for _, item := range config.LevelList {
+		if running, _ := p.process.Status(clusterName, item.File); running {
+			return true
+		}
+	}
+	return false
+}
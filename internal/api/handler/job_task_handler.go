@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/context"
+	"dst-admin-go/internal/pkg/response"
+	"dst-admin-go/internal/service/schedule"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobTaskHandler struct {
+	schedule *schedule.Schedule
+}
+
+func NewJobTaskHandler(schedule *schedule.Schedule) *JobTaskHandler {
+	return &JobTaskHandler{
+		schedule: schedule,
+	}
+}
+
+func (h *JobTaskHandler) RegisterRoute(router *gin.RouterGroup) {
+	task := router.Group("/api/task")
+	{
+		task.GET("", h.GetJobTaskList)
+		task.POST("", h.CreateJobTask)
+		task.PUT("", h.UpdateJobTask)
+		task.DELETE("", h.DeleteJobTask)
+		task.GET("/record", h.GetJobTaskRecordPage)
+	}
+}
+
+// GetJobTaskList 获取定时任务列表
+// @Summary 获取定时任务列表
+// @Description 获取当前集群的定时任务列表，包含下一次执行时间
+// @Tags task
+// @Produce json
+// @Success 200 {object} response.Response{data=[]schedule.JobTaskInfo}
+// @Router /api/task [get]
+func (h *JobTaskHandler) GetJobTaskList(ctx *gin.Context) {
+	clusterName := context.GetClusterName(ctx)
+	response.OkWithData(h.schedule.GetJobTaskList(clusterName), ctx)
+}
+
+// CreateJobTask 创建定时任务
+// @Summary 创建定时任务
+// @Description 创建定时任务，category 可选 start、stop、restart、update、backup、regenerate、command
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param task body model.JobTask true "定时任务"
+// @Success 200 {object} response.Response{data=model.JobTask}
+// @Router /api/task [post]
+func (h *JobTaskHandler) CreateJobTask(ctx *gin.Context) {
+	var task model.JobTask
+	if err := ctx.ShouldBindJSON(&task); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	task.ClusterName = context.GetClusterName(ctx)
+	if err := h.schedule.CreateJobTask(&task); err != nil {
+		response.FailWithMessage("创建定时任务失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(task, ctx)
+}
+
+// UpdateJobTask 更新定时任务
+// @Summary 更新定时任务
+// @Description 更新定时任务并重新调度
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param task body model.JobTask true "定时任务"
+// @Success 200 {object} response.Response{data=model.JobTask}
+// @Router /api/task [put]
+func (h *JobTaskHandler) UpdateJobTask(ctx *gin.Context) {
+	var task model.JobTask
+	if err := ctx.ShouldBindJSON(&task); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if err := h.schedule.UpdateJobTask(context.GetClusterName(ctx), &task); err != nil {
+		response.FailWithMessage("更新定时任务失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(task, ctx)
+}
+
+// DeleteJobTask 删除定时任务
+// @Summary 删除定时任务
+// @Description 删除定时任务并移出调度
+// @Tags task
+// @Produce json
+// @Param id query int true "任务ID"
+// @Success 200 {object} response.Response
+// @Router /api/task [delete]
+func (h *JobTaskHandler) DeleteJobTask(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Query("id"), 10, 64)
+	if err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if err := h.schedule.DeleteJobTask(context.GetClusterName(ctx), uint(id)); err != nil {
+		response.FailWithMessage("删除定时任务失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithMessage("删除定时任务成功", ctx)
+}
+
+// GetJobTaskRecordPage 分页查询定时任务执行记录
+// @Summary 分页查询定时任务执行记录
+// @Description 分页查询当前集群的定时任务执行记录
+// @Tags task
+// @Produce json
+// @Param taskId query int false "任务ID"
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Success 200 {object} response.Response{data=response.Page}
+// @Router /api/task/record [get]
+func (h *JobTaskHandler) GetJobTaskRecordPage(ctx *gin.Context) {
+	clusterName := context.GetClusterName(ctx)
+	taskId, _ := strconv.ParseUint(ctx.Query("taskId"), 10, 64)
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(ctx.DefaultQuery("size", "10"))
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = 10
+	}
+	records, total := h.schedule.GetJobTaskRecordPage(clusterName, uint(taskId), page, size)
+	response.OkWithPage(records, total, int64(page), int64(size), ctx)
+}
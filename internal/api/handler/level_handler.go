@@ -5,6 +5,7 @@ import (
 	"dst-admin-go/internal/pkg/response"
 	"dst-admin-go/internal/service/level"
 	"dst-admin-go/internal/service/levelConfig"
+	"dst-admin-go/internal/service/mod"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -12,11 +13,13 @@ import (
 
 type LevelHandler struct {
 	levelService *level.LevelService
+	modService   *mod.ModService
 }
 
-func NewLevelHandler(levelService *level.LevelService) *LevelHandler {
+func NewLevelHandler(levelService *level.LevelService, modService *mod.ModService) *LevelHandler {
 	return &LevelHandler{
 		levelService: levelService,
+		modService:   modService,
 	}
 }
 
@@ -159,12 +162,12 @@ func (h *LevelHandler) DeleteLevel(ctx *gin.Context) {
 
 // UpdateLevels 批量更新世界
 // @Summary 批量更新世界
-// @Description 批量更新多个世界的配置信息
+// @Description 批量更新多个世界的配置信息，返回保存前对所有世界模组依赖和冲突的检查结果
 // @Tags level
 // @Accept json
 // @Produce json
 // @Param levels body []levelConfig.LevelInfo true "世界配置信息列表"
-// @Success 200 {object} response.Response
+// @Success 200 {object} response.Response{data=mod.ModDependencyReport}
 // @Router /api/cluster/level [put]
 func (h *LevelHandler) UpdateLevels(ctx *gin.Context) {
 	clusterName := context.GetClusterName(ctx)
@@ -180,6 +183,22 @@ func (h *LevelHandler) UpdateLevels(ctx *gin.Context) {
 		return
 	}
 
+	// 没有提交的世界按当前的配置一起检查
+	levels := payload.Levels
+	for _, current := range h.levelService.GetLevelList(clusterName) {
+		submitted := false
+		for i := range payload.Levels {
+			if payload.Levels[i].Uuid == current.Uuid {
+				submitted = true
+				break
+			}
+		}
+		if !submitted {
+			levels = append(levels, current)
+		}
+	}
+	report := h.modService.CheckModDependencies(levels)
+
 	err := h.levelService.UpdateLevels(clusterName, payload.Levels)
 	if err != nil {
 		ctx.JSON(http.StatusOK, response.Response{
@@ -193,6 +212,6 @@ func (h *LevelHandler) UpdateLevels(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, response.Response{
 		Code: 200,
 		Msg:  "update levels success",
-		Data: nil,
+		Data: report,
 	})
 }
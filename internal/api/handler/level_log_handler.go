@@ -8,6 +8,7 @@ import (
 	"dst-admin-go/internal/pkg/response"
 	"dst-admin-go/internal/pkg/utils/fileUtils"
 	"dst-admin-go/internal/service/archive"
+	"dst-admin-go/internal/service/game"
 	"fmt"
 	"io"
 	"net/http"
@@ -21,13 +22,27 @@ import (
 
 type LevelLogHandler struct {
 	archive *archive.PathResolver
+	process game.Process
 }
 
-func NewLevelLogHandler(archive *archive.PathResolver) *LevelLogHandler {
+func NewLevelLogHandler(archive *archive.PathResolver, process game.Process) *LevelLogHandler {
 	return &LevelLogHandler{
 		archive: archive,
+		process: process,
 	}
 }
+
+// logPath source 为 console 时返回世界控制台输出的文件，否则返回 server_log.txt
+func (h *LevelLogHandler) logPath(clusterName, levelName, source string) string {
+	if source == "console" {
+		if consoleLogger, ok := h.process.(game.ConsoleLogger); ok {
+			if path := consoleLogger.ConsoleLogPath(clusterName, levelName); path != "" {
+				return path
+			}
+		}
+	}
+	return h.archive.ServerLogPath(clusterName, levelName)
+}
 func (h *LevelLogHandler) RegisterRoute(router *gin.RouterGroup) {
 	router.GET("/api/game/log/stream", h.Stream)
 	router.GET("/api/game/level/server/log", h.GetServerLog)
@@ -42,6 +57,7 @@ func (h *LevelLogHandler) RegisterRoute(router *gin.RouterGroup) {
 // @Produce text/event-stream
 // @Param clusterName query string false "集群名称"
 // @Param levelName query string true "世界名称"
+// @Param source query string false "日志来源，server（默认）为 server_log.txt，console 为世界进程的控制台输出"
 // @Success 200 {string} string "SSE 格式的日志流"
 // @Router /api/game/log/stream [get]
 func (h *LevelLogHandler) Stream(c *gin.Context) {
@@ -68,7 +84,7 @@ func (h *LevelLogHandler) Stream(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// 1️⃣ snapshot
-	serverLogPath := h.archive.ServerLogPath(clusterName, levelName)
+	serverLogPath := h.logPath(clusterName, levelName, c.Query("source"))
 	lines, err := reader.Snapshot(serverLogPath, 100)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"dst-admin-go/internal/pkg/context"
+	"dst-admin-go/internal/pkg/response"
+	"dst-admin-go/internal/service/lifecycle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LifecycleHandler struct {
+	lifecycleService *lifecycle.LifecycleService
+}
+
+func NewLifecycleHandler(lifecycleService *lifecycle.LifecycleService) *LifecycleHandler {
+	return &LifecycleHandler{
+		lifecycleService: lifecycleService,
+	}
+}
+
+func (h *LifecycleHandler) RegisterRoute(router *gin.RouterGroup) {
+	router.GET("/api/game/8level/state", h.GetState)
+	router.GET("/api/game/8level/state/stream", h.StreamState)
+}
+
+// GetState 获取世界状态
+// @Summary 获取世界状态
+// @Description 获取集群所有世界的生命周期状态：stopped、starting、generating、running、saving、stopping、crashed。
+// @Description 指定 levelName 和 wait 时会等待该世界进入指定状态（多个状态用逗号分隔），最多等待 timeout 秒
+// @Tags game
+// @Produce json
+// @Param clusterName query string false "集群名称"
+// @Param levelName query string false "世界名称，为空时返回所有世界"
+// @Param wait query string false "等待的状态，需要同时指定 levelName"
+// @Param timeout query int false "等待超时时间（秒），默认 60"
+// @Success 200 {object} response.Response{data=[]lifecycle.LevelState}
+// @Router /api/game/8level/state [get]
+func (h *LifecycleHandler) GetState(ctx *gin.Context) {
+	clusterName := context.GetClusterName(ctx)
+	levelName := ctx.Query("levelName")
+	if levelName == "" {
+		response.OkWithData(h.lifecycleService.GetClusterStates(clusterName), ctx)
+		return
+	}
+
+	wait := ctx.Query("wait")
+	if wait == "" {
+		response.OkWithData([]lifecycle.LevelState{h.lifecycleService.GetState(clusterName, levelName)}, ctx)
+		return
+	}
+	timeout, err := strconv.Atoi(ctx.DefaultQuery("timeout", "60"))
+	if err != nil || timeout <= 0 {
+		response.FailWithMessage("timeout 必须是正整数", ctx)
+		return
+	}
+	var states []lifecycle.State
+	for _, state := range strings.Split(wait, ",") {
+		states = append(states, lifecycle.State(strings.TrimSpace(state)))
+	}
+	state, err := h.lifecycleService.WaitFor(ctx.Request.Context(), clusterName, levelName, time.Duration(timeout)*time.Second, states...)
+	if err != nil {
+		ctx.JSON(http.StatusOK, response.Response{
+			Code: 500,
+			Msg:  "等待世界状态失败: " + err.Error(),
+			Data: []lifecycle.LevelState{state},
+		})
+		return
+	}
+	response.OkWithData([]lifecycle.LevelState{state}, ctx)
+}
+
+// StreamState 世界状态事件流
+// @Summary 世界状态事件流
+// @Description 先推送集群所有世界的当前状态（state 事件），之后每次状态变化推送一个 event 事件 (SSE)
+// @Tags game
+// @Accept text/event-stream
+// @Produce text/event-stream
+// @Param clusterName query string false "集群名称"
+// @Param levelName query string false "世界名称，为空时推送所有世界"
+// @Success 200 {string} string "SSE 格式的状态事件"
+// @Router /api/game/8level/state/stream [get]
+func (h *LifecycleHandler) StreamState(c *gin.Context) {
+	clusterName := context.GetClusterName(c)
+	levelName := c.Query("levelName")
+
+	w := c.Writer
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		c.JSON(500, gin.H{"error": "streaming unsupported"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx
+
+	// 先订阅再发送快照，避免丢失两者之间的事件
+	events, cancel := h.lifecycleService.Subscribe()
+	defer cancel()
+
+	for _, state := range h.lifecycleService.GetClusterStates(clusterName) {
+		if levelName != "" && state.LevelName != levelName {
+			continue
+		}
+		data, _ := json.Marshal(state)
+		writeSSE(w, "state", string(data))
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.ClusterName != clusterName || (levelName != "" && event.LevelName != levelName) {
+				continue
+			}
+			data, _ := json.Marshal(event)
+			writeSSE(w, "event", string(data))
+			flusher.Flush()
+		case <-heartbeat.C:
+			writeSSE(w, "ping", "")
+			flusher.Flush()
+		}
+	}
+}
@@ -13,10 +13,6 @@ import (
 	"gorm.io/gorm"
 )
 
-const (
-	PasswordPath = "./password.txt"
-)
-
 type LoginHandler struct {
 	loginService *login.LoginService
 }
@@ -49,7 +45,7 @@ func (h *LoginHandler) GetUserInfo(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, response.Response{
 		Code: 200,
 		Msg:  "Init user success",
-		Data: h.loginService.GetUserInfo(),
+		Data: h.loginService.GetUserInfo(ctx),
 	})
 }
 
@@ -116,7 +112,7 @@ func (h *LoginHandler) ChangePassword(ctx *gin.Context) {
 		})
 		return
 	}
-	response := h.loginService.ChangePassword(body.NewPassword)
+	response := h.loginService.ChangePassword(ctx, body.NewPassword)
 
 	ctx.JSON(http.StatusOK, response)
 }
@@ -131,12 +127,7 @@ func (h *LoginHandler) ChangePassword(ctx *gin.Context) {
 // @Success 200 {object} response.Response
 // @Router /api/user/update [post]
 func (h *LoginHandler) UpdateUserInfo(ctx *gin.Context) {
-	var body struct {
-		Username    string `json:"username"`
-		DisplayName string `json:"displayName"`
-		PhotoURL    string `json:"photoURL"`
-		Password    string `json:"password"`
-	}
+	var body login.UserInfo
 	if err := ctx.BindJSON(&body); err != nil {
 		ctx.JSON(http.StatusBadRequest, response.Response{
 			Code: 400,
@@ -145,14 +136,9 @@ func (h *LoginHandler) UpdateUserInfo(ctx *gin.Context) {
 		})
 		return
 	}
-	err := fileUtils.WriterLnFile(PasswordPath, []string{
-		"username = " + body.Username,
-		"password = " + body.Password,
-		"displayName=" + body.DisplayName,
-		"photoURL=" + body.PhotoURL,
-	})
+	err := h.loginService.ChangeUser(ctx, body)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, response.Response{
+		ctx.JSON(http.StatusOK, response.Response{
 			Code: 500,
 			Msg:  "修改用户信息失败: " + err.Error(),
 			Data: nil,
@@ -180,7 +166,7 @@ func (h *LoginHandler) InitFirst(ctx *gin.Context) {
 	db := database.Db
 	kv := model.KV{}
 	db.Where("key = 'FIRST_INIT'").First(&kv)
-	if kv.Value == "TRUE" || fileUtils.Exists("./first") {
+	if kv.Value == "TRUE" || fileUtils.Exists("./first") || h.loginService.HasUser() {
 		log.Panicln("非法请求")
 	}
 	var payload struct {
@@ -193,14 +179,16 @@ func (h *LoginHandler) InitFirst(ctx *gin.Context) {
 			Msg:  "Invalid request body: " + err.Error(),
 			Data: nil,
 		})
+		return
 	}
 	// 事务
 	// 记录已经初始化
 	// 保存用户信息
 	err = db.Transaction(func(tx *gorm.DB) error {
-		tx.Create(&model.KV{Key: "FIRST_INIT", Value: "TRUE"})
-		h.loginService.InitUserInfo(payload.UserInfo)
-		return nil
+		if err := tx.Create(&model.KV{Key: "FIRST_INIT", Value: "TRUE"}).Error; err != nil {
+			return err
+		}
+		return h.loginService.InitUserInfo(tx, payload.UserInfo)
 	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, response.Response{
@@ -208,6 +196,7 @@ func (h *LoginHandler) InitFirst(ctx *gin.Context) {
 			Msg:  "初始化失败: " + err.Error(),
 			Data: nil,
 		})
+		return
 	}
 	ctx.JSON(http.StatusOK, response.Response{
 		Code: 200,
@@ -230,7 +219,7 @@ func (h *LoginHandler) CheckIsFirst(ctx *gin.Context) {
 	db := database.Db
 	kv := model.KV{}
 	db.Where("key = 'FIRST_INIT'").First(&kv)
-	if kv.Value == "TRUE" {
+	if kv.Value == "TRUE" || h.loginService.HasUser() {
 		exist = true
 	} else {
 		exist = fileUtils.Exists("./first")
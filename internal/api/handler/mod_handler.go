@@ -1,14 +1,21 @@
 package handler
 
 import (
+	"dst-admin-go/internal/middleware"
 	"dst-admin-go/internal/model"
 	"dst-admin-go/internal/pkg/context"
 	"dst-admin-go/internal/pkg/response"
 	"dst-admin-go/internal/service/dstConfig"
+	"dst-admin-go/internal/service/levelConfig"
 	"dst-admin-go/internal/service/mod"
+	"dst-admin-go/internal/service/user"
 	"encoding/json"
+	"errors"
 	"log"
+	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -38,8 +45,27 @@ func (h *ModHandler) RegisterRoute(router *gin.RouterGroup) {
 		modGroup.POST("/modinfo", h.SaveModInfoFile)
 		modGroup.POST("/modinfo/file", h.AddModInfoFile)
 		modGroup.PUT("/modinfo", h.UpdateAllModInfos)
+		modGroup.PUT("/pin", h.SetModPinned)
 		modGroup.GET("/ugc/acf", h.GetUgcModAcf)
 		modGroup.DELETE("/ugc", h.DeleteUgcModFile)
+		modGroup.GET("/overrides", h.GetLevelModOverrides)
+		modGroup.POST("/overrides/validate", h.ValidateModOverrides)
+		modGroup.PUT("/overrides/enable", h.EnableLevelMod)
+		modGroup.PUT("/overrides/disable", h.DisableLevelMod)
+		modGroup.PUT("/overrides/config", h.ConfigureLevelMod)
+		modGroup.DELETE("/overrides", h.RemoveLevelMod)
+		modGroup.POST("/dependency/check", h.CheckModDependencies)
+	}
+
+	// 模组仓库所有集群共用，需要 admin 权限
+	repository := router.Group("/api/mod/repository", middleware.RequireRole(user.RoleAdmin))
+	{
+		repository.GET("", h.GetRepositoryMods)
+		repository.POST("", h.StoreModToRepository)
+		repository.DELETE("", h.DeleteRepositoryMod)
+		repository.POST("/install", h.InstallModFromRepository)
+		repository.GET("/export", h.ExportRepository)
+		repository.POST("/import", h.ImportRepository)
 	}
 }
 
@@ -61,7 +87,7 @@ func (h *ModHandler) SearchModList(ctx *gin.Context) {
 	size, _ := strconv.Atoi(ctx.DefaultQuery("size", "10"))
 	lang := ctx.DefaultQuery("lang", "zh")
 
-	data, err := h.modService.SearchModList(text, page, size, lang)
+	data, err := h.modService.SearchModList(ctx.Request.Context(), text, page, size, lang)
 	if err != nil {
 		response.FailWithMessage("搜索mod失败: "+err.Error(), ctx)
 		return
@@ -84,7 +110,7 @@ func (h *ModHandler) GetModInfo(ctx *gin.Context) {
 	modId := ctx.Param("modId")
 	lang := ctx.DefaultQuery("lang", "zh")
 	clusterName := context.GetClusterName(ctx)
-	modinfo, err := h.modService.SubscribeModByModId(clusterName, modId, lang)
+	modinfo, err := h.modService.SubscribeModByModId(ctx.Request.Context(), clusterName, modId, lang)
 	if err != nil {
 		response.FailWithMessage("模组下载失败: "+err.Error(), ctx)
 		return
@@ -106,6 +132,7 @@ func (h *ModHandler) GetModInfo(ctx *gin.Context) {
 		"v":             modinfo.V,
 		"mod_config":    modConfig,
 		"update":        modinfo.Update,
+		"pinned":        modinfo.Pinned,
 	}
 
 	response.OkWithData(modData, ctx)
@@ -144,6 +171,7 @@ func (h *ModHandler) GetMyModList(ctx *gin.Context) {
 			"v":             modinfo.V,
 			"mod_config":    modConfig,
 			"update":        modinfo.Update,
+			"pinned":        modinfo.Pinned,
 		}
 		modDataList = append(modDataList, modData)
 	}
@@ -165,7 +193,7 @@ func (h *ModHandler) UpdateAllModInfos(ctx *gin.Context) {
 	clusterName := context.GetClusterName(ctx)
 	lang := ctx.DefaultQuery("lang", "zh")
 
-	err := h.modService.UpdateAllModInfos(clusterName, lang)
+	err := h.modService.UpdateAllModInfos(ctx.Request.Context(), clusterName, lang)
 	if err != nil {
 		response.FailWithMessage("更新失败: "+err.Error(), ctx)
 		return
@@ -174,6 +202,37 @@ func (h *ModHandler) UpdateAllModInfos(ctx *gin.Context) {
 	response.OkWithMessage("更新成功", ctx)
 }
 
+type modPinRequest struct {
+	ModId  string `json:"modId"`
+	Pinned bool   `json:"pinned"`
+}
+
+// SetModPinned 固定模组版本
+// @Summary 固定模组版本
+// @Description 固定时将当前版本保存到模组仓库，自动更新和批量更新都会跳过该模组，有新版本时仍会标记；自动更新重启集群前会从模组仓库恢复固定的版本
+// @Tags mod
+// @Accept json
+// @Produce json
+// @Param data body modPinRequest true "模组ID和是否固定"
+// @Success 200 {object} response.Response{data=model.ModInfo}
+// @Router /api/mod/pin [put]
+func (h *ModHandler) SetModPinned(ctx *gin.Context) {
+	var request modPinRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), ctx)
+		return
+	}
+
+	clusterName := context.GetClusterName(ctx)
+	modInfo, err := h.modService.SetModPinned(clusterName, request.ModId, request.Pinned)
+	if err != nil {
+		response.FailWithMessage("设置失败: "+err.Error(), ctx)
+		return
+	}
+
+	response.OkWithData(modInfo, ctx)
+}
+
 // DeleteMod 删除模组
 // @Summary 删除模组
 // @Description 根据modId删除模组
@@ -278,6 +337,11 @@ func (h *ModHandler) UpdateMod(ctx *gin.Context) {
 	clusterName := context.GetClusterName(ctx)
 	lang := ctx.DefaultQuery("lang", "zh")
 
+	if modInfo, err := h.modService.GetModByModId(modId); err == nil && modInfo.Pinned {
+		response.FailWithMessage("模组更新失败: "+mod.ErrModPinned.Error(), ctx)
+		return
+	}
+
 	// 删除旧数据
 	err := h.modService.DeleteMod(clusterName, modId)
 	if err != nil {
@@ -285,7 +349,7 @@ func (h *ModHandler) UpdateMod(ctx *gin.Context) {
 	}
 
 	// 重新下载
-	modinfo, err := h.modService.SubscribeModByModId(clusterName, modId, lang)
+	modinfo, err := h.modService.SubscribeModByModId(ctx.Request.Context(), clusterName, modId, lang)
 	if err != nil {
 		response.FailWithMessage("模组更新失败: "+err.Error(), ctx)
 		return
@@ -307,6 +371,7 @@ func (h *ModHandler) UpdateMod(ctx *gin.Context) {
 		"v":             modinfo.V,
 		"mod_config":    modConfig,
 		"update":        modinfo.Update,
+		"pinned":        modinfo.Pinned,
 	}
 
 	response.OkWithData(modData, ctx)
@@ -349,7 +414,7 @@ func (h *ModHandler) AddModInfoFile(ctx *gin.Context) {
 		return
 	}
 
-	err = h.modService.AddModInfo(clusterName, lang, payload.WorkshopId, payload.Modinfo, config.Mod_download_path)
+	err = h.modService.AddModInfo(ctx.Request.Context(), clusterName, lang, payload.WorkshopId, payload.Modinfo, config.Mod_download_path)
 	if err != nil {
 		response.FailWithMessage("添加模组失败: "+err.Error(), ctx)
 		return
@@ -372,7 +437,7 @@ func (h *ModHandler) GetUgcModAcf(ctx *gin.Context) {
 	levelName := ctx.Query("levelName")
 	clusterName := context.GetClusterName(ctx)
 
-	workshopItemDetails, err := h.modService.GetUgcModInfo(clusterName, levelName)
+	workshopItemDetails, err := h.modService.GetUgcModInfo(ctx.Request.Context(), clusterName, levelName)
 	if err != nil {
 		response.FailWithMessage("获取UGC模组信息失败: "+err.Error(), ctx)
 		return
@@ -404,3 +469,344 @@ func (h *ModHandler) DeleteUgcModFile(ctx *gin.Context) {
 
 	response.OkWithMessage("删除成功", ctx)
 }
+
+// levelModRequest 世界中单个模组的操作
+type levelModRequest struct {
+	LevelName string `json:"levelName"`
+	ModId     string `json:"modId"`
+}
+
+// GetLevelModOverrides 获取世界的模组配置
+// @Summary 获取世界的模组配置
+// @Description 解析世界的 modoverrides.lua，返回每个模组的配置、配置项定义和校验结果
+// @Tags mod
+// @Accept json
+// @Produce json
+// @Param levelName query string true "世界名称"
+// @Success 200 {object} response.Response{data=mod.LevelModOverrides}
+// @Router /api/mod/overrides [get]
+func (h *ModHandler) GetLevelModOverrides(ctx *gin.Context) {
+	clusterName := context.GetClusterName(ctx)
+	levelName := ctx.Query("levelName")
+
+	overrides, err := h.modService.GetLevelModOverrides(clusterName, levelName)
+	if err != nil {
+		response.FailWithMessage("读取模组配置失败: "+err.Error(), ctx)
+		return
+	}
+
+	response.OkWithData(overrides, ctx)
+}
+
+// ValidateModOverrides 校验模组配置
+// @Summary 校验模组配置
+// @Description 按模组的配置项定义校验 modoverrides.lua 的内容，报告未知的配置项和无效的值
+// @Tags mod
+// @Accept json
+// @Produce json
+// @Param data body object{content=string} true "modoverrides.lua 内容"
+// @Success 200 {object} response.Response{data=[]mod.OverrideIssue}
+// @Router /api/mod/overrides/validate [post]
+func (h *ModHandler) ValidateModOverrides(ctx *gin.Context) {
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), ctx)
+		return
+	}
+
+	issues, err := h.modService.ValidateModOverrides(body.Content)
+	if err != nil {
+		response.FailWithMessage(err.Error(), ctx)
+		return
+	}
+
+	response.OkWithData(issues, ctx)
+}
+
+// EnableLevelMod 在世界中启用模组
+// @Summary 在世界中启用模组
+// @Description 启用世界中的模组，模组未添加时按默认配置添加
+// @Tags mod
+// @Accept json
+// @Produce json
+// @Param data body levelModRequest true "世界名称和模组ID"
+// @Success 200 {object} response.Response{data=mod.LevelModOverrides}
+// @Router /api/mod/overrides/enable [put]
+func (h *ModHandler) EnableLevelMod(ctx *gin.Context) {
+	var request levelModRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), ctx)
+		return
+	}
+	clusterName := context.GetClusterName(ctx)
+
+	overrides, err := h.modService.EnableLevelMod(clusterName, request.LevelName, request.ModId)
+	if err != nil {
+		response.FailWithMessage("启用模组失败: "+err.Error(), ctx)
+		return
+	}
+
+	response.OkWithData(overrides, ctx)
+}
+
+// DisableLevelMod 在世界中禁用模组
+// @Summary 在世界中禁用模组
+// @Description 禁用世界中的模组，保留模组的配置
+// @Tags mod
+// @Accept json
+// @Produce json
+// @Param data body levelModRequest true "世界名称和模组ID"
+// @Success 200 {object} response.Response{data=mod.LevelModOverrides}
+// @Router /api/mod/overrides/disable [put]
+func (h *ModHandler) DisableLevelMod(ctx *gin.Context) {
+	var request levelModRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), ctx)
+		return
+	}
+	clusterName := context.GetClusterName(ctx)
+
+	overrides, err := h.modService.DisableLevelMod(clusterName, request.LevelName, request.ModId)
+	if err != nil {
+		response.FailWithMessage("禁用模组失败: "+err.Error(), ctx)
+		return
+	}
+
+	response.OkWithData(overrides, ctx)
+}
+
+// ConfigureLevelMod 修改世界中模组的配置
+// @Summary 修改世界中模组的配置
+// @Description 校验并保存模组的配置，校验不通过时返回校验结果，force 为 true 时仍然保存
+// @Tags mod
+// @Accept json
+// @Produce json
+// @Param data body mod.ConfigureModRequest true "模组配置"
+// @Success 200 {object} response.Response{data=mod.LevelModOverrides}
+// @Router /api/mod/overrides/config [put]
+func (h *ModHandler) ConfigureLevelMod(ctx *gin.Context) {
+	var request mod.ConfigureModRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), ctx)
+		return
+	}
+	clusterName := context.GetClusterName(ctx)
+
+	overrides, issues, err := h.modService.ConfigureLevelMod(clusterName, request)
+	if errors.Is(err, mod.ErrInvalidModConfig) {
+		ctx.JSON(http.StatusOK, response.Response{
+			Code: 500,
+			Msg:  err.Error(),
+			Data: issues,
+		})
+		return
+	}
+	if err != nil {
+		response.FailWithMessage("修改模组配置失败: "+err.Error(), ctx)
+		return
+	}
+
+	response.OkWithData(overrides, ctx)
+}
+
+// RemoveLevelMod 从世界中移除模组
+// @Summary 从世界中移除模组
+// @Description 从世界的 modoverrides.lua 中移除模组及其配置
+// @Tags mod
+// @Accept json
+// @Produce json
+// @Param levelName query string true "世界名称"
+// @Param modId query string true "模组ID"
+// @Success 200 {object} response.Response{data=mod.LevelModOverrides}
+// @Router /api/mod/overrides [delete]
+func (h *ModHandler) RemoveLevelMod(ctx *gin.Context) {
+	clusterName := context.GetClusterName(ctx)
+	levelName := ctx.Query("levelName")
+	modId := ctx.Query("modId")
+
+	overrides, err := h.modService.RemoveLevelMod(clusterName, levelName, modId)
+	if err != nil {
+		response.FailWithMessage("移除模组失败: "+err.Error(), ctx)
+		return
+	}
+
+	response.OkWithData(overrides, ctx)
+}
+
+// CheckModDependencies 检查世界模组的依赖和冲突
+// @Summary 检查世界模组的依赖和冲突
+// @Description 保存世界前检查缺少的依赖模组、在服务器启用的仅客户端模组，以及只在部分世界启用的模组
+// @Tags mod
+// @Accept json
+// @Produce json
+// @Param data body object{levels=[]levelConfig.LevelInfo} true "世界配置列表"
+// @Success 200 {object} response.Response{data=mod.ModDependencyReport}
+// @Router /api/mod/dependency/check [post]
+func (h *ModHandler) CheckModDependencies(ctx *gin.Context) {
+	var payload struct {
+		Levels []levelConfig.LevelInfo `json:"levels"`
+	}
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), ctx)
+		return
+	}
+
+	response.OkWithData(h.modService.CheckModDependencies(payload.Levels), ctx)
+}
+
+type repositoryModRequest struct {
+	ModId   string `json:"modId"`
+	Version string `json:"version"`
+	Lang    string `json:"lang"`
+}
+
+// GetRepositoryMods 获取模组仓库中的模组
+// @Summary 获取模组仓库中的模组
+// @Description 模组仓库中的所有模组版本，所有集群共用，需要 admin 权限
+// @Tags mod
+// @Produce json
+// @Success 200 {object} response.Response{data=[]repository.Meta}
+// @Router /api/mod/repository [get]
+func (h *ModHandler) GetRepositoryMods(ctx *gin.Context) {
+	metas, err := h.modService.GetRepositoryMods()
+	if err != nil {
+		response.FailWithMessage("读取模组仓库失败: "+err.Error(), ctx)
+		return
+	}
+
+	response.OkWithData(metas, ctx)
+}
+
+// StoreModToRepository 保存模组到模组仓库
+// @Summary 保存模组到模组仓库
+// @Description 将当前集群已下载的模组保存到模组仓库，版本为模组的更新时间，需要 admin 权限
+// @Tags mod
+// @Accept json
+// @Produce json
+// @Param data body repositoryModRequest true "模组ID"
+// @Success 200 {object} response.Response{data=repository.Meta}
+// @Router /api/mod/repository [post]
+func (h *ModHandler) StoreModToRepository(ctx *gin.Context) {
+	var request repositoryModRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), ctx)
+		return
+	}
+	clusterName := context.GetClusterName(ctx)
+
+	meta, err := h.modService.StoreModToRepository(clusterName, request.ModId)
+	if err != nil {
+		response.FailWithMessage("保存模组失败: "+err.Error(), ctx)
+		return
+	}
+
+	response.OkWithData(meta, ctx)
+}
+
+// DeleteRepositoryMod 删除模组仓库中的模组
+// @Summary 删除模组仓库中的模组
+// @Description 删除模组仓库中模组的指定版本，version 为空时删除所有版本，需要 admin 权限
+// @Tags mod
+// @Produce json
+// @Param modId query string true "模组ID"
+// @Param version query string false "版本"
+// @Success 200 {object} response.Response
+// @Router /api/mod/repository [delete]
+func (h *ModHandler) DeleteRepositoryMod(ctx *gin.Context) {
+	if err := h.modService.DeleteRepositoryMod(ctx.Query("modId"), ctx.Query("version")); err != nil {
+		response.FailWithMessage("删除失败: "+err.Error(), ctx)
+		return
+	}
+
+	response.OkWithMessage("删除成功", ctx)
+}
+
+// InstallModFromRepository 从模组仓库安装模组
+// @Summary 从模组仓库安装模组
+// @Description 从模组仓库安装模组到当前集群并添加到已订阅的模组，不需要访问 Steam，version 为空时安装最新版本，需要 admin 权限
+// @Tags mod
+// @Accept json
+// @Produce json
+// @Param data body repositoryModRequest true "模组ID和版本"
+// @Success 200 {object} response.Response{data=model.ModInfo}
+// @Router /api/mod/repository/install [post]
+func (h *ModHandler) InstallModFromRepository(ctx *gin.Context) {
+	var request repositoryModRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		response.FailWithMessage("参数错误: "+err.Error(), ctx)
+		return
+	}
+	if request.Lang == "" {
+		request.Lang = "zh"
+	}
+	clusterName := context.GetClusterName(ctx)
+
+	modInfo, err := h.modService.InstallModFromRepository(clusterName, request.ModId, request.Version, request.Lang)
+	if err != nil {
+		response.FailWithMessage("安装模组失败: "+err.Error(), ctx)
+		return
+	}
+
+	response.OkWithData(modInfo, ctx)
+}
+
+// ExportRepository 导出模组仓库
+// @Summary 导出模组仓库
+// @Description 将模组仓库打包为 tar.gz 下载，可以导入到没有网络的主机，需要 admin 权限
+// @Tags mod
+// @Produce application/gzip
+// @Param modIds query string false "模组ID，多个用逗号分隔，默认导出所有模组"
+// @Param latest query bool false "是否只导出最新版本"
+// @Success 200 {file} file
+// @Router /api/mod/repository/export [get]
+func (h *ModHandler) ExportRepository(ctx *gin.Context) {
+	var modIds []string
+	for _, modId := range strings.Split(ctx.Query("modIds"), ",") {
+		if modId = strings.TrimSpace(modId); modId != "" {
+			modIds = append(modIds, modId)
+		}
+	}
+	fileName := "mod_repository_" + time.Now().Format("20060102150405") + ".tar.gz"
+	ctx.Header("Content-Type", "application/gzip")
+	ctx.Header("Content-Disposition", "attachment; filename="+fileName)
+	if err := h.modService.ExportRepository(ctx.Writer, modIds, ctx.Query("latest") == "true"); err != nil {
+		log.Println("[Mod]导出模组仓库失败", err)
+		if !ctx.Writer.Written() {
+			ctx.Header("Content-Disposition", "")
+			response.FailWithMessage("导出模组仓库失败: "+err.Error(), ctx)
+		}
+	}
+}
+
+// ImportRepository 导入模组仓库
+// @Summary 导入模组仓库
+// @Description 导入其他主机导出的模组仓库，校验压缩包后放入仓库，已有的相同版本会被替换，需要 admin 权限
+// @Tags mod
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "导出的 tar.gz 文件"
+// @Success 200 {object} response.Response{data=[]repository.Meta}
+// @Router /api/mod/repository/import [post]
+func (h *ModHandler) ImportRepository(ctx *gin.Context) {
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		response.FailWithMessage("请上传模组仓库文件", ctx)
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		response.FailWithMessage("读取文件失败: "+err.Error(), ctx)
+		return
+	}
+	defer file.Close()
+
+	metas, err := h.modService.ImportRepository(file)
+	if err != nil {
+		response.FailWithMessage("导入模组仓库失败: "+err.Error(), ctx)
+		return
+	}
+
+	response.OkWithData(metas, ctx)
+}
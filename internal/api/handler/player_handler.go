@@ -2,6 +2,7 @@ package handler
 
 import (
 	"dst-admin-go/internal/pkg/context"
+	"dst-admin-go/internal/pkg/response"
 	"dst-admin-go/internal/service/game"
 	"dst-admin-go/internal/service/player"
 	"net/http"
@@ -28,7 +29,7 @@ func (p *PlayerHandler) GetPlayerList(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"msg":  "success",
-		"data": p.playerService.GetPlayerList(clusterName, "Master", p.gameProcess),
+		"data": p.playerService.GetPlayerList(clusterName, "Master"),
 	})
 }
 
@@ -39,7 +40,7 @@ func (p *PlayerHandler) GetPlayerAllList(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"msg":  "success",
-		"data": p.playerService.GetPlayerAllList(clusterName, p.gameProcess),
+		"data": p.playerService.GetPlayerAllList(clusterName),
 	})
 }
 
@@ -48,5 +49,26 @@ func (p *PlayerHandler) RegisterRoute(router *gin.RouterGroup) {
 	{
 		player.GET("", p.GetPlayerList)
 		player.GET("/all", p.GetPlayerAllList)
+		player.GET("/detail", p.GetPlayerDetail)
 	}
 }
+
+// GetPlayerDetail 获取玩家详情
+// @Summary 获取玩家详情
+// @Description 在玩家所在的世界执行 GetPlayerData，返回生命、饥饿、理智、装备和物品
+// @Tags player
+// @Produce json
+// @Param kuId query string true "玩家 KuId"
+// @Param levelName query string false "世界名称" default(Master)
+// @Success 200 {object} response.Response{data=player.PlayerDetail}
+// @Router /api/game/8level/players/detail [get]
+func (p *PlayerHandler) GetPlayerDetail(ctx *gin.Context) {
+	clusterName := context.GetClusterName(ctx)
+	levelName := ctx.DefaultQuery("levelName", "Master")
+	detail, err := p.playerService.GetPlayerDetail(clusterName, levelName, ctx.Query("kuId"))
+	if err != nil {
+		response.FailWithMessage("获取玩家详情失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(detail, ctx)
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"dst-admin-go/internal/database"
+	"dst-admin-go/internal/middleware"
 	"dst-admin-go/internal/model"
 	"dst-admin-go/internal/pkg/response"
 	"fmt"
@@ -22,7 +23,7 @@ func NewPlayerLogHandler() *PlayerLogHandler {
 func (l *PlayerLogHandler) RegisterRoute(router *gin.RouterGroup) {
 	router.GET("/api/player/log", l.PlayerLogQueryPage)
 	router.POST("/api/player/log/delete", l.DeletePlayerLog)
-	router.GET("/api/player/log/delete/all", l.DeletePlayerLogAll)
+	middleware.WriteGET(router, "/api/player/log/delete/all", l.DeletePlayerLogAll)
 }
 
 // PlayerLogQueryPage 生成 swagger 文档注释
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"dst-admin-go/internal/pkg/context"
+	"dst-admin-go/internal/pkg/response"
+	"dst-admin-go/internal/service/audit"
+	"dst-admin-go/internal/service/restart"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RestartHandler struct {
+	restartService *restart.RestartService
+}
+
+func NewRestartHandler(restartService *restart.RestartService) *RestartHandler {
+	return &RestartHandler{
+		restartService: restartService,
+	}
+}
+
+func (h *RestartHandler) RegisterRoute(router *gin.RouterGroup) {
+	router.POST("/api/game/8level/restart", h.Restart)
+	router.GET("/api/game/8level/restart", h.GetTask)
+	router.POST("/api/game/8level/restart/cancel", h.Cancel)
+	router.GET("/api/game/8level/restart/stream", h.Stream)
+}
+
+// Restart 平滑重启
+// @Summary 平滑重启
+// @Description 倒计时期间按 intervals 向运行中的世界发送 c_announce 公告，倒计时结束后执行 c_save() 并等待存档写入，再按主从顺序重启。
+// @Description 重启在后台执行，进度通过 /api/game/8level/restart/stream 获取。levelName 为空时重启整个集群
+// @Tags game
+// @Accept json
+// @Produce json
+// @Param clusterName query string false "集群名称"
+// @Param body body restart.Options true "重启参数"
+// @Success 200 {object} response.Response{data=restart.Task}
+// @Router /api/game/8level/restart [post]
+func (h *RestartHandler) Restart(ctx *gin.Context) {
+	var options restart.Options
+	if err := ctx.ShouldBindJSON(&options); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	task, err := h.restartService.Restart(audit.OperatorFrom(ctx), context.GetClusterName(ctx), options)
+	if err != nil {
+		response.FailWithMessage("重启失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(task, ctx)
+}
+
+// GetTask 获取最近一次重启
+// @Summary 获取最近一次重启
+// @Description 获取集群最近一次平滑重启的状态、进度和每个世界的结果
+// @Tags game
+// @Produce json
+// @Param clusterName query string false "集群名称"
+// @Success 200 {object} response.Response{data=restart.Task}
+// @Router /api/game/8level/restart [get]
+func (h *RestartHandler) GetTask(ctx *gin.Context) {
+	task, ok := h.restartService.GetTask(context.GetClusterName(ctx))
+	if !ok {
+		response.OkWithData(nil, ctx)
+		return
+	}
+	response.OkWithData(task, ctx)
+}
+
+// Cancel 取消重启
+// @Summary 取消重启
+// @Description 倒计时期间取消重启，开始保存后无法取消
+// @Tags game
+// @Produce json
+// @Param clusterName query string false "集群名称"
+// @Success 200 {object} response.Response
+// @Router /api/game/8level/restart/cancel [post]
+func (h *RestartHandler) Cancel(ctx *gin.Context) {
+	if err := h.restartService.Cancel(context.GetClusterName(ctx)); err != nil {
+		response.FailWithMessage(err.Error(), ctx)
+		return
+	}
+	response.OkWithMessage("已取消重启", ctx)
+}
+
+// Stream 重启进度流
+// @Summary 重启进度流
+// @Description 先推送当前重启任务（task 事件），之后推送进度（progress 事件），结束时推送最终结果（done 事件）(SSE)
+// @Tags game
+// @Accept text/event-stream
+// @Produce text/event-stream
+// @Param clusterName query string false "集群名称"
+// @Success 200 {string} string "SSE 格式的重启进度"
+// @Router /api/game/8level/restart/stream [get]
+func (h *RestartHandler) Stream(c *gin.Context) {
+	clusterName := context.GetClusterName(c)
+
+	w := c.Writer
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		c.JSON(500, gin.H{"error": "streaming unsupported"})
+		return
+	}
+	task, progress, cancel, ok := h.restartService.Subscribe(clusterName)
+	defer cancel()
+	if !ok {
+		c.JSON(404, gin.H{"error": "no restart task"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx
+
+	data, _ := json.Marshal(task)
+	writeSSE(w, "task", string(data))
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item, ok := <-progress:
+			if !ok {
+				task, _ := h.restartService.GetTask(clusterName)
+				data, _ := json.Marshal(task)
+				writeSSE(w, "done", string(data))
+				flusher.Flush()
+				return
+			}
+			data, _ := json.Marshal(item)
+			writeSSE(w, "progress", string(data))
+			flusher.Flush()
+		case <-heartbeat.C:
+			writeSSE(w, "ping", "")
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"dst-admin-go/internal/pkg/context"
+	"dst-admin-go/internal/pkg/response"
+	"dst-admin-go/internal/service/session"
+	"dst-admin-go/internal/service/user"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	sessionService *session.SessionService
+}
+
+func NewSessionHandler(sessionService *session.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+func (h *SessionHandler) RegisterRoute(router *gin.RouterGroup) {
+	group := router.Group("/api/session")
+	{
+		group.GET("", h.GetSessionList)
+		group.DELETE("", h.RevokeSession)
+	}
+}
+
+// scopeUsername admin 可以查看和撤销所有用户的会话，其他用户只能操作自己的会话
+func (h *SessionHandler) scopeUsername(ctx *gin.Context) string {
+	current := context.GetUser(ctx)
+	if user.HasRole(current.Role, user.RoleAdmin) && ctx.Query("all") == "true" {
+		return ""
+	}
+	return current.Username
+}
+
+// GetSessionList 获取登录会话列表
+// @Summary 获取登录会话列表
+// @Description 获取当前用户有效的登录会话，包括设备、IP 和最近访问时间，admin 传 all=true 可以查看所有用户的会话
+// @Tags session
+// @Produce json
+// @Param all query bool false "是否查看所有用户的会话"
+// @Success 200 {object} response.Response{data=[]session.SessionInfo}
+// @Router /api/session [get]
+func (h *SessionHandler) GetSessionList(ctx *gin.Context) {
+	currentId := sessions.Default(ctx).ID()
+	response.OkWithData(h.sessionService.GetSessionList(h.scopeUsername(ctx), currentId), ctx)
+}
+
+// RevokeSession 撤销登录会话
+// @Summary 撤销登录会话
+// @Description 撤销登录会话，被撤销的设备需要重新登录，admin 传 all=true 可以撤销任意用户的会话
+// @Tags session
+// @Produce json
+// @Param id query string true "会话ID"
+// @Param all query bool false "是否可以撤销其他用户的会话"
+// @Success 200 {object} response.Response
+// @Router /api/session [delete]
+func (h *SessionHandler) RevokeSession(ctx *gin.Context) {
+	id := ctx.Query("id")
+	if id == "" {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if err := h.sessionService.RevokeSession(h.scopeUsername(ctx), id); err != nil {
+		response.FailWithMessage("撤销会话失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithMessage("撤销会话成功", ctx)
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"dst-admin-go/internal/middleware"
 	"dst-admin-go/internal/pkg/context"
 	"dst-admin-go/internal/pkg/response"
 	"dst-admin-go/internal/service/update"
@@ -21,7 +22,7 @@ func NewUpdateHandler(update update.Update) *UpdateHandler {
 }
 
 func (h *UpdateHandler) RegisterRoute(router *gin.RouterGroup) {
-	router.GET("/api/game/update", h.Update)
+	middleware.WriteGET(router, "/api/game/update", h.Update)
 }
 
 // Update 生成 swagger 文档注释
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"dst-admin-go/internal/middleware"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/context"
+	"dst-admin-go/internal/pkg/response"
+	"dst-admin-go/internal/service/user"
+	"strconv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+type UserHandler struct {
+	userService *user.UserService
+}
+
+func NewUserHandler(userService *user.UserService) *UserHandler {
+	return &UserHandler{
+		userService: userService,
+	}
+}
+
+func (h *UserHandler) RegisterRoute(router *gin.RouterGroup) {
+	users := router.Group("/api/users", middleware.RequireRole(user.RoleAdmin))
+	{
+		users.GET("", h.GetUserList)
+		users.POST("", h.CreateUser)
+		users.PUT("", h.UpdateUser)
+		users.DELETE("", h.DeleteUser)
+		users.PUT("/grant", h.SaveGrants)
+	}
+}
+
+type userRequest struct {
+	model.User
+	Password string `json:"password"`
+}
+
+// canManage 只有 owner 可以管理 owner 用户和授予 owner 角色
+func (h *UserHandler) canManage(ctx *gin.Context, role string) bool {
+	current := context.GetUser(ctx)
+	if current == nil {
+		return false
+	}
+	return role != user.RoleOwner || current.Role == user.RoleOwner
+}
+
+// GetUserList 获取用户列表
+// @Summary 获取用户列表
+// @Description 获取所有用户及其集群授权，需要 admin 权限
+// @Tags user
+// @Produce json
+// @Success 200 {object} response.Response{data=[]user.UserDetail}
+// @Router /api/users [get]
+func (h *UserHandler) GetUserList(ctx *gin.Context) {
+	response.OkWithData(h.userService.GetUserList(), ctx)
+}
+
+// CreateUser 创建用户
+// @Summary 创建用户
+// @Description 创建用户，role 可选 owner、admin、operator、viewer，需要 admin 权限
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param user body userRequest true "用户信息"
+// @Success 200 {object} response.Response{data=model.User}
+// @Router /api/users [post]
+func (h *UserHandler) CreateUser(ctx *gin.Context) {
+	var body userRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	if !h.canManage(ctx, body.Role) {
+		response.FailWithMessage("只有 owner 可以创建 owner 用户", ctx)
+		return
+	}
+	newUser := body.User
+	if err := h.userService.CreateUser(&newUser, body.Password); err != nil {
+		response.FailWithMessage("创建用户失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(newUser, ctx)
+}
+
+// UpdateUser 更新用户
+// @Summary 更新用户
+// @Description 更新用户信息和角色，password 为空时不修改密码，需要 admin 权限
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param user body userRequest true "用户信息"
+// @Success 200 {object} response.Response{data=model.User}
+// @Router /api/users [put]
+func (h *UserHandler) UpdateUser(ctx *gin.Context) {
+	var body userRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	oldUser := h.findUser(body.ID)
+	if oldUser == nil {
+		response.FailWithMessage("用户不存在", ctx)
+		return
+	}
+	if !h.canManage(ctx, oldUser.Role) || !h.canManage(ctx, body.Role) {
+		response.FailWithMessage("只有 owner 可以修改 owner 用户", ctx)
+		return
+	}
+	updateUser := body.User
+	if err := h.userService.UpdateUser(&updateUser, body.Password, sessions.Default(ctx).ID()); err != nil {
+		response.FailWithMessage("更新用户失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(updateUser, ctx)
+}
+
+// DeleteUser 删除用户
+// @Summary 删除用户
+// @Description 删除用户及其集群授权，需要 admin 权限
+// @Tags user
+// @Produce json
+// @Param id query int true "用户ID"
+// @Success 200 {object} response.Response
+// @Router /api/users [delete]
+func (h *UserHandler) DeleteUser(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Query("id"), 10, 64)
+	if err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	oldUser := h.findUser(uint(id))
+	if oldUser == nil {
+		response.FailWithMessage("用户不存在", ctx)
+		return
+	}
+	if !h.canManage(ctx, oldUser.Role) {
+		response.FailWithMessage("只有 owner 可以删除 owner 用户", ctx)
+		return
+	}
+	if current := context.GetUser(ctx); current != nil && current.ID == oldUser.ID {
+		response.FailWithMessage("不能删除自己", ctx)
+		return
+	}
+	if err := h.userService.DeleteUser(uint(id)); err != nil {
+		response.FailWithMessage("删除用户失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithMessage("删除用户成功", ctx)
+}
+
+// SaveGrants 保存用户的集群授权
+// @Summary 保存用户的集群授权
+// @Description 覆盖保存用户可以访问的集群，role 为空时使用用户自身的角色，需要 admin 权限
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param grant body object true "集群授权 {userId, clusters}"
+// @Success 200 {object} response.Response{data=[]model.UserCluster}
+// @Router /api/users/grant [put]
+func (h *UserHandler) SaveGrants(ctx *gin.Context) {
+	var body struct {
+		UserId   uint                `json:"userId"`
+		Clusters []model.UserCluster `json:"clusters"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		response.FailWithMessage("参数错误", ctx)
+		return
+	}
+	oldUser := h.findUser(body.UserId)
+	if oldUser == nil {
+		response.FailWithMessage("用户不存在", ctx)
+		return
+	}
+	if !h.canManage(ctx, oldUser.Role) {
+		response.FailWithMessage("只有 owner 可以修改 owner 用户", ctx)
+		return
+	}
+	if err := h.userService.SaveGrants(body.UserId, body.Clusters); err != nil {
+		response.FailWithMessage("保存集群授权失败: "+err.Error(), ctx)
+		return
+	}
+	response.OkWithData(h.userService.GetGrants(body.UserId), ctx)
+}
+
+func (h *UserHandler) findUser(id uint) *model.User {
+	u, err := h.userService.GetUserById(id)
+	if err != nil {
+		return nil
+	}
+	return u
+}
@@ -5,8 +5,14 @@ import (
 	"dst-admin-go/internal/collect"
 	"dst-admin-go/internal/config"
 	"dst-admin-go/internal/middleware"
+	"dst-admin-go/internal/service/announce"
+	"dst-admin-go/internal/service/apiToken"
 	"dst-admin-go/internal/service/archive"
+	"dst-admin-go/internal/service/audit"
+	"dst-admin-go/internal/service/autoCheck"
 	"dst-admin-go/internal/service/backup"
+	"dst-admin-go/internal/service/cluster"
+	"dst-admin-go/internal/service/console"
 	"dst-admin-go/internal/service/dstConfig"
 	"dst-admin-go/internal/service/dstMap"
 	"dst-admin-go/internal/service/game"
@@ -14,14 +20,21 @@ import (
 	"dst-admin-go/internal/service/gameConfig"
 	"dst-admin-go/internal/service/level"
 	"dst-admin-go/internal/service/levelConfig"
+	"dst-admin-go/internal/service/lifecycle"
 	"dst-admin-go/internal/service/login"
 	"dst-admin-go/internal/service/mod"
+	"dst-admin-go/internal/service/mod/repository"
+	"dst-admin-go/internal/service/mod/workshop"
+	"dst-admin-go/internal/service/modUpdate"
 	"dst-admin-go/internal/service/player"
+	"dst-admin-go/internal/service/restart"
+	"dst-admin-go/internal/service/schedule"
+	"dst-admin-go/internal/service/session"
 	"dst-admin-go/internal/service/update"
+	"dst-admin-go/internal/service/user"
 	"time"
 
 	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/memstore"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
@@ -31,12 +44,13 @@ import (
 
 func NewRoute(cfg *config.Config, db *gorm.DB) *gin.Engine {
 	app := gin.Default()
-	store := memstore.NewStore([]byte("secret"))
+	store := session.NewStore(db)
 	store.Options(sessions.Options{
 		Path:     "/",
 		MaxAge:   int(60 * 24 * 7 * time.Minute.Seconds()),
 		HttpOnly: true,
 	})
+	app.Use(session.ClientIP())
 	app.Use(sessions.Sessions("token", store))
 	app.Use(middleware.Recover)
 
@@ -50,15 +64,10 @@ func NewRoute(cfg *config.Config, db *gorm.DB) *gin.Engine {
 	return app
 }
 
-func initCollectors(archive *archive.PathResolver, dstConfigService dstConfig.Config) {
-	getDstConfig, err := dstConfigService.GetDstConfig("MyDediServer")
-	if err != nil {
-		return
+func initCollectors(clusterService *cluster.ClusterService, collectMap *collect.CollectMap) {
+	for _, clusterName := range clusterService.GetClusterNames() {
+		collectMap.AddNewCollect(clusterName)
 	}
-	clusterName := getDstConfig.Cluster
-	newCollect := collect.NewCollect(archive.ClusterPath(clusterName), clusterName)
-	collect.Collector = newCollect
-	collect.Collector.StartCollect()
 }
 
 func RegisterStaticFile(app *gin.Engine) {
@@ -90,58 +99,109 @@ func Register(cfg *config.Config, db *gorm.DB, router *gin.RouterGroup) {
 	dstConfigService := dstConfig.NewDstConfig(db)
 	updateService := update.NewUpdateService(dstConfigService)
 	resolverService, _ := archive.NewPathResolver(dstConfigService)
-	loginService := login.NewLoginService(cfg)
+	sessionService := session.NewSessionService(db)
+	userService := user.NewUserService(db, sessionService)
+	loginService := login.NewLoginService(cfg, userService)
+	apiTokenService := apiToken.NewApiTokenService(db, cfg, userService)
+	auditService := audit.NewAuditService(db)
 	levelConfigUtils := levelConfig.NewLevelConfigUtils(resolverService)
-	gameProcess := game.NewGame(dstConfigService, levelConfigUtils)
+	lifecycleService := lifecycle.NewLifecycleService(game.NewGame(dstConfigService, levelConfigUtils), resolverService, levelConfigUtils)
+	gameProcess := lifecycleService.Process()
+	collectMap := collect.NewCollectMap(resolverService, levelConfigUtils)
 
 	gameConfigService := gameConfig.NewGameConfig(resolverService, levelConfigUtils)
-	backupService := backup.NewBackupService(resolverService, dstConfigService, gameProcess)
-	levelService := level.NewLevelService(gameProcess, dstConfigService, resolverService, levelConfigUtils)
-	playerService := player.NewPlayerService(resolverService)
+	levelService := level.NewLevelService(gameProcess, dstConfigService, resolverService, levelConfigUtils, collectMap)
 	gameArchiveService := gameArchive.NewGameArchive(gameConfigService, levelService, resolverService)
-	modService := mod.NewModService(db, dstConfigService, resolverService)
+	backupService := backup.NewBackupService(resolverService, dstConfigService, gameProcess, gameArchiveService)
+	luaConsole := console.NewConsole(gameProcess, resolverService)
+	playerService := player.NewPlayerService(luaConsole)
+	workshopClient := workshop.NewSteamClient(workshop.Options{
+		APIKey:          cfg.SteamAPIKey,
+		BaseURL:         cfg.Workshop.BaseURL,
+		Timeout:         time.Duration(cfg.Workshop.Timeout) * time.Second,
+		DownloadTimeout: time.Duration(cfg.Workshop.DownloadTimeout) * time.Second,
+		Retries:         *cfg.Workshop.Retries,
+		RateLimit:       cfg.Workshop.RateLimit,
+	})
+	modService := mod.NewModService(db, dstConfigService, resolverService, repository.New(cfg.ModRepository), workshopClient)
+	autoCheckService := autoCheck.NewAutoCheckService(db, cfg, gameProcess, updateService, resolverService, levelConfigUtils, modService, auditService)
+	announceService := announce.NewAnnounceService(db, gameProcess, levelConfigUtils)
+	scheduleService := schedule.NewSchedule(db, gameProcess, backupService, updateService, levelConfigUtils, autoCheckService)
+	restartService := restart.NewRestartService(gameProcess, lifecycleService, resolverService, levelConfigUtils, autoCheckService)
+	modUpdateService := modUpdate.NewModUpdateService(db, cfg, gameProcess, levelConfigUtils, modService, autoCheckService, restartService)
+	clusterService := cluster.NewClusterService(db, resolverService, gameProcess, levelConfigUtils, collectMap, backupService, scheduleService, autoCheckService, announceService)
 
 	dstMapGenerator := dstMap.NewDSTMapGenerator()
 
 	// init
-	initCollectors(resolverService, dstConfigService)
+	userService.MigrateFromPasswordFile()
+	initCollectors(clusterService, collectMap)
+	lifecycleService.Start(clusterService.GetClusterNames())
+	scheduleService.Start()
+	autoCheckService.Start()
+	announceService.Start()
+	modUpdateService.Start()
+	backupService.ScheduleBackupSnapshots()
 
 	//  handler
 	updateHandler := handler.NewUpdateHandler(updateService)
-	gameHandler := handler.NewGameHandler(gameProcess, levelService, gameArchiveService, levelConfigUtils, resolverService)
+	gameHandler := handler.NewGameHandler(gameProcess, levelService, gameArchiveService, levelConfigUtils, resolverService, autoCheckService, announceService, auditService)
 	gameConfigHandler := handler.NewGameConfigHandler(gameConfigService)
-	dstConfigHandler := handler.NewDstConfigHandler(dstConfigService, resolverService)
+	dstConfigHandler := handler.NewDstConfigHandler(dstConfigService, resolverService, collectMap)
+	clusterHandler := handler.NewClusterHandler(clusterService, userService)
 	loginHandler := handler.NewLoginHandler(loginService)
+	userHandler := handler.NewUserHandler(userService)
+	apiTokenHandler := handler.NewApiTokenHandler(apiTokenService)
+	sessionHandler := handler.NewSessionHandler(sessionService)
+	auditHandler := handler.NewAuditHandler(auditService)
+	consoleHandler := handler.NewConsoleHandler(luaConsole, auditService)
 	backupHandler := handler.NewBackupHandler(backupService)
-	levelHandler := handler.NewLevelHandler(levelService)
+	levelHandler := handler.NewLevelHandler(levelService, modService)
 	playerHandler := handler.NewPlayerHandler(playerService, gameProcess)
-	levelLogHandler := handler.NewLevelLogHandler(resolverService)
+	levelLogHandler := handler.NewLevelLogHandler(resolverService, gameProcess)
+	lifecycleHandler := handler.NewLifecycleHandler(lifecycleService)
+	restartHandler := handler.NewRestartHandler(restartService)
 	kvHandler := handler.NewKvHandler(db)
 	dstApiHandler := handler.NewDstApiHandler()
 	dstMapHandler := handler.NewDstMapHandler(resolverService, dstMapGenerator)
 	playerLogHandler := handler.NewPlayerLogHandler()
 	statisticsHandler := handler.NewStatisticsHandler()
 	modHandler := handler.NewModHandler(modService, dstConfigService)
+	jobTaskHandler := handler.NewJobTaskHandler(scheduleService)
+	autoCheckHandler := handler.NewAutoCheckHandler(autoCheckService)
+	announceHandler := handler.NewAnnounceHandler(announceService)
 
 	// 中间件
-	router.Use(middleware.Authentication(loginService))
-	router.Use(middleware.ClusterMiddleware(dstConfigService))
+	router.Use(middleware.Authentication(loginService, apiTokenService))
+	router.Use(middleware.ClusterMiddleware(dstConfigService, userService))
+	router.Use(middleware.Audit(auditService))
 
 	//  route
 	updateHandler.RegisterRoute(router)
 	gameHandler.RegisterRoute(router)
 	gameConfigHandler.RegisterRoute(router)
 	dstConfigHandler.RegisterRoute(router)
+	clusterHandler.RegisterRoute(router)
 	loginHandler.RegisterRoute(router)
+	userHandler.RegisterRoute(router)
+	apiTokenHandler.RegisterRoute(router)
+	sessionHandler.RegisterRoute(router)
+	auditHandler.RegisterRoute(router)
+	consoleHandler.RegisterRoute(router)
 	backupHandler.RegisterRoute(router)
 	levelHandler.RegisterRoute(router)
 	playerHandler.RegisterRoute(router)
 	levelLogHandler.RegisterRoute(router)
+	lifecycleHandler.RegisterRoute(router)
+	restartHandler.RegisterRoute(router)
 	kvHandler.RegisterRoute(router)
 	dstApiHandler.RegisterRoute(router)
 	dstMapHandler.RegisterRoute(router)
 	playerLogHandler.RegisterRoute(router)
 	statisticsHandler.RegisterRoute(router)
 	modHandler.RegisterRoute(router)
+	jobTaskHandler.RegisterRoute(router)
+	autoCheckHandler.RegisterRoute(router)
+	announceHandler.RegisterRoute(router)
 
 }
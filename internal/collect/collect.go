@@ -1,82 +1,119 @@
 package collect
 
 import (
+	"context"
 	"dst-admin-go/internal/database"
 	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/service/levelConfig"
 	"fmt"
 	"log"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hpcloud/tail"
 )
 
-var Collector *Collect
+// dedupeWindow 同一条日志在多个世界中出现时的去重时间窗口
+const dedupeWindow = 3 * time.Second
+
+// regenerateWindow 重新生成世界时每个世界都会输出 # Generating，时间窗口内只记录一次
+const regenerateWindow = 2 * time.Minute
+
+// timePrefix 日志行首的时间，各世界启动时间不同，去重时需要忽略
+var timePrefix = regexp.MustCompile(`^\[[^\]]*\]:\s*`)
 
 type Collect struct {
-	state             chan int
-	stop              chan bool
-	severLogList      []string
-	serverChatLogList []string
-	length            int
-	clusterName       string
+	ctx              context.Context
+	cancel           context.CancelFunc
+	baseLogPath      string
+	clusterName      string
+	levelConfigUtils *levelConfig.LevelConfigUtils
+
+	levels map[string]context.CancelFunc
+	recent map[string]time.Time
+	mu     sync.Mutex
 }
 
-func NewCollect(baseLogPath string, clusterName string) *Collect {
-	collect := &Collect{
-		state: make(chan int, 1),
-		severLogList: []string{
-			filepath.Join(baseLogPath, "Master", "server_log.txt"),
-		},
-		serverChatLogList: []string{
-			filepath.Join(baseLogPath, "Master", "server_chat_log.txt"),
-		},
-		stop:        make(chan bool, 2),
-		length:      2,
-		clusterName: clusterName,
-	}
-	collect.state <- 1
-	return collect
+func NewCollect(baseLogPath string, clusterName string, levelConfigUtils *levelConfig.LevelConfigUtils) *Collect {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Collect{
+		ctx:              ctx,
+		cancel:           cancel,
+		baseLogPath:      baseLogPath,
+		clusterName:      clusterName,
+		levelConfigUtils: levelConfigUtils,
+		levels:           map[string]context.CancelFunc{},
+		recent:           map[string]time.Time{},
+	}
 }
 
+// Stop 停止采集集群所有世界的日志
 func (c *Collect) Stop() {
-	close(c.stop)
+	c.cancel()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.levels = map[string]context.CancelFunc{}
 }
 
-func (c *Collect) ReCollect(baseLogPath, clusterName string) {
-	for i := 0; i < c.length; i++ {
-		c.stop <- true
+// StartCollect 开始采集 level.json 中所有世界的日志
+func (c *Collect) StartCollect() {
+	c.Refresh()
+}
+
+// Refresh 按 level.json 同步采集的世界，新增的世界开始采集，已删除的世界停止采集
+func (c *Collect) Refresh() {
+	if c.ctx.Err() != nil {
+		return
 	}
-	c.severLogList = []string{
-		filepath.Join(baseLogPath, "Master", "server_log.txt"),
+	config, err := c.levelConfigUtils.GetLevelConfig(c.clusterName)
+	if err != nil {
+		log.Println("获取世界配置失败", c.clusterName, err)
+		return
 	}
-	c.serverChatLogList = []string{
-		filepath.Join(baseLogPath, "Master", "server_chat_log.txt"),
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	levels := map[string]bool{}
+	for _, item := range config.LevelList {
+		levelName := item.File
+		levels[levelName] = true
+		if _, ok := c.levels[levelName]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(c.ctx)
+		c.levels[levelName] = cancel
+		go c.tailServeLog(ctx, filepath.Join(c.baseLogPath, levelName, "server_log.txt"))
+		go c.tailServerChatLog(ctx, filepath.Join(c.baseLogPath, levelName, "server_chat_log.txt"))
+	}
+	for levelName, cancel := range c.levels {
+		if !levels[levelName] {
+			log.Println("停止采集", c.clusterName, levelName)
+			cancel()
+			delete(c.levels, levelName)
+		}
 	}
-	c.clusterName = clusterName
-	c.state <- 1
 }
 
-func (c *Collect) StartCollect() {
-	go func() {
-		for {
-			select {
-			case <-c.state:
-				// 采集
-				for _, s := range c.severLogList {
-					go c.tailServeLog(s)
-				}
-				for _, s := range c.serverChatLogList {
-					go c.tailServerChatLog(s)
-				}
-			default:
-				time.Sleep(5 * time.Second)
-				continue
-			}
+// duplicate 判断日志是否已在时间窗口内由其他世界记录过
+func (c *Collect) duplicate(key string, window time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, t := range c.recent {
+		if now.Sub(t) > regenerateWindow {
+			delete(c.recent, k)
 		}
-	}()
+	}
+	if t, ok := c.recent[key]; ok && now.Sub(t) <= window {
+		return true
+	}
+	c.recent[key] = now
+	return false
 }
 
 func (c *Collect) parseSpawnRequestLog(text string) {
@@ -115,6 +152,9 @@ func (c *Collect) parseRegenerateLog(text string) {
 		}
 	}()
 
+	if c.duplicate("# Generating", regenerateWindow) {
+		return
+	}
 	regenerate := model.Regenerate{
 		ClusterName: c.clusterName,
 	}
@@ -210,7 +250,7 @@ func (c *Collect) parseNewIncomingLog(lines []string) {
 	database.Db.Create(&connect)
 }
 
-func (c *Collect) tailServeLog(fileName string) {
+func (c *Collect) tailServeLog(ctx context.Context, fileName string) {
 
 	log.Println("开始采集 path:", fileName)
 	config := tail.Config{
@@ -223,6 +263,7 @@ func (c *Collect) tailServeLog(fileName string) {
 	tails, err := tail.TailFile(fileName, config)
 	if err != nil {
 		log.Println("文件监听失败", err)
+		return
 	}
 	var (
 		which        = 0
@@ -256,9 +297,10 @@ func (c *Collect) tailServeLog(fileName string) {
 					}
 				}
 			}
-		case <-c.stop:
+		case <-ctx.Done():
 			// 结束监听
 			err := tails.Stop()
+			tails.Cleanup()
 			if err != nil {
 				log.Println("tail log 结束失败")
 				return
@@ -463,7 +505,7 @@ func (c *Collect) parseJoin(text string) {
 	}
 }
 
-func (c *Collect) tailServerChatLog(fileName string) {
+func (c *Collect) tailServerChatLog(ctx context.Context, fileName string) {
 	log.Println("开始采集 path:", fileName)
 	config := tail.Config{
 		ReOpen:    true,                                 // 重新打开
@@ -475,6 +517,7 @@ func (c *Collect) tailServerChatLog(fileName string) {
 	tails, err := tail.TailFile(fileName, config)
 	if err != nil {
 		log.Println("文件监听失败", err)
+		return
 	}
 	for {
 		select {
@@ -484,11 +527,16 @@ func (c *Collect) tailServerChatLog(fileName string) {
 				time.Sleep(time.Second)
 			} else {
 				text := line.Text
+				// 公告、加入离开等消息会同时出现在多个世界的日志中
+				if c.duplicate(timePrefix.ReplaceAllString(text, ""), dedupeWindow) {
+					continue
+				}
 				c.parseChatLog(text)
 			}
-		case <-c.stop:
+		case <-ctx.Done():
 			// 结束监听
 			err := tails.Stop()
+			tails.Cleanup()
 			if err != nil {
 				log.Println("tail log 结束失败")
 				return
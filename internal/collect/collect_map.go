@@ -2,34 +2,55 @@ package collect
 
 import (
 	"dst-admin-go/internal/service/archive"
+	"dst-admin-go/internal/service/levelConfig"
 	"sync"
 )
 
-var CollectorMap *CollectMap
-
+// CollectMap 日志采集注册表，每个集群一个采集器
 type CollectMap struct {
-	cache   sync.Map
-	archive *archive.PathResolver
+	cache            sync.Map
+	archive          *archive.PathResolver
+	levelConfigUtils *levelConfig.LevelConfigUtils
 }
 
-func NewCollectMap() *CollectMap {
+func NewCollectMap(archive *archive.PathResolver, levelConfigUtils *levelConfig.LevelConfigUtils) *CollectMap {
 	return &CollectMap{
-		cache: sync.Map{},
+		cache:            sync.Map{},
+		archive:          archive,
+		levelConfigUtils: levelConfigUtils,
 	}
 }
 
-func (cm *CollectMap) AddNewCollect(clusterName string, baseLogPath string) {
-	_, ok := cm.cache.Load(clusterName)
-	if !ok {
-		collect := NewCollect(baseLogPath, clusterName)
-		collect.StartCollect()
-		cm.cache.Store(clusterName, collect)
+// AddNewCollect 为集群创建采集器，已存在时只同步世界列表
+func (cm *CollectMap) AddNewCollect(clusterName string) {
+	if clusterName == "" {
+		return
+	}
+	collect := NewCollect(cm.archive.ClusterPath(clusterName), clusterName, cm.levelConfigUtils)
+	value, loaded := cm.cache.LoadOrStore(clusterName, collect)
+	if loaded {
+		value.(*Collect).Refresh()
+		return
 	}
+	collect.StartCollect()
 }
 
+// RemoveCollect 停止并移除集群的采集器
 func (cm *CollectMap) RemoveCollect(clusterName string) {
 	value, loaded := cm.cache.LoadAndDelete(clusterName)
 	if loaded {
 		value.(*Collect).Stop()
 	}
 }
+
+// ReloadCollect 重新创建集群的采集器，集群配置修改后使用
+func (cm *CollectMap) ReloadCollect(oldClusterName, clusterName string) {
+	cm.RemoveCollect(oldClusterName)
+	cm.RemoveCollect(clusterName)
+	cm.AddNewCollect(clusterName)
+}
+
+// RefreshCollect 集群的世界新增或删除后，同步采集的世界
+func (cm *CollectMap) RefreshCollect(clusterName string) {
+	cm.AddNewCollect(clusterName)
+}
@@ -17,16 +17,33 @@ type Config struct {
 	WanIP             string `yaml:"wanip"`
 	WhiteAdminIP      string `yaml:"whiteadminip"`
 	Token             string `yaml:"token"`
+	ModRepository     string `yaml:"modRepository"` // 本地模组仓库目录，所有集群共用
 	AutoUpdateModinfo struct {
 		Enable              bool `yaml:"enable"`
 		CheckInterval       int  `yaml:"checkInterval"`
 		UpdateCheckInterval int  `yaml:"updateCheckInterval"`
+		// Download 检测到更新后自动更新模组信息和配置并下载新版本
+		Download bool `yaml:"download"`
+		// Restart 模组下载后公告倒计时并重启使用该模组的集群，需要同时开启 Download
+		Restart bool `yaml:"restart"`
+		// Countdown 重启倒计时秒数，0 为默认值
+		Countdown int `yaml:"countdown"`
 	} `yaml:"autoUpdateModinfo"`
+	// Workshop 访问 Steam 创意工坊的配置，时间单位都是秒
+	Workshop struct {
+		BaseURL         string `yaml:"baseURL"`
+		Timeout         int    `yaml:"timeout"`
+		DownloadTimeout int    `yaml:"downloadTimeout"`
+		// Retries 重试次数，不配置时为 2，0 为不重试
+		Retries   *int    `yaml:"retries"`
+		RateLimit float64 `yaml:"rateLimit"`
+	} `yaml:"workshop"`
 }
 
 const (
-	ConfigPath  = "./config.yml"
-	DefaultPort = "8083"
+	ConfigPath           = "./config.yml"
+	DefaultPort          = "8083"
+	DefaultModRepository = "./mod-repository"
 )
 
 var Cfg *Config
@@ -44,12 +61,19 @@ func Load() *Config {
 	if c.Port == "" {
 		c.Port = DefaultPort
 	}
+	if c.ModRepository == "" {
+		c.ModRepository = DefaultModRepository
+	}
 	if c.AutoUpdateModinfo.UpdateCheckInterval == 0 {
 		c.AutoUpdateModinfo.UpdateCheckInterval = 10
 	}
 	if c.AutoUpdateModinfo.CheckInterval == 0 {
 		c.AutoUpdateModinfo.CheckInterval = 5
 	}
+	if c.Workshop.Retries == nil {
+		retries := 2
+		c.Workshop.Retries = &retries
+	}
 	Cfg = c
 	return c
 }
@@ -28,12 +28,18 @@ func InitDB(config *config.Config) *gorm.DB {
 		&model.ModInfo{},
 		&model.Cluster{},
 		&model.JobTask{},
+		&model.JobTaskRecord{},
 		&model.AutoCheck{},
 		&model.Announce{},
 		&model.WebLink{},
 		&model.BackupSnapshot{},
+		&model.BackupTarget{},
 		&model.LogRecord{},
 		&model.KV{},
+		&model.User{},
+		&model.UserCluster{},
+		&model.ApiToken{},
+		&model.Session{},
 	)
 	if err != nil {
 		log.Println("AutoMigrate error", err)
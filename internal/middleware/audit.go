@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/context"
+	"dst-admin-go/internal/service/audit"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditBodyLimit 记录到审计日志的请求体最大长度
+const auditBodyLimit = 1024
+
+// auditResponseLimit 用于判断操作结果的响应体最大长度
+const auditResponseLimit = 4096
+
+type auditRoute struct {
+	method string
+	prefix string
+	action model.Action
+	// withBody 是否记录请求体，包含密码的接口不能记录
+	withBody bool
+}
+
+// auditRoutes 需要审计的接口，按前缀匹配，越具体的越靠前；未匹配的 /api 写接口记录为 OPERATE
+var auditRoutes = []auditRoute{
+	{http.MethodGet, "/api/game/update", model.UPDATE_GAME, false},
+	{http.MethodPost, "/api/game/backup/restore", model.RESTORE_BACKUP, false},
+	{http.MethodPost, "/api/game/backup/remote/restore", model.RESTORE_BACKUP, true},
+	{http.MethodDelete, "/api/game/backup", model.DELETE_BACKUP, true},
+	{http.MethodPost, "/api/game/backup/snapshot/setting", model.SAVE_CONFIG, true},
+	// 远程存储设置的请求体包含密钥
+	{http.MethodPost, "/api/game/backup/remote/setting", model.SAVE_CONFIG, false},
+	{http.MethodPost, "/api/game/backup/remote/test", model.SAVE_CONFIG, false},
+	{http.MethodPost, "/api/game/backup", model.BACKUP, true},
+	{http.MethodPut, "/api/game/backup", model.BACKUP, true},
+	{http.MethodPost, "/api/game/8level/blacklist", model.BLACKLIST, true},
+	{http.MethodPost, "/api/game/8level/adminilist", model.SAVE_CONFIG, true},
+	{http.MethodPost, "/api/game/8level/whitelist", model.SAVE_CONFIG, true},
+	{http.MethodPost, "/api/game/8level/clusterIni", model.SAVE_CONFIG, false},
+	{http.MethodPost, "/api/game/config", model.SAVE_CONFIG, false},
+	{http.MethodPost, "/api/dst/config", model.SAVE_CONFIG, true},
+	{"", "/api/cluster/level", model.SAVE_CONFIG, false},
+	{"", "/api/mod", model.MOD_CHANGE, false},
+}
+
+// auditSkipList 不需要审计的写接口
+var auditSkipList = []string{"/api/login", "/api/logout", "/api/init"}
+
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	if remain := auditResponseLimit - w.body.Len(); remain > 0 {
+		if len(data) > remain {
+			w.body.Write(data[:remain])
+		} else {
+			w.body.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func matchAuditRoute(method, path string) (auditRoute, bool) {
+	for _, route := range auditRoutes {
+		if (route.method == "" || route.method == method) && strings.HasPrefix(path, route.prefix) {
+			if route.method == "" && method == http.MethodGet {
+				continue
+			}
+			return route, true
+		}
+	}
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+		return auditRoute{}, false
+	}
+	if !strings.HasPrefix(path, "/api") || apiFilter(auditSkipList, path) {
+		return auditRoute{}, false
+	}
+	return auditRoute{method: method, prefix: path, action: model.OPERATE}, true
+}
+
+// auditResult 根据 HTTP 状态码和响应体中的 code 判断操作是否成功
+func auditResult(status int, body []byte) error {
+	if status >= http.StatusBadRequest {
+		return errors.New(http.StatusText(status))
+	}
+	var result struct {
+		Code interface{} `json:"code"`
+		Msg  string      `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.Code == nil {
+		return nil
+	}
+	code := fmt.Sprint(result.Code)
+	if code == "200" || code == "0" {
+		return nil
+	}
+	return errors.New(result.Msg)
+}
+
+// Audit 审计中间件，记录写操作的操作人、IP、集群、世界和结果
+// handler 已通过 audit.OperatorFrom 自行记录的请求不会重复记录
+func Audit(auditService *audit.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, ok := matchAuditRoute(c.Request.Method, c.Request.URL.Path)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		target := c.Request.URL.RawQuery
+		if route.withBody && c.Request.Body != nil {
+			body, _ := io.ReadAll(io.LimitReader(c.Request.Body, auditBodyLimit+1))
+			rest, _ := io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), bytes.NewReader(rest)))
+			if len(body) > auditBodyLimit {
+				body = append(body[:auditBodyLimit], "..."...)
+			}
+			if len(body) > 0 {
+				if target != "" {
+					target += " "
+				}
+				target += string(body)
+			}
+		}
+
+		writer := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if audit.Recorded(c) {
+			return
+		}
+		operator := audit.OperatorFrom(c)
+		auditService.Record(operator, model.LogRecord{
+			Action:      route.action,
+			ClusterName: context.GetClusterName(c),
+			LevelName:   c.Query("levelName"),
+			Target:      target,
+		}, auditResult(writer.Status(), writer.body.Bytes()))
+	}
+}
@@ -1,7 +1,11 @@
 package middleware
 
 import (
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/response"
+	"dst-admin-go/internal/service/apiToken"
 	"dst-admin-go/internal/service/login"
+	"dst-admin-go/internal/service/user"
 	"log"
 	"net/http"
 	"strings"
@@ -26,13 +30,37 @@ func apiFilter(s []string, str string) bool {
 	}
 	return false
 }
-func Authentication(loginService *login.LoginService) gin.HandlerFunc {
+func Authentication(loginService *login.LoginService, apiTokenService *apiToken.ApiTokenService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		path := c.Request.URL.Path
 		if apiFilter(whitelist, path) {
 			c.Next()
 			return
 		}
+		// 脚本和机器人使用 Authorization: Bearer 令牌
+		if token := apiToken.BearerToken(c.Request); token != "" {
+			tokenUser, scopes, err := apiTokenService.Authenticate(token, c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, response.Response{
+					Code: 401,
+					Msg:  err.Error(),
+				})
+				c.Abort()
+				return
+			}
+			if !apiToken.Allow(scopes, c.Request.Method, path) {
+				c.JSON(http.StatusForbidden, response.Response{
+					Code: 403,
+					Msg:  "令牌没有权限",
+				})
+				c.Abort()
+				return
+			}
+			c.Set(userKey, tokenUser)
+			c.Set(authSourceKey, "token")
+			c.Next()
+			return
+		}
 		session := sessions.Default(c)
 		username := session.Get("username")
 		log.Println("username:", username)
@@ -40,13 +68,37 @@ func Authentication(loginService *login.LoginService) gin.HandlerFunc {
 			if loginService.IsWhiteIP(c) {
 				loginService.DirectLogin(c)
 				log.Println("white ip login", c.ClientIP())
-				c.Next()
+			} else {
+				c.AbortWithStatus(http.StatusUnauthorized)
 				return
 			}
+		}
+		user, err := loginService.CurrentUser(c)
+		if err != nil {
+			// 用户已被删除或改名
 			c.AbortWithStatus(http.StatusUnauthorized)
-		} else {
-			c.Next()
+			return
 		}
+		c.Set(userKey, user)
+		c.Set(authSourceKey, "web")
+		c.Next()
+	}
+}
+
+// RequireRole 要求当前用户的角色不低于 role
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get(userKey)
+		current, ok := value.(*model.User)
+		if !ok || !user.HasRole(current.Role, role) {
+			c.JSON(http.StatusForbidden, response.Response{
+				Code: 403,
+				Msg:  "没有权限",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
 	}
 }
 
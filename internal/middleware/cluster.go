@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"dst-admin-go/internal/model"
 	"dst-admin-go/internal/pkg/response"
+	"dst-admin-go/internal/service/apiToken"
 	"dst-admin-go/internal/service/dstConfig"
+	"dst-admin-go/internal/service/user"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,10 +14,17 @@ import (
 const (
 	clusterNameKey = "cluster_name"
 	dstConfigKey   = "dst_config"
+	userKey        = "user"
+	clusterRoleKey = "cluster_role"
+	authSourceKey  = "auth_source"
 )
 
+// selfServiceList 修改自己账号的接口，viewer 也可以调用
+var selfServiceList = []string{"/api/user", "/api/change/password", "/api/token", "/api/session"}
+
 // ClusterMiddleware 从 HTTP Header 解析 cluster 名称并加载配置到 context
-func ClusterMiddleware(dstConfigService dstConfig.Config) gin.HandlerFunc {
+// 并校验当前用户是否有该集群的权限，viewer 只能执行查询，是否为查询与令牌权限使用同一个判断
+func ClusterMiddleware(dstConfigService dstConfig.Config, userService *user.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		path := c.Request.URL.Path
@@ -37,8 +47,34 @@ func ClusterMiddleware(dstConfigService dstConfig.Config) gin.HandlerFunc {
 			return
 		}
 
+		// 校验集群权限
+		value, _ := c.Get(userKey)
+		current, ok := value.(*model.User)
+		if !ok {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		role, ok := userService.ClusterRole(current, config.Cluster)
+		if !ok {
+			c.JSON(http.StatusForbidden, response.Response{
+				Code: 403,
+				Msg:  "没有集群权限: " + config.Cluster,
+			})
+			c.Abort()
+			return
+		}
+		if !user.HasRole(role, user.RoleOperator) && !apiFilter(selfServiceList, path) && apiToken.IsWrite(c.Request.Method, path) {
+			c.JSON(http.StatusForbidden, response.Response{
+				Code: 403,
+				Msg:  "没有操作权限",
+			})
+			c.Abort()
+			return
+		}
+
 		// 将集群名称和配置注入到 context
 		c.Set(clusterNameKey, config.Cluster)
+		c.Set(clusterRoleKey, role)
 		c.Set(dstConfigKey, config)
 
 		c.Next()
@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"dst-admin-go/internal/service/apiToken"
+	"path"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WriteGET 注册会修改状态的 GET 接口，这些接口为兼容前端保留 GET 方法
+// 注册时同时登记到 apiToken，令牌权限和集群 viewer 权限按写接口处理
+func WriteGET(router *gin.RouterGroup, relativePath string, handlers ...gin.HandlerFunc) {
+	router.GET(relativePath, handlers...)
+	apiToken.RegisterWriteGet(path.Join(router.BasePath(), relativePath))
+}
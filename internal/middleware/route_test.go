@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"dst-admin-go/internal/service/apiToken"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWriteGET(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handler := func(c *gin.Context) {}
+	WriteGET(engine.Group(""), "/api/test/write/start", handler)
+	WriteGET(engine.Group("/api/test"), "/write/stop/", handler)
+	engine.GET("/api/test/read", handler)
+
+	tests := []struct {
+		method string
+		path   string
+		write  bool
+	}{
+		{http.MethodGet, "/api/test/write/start", true},
+		{http.MethodGet, "/api/test/write/start/", true},
+		{http.MethodGet, "/api/test/write/stop", true},
+		{http.MethodHead, "/api/test/write/stop", true},
+		{http.MethodGet, "/api/test/read", false},
+		{http.MethodPost, "/api/test/read", true},
+	}
+	for _, tt := range tests {
+		if got := apiToken.IsWrite(tt.method, tt.path); got != tt.write {
+			t.Errorf("IsWrite(%s, %s) = %v, want %v", tt.method, tt.path, got, tt.write)
+		}
+	}
+}
@@ -8,11 +8,55 @@ const (
 	RUN Action = iota
 	STOP
 	NORMAL
+	RESTART
+	UPDATE_GAME
+	UPDATE_MOD
+	COMMAND
+	SAVE_CONFIG
+	BACKUP
+	RESTORE_BACKUP
+	DELETE_BACKUP
+	MOD_CHANGE
+	BLACKLIST
+	OPERATE
 )
 
+var actionNames = map[Action]string{
+	RUN:            "RUN",
+	STOP:           "STOP",
+	NORMAL:         "NORMAL",
+	RESTART:        "RESTART",
+	UPDATE_GAME:    "UPDATE_GAME",
+	UPDATE_MOD:     "UPDATE_MOD",
+	COMMAND:        "COMMAND",
+	SAVE_CONFIG:    "SAVE_CONFIG",
+	BACKUP:         "BACKUP",
+	RESTORE_BACKUP: "RESTORE_BACKUP",
+	DELETE_BACKUP:  "DELETE_BACKUP",
+	MOD_CHANGE:     "MOD_CHANGE",
+	BLACKLIST:      "BLACKLIST",
+	OPERATE:        "OPERATE",
+}
+
+func (a Action) String() string {
+	if name, ok := actionNames[a]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// LogRecord 操作记录，同时作为审计日志，记录谁在什么时间从哪个 IP 对哪个集群/世界做了什么操作以及结果
 type LogRecord struct {
 	gorm.Model
-	Action      Action `json:"action"`
-	ClusterName string `json:"clusterName"`
+	Action      Action `gorm:"index" json:"action"`
+	ClusterName string `gorm:"index" json:"clusterName"`
 	LevelName   string `json:"levelName"`
+	Message     string `json:"message"`
+	Operator    string `gorm:"index" json:"operator"`
+	IP          string `json:"ip"`
+	Source      string `json:"source"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Target      string `json:"target"`
+	Success     bool   `json:"success"`
 }
@@ -4,6 +4,7 @@ import "gorm.io/gorm"
 
 type Announce struct {
 	gorm.Model
+	ClusterName  string `json:"clusterName"`
 	Enable       bool   `json:"enable"`
 	Frequency    int64  `json:"frequency"`
 	Interval     int64  `json:"interval"`
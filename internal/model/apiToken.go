@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ApiToken 脚本和机器人使用的 API 令牌，只保存令牌的 sha256
+type ApiToken struct {
+	gorm.Model
+	UserId     uint       `gorm:"index" json:"userId"`
+	Name       string     `json:"name"`
+	TokenHash  string     `gorm:"uniqueIndex" json:"-"`
+	Prefix     string     `json:"prefix"`
+	Scopes     string     `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	LastUsedIP string     `json:"lastUsedIp"`
+}
@@ -4,9 +4,13 @@ import "gorm.io/gorm"
 
 type BackupSnapshot struct {
 	gorm.Model
+	ClusterName  string `json:"clusterName"`
 	Name         string `json:"name"`
 	Interval     int    `json:"interval"`
 	MaxSnapshots int    `json:"maxSnapshots"`
-	Enable       int    `json:"enable"`
-	IsCSave      int    `json:"isCSave"`
+	// MaxAge 快照最长保留时间(小时)，0 表示不按时间清理
+	MaxAge  int    `json:"maxAge"`
+	Enable  int    `json:"enable"`
+	IsCSave int    `json:"isCSave"`
+	LastMd5 string `json:"lastMd5"`
 }
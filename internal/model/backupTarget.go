@@ -0,0 +1,40 @@
+package model
+
+import "gorm.io/gorm"
+
+// BackupTarget 集群的远程备份存储，每个集群一条
+type BackupTarget struct {
+	gorm.Model
+	ClusterName string `json:"clusterName"`
+	Enable      int    `json:"enable"`
+	// Type s3、sftp、webdav
+	Type string `json:"type"`
+	// Endpoint S3 为服务地址（如 http://127.0.0.1:9000），WebDAV 为服务地址，SFTP 为 host:port
+	Endpoint string `json:"endpoint"`
+	// Region S3 区域，MinIO 可以留空
+	Region string `json:"region"`
+	// Bucket S3 存储桶
+	Bucket string `json:"bucket"`
+	// PathStyle S3 使用 endpoint/bucket/key 形式的地址，MinIO 需要开启
+	PathStyle bool `json:"pathStyle"`
+	// Prefix 远程目录或对象前缀，备份保存在 Prefix/集群名称/ 下
+	Prefix string `json:"prefix"`
+	// Username S3 为 Access Key
+	Username string `json:"username"`
+	// Password S3 为 Secret Key
+	Password string `json:"password"`
+	// PrivateKey SFTP 的私钥（PEM），为空时使用密码登录
+	PrivateKey string `json:"privateKey"`
+	// HostKey SFTP 服务器公钥指纹（SHA256:...），SFTP 必须设置，除非开启 InsecureHostKey
+	HostKey string `json:"hostKey"`
+	// InsecureHostKey 不校验 SFTP 服务器身份，备份和密码可能发送给冒充的服务器，只在可信网络中使用
+	InsecureHostKey bool `json:"insecureHostKey"`
+	// Retries 上传失败的重试次数，0 为不重试
+	Retries int `json:"retries"`
+	// Timeout 单次请求超时时间(秒)
+	Timeout int `json:"timeout"`
+	// MaxBackups 远程最多保留的备份数，0 表示不限制
+	MaxBackups int `json:"maxBackups"`
+	// MaxAge 远程备份最长保留时间(小时)，0 表示不按时间清理
+	MaxAge int `json:"maxAge"`
+}
@@ -14,4 +14,5 @@ type JobTask struct {
 	Sleep        int    `json:"sleep"`
 	Times        int    `json:"times"`
 	Script       int    `json:"script"`
+	Command      string `json:"command"`
 }
@@ -0,0 +1,15 @@
+package model
+
+import "gorm.io/gorm"
+
+type JobTaskRecord struct {
+	gorm.Model
+	JobTaskId   uint   `json:"jobTaskId"`
+	ClusterName string `json:"clusterName"`
+	LevelName   string `json:"levelName"`
+	Category    string `json:"category"`
+	Comment     string `json:"comment"`
+	Success     bool   `json:"success"`
+	Message     string `json:"message"`
+	Duration    int64  `json:"duration"`
+}
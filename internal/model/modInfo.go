@@ -16,4 +16,10 @@ type ModInfo struct {
 	Name          string  `json:"name"`
 	V             string  `json:"v"`
 	Update        bool    `json:"update"`
+	// Child 创意工坊中声明的依赖模组，多个 id 用逗号分隔
+	Child string `json:"child"`
+	// Pinned 固定版本，自动更新和批量更新时跳过
+	Pinned bool `gorm:"default:false" json:"pinned"`
+	// PinnedVersion 固定的模组仓库版本，重启前从模组仓库安装该版本
+	PinnedVersion string `json:"pinnedVersion"`
 }
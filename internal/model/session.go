@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Session 登录会话，面板重启后仍然有效
+type Session struct {
+	ID        string    `gorm:"primaryKey" json:"id"`
+	Username  string    `gorm:"index" json:"username"`
+	Data      string    `json:"-"`
+	Device    string    `json:"device"`
+	UserAgent string    `json:"userAgent"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"createdAt"`
+	LastSeen  time.Time `json:"lastSeen"`
+	ExpiresAt time.Time `gorm:"index" json:"expiresAt"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "gorm.io/gorm"
+
+type User struct {
+	gorm.Model
+	Username    string `gorm:"uniqueIndex" json:"username"`
+	Password    string `json:"-"`
+	DisplayName string `json:"displayName"`
+	PhotoURL    string `json:"photoURL"`
+	Role        string `json:"role"`
+}
@@ -0,0 +1,11 @@
+package model
+
+import "gorm.io/gorm"
+
+// UserCluster 用户的集群授权，Role 为空时使用用户自身的角色
+type UserCluster struct {
+	gorm.Model
+	UserId      uint   `gorm:"index" json:"userId"`
+	ClusterName string `json:"clusterName"`
+	Role        string `json:"role"`
+}
@@ -1,6 +1,7 @@
 package context
 
 import (
+	"dst-admin-go/internal/model"
 	"dst-admin-go/internal/service/dstConfig"
 
 	"github.com/gin-gonic/gin"
@@ -9,6 +10,9 @@ import (
 const (
 	clusterNameKey = "cluster_name"
 	dstConfigKey   = "dst_config"
+	userKey        = "user"
+	clusterRoleKey = "cluster_role"
+	authSourceKey  = "auth_source"
 )
 
 // GetClusterName 从 gin.Context 获取集群名称
@@ -32,3 +36,31 @@ func GetDstConfig(c *gin.Context) *dstConfig.DstConfig {
 	}
 	return nil
 }
+
+// GetUser 从 gin.Context 获取当前登录的用户
+// 需要配合 Authentication 使用
+func GetUser(c *gin.Context) *model.User {
+	if value, exists := c.Get(userKey); exists {
+		if user, ok := value.(*model.User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+// GetClusterRole 从 gin.Context 获取当前用户在集群上的角色
+// 需要配合 ClusterMiddleware 使用
+func GetClusterRole(c *gin.Context) string {
+	if value, exists := c.Get(clusterRoleKey); exists {
+		if role, ok := value.(string); ok {
+			return role
+		}
+	}
+	return ""
+}
+
+// GetAuthSource 从 gin.Context 获取当前请求的认证方式，web 或 token
+// 需要配合 Authentication 使用
+func GetAuthSource(c *gin.Context) string {
+	return c.GetString(authSourceKey)
+}
@@ -24,6 +24,27 @@ func EscapePath(path string) string {
 	return path
 }
 
+// EscapeLuaString 转义 Lua 双引号字符串中的特殊字符，中文等多字节字符原样保留
+func EscapeLuaString(content string) string {
+	replacer := strings.NewReplacer(
+		"\\", "\\\\",
+		"\"", "\\\"",
+		"\r", "",
+		"\n", "\\n",
+	)
+	return replacer.Replace(content)
+}
+
+// AnnounceCommand 生成游戏内公告命令 c_announce("...")
+func AnnounceCommand(content string) string {
+	return "c_announce(\"" + EscapeLuaString(content) + "\")"
+}
+
+// SystemMessageCommand 生成系统消息命令 TheNet:SystemMessage("...")
+func SystemMessageCommand(content string) string {
+	return "TheNet:SystemMessage(\"" + EscapeLuaString(content) + "\")"
+}
+
 func WorkshopIds(content string) []string {
 	var workshopIds []string
 
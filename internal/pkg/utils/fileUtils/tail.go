@@ -0,0 +1,110 @@
+package fileUtils
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrWaitTimeout 等待日志超时
+var ErrWaitTimeout = errors.New("等待日志超时")
+
+// tailPollInterval 轮询文件新内容的间隔
+const tailPollInterval = 100 * time.Millisecond
+
+// FileSize 文件大小，文件不存在时返回 0
+func FileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// WaitForLine 从 offset 开始读取文件新增的行，直到 match 返回 true 或超时
+// 文件被截断（例如世界重启重新生成日志）时从头开始读取
+func WaitForLine(ctx context.Context, path string, offset int64, timeout time.Duration, match func(line string) bool) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+	partial := ""
+	for {
+		line, newOffset, found, err := scanLines(path, offset, &partial, match)
+		if err == nil {
+			offset = newOffset
+			if found {
+				return line, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return "", ErrWaitTimeout
+			}
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// FollowLines 从 offset 开始持续读取文件新增的行并交给 handle 处理，直到 ctx 结束
+// 文件被截断时从头开始读取
+func FollowLines(ctx context.Context, path string, offset int64, handle func(line string)) {
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+	partial := ""
+	for {
+		_, newOffset, _, err := scanLines(path, offset, &partial, func(line string) bool {
+			handle(line)
+			return false
+		})
+		if err == nil {
+			offset = newOffset
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scanLines 读取 offset 之后的完整行，未以换行结尾的内容保存在 partial 中等待下次读取
+func scanLines(path string, offset int64, partial *string, match func(line string) bool) (string, int64, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", offset, false, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", offset, false, err
+	}
+	if info.Size() < offset {
+		offset = 0
+		*partial = ""
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return "", offset, false, err
+	}
+	reader := bufio.NewReader(file)
+	for {
+		data, err := reader.ReadString('\n')
+		offset += int64(len(data))
+		if err != nil {
+			*partial += data
+			return "", offset, false, nil
+		}
+		line := strings.TrimRight(*partial+data, "\r\n")
+		*partial = ""
+		if match(line) {
+			return line, offset, true, nil
+		}
+	}
+}
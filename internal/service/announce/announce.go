@@ -0,0 +1,271 @@
+package announce
+
+import (
+	"context"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/utils/dstUtils"
+	"dst-admin-go/internal/service/game"
+	"dst-admin-go/internal/service/levelConfig"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AnnounceMethod = "announce"
+	SystemMethod   = "system"
+)
+
+// AnnounceService 定时公告服务，按间隔向集群运行中的世界发送公告
+type AnnounceService struct {
+	db               *gorm.DB
+	gameProcess      game.Process
+	levelConfigUtils *levelConfig.LevelConfigUtils
+
+	cancels  map[uint]context.CancelFunc
+	clusters map[uint]string
+	mu       sync.Mutex
+}
+
+func NewAnnounceService(db *gorm.DB, gameProcess game.Process, levelConfigUtils *levelConfig.LevelConfigUtils) *AnnounceService {
+	return &AnnounceService{
+		db:               db,
+		gameProcess:      gameProcess,
+		levelConfigUtils: levelConfigUtils,
+		cancels:          map[uint]context.CancelFunc{},
+		clusters:         map[uint]string{},
+	}
+}
+
+// Start 启动所有已开启的公告
+func (s *AnnounceService) Start() {
+	var announces []model.Announce
+	if err := s.db.Where("enable = ?", true).Find(&announces).Error; err != nil {
+		log.Println("[Announce]加载公告失败", err)
+		return
+	}
+	for i := range announces {
+		s.run(announces[i])
+	}
+	log.Println("[Announce]定时公告已启动，公告数:", len(announces))
+}
+
+// StartCluster 集群启动时开始该集群未在发送中的公告，发送次数重新计算
+func (s *AnnounceService) StartCluster(clusterName string) {
+	var announces []model.Announce
+	s.db.Where("cluster_name = ? AND enable = ?", clusterName, true).Find(&announces)
+	for i := range announces {
+		s.mu.Lock()
+		_, running := s.cancels[announces[i].ID]
+		s.mu.Unlock()
+		if !running {
+			s.run(announces[i])
+		}
+	}
+}
+
+// StopCluster 集群停止时停止该集群的所有公告
+func (s *AnnounceService) StopCluster(clusterName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, name := range s.clusters {
+		if name != clusterName {
+			continue
+		}
+		if cancel, ok := s.cancels[id]; ok {
+			cancel()
+		}
+		delete(s.cancels, id)
+		delete(s.clusters, id)
+	}
+}
+
+// GetAnnounceList 获取集群的公告列表
+func (s *AnnounceService) GetAnnounceList(clusterName string) []model.Announce {
+	announces := make([]model.Announce, 0)
+	s.db.Where("cluster_name = ?", clusterName).Order("id asc").Find(&announces)
+	return announces
+}
+
+// CreateAnnounce 创建公告
+func (s *AnnounceService) CreateAnnounce(announce *model.Announce) error {
+	if err := validate(announce); err != nil {
+		return err
+	}
+	announce.ID = 0
+	if err := s.db.Create(announce).Error; err != nil {
+		return err
+	}
+	s.run(*announce)
+	return nil
+}
+
+// UpdateAnnounce 更新集群的公告，并按新配置重新开始发送
+func (s *AnnounceService) UpdateAnnounce(clusterName string, announce *model.Announce) error {
+	if err := validate(announce); err != nil {
+		return err
+	}
+	oldAnnounce := model.Announce{}
+	if err := s.db.Where("cluster_name = ?", clusterName).First(&oldAnnounce, announce.ID).Error; err != nil {
+		return errors.New("公告不存在")
+	}
+	oldAnnounce.Enable = announce.Enable
+	oldAnnounce.Frequency = announce.Frequency
+	oldAnnounce.Interval = announce.Interval
+	oldAnnounce.IntervalUnit = announce.IntervalUnit
+	oldAnnounce.Method = announce.Method
+	oldAnnounce.Content = announce.Content
+	if err := s.db.Save(&oldAnnounce).Error; err != nil {
+		return err
+	}
+	*announce = oldAnnounce
+	s.run(oldAnnounce)
+	return nil
+}
+
+// DeleteAnnounce 删除集群的公告
+func (s *AnnounceService) DeleteAnnounce(clusterName string, id uint) error {
+	announce := model.Announce{}
+	if err := s.db.Where("cluster_name = ?", clusterName).First(&announce, id).Error; err != nil {
+		return errors.New("公告不存在")
+	}
+	s.cancel(id)
+	return s.db.Where("cluster_name = ?", clusterName).Delete(&model.Announce{}, id).Error
+}
+
+// Preview 生成公告对应的游戏命令
+func (s *AnnounceService) Preview(method, content string) string {
+	if method == SystemMethod {
+		return dstUtils.SystemMessageCommand(content)
+	}
+	return dstUtils.AnnounceCommand(content)
+}
+
+// Send 立即向集群所有运行中的世界发送公告，返回发送成功的世界
+func (s *AnnounceService) Send(clusterName, method, content string) ([]string, error) {
+	if content == "" {
+		return nil, errors.New("公告内容不能为空")
+	}
+	command := s.Preview(method, content)
+	levels := s.runningLevels(clusterName)
+	if len(levels) == 0 {
+		return nil, errors.New("没有运行中的世界")
+	}
+	var sent []string
+	var lastErr error
+	for _, levelName := range levels {
+		if err := s.gameProcess.Command(clusterName, levelName, command); err != nil {
+			log.Println("[Announce]发送公告失败", "cluster:", clusterName, "level:", levelName, err)
+			lastErr = err
+			continue
+		}
+		sent = append(sent, levelName)
+	}
+	if len(sent) == 0 {
+		return nil, lastErr
+	}
+	return sent, nil
+}
+
+func validate(announce *model.Announce) error {
+	if announce.Content == "" {
+		return errors.New("公告内容不能为空")
+	}
+	if announce.Interval <= 0 {
+		return errors.New("公告间隔必须大于 0")
+	}
+	if intervalUnit(announce.IntervalUnit) == 0 {
+		return errors.New("不支持的间隔单位: " + announce.IntervalUnit)
+	}
+	switch announce.Method {
+	case "", AnnounceMethod, SystemMethod:
+	default:
+		return errors.New("不支持的公告方式: " + announce.Method)
+	}
+	return nil
+}
+
+func intervalUnit(unit string) time.Duration {
+	switch unit {
+	case "s", "second":
+		return time.Second
+	case "", "m", "minute":
+		return time.Minute
+	case "h", "hour":
+		return time.Hour
+	case "d", "day":
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// run 启动公告协程，已存在的协程会先被取消，Frequency 大于 0 时最多发送 Frequency 次
+func (s *AnnounceService) run(announce model.Announce) {
+	s.cancel(announce.ID)
+	if !announce.Enable || validate(&announce) != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancels[announce.ID] = cancel
+	s.clusters[announce.ID] = announce.ClusterName
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(announce.Interval) * intervalUnit(announce.IntervalUnit))
+		defer ticker.Stop()
+		var times int64
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// 集群未运行时不计入发送次数
+				if _, err := s.Send(announce.ClusterName, announce.Method, announce.Content); err != nil {
+					continue
+				}
+				times++
+				if announce.Frequency > 0 && times >= announce.Frequency {
+					log.Println("[Announce]公告已达到发送次数", "id:", announce.ID, "times:", times)
+					// 移出发送中的公告，集群再次启动时 StartCluster 重新开始发送
+					s.mu.Lock()
+					if ctx.Err() == nil {
+						cancel()
+						delete(s.cancels, announce.ID)
+						delete(s.clusters, announce.ID)
+					}
+					s.mu.Unlock()
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (s *AnnounceService) cancel(id uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.cancels[id]; ok {
+		cancel()
+		delete(s.cancels, id)
+		delete(s.clusters, id)
+	}
+}
+
+func (s *AnnounceService) runningLevels(clusterName string) []string {
+	config, err := s.levelConfigUtils.GetLevelConfig(clusterName)
+	if err != nil {
+		return []string{}
+	}
+	var levels []string
+	for _, item := range config.LevelList {
+		if running, _ := s.gameProcess.Status(clusterName, item.File); running {
+			levels = append(levels, item.File)
+		}
+	}
+	return levels
+}
@@ -0,0 +1,265 @@
+package apiToken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"dst-admin-go/internal/config"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/service/user"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// ScopeAll 所有权限
+	ScopeAll = "*"
+	// ScopeRead 查询接口
+	ScopeRead = "read"
+	// ScopeCommand 发送游戏命令和公告
+	ScopeCommand = "command"
+	// ScopeGame 启动、停止、更新游戏和修改游戏配置
+	ScopeGame = "game"
+	// ScopeBackup 备份和快照
+	ScopeBackup = "backup"
+	// ScopeMod 模组
+	ScopeMod = "mod"
+)
+
+const tokenPrefix = "dst_"
+
+// lastUsedInterval 最近使用时间的更新间隔，避免每个请求都写数据库
+const lastUsedInterval = time.Minute
+
+var validScopes = map[string]bool{
+	ScopeAll:     true,
+	ScopeRead:    true,
+	ScopeCommand: true,
+	ScopeGame:    true,
+	ScopeBackup:  true,
+	ScopeMod:     true,
+}
+
+// scopeRoutes 写接口对应的权限，按前缀匹配，越具体的越靠前
+var scopeRoutes = []struct {
+	prefix string
+	scope  string
+}{
+	{"/api/game/8level/command", ScopeCommand},
+	{"/api/game/8level/lua", ScopeCommand},
+	{"/api/announce/send", ScopeCommand},
+	{"/api/game/update", ScopeGame},
+	{"/api/dst/map/gen", ScopeGame},
+	{"/api/game/backup", ScopeBackup},
+	{"/api/mod", ScopeMod},
+	{"/api/game", ScopeGame},
+	{"/api/cluster", ScopeGame},
+}
+
+// writeGetRoutes 会修改状态的 GET 接口，由 middleware.WriteGET 在注册路由时登记，不能按请求方法当作查询
+var (
+	writeGetMu     sync.RWMutex
+	writeGetRoutes = map[string]bool{}
+)
+
+// RegisterWriteGet 登记会修改状态的 GET 接口，path 为完整路径
+func RegisterWriteGet(path string) {
+	writeGetMu.Lock()
+	defer writeGetMu.Unlock()
+	writeGetRoutes[strings.TrimSuffix(path, "/")] = true
+}
+
+// IsWrite 请求是否会修改状态，除 writeGetRoutes 外的 GET 和 HEAD 请求为查询
+// 令牌权限和集群 viewer 权限都按这个判断
+func IsWrite(method, path string) bool {
+	if method != http.MethodGet && method != http.MethodHead {
+		return true
+	}
+	writeGetMu.RLock()
+	defer writeGetMu.RUnlock()
+	return writeGetRoutes[strings.TrimSuffix(path, "/")]
+}
+
+// managementRoutes 令牌和用户管理接口，只能通过登录访问，按前缀匹配
+var managementRoutes = []string{"/api/token", "/api/users", "/api/session", "/api/change/password"}
+
+// managementPaths 只能通过登录访问的接口，完全匹配，/api/user 可以修改当前用户的用户名和密码
+var managementPaths = map[string]bool{"/api/user": true}
+
+type ApiTokenService struct {
+	db          *gorm.DB
+	config      *config.Config
+	userService *user.UserService
+}
+
+// TokenDetail 创建令牌后返回，Token 明文只返回这一次
+type TokenDetail struct {
+	model.ApiToken
+	Token string `json:"token"`
+}
+
+func NewApiTokenService(db *gorm.DB, config *config.Config, userService *user.UserService) *ApiTokenService {
+	return &ApiTokenService{
+		db:          db,
+		config:      config,
+		userService: userService,
+	}
+}
+
+// BearerToken 从 Authorization 头解析 Bearer 令牌
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeScopes(scopes []string) (string, error) {
+	var list []string
+	seen := map[string]bool{}
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || seen[scope] {
+			continue
+		}
+		if !validScopes[scope] {
+			return "", errors.New("不支持的权限: " + scope)
+		}
+		seen[scope] = true
+		list = append(list, scope)
+	}
+	if len(list) == 0 {
+		return "", errors.New("至少需要一个权限")
+	}
+	return strings.Join(list, ","), nil
+}
+
+// GetTokenList 获取用户的令牌列表
+func (s *ApiTokenService) GetTokenList(userId uint) []model.ApiToken {
+	tokens := make([]model.ApiToken, 0)
+	s.db.Where("user_id = ?", userId).Order("id asc").Find(&tokens)
+	return tokens
+}
+
+// CreateToken 创建令牌，expireDays 为 0 时永不过期
+func (s *ApiTokenService) CreateToken(userId uint, name string, scopes []string, expireDays int) (*TokenDetail, error) {
+	if name == "" {
+		return nil, errors.New("令牌名称不能为空")
+	}
+	if expireDays < 0 {
+		return nil, errors.New("过期天数不能小于 0")
+	}
+	scope, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	token := tokenPrefix + hex.EncodeToString(buf)
+
+	apiToken := model.ApiToken{
+		UserId:    userId,
+		Name:      name,
+		TokenHash: hashToken(token),
+		Prefix:    token[:len(tokenPrefix)+6],
+		Scopes:    scope,
+	}
+	if expireDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, expireDays)
+		apiToken.ExpiresAt = &expiresAt
+	}
+	if err := s.db.Create(&apiToken).Error; err != nil {
+		return nil, err
+	}
+	return &TokenDetail{ApiToken: apiToken, Token: token}, nil
+}
+
+// RevokeToken 撤销令牌，userId 为 0 时可以撤销任意用户的令牌
+func (s *ApiTokenService) RevokeToken(userId uint, id uint) error {
+	apiToken := model.ApiToken{}
+	if err := s.db.First(&apiToken, id).Error; err != nil {
+		return errors.New("令牌不存在")
+	}
+	if userId != 0 && apiToken.UserId != userId {
+		return errors.New("令牌不存在")
+	}
+	return s.db.Unscoped().Delete(&apiToken).Error
+}
+
+// Authenticate 校验令牌，返回令牌所属用户和权限
+// config.yml 中配置的 token 视为 owner 的全部权限令牌
+func (s *ApiTokenService) Authenticate(token, ip string) (*model.User, []string, error) {
+	if token == "" {
+		return nil, nil, errors.New("令牌不能为空")
+	}
+	if s.config.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Token)) == 1 {
+		owner, err := s.userService.GetOwner()
+		if err != nil {
+			return nil, nil, errors.New("还没有初始化用户")
+		}
+		return owner, []string{ScopeAll}, nil
+	}
+
+	apiToken := model.ApiToken{}
+	if err := s.db.Where("token_hash = ?", hashToken(token)).First(&apiToken).Error; err != nil {
+		return nil, nil, errors.New("令牌无效")
+	}
+	now := time.Now()
+	if apiToken.ExpiresAt != nil && now.After(*apiToken.ExpiresAt) {
+		return nil, nil, errors.New("令牌已过期")
+	}
+	tokenUser, err := s.userService.GetUserById(apiToken.UserId)
+	if err != nil {
+		return nil, nil, errors.New("令牌所属用户不存在")
+	}
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) > lastUsedInterval || apiToken.LastUsedIP != ip {
+		s.db.Model(&apiToken).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ip,
+		})
+	}
+	return tokenUser, strings.Split(apiToken.Scopes, ","), nil
+}
+
+// Allow 令牌权限是否允许访问接口，查询接口需要 read 权限，写接口按 scopeRoutes 匹配，没有匹配的需要所有权限
+func Allow(scopes []string, method, path string) bool {
+	if managementPaths[strings.TrimSuffix(path, "/")] {
+		return false
+	}
+	for _, prefix := range managementRoutes {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	required := ScopeAll
+	if !IsWrite(method, path) {
+		required = ScopeRead
+	} else {
+		for _, route := range scopeRoutes {
+			if strings.HasPrefix(path, route.prefix) {
+				required = route.scope
+				break
+			}
+		}
+	}
+	for _, scope := range scopes {
+		if scope == ScopeAll || scope == required {
+			return true
+		}
+	}
+	return false
+}
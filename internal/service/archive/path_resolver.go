@@ -126,6 +126,11 @@ func (r *PathResolver) ServerLogPath(cluster string, levelName string) string {
 	return filepath.Join(r.LevelPath(cluster, levelName), "server_log.txt")
 }
 
+// SessionPath 世界存档的 session 目录，每次保存会在其中写入新的 .meta 文件
+func (r *PathResolver) SessionPath(cluster string, levelName string) string {
+	return filepath.Join(r.LevelPath(cluster, levelName), "save", "session")
+}
+
 func (r *PathResolver) GetUgcWorkshopModPath(clusterName, levelName, workshopId string) string {
 	// dstConfig := dstConfigUtils.GetDstConfig()
 	config, _ := r.dstConfig.GetDstConfig(clusterName)
@@ -196,10 +201,13 @@ func (r *PathResolver) GetLastDstVersion() (int64, error) {
 	resp, err := http.Get(url)
 	if err != nil {
 		log.Println(err)
+		return 0, err
 	}
+	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Println(err)
+		return 0, err
 	}
 	s := string(body)
 	veriosn, err := strconv.Atoi(s)
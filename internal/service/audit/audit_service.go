@@ -0,0 +1,217 @@
+package audit
+
+import (
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/context"
+	"encoding/csv"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	SourceWeb    = "web"
+	SourceToken  = "token"
+	SourceSystem = "system"
+)
+
+// recordedKey 请求已由 handler 自行记录审计日志，审计中间件不再重复记录
+const recordedKey = "audit_recorded"
+
+// exportLimit CSV 导出的最大条数
+const exportLimit = 100000
+
+// Operator 操作人
+type Operator struct {
+	Username string
+	IP       string
+	Source   string
+	Method   string
+	Path     string
+}
+
+// System 后台任务的操作人，例如 schedule、autoCheck
+func System(name string) Operator {
+	return Operator{
+		Username: name,
+		Source:   SourceSystem,
+	}
+}
+
+// OperatorFrom 从请求中获取操作人，handler 自行记录审计日志时使用，同时标记该请求已记录
+func OperatorFrom(ctx *gin.Context) Operator {
+	ctx.Set(recordedKey, true)
+	operator := Operator{
+		IP:     ctx.ClientIP(),
+		Source: context.GetAuthSource(ctx),
+		Method: ctx.Request.Method,
+		Path:   ctx.Request.URL.Path,
+	}
+	if current := context.GetUser(ctx); current != nil {
+		operator.Username = current.Username
+	}
+	return operator
+}
+
+// Recorded 请求是否已由 handler 记录审计日志
+func Recorded(ctx *gin.Context) bool {
+	return ctx.GetBool(recordedKey)
+}
+
+// Filter 审计日志查询条件，零值表示不过滤
+type Filter struct {
+	ClusterName string
+	LevelName   string
+	Operator    string
+	Source      string
+	Actions     []model.Action
+	Success     *bool
+	Keyword     string
+	Start       time.Time
+	End         time.Time
+}
+
+type AuditService struct {
+	db *gorm.DB
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{
+		db: db,
+	}
+}
+
+// Record 保存审计日志，err 不为空时记录为失败
+func (s *AuditService) Record(operator Operator, record model.LogRecord, err error) {
+	record.ID = 0
+	record.Operator = operator.Username
+	record.IP = operator.IP
+	record.Source = operator.Source
+	if record.Method == "" {
+		record.Method = operator.Method
+	}
+	if record.Path == "" {
+		record.Path = operator.Path
+	}
+	record.Success = err == nil
+	if err != nil {
+		if record.Message == "" {
+			record.Message = err.Error()
+		} else {
+			record.Message = record.Message + ": " + err.Error()
+		}
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		log.Println("[Audit]保存审计日志失败", err)
+	}
+}
+
+func (s *AuditService) query(filter Filter) *gorm.DB {
+	db := s.db.Model(&model.LogRecord{})
+	if filter.ClusterName != "" {
+		db = db.Where("cluster_name = ?", filter.ClusterName)
+	}
+	if filter.LevelName != "" {
+		db = db.Where("level_name = ?", filter.LevelName)
+	}
+	if filter.Operator != "" {
+		db = db.Where("operator = ?", filter.Operator)
+	}
+	if filter.Source != "" {
+		db = db.Where("source = ?", filter.Source)
+	}
+	if len(filter.Actions) > 0 {
+		db = db.Where("action IN ?", filter.Actions)
+	}
+	if filter.Success != nil {
+		db = db.Where("success = ?", *filter.Success)
+	}
+	if filter.Keyword != "" {
+		keyword := "%" + filter.Keyword + "%"
+		db = db.Where("message LIKE ? OR target LIKE ? OR path LIKE ?", keyword, keyword, keyword)
+	}
+	if !filter.Start.IsZero() {
+		db = db.Where("created_at >= ?", filter.Start)
+	}
+	if !filter.End.IsZero() {
+		db = db.Where("created_at <= ?", filter.End)
+	}
+	return db
+}
+
+// GetAuditPage 分页查询审计日志
+func (s *AuditService) GetAuditPage(filter Filter, page, size int) ([]model.LogRecord, int64) {
+	var total int64
+	s.query(filter).Count(&total)
+
+	records := make([]model.LogRecord, 0)
+	s.query(filter).Order("id desc").Limit(size).Offset((page - 1) * size).Find(&records)
+	return records, total
+}
+
+// ExportCSV 按查询条件导出审计日志
+func (s *AuditService) ExportCSV(w io.Writer, filter Filter) error {
+	var records []model.LogRecord
+	if err := s.query(filter).Order("id desc").Limit(exportLimit).Find(&records).Error; err != nil {
+		return err
+	}
+	// 写入 BOM，Excel 打开时中文不乱码
+	if _, err := io.WriteString(w, "\xEF\xBB\xBF"); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	header := []string{"id", "time", "operator", "ip", "source", "cluster", "level", "action", "method", "path", "target", "success", "message"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for i := range records {
+		record := records[i]
+		row := []string{
+			strconv.FormatUint(uint64(record.ID), 10),
+			record.CreatedAt.Format("2006-01-02 15:04:05"),
+			record.Operator,
+			record.IP,
+			record.Source,
+			record.ClusterName,
+			record.LevelName,
+			record.Action.String(),
+			record.Method,
+			record.Path,
+			record.Target,
+			strconv.FormatBool(record.Success),
+			record.Message,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ParseActions 解析逗号分隔的操作类型，支持名称和数字
+func ParseActions(value string) []model.Action {
+	var actions []model.Action
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(item); err == nil {
+			actions = append(actions, model.Action(n))
+			continue
+		}
+		for action := model.RUN; action <= model.OPERATE; action++ {
+			if strings.EqualFold(action.String(), item) {
+				actions = append(actions, action)
+				break
+			}
+		}
+	}
+	return actions
+}
@@ -0,0 +1,471 @@
+package autoCheck
+
+import (
+	"context"
+	"dst-admin-go/internal/config"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/utils/dstUtils"
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"dst-admin-go/internal/service/archive"
+	"dst-admin-go/internal/service/audit"
+	"dst-admin-go/internal/service/game"
+	"dst-admin-go/internal/service/levelConfig"
+	"dst-admin-go/internal/service/mod"
+	"dst-admin-go/internal/service/update"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	LevelDown  = "LEVEL_DOWN"
+	GameUpdate = "GAME_UPDATE"
+	ModUpdate  = "MOD_UPDATE"
+)
+
+// systemOperator 自动检测在审计日志中的操作人
+var systemOperator = audit.System("autoCheck")
+
+// maxRestartTimes 世界连续重启失败的最大次数，超过后不再自动重启，直到手动启动
+const maxRestartTimes = 3
+
+// AutoCheckService 自动检测服务，定时检测世界宕机、游戏更新和模组更新
+type AutoCheckService struct {
+	db               *gorm.DB
+	config           *config.Config
+	gameProcess      game.Process
+	updateService    update.Update
+	archive          *archive.PathResolver
+	levelConfigUtils *levelConfig.LevelConfigUtils
+	modService       *mod.ModService
+	auditService     *audit.AuditService
+
+	cancels      map[uint]context.CancelFunc
+	failures     map[string]int
+	clusterLocks map[string]*sync.Mutex
+	mu           sync.Mutex
+}
+
+func NewAutoCheckService(db *gorm.DB, config *config.Config, gameProcess game.Process, updateService update.Update, archive *archive.PathResolver, levelConfigUtils *levelConfig.LevelConfigUtils, modService *mod.ModService, auditService *audit.AuditService) *AutoCheckService {
+	return &AutoCheckService{
+		db:               db,
+		config:           config,
+		gameProcess:      gameProcess,
+		updateService:    updateService,
+		archive:          archive,
+		levelConfigUtils: levelConfigUtils,
+		modService:       modService,
+		auditService:     auditService,
+		cancels:          map[uint]context.CancelFunc{},
+		failures:         map[string]int{},
+		clusterLocks:     map[string]*sync.Mutex{},
+	}
+}
+
+// Start 启动所有已开启的自动检测
+func (s *AutoCheckService) Start() {
+	var autoChecks []model.AutoCheck
+	if err := s.db.Where("enable = ?", 1).Find(&autoChecks).Error; err != nil {
+		log.Println("[AutoCheck]加载自动检测失败", err)
+		return
+	}
+	for i := range autoChecks {
+		s.run(autoChecks[i])
+	}
+	log.Println("[AutoCheck]自动检测已启动，检测数:", len(autoChecks))
+}
+
+// ReloadCluster 按数据库中的配置重新启动集群的自动检测，集群重命名后使用
+func (s *AutoCheckService) ReloadCluster(clusterName string) {
+	for _, autoCheck := range s.GetAutoCheckList(clusterName) {
+		s.run(autoCheck)
+	}
+}
+
+// GetAutoCheckList 获取集群的自动检测列表
+func (s *AutoCheckService) GetAutoCheckList(clusterName string) []model.AutoCheck {
+	autoChecks := make([]model.AutoCheck, 0)
+	s.db.Where("cluster_name = ?", clusterName).Order("id asc").Find(&autoChecks)
+	return autoChecks
+}
+
+// CreateAutoCheck 创建自动检测
+func (s *AutoCheckService) CreateAutoCheck(autoCheck *model.AutoCheck) error {
+	if err := validate(autoCheck); err != nil {
+		return err
+	}
+	autoCheck.ID = 0
+	autoCheck.Uuid = "check_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := s.db.Create(autoCheck).Error; err != nil {
+		return err
+	}
+	s.run(*autoCheck)
+	return nil
+}
+
+// UpdateAutoCheck 更新集群的自动检测，并按新配置重新启动
+func (s *AutoCheckService) UpdateAutoCheck(clusterName string, autoCheck *model.AutoCheck) error {
+	if err := validate(autoCheck); err != nil {
+		return err
+	}
+	oldAutoCheck := model.AutoCheck{}
+	if err := s.db.Where("cluster_name = ?", clusterName).First(&oldAutoCheck, autoCheck.ID).Error; err != nil {
+		return errors.New("自动检测不存在")
+	}
+	oldAutoCheck.Name = autoCheck.Name
+	oldAutoCheck.LevelName = autoCheck.LevelName
+	oldAutoCheck.Enable = autoCheck.Enable
+	oldAutoCheck.Announcement = autoCheck.Announcement
+	oldAutoCheck.Times = autoCheck.Times
+	oldAutoCheck.Sleep = autoCheck.Sleep
+	oldAutoCheck.Interval = autoCheck.Interval
+	oldAutoCheck.CheckType = autoCheck.CheckType
+	if err := s.db.Save(&oldAutoCheck).Error; err != nil {
+		return err
+	}
+	*autoCheck = oldAutoCheck
+	s.run(oldAutoCheck)
+	return nil
+}
+
+// DeleteAutoCheck 删除集群的自动检测
+func (s *AutoCheckService) DeleteAutoCheck(clusterName string, id uint) error {
+	autoCheck := model.AutoCheck{}
+	if err := s.db.Where("cluster_name = ?", clusterName).First(&autoCheck, id).Error; err != nil {
+		return errors.New("自动检测不存在")
+	}
+	s.cancel(id)
+	return s.db.Where("cluster_name = ?", clusterName).Delete(&model.AutoCheck{}, id).Error
+}
+
+// GetLogRecordPage 分页查询自动检测的操作记录
+func (s *AutoCheckService) GetLogRecordPage(clusterName string, page, size int) ([]model.LogRecord, int64) {
+	actions := []model.Action{model.RUN, model.STOP, model.RESTART, model.UPDATE_GAME, model.UPDATE_MOD}
+	db := s.db.Model(&model.LogRecord{}).Where("cluster_name = ? AND action IN ?", clusterName, actions)
+	var total int64
+	db.Count(&total)
+
+	records := make([]model.LogRecord, 0)
+	db.Order("created_at desc").Limit(size).Offset((page - 1) * size).Find(&records)
+	return records, total
+}
+
+// RecordLog 记录世界的操作到审计日志，手动启动和停止也需要记录，用于判断世界是否应该处于运行状态
+func (s *AutoCheckService) RecordLog(operator audit.Operator, clusterName, levelName string, action model.Action, message string, err error) {
+	if action == model.RUN {
+		s.mu.Lock()
+		delete(s.failures, clusterName+"/"+levelName)
+		s.mu.Unlock()
+	}
+	s.auditService.Record(operator, model.LogRecord{
+		Action:      action,
+		ClusterName: clusterName,
+		LevelName:   levelName,
+		Message:     message,
+	}, err)
+}
+
+// RecordClusterLog 记录集群所有世界的操作
+func (s *AutoCheckService) RecordClusterLog(operator audit.Operator, clusterName string, action model.Action, message string, err error) {
+	for _, levelName := range s.levels(clusterName, "") {
+		s.RecordLog(operator, clusterName, levelName, action, message, err)
+	}
+}
+
+func validate(autoCheck *model.AutoCheck) error {
+	switch autoCheck.CheckType {
+	case LevelDown, GameUpdate, ModUpdate:
+	default:
+		return errors.New("不支持的检测类型: " + autoCheck.CheckType)
+	}
+	if autoCheck.Interval <= 0 {
+		return errors.New("检测间隔必须大于 0")
+	}
+	return nil
+}
+
+// run 启动自动检测协程，已存在的协程会先被取消
+func (s *AutoCheckService) run(autoCheck model.AutoCheck) {
+	s.cancel(autoCheck.ID)
+	if autoCheck.Enable != 1 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancels[autoCheck.ID] = cancel
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(autoCheck.Interval) * time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.check(autoCheck)
+			}
+		}
+	}()
+}
+
+func (s *AutoCheckService) cancel(id uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.cancels[id]; ok {
+		cancel()
+		delete(s.cancels, id)
+	}
+}
+
+// clusterLock 同一集群同时只允许一个检测执行操作，避免更新期间被判定为宕机
+func (s *AutoCheckService) clusterLock(clusterName string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lock, ok := s.clusterLocks[clusterName]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.clusterLocks[clusterName] = lock
+	}
+	return lock
+}
+
+// LockCluster 在集群执行更新、重启等操作期间暂停该集群的自动检测，返回解锁函数
+func (s *AutoCheckService) LockCluster(clusterName string) func() {
+	lock := s.clusterLock(clusterName)
+	lock.Lock()
+	return lock.Unlock
+}
+
+func (s *AutoCheckService) check(autoCheck model.AutoCheck) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("[AutoCheck]检测异常", "cluster:", autoCheck.ClusterName, "type:", autoCheck.CheckType, r)
+		}
+	}()
+
+	lock := s.clusterLock(autoCheck.ClusterName)
+	if !lock.TryLock() {
+		return
+	}
+	defer lock.Unlock()
+
+	switch autoCheck.CheckType {
+	case LevelDown:
+		s.checkLevelDown(autoCheck)
+	case GameUpdate:
+		s.checkGameUpdate(autoCheck)
+	case ModUpdate:
+		s.checkModUpdate(autoCheck)
+	}
+}
+
+// checkLevelDown 检测应处于运行状态的世界是否已宕机，宕机则重启
+func (s *AutoCheckService) checkLevelDown(autoCheck model.AutoCheck) {
+	clusterName := autoCheck.ClusterName
+	for _, levelName := range s.levels(clusterName, autoCheck.LevelName) {
+		if running, _ := s.gameProcess.Status(clusterName, levelName); running {
+			s.mu.Lock()
+			delete(s.failures, clusterName+"/"+levelName)
+			s.mu.Unlock()
+			continue
+		}
+		if !s.expectRunning(clusterName, levelName) {
+			continue
+		}
+
+		key := clusterName + "/" + levelName
+		s.mu.Lock()
+		failures := s.failures[key]
+		s.failures[key] = failures + 1
+		s.mu.Unlock()
+		if failures == maxRestartTimes {
+			log.Println("[AutoCheck]世界连续重启失败，停止自动重启", "cluster:", clusterName, "level:", levelName)
+			s.RecordLog(systemOperator, clusterName, levelName, model.RESTART, "连续重启失败 "+strconv.Itoa(maxRestartTimes)+" 次，停止自动重启", nil)
+		}
+		if failures >= maxRestartTimes {
+			continue
+		}
+
+		log.Println("[AutoCheck]检测到世界宕机，正在重启", "cluster:", clusterName, "level:", levelName)
+		s.announce(autoCheck, s.runningLevels(clusterName, ""))
+		err := s.gameProcess.Start(clusterName, levelName)
+		s.RecordLog(systemOperator, clusterName, levelName, model.RESTART, "检测到世界宕机，自动重启", err)
+	}
+}
+
+// checkGameUpdate 检测游戏版本，有新版本时公告、更新并重启集群
+func (s *AutoCheckService) checkGameUpdate(autoCheck model.AutoCheck) {
+	clusterName := autoCheck.ClusterName
+	localVersion, err := s.archive.GetLocalDstVersion(clusterName)
+	if err != nil {
+		log.Println("[AutoCheck]获取本地游戏版本失败", err)
+		return
+	}
+	lastVersion, err := s.archive.GetLastDstVersion()
+	if err != nil || lastVersion <= 0 || localVersion >= lastVersion {
+		return
+	}
+
+	log.Println("[AutoCheck]检测到游戏更新", "cluster:", clusterName, localVersion, "->", lastVersion)
+	message := "游戏版本更新 " + strconv.FormatInt(localVersion, 10) + " -> " + strconv.FormatInt(lastVersion, 10)
+	runningLevels := s.runningLevels(clusterName, "")
+	s.announce(autoCheck, runningLevels)
+	if len(runningLevels) > 0 {
+		if err := s.gameProcess.StopAll(clusterName); err != nil {
+			log.Println("[AutoCheck]停止集群失败", err)
+		}
+	}
+	if err = s.updateService.Update(clusterName); err != nil {
+		s.RecordLog(systemOperator, clusterName, "", model.UPDATE_GAME, message, err)
+		return
+	}
+	if len(runningLevels) > 0 {
+		if err = s.gameProcess.StartAll(clusterName); err != nil {
+			message = message + "，重启失败"
+		}
+	}
+	s.RecordLog(systemOperator, clusterName, "", model.UPDATE_GAME, message, err)
+}
+
+// checkModUpdate 检测集群使用的模组是否有更新，有更新时公告、更新模组并重启集群
+// 开启模组自动更新下载（autoUpdateModinfo.download）时由 modUpdate 统一更新和重启，这里不再处理，避免重复更新和重启
+func (s *AutoCheckService) checkModUpdate(autoCheck model.AutoCheck) {
+	if s.modUpdateManaged() {
+		return
+	}
+	clusterName := autoCheck.ClusterName
+	workshopIds := s.WorkshopIds(clusterName)
+	if len(workshopIds) == 0 {
+		return
+	}
+	// 先对比创意工坊的更新时间，不依赖模组自动更新是否开启
+	pending, err := s.modService.CheckModUpdates(context.Background())
+	if err != nil {
+		log.Println("[AutoCheck]检测模组更新失败", "cluster:", clusterName, err)
+		return
+	}
+	used := make(map[string]bool, len(workshopIds))
+	for _, workshopId := range workshopIds {
+		used[workshopId] = true
+	}
+	var modInfos []model.ModInfo
+	for i := range pending {
+		if used[pending[i].Modid] {
+			modInfos = append(modInfos, pending[i])
+		}
+	}
+	if len(modInfos) == 0 {
+		return
+	}
+
+	message := "模组更新:"
+	for i := range modInfos {
+		message = message + " " + modInfos[i].Name + "(" + modInfos[i].Modid + ")"
+	}
+	log.Println("[AutoCheck]检测到模组更新", "cluster:", clusterName, message)
+
+	runningLevels := s.runningLevels(clusterName, "")
+	s.announce(autoCheck, runningLevels)
+	if len(runningLevels) > 0 {
+		if err := s.gameProcess.StopAll(clusterName); err != nil {
+			log.Println("[AutoCheck]停止集群失败", err)
+		}
+	}
+	var modIds []string
+	for i := range modInfos {
+		modIds = append(modIds, modInfos[i].Modid)
+		if _, err := s.modService.SubscribeModByModId(context.Background(), clusterName, modInfos[i].Modid, "zh"); err != nil {
+			log.Println("[AutoCheck]更新模组信息失败", modInfos[i].Modid, err)
+		}
+	}
+	s.db.Model(&model.ModInfo{}).Where("modid IN ?", modIds).Update("update", false)
+	// 模组文件由游戏启动时根据 dedicated_server_mods_setup.lua 自动下载更新
+	if len(runningLevels) > 0 {
+		if err = s.gameProcess.StartAll(clusterName); err != nil {
+			message = message + "，重启失败"
+		}
+	}
+	s.RecordLog(systemOperator, clusterName, "", model.UPDATE_MOD, message, err)
+}
+
+// modUpdateManaged 模组更新是否由 modUpdate 统一下载和重启
+func (s *AutoCheckService) modUpdateManaged() bool {
+	return s.config != nil && s.config.AutoUpdateModinfo.Enable && s.config.AutoUpdateModinfo.Download
+}
+
+// announce 执行操作前向运行中的世界发送公告，重复 Times 次，每次间隔 Sleep 秒
+func (s *AutoCheckService) announce(autoCheck model.AutoCheck, levels []string) {
+	if autoCheck.Announcement == "" || autoCheck.Times <= 0 || len(levels) == 0 {
+		return
+	}
+	for i := 0; i < autoCheck.Times; i++ {
+		for _, levelName := range levels {
+			if err := s.gameProcess.Command(autoCheck.ClusterName, levelName, dstUtils.AnnounceCommand(autoCheck.Announcement)); err != nil {
+				log.Println("[AutoCheck]发送公告失败", "level:", levelName, err)
+			}
+		}
+		time.Sleep(time.Duration(autoCheck.Sleep) * time.Second)
+	}
+}
+
+// expectRunning 根据最近一次启动/停止记录判断世界是否应该处于运行状态
+func (s *AutoCheckService) expectRunning(clusterName, levelName string) bool {
+	record := model.LogRecord{}
+	err := s.db.Where("cluster_name = ? AND level_name = ? AND action IN ?", clusterName, levelName, []model.Action{model.RUN, model.STOP}).
+		Order("id desc").First(&record).Error
+	if err != nil {
+		return false
+	}
+	return record.Action == model.RUN
+}
+
+func (s *AutoCheckService) runningLevels(clusterName, levelName string) []string {
+	var levels []string
+	for _, level := range s.levels(clusterName, levelName) {
+		if running, _ := s.gameProcess.Status(clusterName, level); running {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+// levels 返回检测范围内的世界，levelName 为空时表示集群的所有世界
+func (s *AutoCheckService) levels(clusterName, levelName string) []string {
+	if levelName != "" {
+		return []string{levelName}
+	}
+	config, err := s.levelConfigUtils.GetLevelConfig(clusterName)
+	if err != nil {
+		return []string{}
+	}
+	levels := make([]string, 0, len(config.LevelList))
+	for _, item := range config.LevelList {
+		levels = append(levels, item.File)
+	}
+	return levels
+}
+
+// WorkshopIds 集群所有世界 modoverrides.lua 中使用的模组
+func (s *AutoCheckService) WorkshopIds(clusterName string) []string {
+	var workshopIds []string
+	exists := map[string]bool{}
+	for _, levelName := range s.levels(clusterName, "") {
+		content, err := fileUtils.ReadFile(s.archive.ModoverridesPath(clusterName, levelName))
+		if err != nil {
+			continue
+		}
+		for _, workshopId := range dstUtils.WorkshopIds(content) {
+			if !exists[workshopId] {
+				exists[workshopId] = true
+				workshopIds = append(workshopIds, workshopId)
+			}
+		}
+	}
+	return workshopIds
+}
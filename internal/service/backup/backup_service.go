@@ -1,21 +1,21 @@
 package backup
 
 import (
-	"dst-admin-go/internal/database"
-	"dst-admin-go/internal/model"
 	"dst-admin-go/internal/pkg/context"
 	"dst-admin-go/internal/pkg/utils/dstUtils"
 	"dst-admin-go/internal/pkg/utils/fileUtils"
-	"dst-admin-go/internal/pkg/utils/shellUtils"
-	"dst-admin-go/internal/pkg/utils/zip"
 	"dst-admin-go/internal/service/archive"
 	"dst-admin-go/internal/service/dstConfig"
 	"dst-admin-go/internal/service/game"
+	"dst-admin-go/internal/service/gameArchive"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -25,13 +25,28 @@ type BackupService struct {
 	archive     *archive.PathResolver
 	dstConfig   dstConfig.Config
 	gameProcess game.Process
+	gameArchive *gameArchive.GameArchive
+
+	snapshotCancels map[string]func()
+	snapshotMu      sync.Mutex
+	// repoMu 写入和读取仓库时加读锁，删除快照和 GC 时加写锁
+	repoMu sync.RWMutex
+	// remoteMu 同一时间只上传一个远程备份，避免清理旧备份时互相影响
+	remoteMu sync.Mutex
 }
 
+const (
+	KindZip        = "zip"
+	KindRepository = "repository"
+)
+
 type BackupInfo struct {
 	FileName   string    `json:"fileName"`
 	FileSize   int64     `json:"fileSize"`
 	CreateTime time.Time `json:"createTime"`
 	Time       int64     `json:"time"`
+	// Kind zip 为备份目录中的压缩包，repository 为备份仓库中的快照
+	Kind string `json:"kind"`
 }
 
 type BackupSnapshot struct {
@@ -41,11 +56,21 @@ type BackupSnapshot struct {
 	IsCSave      int `json:"isCSave"`
 }
 
-func NewBackupService(archive *archive.PathResolver, dstConfig dstConfig.Config, gameProcess game.Process) *BackupService {
+// RestoreResult 恢复备份的结果
+type RestoreResult struct {
+	Verify VerifyResult `json:"verify"`
+	// SafetyBackup 恢复前自动创建的备份，恢复出错时可以用它还原
+	SafetyBackup string `json:"safetyBackup"`
+}
+
+func NewBackupService(archive *archive.PathResolver, dstConfig dstConfig.Config, gameProcess game.Process, gameArchive *gameArchive.GameArchive) *BackupService {
 	return &BackupService{
 		archive:     archive,
 		dstConfig:   dstConfig,
 		gameProcess: gameProcess,
+		gameArchive: gameArchive,
+
+		snapshotCancels: map[string]func(){},
 	}
 }
 
@@ -78,12 +103,13 @@ func (b *BackupService) GetBackupList(clusterName string) []BackupInfo {
 				FileSize:   file.Size(),
 				CreateTime: file.ModTime(),
 				Time:       file.ModTime().Unix(),
+				Kind:       KindZip,
 			}
 			backupList = append(backupList, backup)
 		}
 	}
 
-	return backupList
+	return append(backupList, b.repoBackupList(clusterName, backupPath)...)
 
 }
 
@@ -95,6 +121,12 @@ func (b *BackupService) RenameBackup(ctx *gin.Context, fileName, newName string)
 		return
 	}
 	backupPath := config.Backup
+	if isRepoBackup(fileName) {
+		if err := b.renameRepoBackup(backupPath, fileName, newName); err != nil {
+			log.Println("[Backup]重命名快照失败", err)
+		}
+		return
+	}
 	err = fileUtils.Rename(filepath.Join(backupPath, fileName), filepath.Join(backupPath, newName))
 	if err != nil {
 		return
@@ -109,55 +141,174 @@ func (b *BackupService) DeleteBackup(ctx *gin.Context, fileNames []string) {
 		log.Println("failed to get dst config:", err)
 		return
 	}
-	backupPath := config.Backup
-	for _, fileName := range fileNames {
-		filePath := filepath.Join(backupPath, fileName)
-		if !fileUtils.Exists(filePath) {
-			continue
-		}
-		err := fileUtils.DeleteFile(filePath)
-		if err != nil {
-			return
-		}
+	if err := b.removeBackups(config.Backup, fileNames); err != nil {
+		log.Println("[Backup]删除备份失败", err)
 	}
 
 }
 
-func (b *BackupService) RestoreBackup(ctx *gin.Context, backupName string) {
+// safetyBackupPrefix 恢复前自动创建的备份的文件名前缀
+const safetyBackupPrefix = "(pre-restore)"
 
-	clusterName := context.GetClusterName(ctx)
+// VerifyBackup 校验备份，有清单时校验每个文件的 SHA-256
+func (b *BackupService) VerifyBackup(clusterName, backupName string) (VerifyResult, error) {
 	config, err := b.dstConfig.GetDstConfig(clusterName)
 	if err != nil {
-		log.Println("failed to get dst config:", err)
-		return
+		return VerifyResult{}, err
+	}
+	if isRepoBackup(backupName) {
+		return b.verifyRepoBackup(config.Backup, backupName)
 	}
+	filePath := filepath.Join(config.Backup, filepath.Base(backupName))
+	if !fileUtils.Exists(filePath) {
+		return VerifyResult{}, errors.New("备份不存在: " + backupName)
+	}
+	return verifyArchive(filePath)
+}
 
-	filePath := filepath.Join(config.Backup, backupName)
-	clusterPath := filepath.Join(b.archive.ClusterPath(clusterName))
-	err = fileUtils.DeleteDir(clusterPath)
+// RestoreBackup 恢复备份
+// 先校验备份并解压到临时目录，再为当前存档创建一个安全备份，最后用重命名替换存档目录
+// 有世界正在运行时拒绝恢复，force 为 true 时先停止集群
+func (b *BackupService) RestoreBackup(clusterName, backupName string, force bool) (RestoreResult, error) {
+	result := RestoreResult{}
+	config, err := b.dstConfig.GetDstConfig(clusterName)
 	if err != nil {
-		log.Panicln("删除失败,", clusterPath, err)
+		return result, err
+	}
+	clusterPath := b.archive.ClusterPath(clusterName)
+	if running := b.runningLevels(clusterName); len(running) > 0 {
+		if !force {
+			return result, errors.New("世界正在运行，请先停止: " + strings.Join(running, ", "))
+		}
+		log.Println("[Backup]强制恢复，正在停止集群", clusterName)
+		if err := b.gameProcess.StopAll(clusterName); err != nil {
+			return result, fmt.Errorf("停止集群失败: %w", err)
+		}
 	}
-	log.Println("正在恢复存档", filePath, filepath.Join(b.archive.KleiBasePath(clusterName)))
 
-	// err = zip.Unzip2(filePath, filepath.Join(constant.HOME_PATH, ".klei/DoNotStarveTogether"), cluster.ClusterName)
-	err = zip.Unzip3(filePath, clusterPath)
+	// 临时目录与存档目录在同一个目录下，保证可以直接重命名
+	suffix := time.Now().Format("20060102150405")
+	staging := filepath.Join(filepath.Dir(clusterPath), "."+clusterName+".restore-"+suffix)
+	defer os.RemoveAll(staging)
+	result.Verify, err = b.stageBackup(config.Backup, backupName, staging)
 	if err != nil {
-		log.Panicln("解压失败,", filePath, clusterPath, err)
+		return result, err
+	}
+	if fileUtils.Exists(clusterPath) {
+		result.SafetyBackup, err = b.createSafetyBackup(clusterName, config.Backup)
+		if err != nil {
+			return result, err
+		}
 	}
+
+	if err := swapDir(clusterPath, staging, suffix); err != nil {
+		return result, err
+	}
+	log.Println("[Backup]恢复存档成功", clusterName, backupName)
+
 	// 安装mod
 	modoverride, err := fileUtils.ReadFile(b.archive.ModoverridesPath(clusterName, "Master"))
 	if err != nil {
 		log.Println("读取模组失败", err)
 	}
-	config, err = b.dstConfig.GetDstConfig(clusterName)
+	err = dstUtils.DedicatedServerModsSetup(config, modoverride)
 	if err != nil {
 		log.Println(err.Error())
 	}
-	err = dstUtils.DedicatedServerModsSetup(config, modoverride)
+	return result, nil
+}
+
+// stageBackup 校验备份并解压到 staging，解压后再按清单校验每个文件
+func (b *BackupService) stageBackup(backupPath, backupName, staging string) (VerifyResult, error) {
+	if isRepoBackup(backupName) {
+		result, err := b.materializeRepoBackup(backupPath, backupName, staging)
+		if err != nil {
+			return result, err
+		}
+		return result, verifyStaging(staging, result.Manifest)
+	}
+	filePath := filepath.Join(backupPath, filepath.Base(backupName))
+	if !fileUtils.Exists(filePath) {
+		return VerifyResult{}, errors.New("备份不存在: " + backupName)
+	}
+	result, err := verifyArchive(filePath)
 	if err != nil {
-		log.Println(err.Error())
+		return result, err
+	}
+	if !result.Valid {
+		return result, errors.New("备份校验失败: " + strings.Join(result.Errors, "; "))
 	}
+	log.Println("[Backup]正在解压备份", filePath, staging)
+	if err := extractArchive(filePath, staging); err != nil {
+		return result, fmt.Errorf("解压备份失败: %w", err)
+	}
+	return result, verifyStaging(staging, result.Manifest)
+}
+
+// createSafetyBackup 恢复前为当前存档创建备份，返回备份文件名
+func (b *BackupService) createSafetyBackup(clusterName, backupPath string) (string, error) {
+	name := safetyBackupPrefix + b.GenGameBackUpName(clusterName)
+	if _, err := b.writeBackup(clusterName, filepath.Join(backupPath, name)); err != nil {
+		return "", fmt.Errorf("创建恢复前备份失败: %w", err)
+	}
+	log.Println("[Backup]已创建恢复前备份", name)
+	return name, nil
+}
+
+// runningLevels 集群中正在运行的世界
+func (b *BackupService) runningLevels(clusterName string) []string {
+	var running []string
+	for _, levelName := range levelNames(b.archive.ClusterPath(clusterName)) {
+		if ok, _ := b.gameProcess.Status(clusterName, levelName); ok {
+			running = append(running, levelName)
+		}
+	}
+	return running
+}
+
+// verifyStaging 校验解压后的文件与清单一致，没有清单的旧备份只检查 cluster.ini
+func verifyStaging(staging string, manifest *Manifest) error {
+	if !fileUtils.Exists(filepath.Join(staging, "cluster.ini")) {
+		return errors.New("解压后缺少 cluster.ini")
+	}
+	if manifest == nil {
+		return nil
+	}
+	for _, file := range manifest.Files {
+		sum, err := sha256File(filepath.Join(staging, filepath.FromSlash(file.Path)))
+		if err != nil {
+			return fmt.Errorf("校验解压文件失败 %s: %w", file.Path, err)
+		}
+		if sum != file.Sha256 {
+			return errors.New("解压文件校验失败: " + file.Path)
+		}
+	}
+	return nil
+}
+
+// swapDir 用 staging 替换 target，替换失败时还原 target
+func swapDir(target, staging, suffix string) error {
+	old := ""
+	if fileUtils.Exists(target) {
+		old = target + ".old-" + suffix
+		if err := os.Rename(target, old); err != nil {
+			return fmt.Errorf("移动当前存档失败: %w", err)
+		}
+	}
+	if err := os.Rename(staging, target); err != nil {
+		if old != "" {
+			if restoreErr := os.Rename(old, target); restoreErr != nil {
+				log.Println("[Backup]还原存档目录失败", old, restoreErr)
+			}
+		}
+		return fmt.Errorf("替换存档失败: %w", err)
+	}
+	if old != "" {
+		if err := os.RemoveAll(old); err != nil {
+			log.Println("[Backup]删除旧存档目录失败", old, err)
+		}
+	}
+	return nil
 }
 
 func (b *BackupService) CreateBackup(clusterName, backupName string) {
@@ -178,7 +329,6 @@ func (b *BackupService) CreateBackup(clusterName, backupName string) {
 	// 等待保存完成
 	time.Sleep(2 * time.Second)
 
-	src := b.archive.ClusterPath(clusterName)
 	if !fileUtils.Exists(backupPath) {
 		log.Panicln("backup path is not exists")
 	}
@@ -186,23 +336,28 @@ func (b *BackupService) CreateBackup(clusterName, backupName string) {
 		backupName = b.GenGameBackUpName(clusterName)
 	}
 	dst := filepath.Join(backupPath, backupName)
-	log.Println("src", src, dst)
-	err = zip.Zip(src, dst)
+	log.Println("src", b.archive.ClusterPath(clusterName), dst)
+	_, err = b.writeBackup(clusterName, dst)
 	if err != nil {
 		log.Panicln("create backup error", err)
 	}
 	log.Println("创建备份成功")
+	b.pushRemoteAsync(clusterName, backupName)
 }
 
 func (b *BackupService) DownloadBackup(c *gin.Context) {
 	fileName := c.Query("fileName")
 
-	clusterName := c.GetHeader("level")
+	clusterName := context.GetClusterName(c)
 	config, err := b.dstConfig.GetDstConfig(clusterName)
 	if err != nil {
 		log.Println("failed to get dst config:", err)
 		return
 	}
+	if isRepoBackup(fileName) {
+		b.exportRepoBackup(c, config.Backup, fileName)
+		return
+	}
 
 	filePath := filepath.Join(config.Backup, fileName)
 	//打开文件
@@ -245,109 +400,6 @@ func (b *BackupService) UploadBackup(c *gin.Context) {
 
 }
 
-func (b *BackupService) ScheduleBackupSnapshots() {
-	log.Println("开始创建快照")
-	for {
-		db := database.Db
-		snapshot := model.BackupSnapshot{}
-		db.First(&snapshot)
-		if snapshot.Enable == 1 {
-			if snapshot.Interval == 0 {
-				snapshot.Interval = 8
-			}
-			if snapshot.MaxSnapshots == 0 {
-				snapshot.MaxSnapshots = 6
-			}
-			time.Sleep(time.Duration(snapshot.Interval) * time.Minute)
-
-			// 定时创建备份,每隔 x 分钟备份一次
-			// Get the first available cluster config (or "MyCluster" as default)
-			clusterName := "MyCluster"
-			config, err := b.dstConfig.GetDstConfig(clusterName)
-			if err != nil {
-				log.Println("failed to get dst config for snapshot:", err)
-				continue
-			}
-			if config.Cluster != "" {
-				snapshotPrefix := "(snapshot)"
-				if snapshot.IsCSave == 1 {
-					// 执行 CSave 命令
-					err := b.gameProcess.Command(config.Cluster, "Master", "c_save()")
-					if err != nil {
-						log.Println("CSave command error:", err)
-					}
-					// 等待保存完成
-					time.Sleep(2 * time.Second)
-				}
-				b.CreateSnapshotBackup(snapshotPrefix, config.Cluster)
-				// 删除快照
-				b.DeleteBackupSnapshots(snapshotPrefix, snapshot.MaxSnapshots, config.Cluster, config.Backup)
-			}
-		} else {
-			time.Sleep(1 * time.Minute)
-		}
-	}
-}
-
-func sumMd5(filePath string) string {
-	// find save -type f -exec md5sum {} \; | awk '{print $1}' | sort | md5sum
-	comamd := "find " + filePath + " -type f -exec md5sum {} \\; | awk '{print $1}' | sort | md5sum"
-	info, err := shellUtils.ExecuteCommand(comamd)
-	if err != nil {
-		return ""
-	}
-	return info
-}
-
-func (b *BackupService) CreateSnapshotBackup(prefix, clusterName string) {
-
-	config, err := b.dstConfig.GetDstConfig(clusterName)
-	if err != nil {
-		log.Println("failed to get dst config:", err)
-		return
-	}
-
-	snapshotMd5FilePath := "./snapshotMd5"
-	fileUtils.CreateFileIfNotExists(snapshotMd5FilePath)
-
-	src := b.archive.ClusterPath(clusterName)
-	dst := filepath.Join(config.Backup, b.GenBackUpSnapshotName(prefix, clusterName))
-	log.Println("[Snapshot]正在定时创建游戏备份", "src: ", src, "dst: ", dst)
-	err = zip.Zip(src, dst)
-	if err != nil {
-		log.Println("[Snapshot]create backup error", err)
-	}
-}
-
-func (b *BackupService) DeleteBackupSnapshots(prefix string, maxSnapshots int, clusterName, backupPath string) {
-
-	log.Println("[Snapshot]正在删除快照备份", "maxSnapshots", maxSnapshots, "clusterName: ", clusterName)
-
-	backupList := b.GetBackupList(clusterName)
-	var newBackupList []BackupInfo
-	for i := range backupList {
-		name := backupList[i].FileName
-		if strings.HasPrefix(name, prefix) {
-			newBackupList = append(newBackupList, backupList[i])
-		}
-	}
-	if len(newBackupList) > maxSnapshots {
-		deleteBackupList := newBackupList[:len(newBackupList)-maxSnapshots]
-		for i := range deleteBackupList {
-			filePath := filepath.Join(backupPath, deleteBackupList[i].FileName)
-			log.Println("删除快照备份", filePath)
-			if !fileUtils.Exists(filePath) {
-				continue
-			}
-			err := fileUtils.DeleteFile(filePath)
-			if err != nil {
-				return
-			}
-		}
-	}
-
-}
-
 func (b *BackupService) backupPath() string {
 	// dstConfig := dstConfigUtils.GetDstConfig()
 	// backupPath := dstConfig.Backup
@@ -365,10 +417,13 @@ var SeasonMap = map[string]string{
 	"winter": "冬天",
 }
 
+// backupTimeLayout 备份文件名中的时间格式
+const backupTimeLayout = "2006年01月02日15点04分05秒"
+
 // GenGameBackUpName 备份名称增加存档信息如  猜猜我是谁的世界-10天-spring-1-20-2023071415
 func (b *BackupService) GenGameBackUpName(clusterName string) string {
 	// 简化实现，使用时间戳和集群名称
-	backupName := time.Now().Format("2006年01月02日15点04分05秒") + "_" + clusterName + ".zip"
+	backupName := time.Now().Format(backupTimeLayout) + "_" + clusterName + ".zip"
 
 	return backupName
 }
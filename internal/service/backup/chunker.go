@@ -0,0 +1,65 @@
+package backup
+
+import (
+	"bufio"
+	"io"
+)
+
+// 按内容切分文件，文件中间插入或删除数据时，后面的块仍然可以和之前的快照复用
+const (
+	minChunkSize = 256 << 10
+	maxChunkSize = 4 << 20
+	// chunkMask 平均块大小约 1MiB
+	chunkMask = uint64(1<<20-1) << 44
+)
+
+// gearTable 固定种子生成的随机表，修改后已有仓库的块将无法复用
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	seed := uint64(0x6473745f61646d69)
+	for i := range table {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+type chunker struct {
+	reader *bufio.Reader
+	buf    []byte
+}
+
+func newChunker(reader io.Reader) *chunker {
+	return &chunker{
+		reader: bufio.NewReaderSize(reader, 1<<20),
+		buf:    make([]byte, 0, maxChunkSize),
+	}
+}
+
+// Next 返回下一个块，返回的切片在下次调用前有效，读完时返回 io.EOF
+func (c *chunker) Next() ([]byte, error) {
+	c.buf = c.buf[:0]
+	var hash uint64
+	for len(c.buf) < maxChunkSize {
+		b, err := c.reader.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.buf = append(c.buf, b)
+		hash = (hash << 1) + gearTable[b]
+		if len(c.buf) >= minChunkSize && hash&chunkMask == 0 {
+			break
+		}
+	}
+	if len(c.buf) == 0 {
+		return nil, io.EOF
+	}
+	return c.buf, nil
+}
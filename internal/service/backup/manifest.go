@@ -0,0 +1,391 @@
+package backup
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"dst-admin-go/internal/pkg/utils/dstUtils"
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestName 备份清单在压缩包中的文件名，位于压缩包根目录
+const ManifestName = "backup_manifest.json"
+
+// manifestVersion 清单格式版本
+const manifestVersion = 1
+
+// Manifest 备份清单，创建备份时写入压缩包，恢复时用于校验
+type Manifest struct {
+	Version     int            `json:"version"`
+	ClusterName string         `json:"clusterName"`
+	Levels      []string       `json:"levels"`
+	DstVersion  int64          `json:"dstVersion"`
+	Day         int            `json:"day"`
+	Season      string         `json:"season"`
+	Mods        []string       `json:"mods"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	Files       []ManifestFile `json:"files"`
+}
+
+// ManifestFile 备份中的文件，Path 为相对集群目录的路径，使用 / 分隔
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// VerifyResult 备份校验结果
+type VerifyResult struct {
+	FileName    string    `json:"fileName"`
+	HasManifest bool      `json:"hasManifest"`
+	Valid       bool      `json:"valid"`
+	Files       int       `json:"files"`
+	Errors      []string  `json:"errors"`
+	Manifest    *Manifest `json:"manifest"`
+}
+
+// levelNames 集群目录下包含 server.ini 的世界目录
+func levelNames(clusterPath string) []string {
+	entries, err := os.ReadDir(clusterPath)
+	if err != nil {
+		return nil
+	}
+	var levels []string
+	for _, entry := range entries {
+		if entry.IsDir() && fileUtils.Exists(filepath.Join(clusterPath, entry.Name(), "server.ini")) {
+			levels = append(levels, entry.Name())
+		}
+	}
+	return levels
+}
+
+// newManifest 收集集群的存档信息，文件列表在写入压缩包时填充
+func (b *BackupService) newManifest(clusterName string) *Manifest {
+	clusterPath := b.archive.ClusterPath(clusterName)
+	manifest := &Manifest{
+		Version:     manifestVersion,
+		ClusterName: clusterName,
+		Levels:      levelNames(clusterPath),
+		CreatedAt:   time.Now(),
+	}
+	if version, err := b.archive.GetLocalDstVersion(clusterName); err == nil {
+		manifest.DstVersion = version
+	}
+	if b.gameArchive != nil {
+		meta := b.gameArchive.Snapshoot(clusterName)
+		manifest.Day = meta.Clock.Cycles + 1
+		manifest.Season = meta.Seasons.Season
+	}
+	exists := map[string]bool{}
+	for _, levelName := range manifest.Levels {
+		content, err := fileUtils.ReadFile(b.archive.ModoverridesPath(clusterName, levelName))
+		if err != nil {
+			continue
+		}
+		for _, workshopId := range dstUtils.WorkshopIds(content) {
+			if !exists[workshopId] {
+				exists[workshopId] = true
+				manifest.Mods = append(manifest.Mods, workshopId)
+			}
+		}
+	}
+	return manifest
+}
+
+// writeBackup 将集群目录打包为 dst，压缩包内的目录结构与 zip.Zip 相同，并在根目录写入清单
+// 先写入临时文件，完成后再重命名，避免留下不完整的备份
+func (b *BackupService) writeBackup(clusterName, dst string) (*Manifest, error) {
+	clusterPath := b.archive.ClusterPath(clusterName)
+	if !fileUtils.Exists(clusterPath) {
+		return nil, errors.New("存档目录不存在: " + clusterPath)
+	}
+	manifest := b.newManifest(clusterName)
+
+	tmp := dst + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	writer := zip.NewWriter(file)
+	err = addClusterFiles(writer, clusterPath, manifest)
+	if err == nil {
+		err = addManifest(writer, manifest)
+	}
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func addClusterFiles(writer *zip.Writer, clusterPath string, manifest *Manifest) error {
+	prefix := filepath.Base(clusterPath)
+	return filepath.Walk(clusterPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(clusterPath, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		src, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(prefix, relPath)
+		header.Method = zip.Deflate
+		entry, err := writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		size, err := io.Copy(io.MultiWriter(entry, hash), src)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, ManifestFile{
+			Path:   relPath,
+			Size:   size,
+			Sha256: hex.EncodeToString(hash.Sum(nil)),
+		})
+		return nil
+	})
+}
+
+func addManifest(writer *zip.Writer, manifest *Manifest) error {
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Path < manifest.Files[j].Path
+	})
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	entry, err := writer.Create(ManifestName)
+	if err != nil {
+		return err
+	}
+	_, err = entry.Write(data)
+	return err
+}
+
+// clusterRoot 压缩包中集群目录的前缀，与 zip.Unzip3 一样以 cluster.ini 所在目录为准
+func clusterRoot(files []*zip.File) (string, error) {
+	for _, file := range files {
+		if path.Base(file.Name) == "cluster.ini" {
+			root := path.Dir(strings.ReplaceAll(file.Name, "\\", "/"))
+			if root == "." {
+				return "", nil
+			}
+			return root + "/", nil
+		}
+	}
+	return "", errors.New("压缩包中缺少 cluster.ini 文件")
+}
+
+// relativeName 压缩包中的文件相对集群目录的路径，不在集群目录中或路径不安全时返回空
+func relativeName(root, name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if !strings.HasPrefix(name, root) {
+		return ""
+	}
+	rel := path.Clean(strings.TrimPrefix(name, root))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+		return ""
+	}
+	return rel
+}
+
+func readManifest(reader *zip.Reader) (*Manifest, error) {
+	for _, file := range reader.File {
+		if file.Name != ManifestName {
+			continue
+		}
+		entry, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer entry.Close()
+		manifest := &Manifest{}
+		if err := json.NewDecoder(entry).Decode(manifest); err != nil {
+			return nil, fmt.Errorf("解析备份清单失败: %w", err)
+		}
+		return manifest, nil
+	}
+	return nil, nil
+}
+
+// verifyArchive 校验压缩包：读取每个文件以检查 CRC，有清单时同时校验 SHA-256 和文件是否缺失
+func verifyArchive(filePath string) (VerifyResult, error) {
+	result := VerifyResult{FileName: filepath.Base(filePath)}
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return result, fmt.Errorf("打开备份失败: %w", err)
+	}
+	defer reader.Close()
+
+	root, err := clusterRoot(reader.File)
+	if err != nil {
+		return result, err
+	}
+	manifest, err := readManifest(&reader.Reader)
+	if err != nil {
+		return result, err
+	}
+	result.Manifest = manifest
+	result.HasManifest = manifest != nil
+
+	expected := map[string]ManifestFile{}
+	if manifest != nil {
+		for _, file := range manifest.Files {
+			expected[file.Path] = file
+		}
+	}
+	for _, file := range reader.File {
+		if file.Name == ManifestName || file.FileInfo().IsDir() {
+			continue
+		}
+		if !strings.HasPrefix(strings.ReplaceAll(file.Name, "\\", "/"), root) {
+			// 不属于集群目录的文件恢复时会被忽略
+			continue
+		}
+		rel := relativeName(root, file.Name)
+		if rel == "" {
+			result.Errors = append(result.Errors, "不安全的路径: "+file.Name)
+			continue
+		}
+		sum, size, err := hashEntry(file)
+		if err != nil {
+			result.Errors = append(result.Errors, rel+": "+err.Error())
+			continue
+		}
+		result.Files++
+		if manifest == nil {
+			continue
+		}
+		want, ok := expected[rel]
+		if !ok {
+			result.Errors = append(result.Errors, "清单中没有该文件: "+rel)
+			continue
+		}
+		delete(expected, rel)
+		if want.Sha256 != sum || want.Size != size {
+			result.Errors = append(result.Errors, "文件校验失败: "+rel)
+		}
+	}
+	for rel := range expected {
+		result.Errors = append(result.Errors, "备份中缺少文件: "+rel)
+	}
+	sort.Strings(result.Errors)
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+func hashEntry(file *zip.File) (string, int64, error) {
+	entry, err := file.Open()
+	if err != nil {
+		return "", 0, err
+	}
+	defer entry.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, entry)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// extractArchive 将压缩包中的集群目录解压到 dest，跳过清单文件和不安全的路径
+func extractArchive(filePath, dest string) error {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	root, err := clusterRoot(reader.File)
+	if err != nil {
+		return err
+	}
+	for _, file := range reader.File {
+		if file.Name == ManifestName {
+			continue
+		}
+		rel := relativeName(root, file.Name)
+		if rel == "" {
+			continue
+		}
+		target := filepath.Join(dest, filepath.FromSlash(rel))
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, os.ModePerm); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := extractFile(file, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(file *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	entry, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+	writer, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(writer, entry); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+func sha256File(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
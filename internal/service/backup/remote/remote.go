@@ -0,0 +1,64 @@
+package remote
+
+import (
+	"context"
+	"dst-admin-go/internal/model"
+	"errors"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	TypeS3     = "s3"
+	TypeSFTP   = "sftp"
+	TypeWebDAV = "webdav"
+)
+
+// defaultTimeout 单次请求的默认超时时间
+const defaultTimeout = 10 * time.Minute
+
+// Object 远程存储中的备份
+type Object struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// Target 远程备份存储，name 为相对 Prefix/集群名称 的文件名
+type Target interface {
+	// Upload 上传本地文件，已存在时覆盖
+	Upload(ctx context.Context, name, localPath string) error
+	// Download 下载到本地文件
+	Download(ctx context.Context, name, localPath string) error
+	// List 列出目录中的备份
+	List(ctx context.Context) ([]Object, error)
+	Delete(ctx context.Context, name string) error
+	Close() error
+}
+
+// New 根据设置创建远程存储，clusterName 作为子目录区分不同集群的备份
+func New(setting model.BackupTarget, clusterName string) (Target, error) {
+	dir := strings.Trim(path.Join(strings.Trim(setting.Prefix, "/"), clusterName), "/")
+	timeout := defaultTimeout
+	if setting.Timeout > 0 {
+		timeout = time.Duration(setting.Timeout) * time.Second
+	}
+	switch setting.Type {
+	case TypeS3:
+		return newS3Target(setting, dir, timeout)
+	case TypeWebDAV:
+		return newWebDAVTarget(setting, dir, timeout)
+	case TypeSFTP:
+		return newSFTPTarget(setting, dir, timeout)
+	}
+	return nil, errors.New("不支持的远程存储类型: " + setting.Type)
+}
+
+// validName 远程文件名不能包含目录
+func validName(name string) error {
+	if name == "" || strings.ContainsAny(name, "/\\") || name == "." || name == ".." {
+		return errors.New("无效的文件名: " + name)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"dst-admin-go/internal/model"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testTarget 对远程存储执行上传、列出、下载和删除，各存储类型共用
+func testTarget(t *testing.T, target Target) {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+	data := []byte("dst-admin-go backup " + t.Name())
+	localPath := filepath.Join(dir, "local.zip")
+	if err := os.WriteFile(localPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	objects, err := target.List(ctx)
+	if err != nil {
+		t.Fatalf("List 空目录失败: %v", err)
+	}
+	if len(objects) != 0 {
+		t.Fatalf("空目录中有备份: %v", objects)
+	}
+
+	for _, name := range []string{"a.zip", "b c.zip"} {
+		if err := target.Upload(ctx, name, localPath); err != nil {
+			t.Fatalf("Upload %s 失败: %v", name, err)
+		}
+	}
+	// 覆盖已存在的文件
+	if err := target.Upload(ctx, "a.zip", localPath); err != nil {
+		t.Fatalf("覆盖上传失败: %v", err)
+	}
+
+	objects, err = target.List(ctx)
+	if err != nil {
+		t.Fatalf("List 失败: %v", err)
+	}
+	sizes := make(map[string]int64)
+	for _, object := range objects {
+		sizes[object.Name] = object.Size
+	}
+	if len(sizes) != 2 || sizes["a.zip"] != int64(len(data)) || sizes["b c.zip"] != int64(len(data)) {
+		t.Fatalf("List 结果不正确: %v", objects)
+	}
+
+	downloadPath := filepath.Join(dir, "download.zip")
+	if err := target.Download(ctx, "b c.zip", downloadPath); err != nil {
+		t.Fatalf("Download 失败: %v", err)
+	}
+	if got, _ := os.ReadFile(downloadPath); !bytes.Equal(got, data) {
+		t.Fatalf("下载的内容不一致: %q", got)
+	}
+
+	if err := target.Delete(ctx, "a.zip"); err != nil {
+		t.Fatalf("Delete 失败: %v", err)
+	}
+	objects, err = target.List(ctx)
+	if err != nil {
+		t.Fatalf("List 失败: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Name != "b c.zip" {
+		t.Fatalf("删除后 List 结果不正确: %v", objects)
+	}
+
+	for _, name := range []string{"", ".", "..", "../a.zip", "dir/a.zip", `dir\a.zip`} {
+		if err := target.Upload(ctx, name, localPath); err == nil {
+			t.Fatalf("Upload 应拒绝文件名 %q", name)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		typ      string
+		endpoint string
+		bucket   string
+		ok       bool
+	}{
+		{TypeS3, "http://127.0.0.1:9000", "backup", true},
+		{TypeS3, "", "backup", false},
+		{TypeS3, "http://127.0.0.1:9000", "", false},
+		{TypeS3, "127.0.0.1:9000", "backup", false},
+		{TypeWebDAV, "http://127.0.0.1/dav", "", true},
+		{TypeWebDAV, "/dav", "", false},
+		{TypeSFTP, "", "", false},
+		{"ftp", "127.0.0.1", "", false},
+	}
+	for _, c := range cases {
+		target, err := New(model.BackupTarget{Type: c.typ, Endpoint: c.endpoint, Bucket: c.bucket}, "Cluster1")
+		if (err == nil) != c.ok {
+			t.Errorf("New(%s, %q, %q) err = %v", c.typ, c.endpoint, c.bucket, err)
+		}
+		if err == nil {
+			target.Close()
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package remote
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"dst-admin-go/internal/model"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Target S3 兼容存储（AWS S3、MinIO 等），使用 Signature V4 签名
+type s3Target struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	pathStyle bool
+	accessKey string
+	secretKey string
+	dir       string
+	client    *http.Client
+}
+
+func newS3Target(setting model.BackupTarget, dir string, timeout time.Duration) (*s3Target, error) {
+	if setting.Endpoint == "" || setting.Bucket == "" {
+		return nil, errors.New("S3 需要设置 endpoint 和 bucket")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(setting.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, errors.New("无效的 S3 endpoint: " + setting.Endpoint)
+	}
+	region := setting.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &s3Target{
+		endpoint:  endpoint,
+		region:    region,
+		bucket:    setting.Bucket,
+		pathStyle: setting.PathStyle,
+		accessKey: setting.Username,
+		secretKey: setting.Password,
+		dir:       dir,
+		client:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (t *s3Target) key(name string) string {
+	if t.dir == "" {
+		return name
+	}
+	return t.dir + "/" + name
+}
+
+// objectURL 对象地址，key 为空时为存储桶地址
+func (t *s3Target) objectURL(key string, query url.Values) *url.URL {
+	u := *t.endpoint
+	if t.pathStyle {
+		u.Path = u.Path + "/" + t.bucket
+	} else {
+		u.Host = t.bucket + "." + u.Host
+	}
+	if key != "" {
+		u.Path = u.Path + "/" + key
+	} else if u.Path == "" {
+		u.Path = "/"
+	}
+	// 请求中的路径与签名使用相同的编码
+	u.RawPath = canonicalURI(u.Path)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+func (t *s3Target) Upload(ctx context.Context, name, localPath string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	payloadHash, size, err := hashFile(localPath)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, t.objectURL(t.key(name), nil).String(), file)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/zip")
+	resp, err := t.do(req, payloadHash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (t *s3Target) Download(ctx context.Context, name, localPath string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.objectURL(t.key(name), nil).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(req, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return writeLocal(localPath, resp.Body)
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (t *s3Target) List(ctx context.Context) ([]Object, error) {
+	prefix := ""
+	if t.dir != "" {
+		prefix = t.dir + "/"
+	}
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		query.Set("delimiter", "/")
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.objectURL("", query).String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.do(req, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+		result := listBucketResult{}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析 S3 列表失败: %w", err)
+		}
+		for _, item := range result.Contents {
+			name := strings.TrimPrefix(item.Key, prefix)
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			objects = append(objects, Object{Name: name, Size: item.Size, ModTime: item.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (t *s3Target) Delete(ctx context.Context, name string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.objectURL(t.key(name), nil).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(req, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (t *s3Target) Close() error {
+	return nil
+}
+
+// do 签名并发送请求，非 2xx 响应返回错误
+func (t *s3Target) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	t.sign(req, payloadHash, time.Now().UTC())
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("S3 请求失败: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign 按 AWS Signature Version 4 签名，签名的请求头为 host、x-amz-content-sha256、x-amz-date
+func (t *s3Target) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + t.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+t.secretKey), date)
+	key = hmacSHA256(key, t.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+t.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalURI 按 RFC 3986 编码路径，保留 /
+func canonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i := range segments {
+		segments[i] = uriEncode(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery 按参数名排序并编码，同时作为请求的查询字符串
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, uriEncode(key)+"="+uriEncode(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func uriEncode(s string) string {
+	var builder strings.Builder
+	for _, b := range []byte(s) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' {
+			builder.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&builder, "%%%02X", b)
+	}
+	return builder.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hashFile(localPath string) (string, int64, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// writeLocal 写入本地文件，先写临时文件再重命名
+func writeLocal(localPath string, reader io.Reader) error {
+	tmp := localPath + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, localPath)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
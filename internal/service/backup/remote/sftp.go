@@ -0,0 +1,193 @@
+package remote
+
+import (
+	"context"
+	"dst-admin-go/internal/model"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpTarget SFTP 存储，每次创建时建立连接，Close 时断开
+type sftpTarget struct {
+	conn   *ssh.Client
+	client *sftp.Client
+	dir    string
+}
+
+func newSFTPTarget(setting model.BackupTarget, dir string, timeout time.Duration) (*sftpTarget, error) {
+	addr := setting.Endpoint
+	if addr == "" {
+		return nil, errors.New("SFTP 需要设置 host:port")
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+	var auth []ssh.AuthMethod
+	if setting.PrivateKey != "" {
+		var signer ssh.Signer
+		var err error
+		if setting.Password != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(setting.PrivateKey), []byte(setting.Password))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(setting.PrivateKey))
+		}
+		if err != nil {
+			return nil, errors.New("解析 SFTP 私钥失败: " + err.Error())
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	} else {
+		auth = append(auth, ssh.Password(setting.Password))
+	}
+	var hostKeyCallback ssh.HostKeyCallback
+	expected := strings.TrimSpace(setting.HostKey)
+	switch {
+	case expected != "":
+		hostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if ssh.FingerprintSHA256(key) != expected {
+				return errors.New("SFTP 服务器公钥指纹不匹配: " + ssh.FingerprintSHA256(key))
+			}
+			return nil
+		}
+	case setting.InsecureHostKey:
+		log.Println("[Backup]已关闭 SFTP 服务器公钥校验，不校验服务器身份", addr)
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		// 没有设置指纹时拒绝连接，返回服务器的指纹供确认后填写
+		hostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return errors.New("没有设置 SFTP 服务器公钥指纹，服务器的指纹为 " + ssh.FingerprintSHA256(key) + "，确认无误后填写到服务器公钥指纹")
+		}
+	}
+	conn, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            setting.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &sftpTarget{conn: conn, client: client, dir: dir}, nil
+}
+
+func (t *sftpTarget) remotePath(name string) string {
+	if t.dir == "" {
+		return name
+	}
+	return path.Join(t.dir, name)
+}
+
+// watch ctx 取消时断开连接，使阻塞的读写返回
+func (t *sftpTarget) watch(ctx context.Context) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			t.conn.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (t *sftpTarget) Upload(ctx context.Context, name, localPath string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	defer t.watch(ctx)()
+	if t.dir != "" {
+		if err := t.client.MkdirAll(t.dir); err != nil {
+			return err
+		}
+	}
+	local, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer local.Close()
+	// 先写临时文件再重命名，避免中断时留下不完整的备份
+	target := t.remotePath(name)
+	tmp := target + ".tmp"
+	file, err := t.client.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, local)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		t.client.Remove(target)
+		err = t.client.Rename(tmp, target)
+	}
+	if err != nil {
+		t.client.Remove(tmp)
+	}
+	return err
+}
+
+func (t *sftpTarget) Download(ctx context.Context, name, localPath string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	defer t.watch(ctx)()
+	file, err := t.client.Open(t.remotePath(name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return writeLocal(localPath, file)
+}
+
+func (t *sftpTarget) List(ctx context.Context) ([]Object, error) {
+	defer t.watch(ctx)()
+	dir := t.dir
+	if dir == "" {
+		dir = "."
+	}
+	infos, err := t.client.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var objects []Object
+	for _, info := range infos {
+		if info.IsDir() || strings.HasSuffix(info.Name(), ".tmp") {
+			continue
+		}
+		objects = append(objects, Object{Name: info.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return objects, nil
+}
+
+func (t *sftpTarget) Delete(ctx context.Context, name string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	defer t.watch(ctx)()
+	err := t.client.Remove(t.remotePath(name))
+	if err != nil && errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (t *sftpTarget) Close() error {
+	t.client.Close()
+	return t.conn.Close()
+}
@@ -0,0 +1,150 @@
+package remote
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"dst-admin-go/internal/model"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpServer 本机的 SSH 服务，sftp 子系统使用内存文件系统
+type sftpServer struct {
+	listener net.Listener
+	hostKey  ssh.Signer
+}
+
+func newSFTPServer(t *testing.T, username, password string) *sftpServer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if conn.User() == username && string(pass) == password {
+				return nil, nil
+			}
+			return nil, errors.New("密码错误")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	// 所有连接共用同一个内存文件系统
+	handlers := sftp.InMemHandler()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, config, handlers)
+		}
+	}()
+	return &sftpServer{listener: listener, hostKey: hostKey}
+}
+
+func serveSSH(conn net.Conn, config *ssh.ServerConfig, handlers sftp.Handlers) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					server := sftp.NewRequestServer(channel, handlers)
+					server.Serve()
+					server.Close()
+				}
+			}
+		}()
+	}
+}
+
+func (s *sftpServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func TestSFTPTarget(t *testing.T) {
+	server := newSFTPServer(t, "dst", "secret")
+	target, err := New(model.BackupTarget{
+		Type:     TypeSFTP,
+		Endpoint: server.addr(),
+		Username: "dst",
+		Password: "secret",
+		Prefix:   "backup/dst",
+		HostKey:  ssh.FingerprintSHA256(server.hostKey.PublicKey()),
+	}, "Cluster1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	testTarget(t, target)
+}
+
+func TestSFTPTargetConnect(t *testing.T) {
+	server := newSFTPServer(t, "dst", "secret")
+	fingerprint := ssh.FingerprintSHA256(server.hostKey.PublicKey())
+	tests := []struct {
+		name     string
+		password string
+		hostKey  string
+		insecure bool
+		err      string
+	}{
+		{"指纹正确", "secret", fingerprint, false, ""},
+		{"关闭校验", "secret", "", true, ""},
+		{"没有设置指纹时返回服务器的指纹", "secret", "", false, "服务器的指纹为 " + fingerprint},
+		{"密码错误", "wrong", fingerprint, false, "unable to authenticate"},
+		{"指纹不匹配", "secret", "SHA256:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", false, "公钥指纹不匹配"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := New(model.BackupTarget{
+				Type:            TypeSFTP,
+				Endpoint:        server.addr(),
+				Username:        "dst",
+				Password:        tt.password,
+				HostKey:         tt.hostKey,
+				InsecureHostKey: tt.insecure,
+			}, "Cluster1")
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				target.Close()
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
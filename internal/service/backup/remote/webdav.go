@@ -0,0 +1,235 @@
+package remote
+
+import (
+	"context"
+	"dst-admin-go/internal/model"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// webDAVTarget WebDAV 存储，使用 Basic 认证
+type webDAVTarget struct {
+	base     *url.URL
+	username string
+	password string
+	dir      string
+	client   *http.Client
+}
+
+func newWebDAVTarget(setting model.BackupTarget, dir string, timeout time.Duration) (*webDAVTarget, error) {
+	base, err := url.Parse(strings.TrimRight(setting.Endpoint, "/"))
+	if err != nil || base.Host == "" {
+		return nil, errors.New("无效的 WebDAV 地址: " + setting.Endpoint)
+	}
+	return &webDAVTarget{
+		base:     base,
+		username: setting.Username,
+		password: setting.Password,
+		dir:      dir,
+		client:   &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// url 远程路径对应的地址，目录以 / 结尾
+func (t *webDAVTarget) url(name string, isDir bool) string {
+	u := *t.base
+	p := path.Join(u.Path, t.dir, name)
+	if isDir && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	u.Path = p
+	return u.String()
+}
+
+func (t *webDAVTarget) request(ctx context.Context, method, target string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if t.username != "" {
+		req.SetBasicAuth(t.username, t.password)
+	}
+	return req, nil
+}
+
+// do 发送请求，状态码不在 accept 中时返回错误
+func (t *webDAVTarget) do(req *http.Request, accept ...int) (*http.Response, error) {
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range accept {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	if len(accept) == 0 && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	resp.Body.Close()
+	return nil, fmt.Errorf("WebDAV %s 请求失败: %s %s", req.Method, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// mkdirAll 逐级创建远程目录，已存在(405)时忽略
+func (t *webDAVTarget) mkdirAll(ctx context.Context) error {
+	if t.dir == "" {
+		return nil
+	}
+	current := ""
+	for _, part := range strings.Split(t.dir, "/") {
+		current = path.Join(current, part)
+		u := *t.base
+		u.Path = path.Join(u.Path, current) + "/"
+		req, err := t.request(ctx, "MKCOL", u.String(), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := t.do(req, http.StatusCreated, http.StatusMethodNotAllowed, http.StatusOK)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+	return nil
+}
+
+func (t *webDAVTarget) Upload(ctx context.Context, name, localPath string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	if err := t.mkdirAll(ctx); err != nil {
+		return err
+	}
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	req, err := t.request(ctx, http.MethodPut, t.url(name, false), file)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	resp, err := t.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (t *webDAVTarget) Download(ctx context.Context, name, localPath string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	req, err := t.request(ctx, http.MethodGet, t.url(name, false), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return writeLocal(localPath, resp.Body)
+}
+
+type multiStatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ContentLength int64  `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+				ResourceType  struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:getcontentlength/><d:getlastmodified/><d:resourcetype/></d:prop></d:propfind>`
+
+func (t *webDAVTarget) List(ctx context.Context) ([]Object, error) {
+	req, err := t.request(ctx, "PROPFIND", t.url("", true), strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := t.do(req, http.StatusMultiStatus, http.StatusNotFound)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	result := multiStatus{}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析 WebDAV 列表失败: %w", err)
+	}
+	var objects []Object
+	for _, item := range result.Responses {
+		href, err := url.PathUnescape(item.Href)
+		if err != nil {
+			href = item.Href
+		}
+		if strings.HasSuffix(href, "/") {
+			continue
+		}
+		object := Object{Name: path.Base(href)}
+		isDir := false
+		for _, propstat := range item.Propstat {
+			prop := propstat.Prop
+			if prop.ResourceType.Collection != nil {
+				isDir = true
+			}
+			if prop.ContentLength > 0 {
+				object.Size = prop.ContentLength
+			}
+			if modTime, err := http.ParseTime(prop.LastModified); err == nil {
+				object.ModTime = modTime
+			}
+		}
+		if !isDir {
+			objects = append(objects, object)
+		}
+	}
+	return objects, nil
+}
+
+func (t *webDAVTarget) Delete(ctx context.Context, name string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	req, err := t.request(ctx, http.MethodDelete, t.url(name, false), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(req, http.StatusOK, http.StatusNoContent, http.StatusNotFound)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (t *webDAVTarget) Close() error {
+	return nil
+}
@@ -0,0 +1,68 @@
+package remote
+
+import (
+	"context"
+	"dst-admin-go/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+// newWebDAVServer 内存中的 WebDAV 服务，挂载在 /dav/ 下，使用 Basic 认证
+func newWebDAVServer(username, password string) *httptest.Server {
+	handler := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != username || p != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="dav"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+func TestWebDAVTarget(t *testing.T) {
+	server := newWebDAVServer("dst", "secret")
+	defer server.Close()
+
+	for _, prefix := range []string{"", "backup/dst"} {
+		t.Run("prefix="+prefix, func(t *testing.T) {
+			target, err := New(model.BackupTarget{
+				Type:     TypeWebDAV,
+				Endpoint: server.URL + "/dav/",
+				Username: "dst",
+				Password: "secret",
+				Prefix:   prefix,
+			}, "Cluster-"+strings.ReplaceAll(prefix, "/", "-"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer target.Close()
+			testTarget(t, target)
+		})
+	}
+}
+
+func TestWebDAVTargetUnauthorized(t *testing.T) {
+	server := newWebDAVServer("dst", "secret")
+	defer server.Close()
+	target, err := New(model.BackupTarget{
+		Type:     TypeWebDAV,
+		Endpoint: server.URL + "/dav",
+		Username: "dst",
+		Password: "wrong",
+	}, "Cluster1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := target.List(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("密码错误时应返回 401，err = %v", err)
+	}
+}
@@ -0,0 +1,322 @@
+package backup
+
+import (
+	"context"
+	"dst-admin-go/internal/database"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"dst-admin-go/internal/service/backup/remote"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// remotePrefix 从远程下载的备份在本地的文件名前缀
+	remotePrefix = "(remote)"
+	// maskedSecret 返回给前端的密码占位，保存时收到占位表示不修改
+	maskedSecret = "******"
+
+	defaultRemoteRetries = 3
+	maxRemoteBackoff     = 5 * time.Minute
+)
+
+// GetRemoteSetting 获取集群的远程备份设置，密码和私钥不返回原文
+func (b *BackupService) GetRemoteSetting(clusterName string) model.BackupTarget {
+	setting := b.remoteSetting(clusterName)
+	if setting.Password != "" {
+		setting.Password = maskedSecret
+	}
+	if setting.PrivateKey != "" {
+		setting.PrivateKey = maskedSecret
+	}
+	return setting
+}
+
+// remoteSetting 集群的远程备份设置，不存在时返回默认设置
+func (b *BackupService) remoteSetting(clusterName string) model.BackupTarget {
+	setting := model.BackupTarget{}
+	if err := database.Db.Where("cluster_name = ?", clusterName).First(&setting).Error; err != nil {
+		setting = model.BackupTarget{
+			ClusterName: clusterName,
+			Type:        remote.TypeS3,
+			PathStyle:   true,
+			Retries:     defaultRemoteRetries,
+		}
+	}
+	return setting
+}
+
+// mergeRemoteSetting 用前端提交的设置覆盖已保存的设置，密码为占位时保留原值
+func (b *BackupService) mergeRemoteSetting(clusterName string, setting model.BackupTarget) (model.BackupTarget, error) {
+	switch setting.Type {
+	case remote.TypeS3, remote.TypeSFTP, remote.TypeWebDAV:
+	default:
+		return model.BackupTarget{}, errors.New("不支持的远程存储类型: " + setting.Type)
+	}
+	if setting.Retries < 0 || setting.MaxBackups < 0 || setting.MaxAge < 0 || setting.Timeout < 0 {
+		return model.BackupTarget{}, errors.New("重试次数、保留数量和时间不能为负数")
+	}
+	saved := b.remoteSetting(clusterName)
+	if setting.Password == maskedSecret {
+		setting.Password = saved.Password
+	}
+	if setting.PrivateKey == maskedSecret {
+		setting.PrivateKey = saved.PrivateKey
+	}
+	setting.Model = saved.Model
+	setting.ClusterName = clusterName
+	return setting, nil
+}
+
+// SaveRemoteSetting 保存集群的远程备份设置
+func (b *BackupService) SaveRemoteSetting(clusterName string, setting model.BackupTarget) (model.BackupTarget, error) {
+	setting, err := b.mergeRemoteSetting(clusterName, setting)
+	if err != nil {
+		return model.BackupTarget{}, err
+	}
+	if setting.Type == remote.TypeSFTP && strings.TrimSpace(setting.HostKey) == "" && !setting.InsecureHostKey {
+		return model.BackupTarget{}, errors.New("SFTP 需要设置服务器公钥指纹，可以先测试连接获取指纹")
+	}
+	if err := database.Db.Save(&setting).Error; err != nil {
+		return model.BackupTarget{}, err
+	}
+	return b.GetRemoteSetting(clusterName), nil
+}
+
+// TestRemote 使用提交的设置连接远程存储并列出备份，不保存设置
+func (b *BackupService) TestRemote(clusterName string, setting model.BackupTarget) ([]remote.Object, error) {
+	setting, err := b.mergeRemoteSetting(clusterName, setting)
+	if err != nil {
+		return nil, err
+	}
+	return listRemote(setting, clusterName)
+}
+
+// openRemote 打开集群已开启的远程存储
+func (b *BackupService) openRemote(clusterName string) (remote.Target, model.BackupTarget, error) {
+	setting := b.remoteSetting(clusterName)
+	if setting.ID == 0 || setting.Enable != 1 {
+		return nil, setting, errors.New("远程备份未开启")
+	}
+	target, err := remote.New(setting, clusterName)
+	return target, setting, err
+}
+
+func listRemote(setting model.BackupTarget, clusterName string) ([]remote.Object, error) {
+	target, err := remote.New(setting, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	defer target.Close()
+	objects, err := target.List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var backups []remote.Object
+	for _, object := range objects {
+		if strings.HasSuffix(object.Name, ".zip") {
+			backups = append(backups, object)
+		}
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].ModTime.After(backups[j].ModTime)
+	})
+	return backups, nil
+}
+
+// ListRemoteBackups 远程存储中的备份，按修改时间倒序
+func (b *BackupService) ListRemoteBackups(clusterName string) ([]remote.Object, error) {
+	setting := b.remoteSetting(clusterName)
+	if setting.ID == 0 || setting.Enable != 1 {
+		return nil, errors.New("远程备份未开启")
+	}
+	return listRemote(setting, clusterName)
+}
+
+// pushRemoteAsync 远程备份开启时在后台上传备份
+func (b *BackupService) pushRemoteAsync(clusterName, fileName string) {
+	setting := b.remoteSetting(clusterName)
+	if setting.ID == 0 || setting.Enable != 1 {
+		return
+	}
+	go func() {
+		if err := b.PushRemote(clusterName, fileName); err != nil {
+			log.Println("[Backup]上传远程备份失败", clusterName, fileName, err)
+		}
+	}()
+}
+
+// PushRemote 上传本地备份到远程存储，失败时按退避时间重试，上传完成后清理远程的旧备份
+// 仓库快照导出为 zip 后上传
+func (b *BackupService) PushRemote(clusterName, fileName string) error {
+	config, err := b.dstConfig.GetDstConfig(clusterName)
+	if err != nil {
+		return err
+	}
+	setting := b.remoteSetting(clusterName)
+	if setting.ID == 0 || setting.Enable != 1 {
+		return errors.New("远程备份未开启")
+	}
+
+	b.remoteMu.Lock()
+	defer b.remoteMu.Unlock()
+
+	fileName = filepath.Base(fileName)
+	localPath := filepath.Join(config.Backup, fileName)
+	remoteName := fileName
+	if isRepoBackup(fileName) {
+		remoteName = strings.TrimSuffix(fileName, RepoSuffix) + ".zip"
+		localPath = filepath.Join(config.Backup, "."+remoteName+".upload")
+		if err := b.exportRepoBackupFile(config.Backup, fileName, localPath); err != nil {
+			return err
+		}
+		defer os.Remove(localPath)
+	} else if !fileUtils.Exists(localPath) {
+		return errors.New("备份不存在: " + fileName)
+	}
+
+	// 新集群的默认设置由 remoteSetting 填充重试次数，保存的 0 表示不重试
+	retries := setting.Retries
+	backoff := 5 * time.Second
+	for attempt := 0; ; attempt++ {
+		err = uploadRemote(setting, clusterName, remoteName, localPath)
+		if err == nil {
+			break
+		}
+		if attempt >= retries {
+			return err
+		}
+		log.Println("[Backup]上传远程备份失败，稍后重试", remoteName, "attempt:", attempt+1, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxRemoteBackoff)
+	}
+	log.Println("[Backup]已上传远程备份", clusterName, remoteName)
+
+	if err := applyRemoteRetention(setting, clusterName); err != nil {
+		log.Println("[Backup]清理远程备份失败", clusterName, err)
+	}
+	return nil
+}
+
+// uploadRemote 每次重试重新建立连接
+func uploadRemote(setting model.BackupTarget, clusterName, name, localPath string) error {
+	target, err := remote.New(setting, clusterName)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+	return target.Upload(context.Background(), name, localPath)
+}
+
+// applyRemoteRetention 按保留数量和保留时间删除远程的旧备份
+func applyRemoteRetention(setting model.BackupTarget, clusterName string) error {
+	if setting.MaxBackups <= 0 && setting.MaxAge <= 0 {
+		return nil
+	}
+	backups, err := listRemote(setting, clusterName)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(-time.Duration(setting.MaxAge) * time.Hour)
+	var expired []string
+	for i, backup := range backups {
+		if setting.MaxBackups > 0 && i >= setting.MaxBackups {
+			expired = append(expired, backup.Name)
+		} else if setting.MaxAge > 0 && backup.ModTime.Before(deadline) {
+			expired = append(expired, backup.Name)
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	target, err := remote.New(setting, clusterName)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+	var errs []error
+	for _, name := range expired {
+		log.Println("[Backup]删除远程旧备份", clusterName, name)
+		if err := target.Delete(context.Background(), name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DeleteRemoteBackup 删除远程备份
+func (b *BackupService) DeleteRemoteBackup(clusterName, fileName string) error {
+	target, _, err := b.openRemote(clusterName)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+	return target.Delete(context.Background(), filepath.Base(fileName))
+}
+
+// DownloadRemoteBackup 下载远程备份到本地备份目录，返回本地文件名
+func (b *BackupService) DownloadRemoteBackup(clusterName, fileName string) (string, error) {
+	config, err := b.dstConfig.GetDstConfig(clusterName)
+	if err != nil {
+		return "", err
+	}
+	target, _, err := b.openRemote(clusterName)
+	if err != nil {
+		return "", err
+	}
+	defer target.Close()
+
+	fileName = filepath.Base(fileName)
+	localName := fileName
+	if !strings.HasPrefix(localName, remotePrefix) {
+		localName = remotePrefix + localName
+	}
+	log.Println("[Backup]正在下载远程备份", clusterName, fileName, localName)
+	if err := target.Download(context.Background(), fileName, filepath.Join(config.Backup, localName)); err != nil {
+		return "", err
+	}
+	return localName, nil
+}
+
+// RestoreRemoteBackup 下载远程备份后按本地备份的流程校验并恢复
+func (b *BackupService) RestoreRemoteBackup(clusterName, fileName string, force bool) (RestoreResult, error) {
+	if running := b.runningLevels(clusterName); len(running) > 0 && !force {
+		return RestoreResult{}, errors.New("世界正在运行，请先停止: " + strings.Join(running, ", "))
+	}
+	localName, err := b.DownloadRemoteBackup(clusterName, fileName)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	return b.RestoreBackup(clusterName, localName, force)
+}
+
+// exportRepoBackupFile 将仓库快照导出为本地 zip 文件
+func (b *BackupService) exportRepoBackupFile(backupPath, fileName, dst string) error {
+	b.repoMu.RLock()
+	defer b.repoMu.RUnlock()
+	repo, err := openRepository(repositoryPath(backupPath))
+	if err != nil {
+		return err
+	}
+	snapshot, err := repo.Find(fileName)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	err = repo.ExportZip(snapshot, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
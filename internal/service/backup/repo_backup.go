@@ -0,0 +1,237 @@
+package backup
+
+import (
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func isRepoBackup(fileName string) bool {
+	return strings.HasSuffix(fileName, RepoSuffix)
+}
+
+// CreateRepositoryBackup 将集群存档保存到备份仓库，name 为空时按普通备份的规则生成
+func (b *BackupService) CreateRepositoryBackup(clusterName, name string) (RepoSnapshot, error) {
+	config, err := b.dstConfig.GetDstConfig(clusterName)
+	if err != nil {
+		return RepoSnapshot{}, err
+	}
+	clusterPath := b.archive.ClusterPath(clusterName)
+	if !fileUtils.Exists(clusterPath) {
+		return RepoSnapshot{}, errors.New("存档目录不存在: " + clusterPath)
+	}
+	if name == "" {
+		name = strings.TrimSuffix(b.GenGameBackUpName(clusterName), ".zip")
+	}
+	name = strings.TrimSuffix(name, RepoSuffix)
+
+	b.repoMu.RLock()
+	defer b.repoMu.RUnlock()
+	repo, err := openRepository(repositoryPath(config.Backup))
+	if err != nil {
+		return RepoSnapshot{}, err
+	}
+	if _, err := repo.Find(name + RepoSuffix); err == nil {
+		return RepoSnapshot{}, errors.New("快照已存在: " + name)
+	}
+	snapshot := &RepoSnapshot{
+		Name:        name,
+		ClusterName: clusterName,
+		CreatedAt:   time.Now(),
+		Manifest:    *b.newManifest(clusterName),
+	}
+	if err := repo.Store(clusterPath, snapshot); err != nil {
+		return RepoSnapshot{}, err
+	}
+	log.Println("[Backup]已保存到备份仓库", clusterName, snapshot.FileName())
+	return *snapshot, nil
+}
+
+// repoBackupList 备份仓库中属于集群的快照
+func (b *BackupService) repoBackupList(clusterName, backupPath string) []BackupInfo {
+	root := repositoryPath(backupPath)
+	if !fileUtils.Exists(root) {
+		return nil
+	}
+	b.repoMu.RLock()
+	defer b.repoMu.RUnlock()
+	repo, err := openRepository(root)
+	if err != nil {
+		log.Println("[Backup]打开备份仓库失败", err)
+		return nil
+	}
+	snapshots, err := repo.Snapshots()
+	if err != nil {
+		log.Println("[Backup]读取备份仓库失败", err)
+		return nil
+	}
+	var backupList []BackupInfo
+	for _, snapshot := range snapshots {
+		if snapshot.ClusterName != clusterName {
+			continue
+		}
+		backupList = append(backupList, BackupInfo{
+			FileName:   snapshot.FileName(),
+			FileSize:   snapshot.Size,
+			CreateTime: snapshot.CreatedAt,
+			Time:       snapshot.CreatedAt.Unix(),
+			Kind:       KindRepository,
+		})
+	}
+	return backupList
+}
+
+func (b *BackupService) renameRepoBackup(backupPath, fileName, newName string) error {
+	b.repoMu.Lock()
+	defer b.repoMu.Unlock()
+	repo, err := openRepository(repositoryPath(backupPath))
+	if err != nil {
+		return err
+	}
+	snapshot, err := repo.Find(fileName)
+	if err != nil {
+		return err
+	}
+	return repo.Rename(snapshot, strings.TrimSuffix(newName, RepoSuffix))
+}
+
+// removeBackups 删除压缩包或仓库快照，删除了快照时清理不再引用的块
+func (b *BackupService) removeBackups(backupPath string, fileNames []string) error {
+	var repoNames []string
+	for _, fileName := range fileNames {
+		if isRepoBackup(fileName) {
+			repoNames = append(repoNames, fileName)
+			continue
+		}
+		filePath := filepath.Join(backupPath, filepath.Base(fileName))
+		if !fileUtils.Exists(filePath) {
+			continue
+		}
+		if err := fileUtils.DeleteFile(filePath); err != nil {
+			return err
+		}
+	}
+	if len(repoNames) == 0 {
+		return nil
+	}
+
+	b.repoMu.Lock()
+	defer b.repoMu.Unlock()
+	repo, err := openRepository(repositoryPath(backupPath))
+	if err != nil {
+		return err
+	}
+	for _, fileName := range repoNames {
+		snapshot, err := repo.Find(fileName)
+		if err != nil {
+			continue
+		}
+		if err := repo.Delete(snapshot); err != nil {
+			return err
+		}
+	}
+	result, err := repo.GC()
+	if err != nil {
+		return err
+	}
+	log.Println("[Backup]备份仓库清理完成", "removed:", result.Removed, "freed:", result.FreedBytes)
+	return nil
+}
+
+func (b *BackupService) verifyRepoBackup(backupPath, fileName string) (VerifyResult, error) {
+	b.repoMu.RLock()
+	defer b.repoMu.RUnlock()
+	repo, err := openRepository(repositoryPath(backupPath))
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	snapshot, err := repo.Find(fileName)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	return repo.Verify(snapshot), nil
+}
+
+// materializeRepoBackup 将仓库快照还原到 staging，还原时校验每个块和文件
+func (b *BackupService) materializeRepoBackup(backupPath, fileName, staging string) (VerifyResult, error) {
+	b.repoMu.RLock()
+	defer b.repoMu.RUnlock()
+	repo, err := openRepository(repositoryPath(backupPath))
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	snapshot, err := repo.Find(fileName)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	log.Println("[Backup]正在还原仓库快照", fileName, staging)
+	manifest := snapshot.Manifest
+	result := VerifyResult{
+		FileName:    fileName,
+		HasManifest: true,
+		Manifest:    &manifest,
+	}
+	if err := repo.Materialize(snapshot, staging); err != nil {
+		os.RemoveAll(staging)
+		result.Errors = []string{err.Error()}
+		return result, errors.New("还原快照失败: " + err.Error())
+	}
+	result.Valid = true
+	result.Files = len(snapshot.Entries)
+	return result, nil
+}
+
+// exportRepoBackup 将仓库快照导出为 zip 下载
+func (b *BackupService) exportRepoBackup(c *gin.Context, backupPath, fileName string) {
+	b.repoMu.RLock()
+	defer b.repoMu.RUnlock()
+	repo, err := openRepository(repositoryPath(backupPath))
+	if err != nil {
+		log.Panicln("open backup repository error", err)
+	}
+	snapshot, err := repo.Find(fileName)
+	if err != nil {
+		log.Panicln("download snapshot error", err)
+	}
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", "attachment; filename="+snapshot.Name+".zip")
+	if err := repo.ExportZip(snapshot, c.Writer); err != nil {
+		log.Println("[Backup]导出快照失败", fileName, err)
+	}
+}
+
+// RepositoryStats 备份仓库统计
+func (b *BackupService) RepositoryStats(clusterName string) (RepoStats, error) {
+	config, err := b.dstConfig.GetDstConfig(clusterName)
+	if err != nil {
+		return RepoStats{}, err
+	}
+	b.repoMu.RLock()
+	defer b.repoMu.RUnlock()
+	repo, err := openRepository(repositoryPath(config.Backup))
+	if err != nil {
+		return RepoStats{}, err
+	}
+	return repo.Stats()
+}
+
+// GCRepository 清理备份仓库中不再被快照引用的块
+func (b *BackupService) GCRepository(clusterName string) (GCResult, error) {
+	config, err := b.dstConfig.GetDstConfig(clusterName)
+	if err != nil {
+		return GCResult{}, err
+	}
+	b.repoMu.Lock()
+	defer b.repoMu.Unlock()
+	repo, err := openRepository(repositoryPath(config.Backup))
+	if err != nil {
+		return GCResult{}, err
+	}
+	return repo.GC()
+}
@@ -0,0 +1,478 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RepoSuffix 备份列表中仓库快照的文件名后缀
+const RepoSuffix = ".snapshot"
+
+const (
+	repoChunksDir    = "chunks"
+	repoSnapshotsDir = "snapshots"
+)
+
+// Repository 按内容寻址的备份仓库，文件切分成块后以 SHA-256 命名保存，快照之间相同的块只保存一份
+//
+//	chunks/ab/abcdef...   gzip 压缩的块
+//	snapshots/<id>.json   快照，记录每个文件由哪些块组成
+type Repository struct {
+	root string
+}
+
+// RepoSnapshot 仓库中的快照
+type RepoSnapshot struct {
+	Id          string      `json:"id"`
+	Name        string      `json:"name"`
+	ClusterName string      `json:"clusterName"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	Size        int64       `json:"size"`
+	Manifest    Manifest    `json:"manifest"`
+	Entries     []RepoEntry `json:"entries"`
+}
+
+// RepoEntry 快照中的文件，Path 为相对集群目录的路径
+type RepoEntry struct {
+	Path    string      `json:"path"`
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"modTime"`
+	Chunks  []string    `json:"chunks"`
+}
+
+// RepoStats 仓库统计
+type RepoStats struct {
+	Path      string `json:"path"`
+	Snapshots int    `json:"snapshots"`
+	Chunks    int    `json:"chunks"`
+	// StoredSize 块占用的磁盘大小
+	StoredSize int64 `json:"storedSize"`
+	// LogicalSize 所有快照的原始大小之和
+	LogicalSize int64 `json:"logicalSize"`
+}
+
+// GCResult 垃圾回收结果
+type GCResult struct {
+	Removed    int   `json:"removed"`
+	FreedBytes int64 `json:"freedBytes"`
+}
+
+// repositoryPath 仓库位于备份目录旁边，例如 /root/backup 对应 /root/backup.repo
+func repositoryPath(backupPath string) string {
+	return filepath.Clean(backupPath) + ".repo"
+}
+
+func openRepository(root string) (*Repository, error) {
+	for _, dir := range []string{repoChunksDir, repoSnapshotsDir} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &Repository{root: root}, nil
+}
+
+// FileName 快照在备份列表中的文件名
+func (s RepoSnapshot) FileName() string {
+	return s.Name + RepoSuffix
+}
+
+func (r *Repository) chunkPath(id string) string {
+	return filepath.Join(r.root, repoChunksDir, id[:2], id)
+}
+
+func (r *Repository) snapshotPath(id string) string {
+	return filepath.Join(r.root, repoSnapshotsDir, id+".json")
+}
+
+// Store 将集群目录保存为新快照
+func (r *Repository) Store(clusterPath string, snapshot *RepoSnapshot) error {
+	if snapshot.Id == "" {
+		snapshot.Id = strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	snapshot.Manifest.Files = nil
+	snapshot.Entries = nil
+	snapshot.Size = 0
+	err := filepath.Walk(clusterPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(clusterPath, filePath)
+		if err != nil {
+			return err
+		}
+		entry, sum, err := r.storeFile(filePath)
+		if err != nil {
+			return err
+		}
+		entry.Path = filepath.ToSlash(relPath)
+		entry.Mode = info.Mode().Perm()
+		entry.ModTime = info.ModTime()
+		snapshot.Entries = append(snapshot.Entries, entry)
+		snapshot.Manifest.Files = append(snapshot.Manifest.Files, ManifestFile{
+			Path:   entry.Path,
+			Size:   entry.Size,
+			Sha256: sum,
+		})
+		snapshot.Size += entry.Size
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(snapshot.Entries, func(i, j int) bool {
+		return snapshot.Entries[i].Path < snapshot.Entries[j].Path
+	})
+	sort.Slice(snapshot.Manifest.Files, func(i, j int) bool {
+		return snapshot.Manifest.Files[i].Path < snapshot.Manifest.Files[j].Path
+	})
+	return r.saveSnapshot(snapshot)
+}
+
+// storeFile 切分文件并保存不存在的块，返回文件的 SHA-256
+func (r *Repository) storeFile(filePath string) (RepoEntry, string, error) {
+	entry := RepoEntry{}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return entry, "", err
+	}
+	defer file.Close()
+
+	fileHash := sha256.New()
+	chunks := newChunker(io.TeeReader(file, fileHash))
+	for {
+		data, err := chunks.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return entry, "", err
+		}
+		sum := sha256.Sum256(data)
+		id := hex.EncodeToString(sum[:])
+		if err := r.writeChunk(id, data); err != nil {
+			return entry, "", err
+		}
+		entry.Chunks = append(entry.Chunks, id)
+		entry.Size += int64(len(data))
+	}
+	return entry, hex.EncodeToString(fileHash.Sum(nil)), nil
+}
+
+func (r *Repository) writeChunk(id string, data []byte) error {
+	target := r.chunkPath(id)
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return writeFileAtomic(target, buf.Bytes())
+}
+
+// readChunk 读取块并校验内容与块名一致
+func (r *Repository) readChunk(id string) ([]byte, error) {
+	file, err := os.Open(r.chunkPath(id))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("块 %s 已损坏: %w", id, err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("块 %s 已损坏: %w", id, err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != id {
+		return nil, fmt.Errorf("块 %s 校验失败", id)
+	}
+	return data, nil
+}
+
+func (r *Repository) saveSnapshot(snapshot *RepoSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.snapshotPath(snapshot.Id), data)
+}
+
+// Snapshots 仓库中的所有快照，按创建时间升序
+func (r *Repository) Snapshots() ([]RepoSnapshot, error) {
+	entries, err := os.ReadDir(filepath.Join(r.root, repoSnapshotsDir))
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []RepoSnapshot
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		snapshot, err := r.Load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+func (r *Repository) Load(id string) (RepoSnapshot, error) {
+	snapshot := RepoSnapshot{}
+	data, err := os.ReadFile(r.snapshotPath(id))
+	if err != nil {
+		return snapshot, err
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("解析快照 %s 失败: %w", id, err)
+	}
+	return snapshot, nil
+}
+
+// Find 根据备份列表中的文件名查找快照
+func (r *Repository) Find(fileName string) (RepoSnapshot, error) {
+	snapshots, err := r.Snapshots()
+	if err != nil {
+		return RepoSnapshot{}, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.FileName() == fileName {
+			return snapshot, nil
+		}
+	}
+	return RepoSnapshot{}, errors.New("快照不存在: " + fileName)
+}
+
+// Rename 修改快照名称，名称在仓库中必须唯一
+func (r *Repository) Rename(snapshot RepoSnapshot, name string) error {
+	if name == "" {
+		return errors.New("名称不能为空")
+	}
+	if _, err := r.Find(name + RepoSuffix); err == nil {
+		return errors.New("快照已存在: " + name)
+	}
+	snapshot.Name = name
+	return r.saveSnapshot(&snapshot)
+}
+
+// Delete 删除快照，块由 GC 清理
+func (r *Repository) Delete(snapshot RepoSnapshot) error {
+	return os.Remove(r.snapshotPath(snapshot.Id))
+}
+
+// Materialize 将快照还原到 dest 目录，每个块和文件都会校验
+func (r *Repository) Materialize(snapshot RepoSnapshot, dest string) error {
+	sums := map[string]string{}
+	for _, file := range snapshot.Manifest.Files {
+		sums[file.Path] = file.Sha256
+	}
+	for _, entry := range snapshot.Entries {
+		rel := path.Clean(entry.Path)
+		if rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+			return errors.New("不安全的路径: " + entry.Path)
+		}
+		target := filepath.Join(dest, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return err
+		}
+		mode := entry.Mode
+		if mode == 0 {
+			mode = 0644
+		}
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		err = r.copyEntry(io.MultiWriter(file, hash), entry)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Path, err)
+		}
+		if sum, ok := sums[entry.Path]; ok && sum != hex.EncodeToString(hash.Sum(nil)) {
+			return errors.New("文件校验失败: " + entry.Path)
+		}
+		os.Chtimes(target, entry.ModTime, entry.ModTime)
+	}
+	return nil
+}
+
+func (r *Repository) copyEntry(writer io.Writer, entry RepoEntry) error {
+	for _, id := range entry.Chunks {
+		data, err := r.readChunk(id)
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify 校验快照引用的块是否存在且内容完整
+func (r *Repository) Verify(snapshot RepoSnapshot) VerifyResult {
+	manifest := snapshot.Manifest
+	result := VerifyResult{
+		FileName:    snapshot.FileName(),
+		HasManifest: true,
+		Manifest:    &manifest,
+	}
+	sums := map[string]string{}
+	for _, file := range snapshot.Manifest.Files {
+		sums[file.Path] = file.Sha256
+	}
+	for _, entry := range snapshot.Entries {
+		hash := sha256.New()
+		if err := r.copyEntry(hash, entry); err != nil {
+			result.Errors = append(result.Errors, entry.Path+": "+err.Error())
+			continue
+		}
+		result.Files++
+		if sums[entry.Path] != hex.EncodeToString(hash.Sum(nil)) {
+			result.Errors = append(result.Errors, "文件校验失败: "+entry.Path)
+		}
+	}
+	result.Valid = len(result.Errors) == 0
+	return result
+}
+
+// ExportZip 将快照导出为与普通备份相同结构的 zip，包含清单，可以上传后恢复
+func (r *Repository) ExportZip(snapshot RepoSnapshot, w io.Writer) error {
+	writer := zip.NewWriter(w)
+	for _, entry := range snapshot.Entries {
+		header := &zip.FileHeader{
+			Name:     path.Join(snapshot.ClusterName, entry.Path),
+			Method:   zip.Deflate,
+			Modified: entry.ModTime,
+		}
+		header.SetMode(entry.Mode)
+		zipEntry, err := writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if err := r.copyEntry(zipEntry, entry); err != nil {
+			return fmt.Errorf("%s: %w", entry.Path, err)
+		}
+	}
+	manifest := snapshot.Manifest
+	if err := addManifest(writer, &manifest); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// GC 删除没有被任何快照引用的块
+func (r *Repository) GC() (GCResult, error) {
+	result := GCResult{}
+	snapshots, err := r.Snapshots()
+	if err != nil {
+		return result, err
+	}
+	referenced := map[string]bool{}
+	for _, snapshot := range snapshots {
+		for _, entry := range snapshot.Entries {
+			for _, id := range entry.Chunks {
+				referenced[id] = true
+			}
+		}
+	}
+	err = filepath.Walk(filepath.Join(r.root, repoChunksDir), func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if referenced[info.Name()] {
+			return nil
+		}
+		if err := os.Remove(filePath); err != nil {
+			return err
+		}
+		result.Removed++
+		result.FreedBytes += info.Size()
+		return nil
+	})
+	return result, err
+}
+
+// Stats 仓库统计
+func (r *Repository) Stats() (RepoStats, error) {
+	stats := RepoStats{Path: r.root}
+	snapshots, err := r.Snapshots()
+	if err != nil {
+		return stats, err
+	}
+	stats.Snapshots = len(snapshots)
+	for _, snapshot := range snapshots {
+		stats.LogicalSize += snapshot.Size
+	}
+	err = filepath.Walk(filepath.Join(r.root, repoChunksDir), func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		stats.Chunks++
+		stats.StoredSize += info.Size()
+		return nil
+	})
+	return stats, err
+}
+
+// writeFileAtomic 先写入临时文件再重命名，避免留下不完整的文件
+// 多个集群的快照在读锁下并发写入，可能写入同一个块，每次写入使用不同的临时文件
+func writeFileAtomic(target string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(target), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWriteFileAtomicConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "chunk")
+	data := []byte("same chunk")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- writeFileAtomic(target, data)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("并发写入同一个块失败: %v", err)
+		}
+	}
+
+	if got, _ := os.ReadFile(target); string(got) != string(data) {
+		t.Fatalf("文件内容 = %q", got)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("留下了临时文件: %v", entries)
+	}
+}
@@ -0,0 +1,498 @@
+package backup
+
+import (
+	"archive/zip"
+	"dst-admin-go/internal/database"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/utils/dstUtils"
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BackupNode 备份中的文件或目录，Path 为相对集群目录的路径，使用 / 分隔
+type BackupNode struct {
+	Name     string        `json:"name"`
+	Path     string        `json:"path"`
+	Dir      bool          `json:"dir"`
+	Size     int64         `json:"size"`
+	ModTime  time.Time     `json:"modTime"`
+	Children []*BackupNode `json:"children,omitempty"`
+}
+
+// BackupPlayer 备份中保存的玩家存档，Path 为玩家的 session 目录
+type BackupPlayer struct {
+	KuId      string `json:"kuId"`
+	Name      string `json:"name"`
+	LevelName string `json:"levelName"`
+	SessionId string `json:"sessionId"`
+	Path      string `json:"path"`
+}
+
+// BackupTree 备份的目录结构
+type BackupTree struct {
+	FileName string         `json:"fileName"`
+	Levels   []string       `json:"levels"`
+	Root     *BackupNode    `json:"root"`
+	Players  []BackupPlayer `json:"players"`
+}
+
+// SelectiveRestore 部分恢复的内容
+type SelectiveRestore struct {
+	BackupName string `json:"backupName"`
+	// Paths 相对集群目录的文件或目录，如 Caves、cluster.ini、Master/modoverrides.lua
+	Paths []string `json:"paths"`
+	// Players 要恢复存档的玩家 KuId
+	Players []string `json:"players"`
+	// LevelName 只恢复该世界中的玩家存档，为空时恢复所有世界中的
+	LevelName string `json:"levelName"`
+	// Force 涉及的世界正在运行时先停止这些世界
+	Force bool `json:"force"`
+}
+
+// RestoredPlayer 恢复的玩家存档，From 为备份中的路径，To 为恢复到的路径
+type RestoredPlayer struct {
+	KuId      string `json:"kuId"`
+	LevelName string `json:"levelName"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+}
+
+// SelectiveRestoreResult 部分恢复的结果
+type SelectiveRestoreResult struct {
+	Verify        VerifyResult     `json:"verify"`
+	Restored      []string         `json:"restored"`
+	Players       []RestoredPlayer `json:"players"`
+	StoppedLevels []string         `json:"stoppedLevels"`
+	SafetyBackup  string           `json:"safetyBackup"`
+}
+
+// backupEntries 备份中的文件，不解压
+func (b *BackupService) backupEntries(backupPath, backupName string) ([]BackupNode, error) {
+	if isRepoBackup(backupName) {
+		b.repoMu.RLock()
+		defer b.repoMu.RUnlock()
+		repo, err := openRepository(repositoryPath(backupPath))
+		if err != nil {
+			return nil, err
+		}
+		snapshot, err := repo.Find(backupName)
+		if err != nil {
+			return nil, err
+		}
+		var entries []BackupNode
+		for _, entry := range snapshot.Entries {
+			entries = append(entries, BackupNode{Path: entry.Path, Size: entry.Size, ModTime: entry.ModTime})
+		}
+		return entries, nil
+	}
+
+	filePath := filepath.Join(backupPath, filepath.Base(backupName))
+	if !fileUtils.Exists(filePath) {
+		return nil, errors.New("备份不存在: " + backupName)
+	}
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("打开备份失败: %w", err)
+	}
+	defer reader.Close()
+	root, err := clusterRoot(reader.File)
+	if err != nil {
+		return nil, err
+	}
+	var entries []BackupNode
+	for _, file := range reader.File {
+		if file.Name == ManifestName || file.FileInfo().IsDir() {
+			continue
+		}
+		rel := relativeName(root, file.Name)
+		if rel == "" {
+			continue
+		}
+		entries = append(entries, BackupNode{Path: rel, Size: int64(file.UncompressedSize64), ModTime: file.Modified})
+	}
+	return entries, nil
+}
+
+// GetBackupTree 备份的目录结构和其中的玩家存档，玩家名称从连接记录中获取
+func (b *BackupService) GetBackupTree(clusterName, backupName string) (BackupTree, error) {
+	config, err := b.dstConfig.GetDstConfig(clusterName)
+	if err != nil {
+		return BackupTree{}, err
+	}
+	entries, err := b.backupEntries(config.Backup, backupName)
+	if err != nil {
+		return BackupTree{}, err
+	}
+	tree := BackupTree{
+		FileName: backupName,
+		Root:     &BackupNode{Dir: true},
+		Levels:   backupLevels(entries),
+		Players:  backupPlayers(entries),
+	}
+	dirs := map[string]*BackupNode{"": tree.Root}
+	var dirOf func(p string) *BackupNode
+	dirOf = func(p string) *BackupNode {
+		if node, ok := dirs[p]; ok {
+			return node
+		}
+		parent := dirOf(parentPath(p))
+		node := &BackupNode{Name: path.Base(p), Path: p, Dir: true}
+		parent.Children = append(parent.Children, node)
+		dirs[p] = node
+		return node
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	for i := range entries {
+		entry := entries[i]
+		entry.Name = path.Base(entry.Path)
+		parent := dirOf(parentPath(entry.Path))
+		parent.Children = append(parent.Children, &entry)
+		for p := parent; ; p = dirs[parentPath(p.Path)] {
+			p.Size += entry.Size
+			if p.Path == "" {
+				break
+			}
+		}
+	}
+
+	names := map[string]string{}
+	for i := range tree.Players {
+		kuId := tree.Players[i].KuId
+		if _, ok := names[kuId]; !ok {
+			connect := model.Connect{}
+			database.Db.Where("cluster_name = ? AND ku_id = ?", clusterName, kuId).Order("id desc").Limit(1).Find(&connect)
+			names[kuId] = connect.Name
+		}
+		tree.Players[i].Name = names[kuId]
+	}
+	return tree, nil
+}
+
+func parentPath(p string) string {
+	dir := path.Dir(p)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// backupLevels 备份中包含 server.ini 的世界目录
+func backupLevels(entries []BackupNode) []string {
+	var levels []string
+	for _, entry := range entries {
+		parts := strings.Split(entry.Path, "/")
+		if len(parts) == 2 && parts[1] == "server.ini" {
+			levels = append(levels, parts[0])
+		}
+	}
+	sort.Strings(levels)
+	return levels
+}
+
+// backupPlayers 备份中的玩家存档，位于 <世界>/save/session/<session id>/<KuId>_/
+func backupPlayers(entries []BackupNode) []BackupPlayer {
+	exists := map[string]bool{}
+	var players []BackupPlayer
+	for _, entry := range entries {
+		parts := strings.Split(entry.Path, "/")
+		if len(parts) < 6 || parts[1] != "save" || parts[2] != "session" {
+			continue
+		}
+		dir := parts[4]
+		if !strings.HasPrefix(dir, "KU_") || !strings.HasSuffix(dir, "_") {
+			continue
+		}
+		playerPath := strings.Join(parts[:5], "/")
+		if exists[playerPath] {
+			continue
+		}
+		exists[playerPath] = true
+		players = append(players, BackupPlayer{
+			KuId:      strings.TrimSuffix(dir, "_"),
+			LevelName: parts[0],
+			SessionId: parts[3],
+			Path:      playerPath,
+		})
+	}
+	return players
+}
+
+// cleanRestorePath 检查并规范化相对集群目录的路径
+func cleanRestorePath(p string) (string, error) {
+	rel := path.Clean(strings.Trim(strings.ReplaceAll(p, "\\", "/"), "/"))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+		return "", errors.New("无效的路径: " + p)
+	}
+	return rel, nil
+}
+
+// underPath p 是否为 parent 或位于 parent 目录中
+func underPath(p, parent string) bool {
+	return p == parent || strings.HasPrefix(p, parent+"/")
+}
+
+// sessionOfPlayer 连接记录中玩家最近一次的 session 目录，SessionFile 形如 <session id>/KU_xxx_[/0000000005]
+func sessionOfPlayer(clusterName, kuId string) (sessionId, dir string) {
+	connect := model.Connect{}
+	database.Db.Where("cluster_name = ? AND ku_id = ? AND session_file <> ''", clusterName, kuId).Order("id desc").Limit(1).Find(&connect)
+	parts := strings.Split(strings.Trim(connect.SessionFile, "/"), "/")
+	if len(parts) < 2 {
+		return "", kuId + "_"
+	}
+	return parts[0], parts[1]
+}
+
+// currentSessionId 世界当前的 session id，即最近写入 .meta 文件的 session 目录
+func currentSessionId(sessionPath string) string {
+	dirs, err := os.ReadDir(sessionPath)
+	if err != nil {
+		return ""
+	}
+	sessionId := ""
+	var latest time.Time
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(sessionPath, dir.Name()))
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if file.IsDir() || filepath.Ext(file.Name()) != ".meta" {
+				continue
+			}
+			if info, err := file.Info(); err == nil && info.ModTime().After(latest) {
+				latest = info.ModTime()
+				sessionId = dir.Name()
+			}
+		}
+	}
+	return sessionId
+}
+
+// restoreItem 需要替换的文件或目录，from 为相对 staging 的路径，to 为相对集群目录的路径
+type restoreItem struct {
+	from, to string
+	// level 不为空时恢复前需要停止该世界
+	level string
+}
+
+// planPlayers 按 KuId 找到备份中的玩家存档，恢复到世界当前的 session 目录
+// 备份中有多个 session 时优先使用连接记录中的 session，其次使用最新的
+func (b *BackupService) planPlayers(clusterName string, request SelectiveRestore, entries []BackupNode) ([]restoreItem, []RestoredPlayer, error) {
+	players := backupPlayers(entries)
+	latest := map[string]time.Time{}
+	for _, entry := range entries {
+		for _, player := range players {
+			if underPath(entry.Path, player.Path) && entry.ModTime.After(latest[player.Path]) {
+				latest[player.Path] = entry.ModTime
+			}
+		}
+	}
+
+	var items []restoreItem
+	var restored []RestoredPlayer
+	for _, kuId := range request.Players {
+		sessionId, dir := sessionOfPlayer(clusterName, kuId)
+		// 每个世界选出一个 session
+		chosen := map[string]BackupPlayer{}
+		for _, player := range players {
+			if player.Path[strings.LastIndex(player.Path, "/")+1:] != dir && player.KuId != kuId {
+				continue
+			}
+			if request.LevelName != "" && player.LevelName != request.LevelName {
+				continue
+			}
+			current, ok := chosen[player.LevelName]
+			switch {
+			case !ok:
+				chosen[player.LevelName] = player
+			case current.SessionId == sessionId:
+			case player.SessionId == sessionId || latest[player.Path].After(latest[current.Path]):
+				chosen[player.LevelName] = player
+			}
+		}
+		if len(chosen) == 0 {
+			return nil, nil, errors.New("备份中没有该玩家的存档: " + kuId)
+		}
+		levels := make([]string, 0, len(chosen))
+		for levelName := range chosen {
+			levels = append(levels, levelName)
+		}
+		sort.Strings(levels)
+		for _, levelName := range levels {
+			player := chosen[levelName]
+			targetSession := currentSessionId(b.archive.SessionPath(clusterName, levelName))
+			if targetSession == "" {
+				targetSession = player.SessionId
+			}
+			to := path.Join(levelName, "save", "session", targetSession, path.Base(player.Path))
+			items = append(items, restoreItem{from: player.Path, to: to, level: levelName})
+			restored = append(restored, RestoredPlayer{KuId: kuId, LevelName: levelName, From: player.Path, To: to})
+		}
+	}
+	return items, restored, nil
+}
+
+// planPaths 选择的路径对应的恢复项，世界目录和 save 目录中的内容恢复前需要停止世界，世界的配置文件不需要
+func planPaths(paths []string, entries []BackupNode, levels []string) ([]restoreItem, error) {
+	isLevel := map[string]bool{}
+	for _, levelName := range levels {
+		isLevel[levelName] = true
+	}
+	var items []restoreItem
+	for _, p := range paths {
+		rel, err := cleanRestorePath(p)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, entry := range entries {
+			if underPath(entry.Path, rel) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("备份中没有该路径: " + p)
+		}
+		item := restoreItem{from: rel, to: rel}
+		parts := strings.Split(rel, "/")
+		if isLevel[parts[0]] && (len(parts) == 1 || parts[1] == "save") {
+			item.level = parts[0]
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// RestoreSelective 从备份中恢复部分文件：世界目录、配置文件或玩家存档
+// 先校验备份并解压到临时目录，再为当前存档创建安全备份，最后逐项用重命名替换
+// 涉及的世界正在运行时拒绝恢复，Force 为 true 时只停止这些世界
+func (b *BackupService) RestoreSelective(clusterName string, request SelectiveRestore) (SelectiveRestoreResult, error) {
+	result := SelectiveRestoreResult{}
+	if len(request.Paths) == 0 && len(request.Players) == 0 {
+		return result, errors.New("请选择要恢复的文件或玩家")
+	}
+	config, err := b.dstConfig.GetDstConfig(clusterName)
+	if err != nil {
+		return result, err
+	}
+	clusterPath := b.archive.ClusterPath(clusterName)
+	if !fileUtils.Exists(clusterPath) {
+		return result, errors.New("存档目录不存在，请使用完整恢复: " + clusterPath)
+	}
+	entries, err := b.backupEntries(config.Backup, request.BackupName)
+	if err != nil {
+		return result, err
+	}
+	items, err := planPaths(request.Paths, entries, backupLevels(entries))
+	if err != nil {
+		return result, err
+	}
+	playerItems, players, err := b.planPlayers(clusterName, request, entries)
+	if err != nil {
+		return result, err
+	}
+	items = append(items, playerItems...)
+	result.Players = players
+
+	// 去掉包含在其它恢复项中的项，避免重复替换
+	sort.SliceStable(items, func(i, j int) bool { return len(items[i].to) < len(items[j].to) })
+	var plan []restoreItem
+	for _, item := range items {
+		covered := false
+		for _, other := range plan {
+			if underPath(item.to, other.to) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			plan = append(plan, item)
+		}
+	}
+
+	var running []string
+	seen := map[string]bool{}
+	for _, item := range plan {
+		if item.level == "" || seen[item.level] {
+			continue
+		}
+		seen[item.level] = true
+		if ok, _ := b.gameProcess.Status(clusterName, item.level); ok {
+			running = append(running, item.level)
+		}
+	}
+	if len(running) > 0 {
+		if !request.Force {
+			return result, errors.New("世界正在运行，请先停止: " + strings.Join(running, ", "))
+		}
+		for _, levelName := range running {
+			log.Println("[Backup]强制恢复，正在停止世界", clusterName, levelName)
+			if err := b.gameProcess.Stop(clusterName, levelName); err != nil {
+				return result, fmt.Errorf("停止世界 %s 失败: %w", levelName, err)
+			}
+		}
+		result.StoppedLevels = running
+	}
+
+	// 临时目录与存档目录在同一个目录下，保证可以直接重命名
+	suffix := time.Now().Format("20060102150405")
+	staging := filepath.Join(filepath.Dir(clusterPath), "."+clusterName+".restore-"+suffix)
+	defer os.RemoveAll(staging)
+	result.Verify, err = b.stageBackup(config.Backup, request.BackupName, staging)
+	if err != nil {
+		return result, err
+	}
+	result.SafetyBackup, err = b.createSafetyBackup(clusterName, config.Backup)
+	if err != nil {
+		return result, err
+	}
+
+	setupMods := false
+	for _, item := range plan {
+		from := filepath.Join(staging, filepath.FromSlash(item.from))
+		to := filepath.Join(clusterPath, filepath.FromSlash(item.to))
+		if err := os.MkdirAll(filepath.Dir(to), os.ModePerm); err != nil {
+			return result, err
+		}
+		info, err := os.Stat(from)
+		if err != nil {
+			return result, err
+		}
+		if info.IsDir() {
+			err = swapDir(to, from, suffix)
+		} else {
+			err = os.Rename(from, to)
+		}
+		if err != nil {
+			return result, fmt.Errorf("恢复 %s 失败: %w", item.to, err)
+		}
+		log.Println("[Backup]已恢复", clusterName, item.from, "->", item.to)
+		result.Restored = append(result.Restored, item.to)
+		if info.IsDir() && !strings.Contains(item.to, "/") || path.Base(item.to) == "modoverrides.lua" {
+			setupMods = true
+		}
+	}
+
+	if setupMods {
+		modoverride, err := fileUtils.ReadFile(b.archive.ModoverridesPath(clusterName, "Master"))
+		if err != nil {
+			log.Println("读取模组失败", err)
+		}
+		if err := dstUtils.DedicatedServerModsSetup(config, modoverride); err != nil {
+			log.Println(err.Error())
+		}
+	}
+	return result, nil
+}
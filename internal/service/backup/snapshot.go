@@ -0,0 +1,315 @@
+package backup
+
+import (
+	"context"
+	"crypto/md5"
+	"dst-admin-go/internal/database"
+	"dst-admin-go/internal/model"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const snapshotPrefix = "(snapshot)"
+
+// ScheduleBackupSnapshots 启动所有集群的定时快照
+func (b *BackupService) ScheduleBackupSnapshots() {
+	db := database.Db
+	b.migrateLegacySnapshot()
+
+	var snapshots []model.BackupSnapshot
+	db.Where("enable = ?", 1).Find(&snapshots)
+	for i := range snapshots {
+		b.ReloadSnapshot(snapshots[i].ClusterName)
+	}
+	log.Println("[Snapshot]定时快照已启动，集群数:", len(snapshots))
+}
+
+// migrateLegacySnapshot 旧版本只有一条不区分集群的快照设置，迁移到当前默认集群
+func (b *BackupService) migrateLegacySnapshot() {
+	db := database.Db
+	var legacy []model.BackupSnapshot
+	db.Where("cluster_name = ? OR cluster_name IS NULL", "").Find(&legacy)
+	if len(legacy) == 0 {
+		return
+	}
+	config, err := b.dstConfig.GetDstConfig("")
+	if err != nil || config.Cluster == "" {
+		return
+	}
+	for i := range legacy {
+		var count int64
+		db.Model(&model.BackupSnapshot{}).Where("cluster_name = ?", config.Cluster).Count(&count)
+		if count > 0 {
+			db.Delete(&legacy[i])
+			continue
+		}
+		legacy[i].ClusterName = config.Cluster
+		db.Save(&legacy[i])
+		log.Println("[Snapshot]迁移旧的快照设置到集群", config.Cluster)
+	}
+}
+
+// GetSnapshotSetting 获取集群的快照设置，不存在时返回默认设置
+func (b *BackupService) GetSnapshotSetting(clusterName string) model.BackupSnapshot {
+	snapshot := model.BackupSnapshot{}
+	if err := database.Db.Where("cluster_name = ?", clusterName).First(&snapshot).Error; err != nil {
+		snapshot = model.BackupSnapshot{
+			ClusterName:  clusterName,
+			Interval:     8,
+			MaxSnapshots: 6,
+		}
+	}
+	return snapshot
+}
+
+// SaveSnapshotSetting 保存集群的快照设置，并立即按新设置重新调度
+func (b *BackupService) SaveSnapshotSetting(clusterName string, setting model.BackupSnapshot) model.BackupSnapshot {
+	db := database.Db
+	snapshot := model.BackupSnapshot{}
+	db.Where("cluster_name = ?", clusterName).First(&snapshot)
+
+	snapshot.ClusterName = clusterName
+	snapshot.Enable = setting.Enable
+	snapshot.Interval = setting.Interval
+	snapshot.MaxSnapshots = setting.MaxSnapshots
+	snapshot.MaxAge = setting.MaxAge
+	snapshot.IsCSave = setting.IsCSave
+	db.Save(&snapshot)
+
+	b.ReloadSnapshot(clusterName)
+	return snapshot
+}
+
+// ReloadSnapshot 重新启动集群的快照协程，快照未开启时只停止
+func (b *BackupService) ReloadSnapshot(clusterName string) {
+	b.snapshotMu.Lock()
+	defer b.snapshotMu.Unlock()
+
+	if cancel, ok := b.snapshotCancels[clusterName]; ok {
+		cancel()
+		delete(b.snapshotCancels, clusterName)
+	}
+	snapshot := b.GetSnapshotSetting(clusterName)
+	if snapshot.Enable != 1 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b.snapshotCancels[clusterName] = cancel
+	go b.superviseSnapshot(ctx, clusterName)
+}
+
+// superviseSnapshot 快照协程异常退出时自动重启
+func (b *BackupService) superviseSnapshot(ctx context.Context, clusterName string) {
+	for {
+		b.runSnapshot(ctx, clusterName)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Minute):
+			log.Println("[Snapshot]快照协程异常退出，正在重启", "cluster:", clusterName)
+		}
+	}
+}
+
+func (b *BackupService) runSnapshot(ctx context.Context, clusterName string) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("[Snapshot]创建快照异常", "cluster:", clusterName, r)
+		}
+	}()
+	for {
+		snapshot := b.GetSnapshotSetting(clusterName)
+		if snapshot.Interval <= 0 {
+			snapshot.Interval = 8
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(snapshot.Interval) * time.Minute):
+		}
+
+		// 重新读取设置，等待期间可能已被修改；关闭快照时由 ReloadSnapshot 取消协程，这里只跳过本次
+		snapshot = b.GetSnapshotSetting(clusterName)
+		if snapshot.Enable != 1 {
+			continue
+		}
+		b.createSnapshot(snapshot)
+	}
+}
+
+func (b *BackupService) createSnapshot(snapshot model.BackupSnapshot) {
+	clusterName := snapshot.ClusterName
+	config, err := b.dstConfig.GetDstConfig(clusterName)
+	if err != nil {
+		log.Println("[Snapshot]failed to get dst config for snapshot:", err)
+		return
+	}
+	if snapshot.IsCSave == 1 {
+		// 执行 CSave 命令
+		err := b.gameProcess.Command(clusterName, "Master", "c_save()")
+		if err != nil {
+			log.Println("[Snapshot]CSave command error:", err)
+		}
+		// 等待保存完成
+		time.Sleep(2 * time.Second)
+	}
+
+	md5 := sumMd5(b.archive.ClusterPath(clusterName))
+	if md5 != "" && md5 == snapshot.LastMd5 {
+		log.Println("[Snapshot]存档没有变化，跳过快照", "cluster:", clusterName)
+	} else {
+		b.CreateSnapshotBackup(snapshotPrefix, clusterName)
+		database.Db.Model(&model.BackupSnapshot{}).Where("id = ?", snapshot.ID).Update("last_md5", md5)
+	}
+
+	maxSnapshots := snapshot.MaxSnapshots
+	if maxSnapshots <= 0 {
+		maxSnapshots = 6
+	}
+	b.DeleteBackupSnapshots(snapshotPrefix, maxSnapshots, clusterName, config.Backup)
+	if snapshot.MaxAge > 0 {
+		b.DeleteExpiredSnapshots(snapshotPrefix, time.Duration(snapshot.MaxAge)*time.Hour, clusterName, config.Backup)
+	}
+}
+
+// sumMd5 计算集群所有世界 save 目录的 md5，用于判断存档是否有变化
+func sumMd5(clusterPath string) string {
+	hash := md5.New()
+	err := filepath.Walk(clusterPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(clusterPath, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(relPath), "/")
+		if len(parts) < 3 || parts[1] != "save" {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		io.WriteString(hash, relPath)
+		_, err = io.Copy(hash, file)
+		return err
+	})
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (b *BackupService) CreateSnapshotBackup(prefix, clusterName string) {
+
+	config, err := b.dstConfig.GetDstConfig(clusterName)
+	if err != nil {
+		log.Println("failed to get dst config:", err)
+		return
+	}
+
+	// 快照保存到备份仓库，与之前的快照相同的内容只保存一份
+	name := strings.TrimSuffix(b.GenBackUpSnapshotName(prefix, clusterName), ".zip")
+	log.Println("[Snapshot]正在定时创建游戏备份", "cluster: ", clusterName, "repository: ", repositoryPath(config.Backup), "name: ", name)
+	snapshot, err := b.CreateRepositoryBackup(clusterName, name)
+	if err != nil {
+		log.Println("[Snapshot]create backup error", err)
+		return
+	}
+	b.pushRemoteAsync(clusterName, snapshot.FileName())
+}
+
+// snapshotZipCluster 解析快照压缩包的文件名 <prefix><时间>_<集群名称>.zip，返回其中的集群名称
+// 集群名称中可能有 _，时间中没有，按第一个 _ 分割
+func snapshotZipCluster(prefix, name string) (string, bool) {
+	rest, ok := strings.CutPrefix(name, prefix)
+	if !ok {
+		return "", false
+	}
+	rest, ok = strings.CutSuffix(rest, ".zip")
+	if !ok {
+		return "", false
+	}
+	backupTime, clusterName, ok := strings.Cut(rest, "_")
+	if !ok {
+		return "", false
+	}
+	if _, err := time.Parse(backupTimeLayout, backupTime); err != nil {
+		return "", false
+	}
+	return clusterName, true
+}
+
+// snapshotList 集群的快照列表，按创建时间升序
+// 所有集群共用备份目录，压缩包按文件名中的集群名称完全匹配，仓库快照已按 RepoSnapshot.ClusterName 过滤
+func (b *BackupService) snapshotList(prefix, clusterName string) []BackupInfo {
+	backupList := b.GetBackupList(clusterName)
+	var snapshotList []BackupInfo
+	for i := range backupList {
+		name := backupList[i].FileName
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if backupList[i].Kind == KindRepository {
+			snapshotList = append(snapshotList, backupList[i])
+			continue
+		}
+		if cluster, ok := snapshotZipCluster(prefix, name); ok && cluster == clusterName {
+			snapshotList = append(snapshotList, backupList[i])
+		}
+	}
+	sort.SliceStable(snapshotList, func(i, j int) bool {
+		return snapshotList[i].CreateTime.Before(snapshotList[j].CreateTime)
+	})
+	return snapshotList
+}
+
+func (b *BackupService) DeleteBackupSnapshots(prefix string, maxSnapshots int, clusterName, backupPath string) {
+
+	log.Println("[Snapshot]正在删除快照备份", "maxSnapshots", maxSnapshots, "clusterName: ", clusterName)
+
+	newBackupList := b.snapshotList(prefix, clusterName)
+	if len(newBackupList) > maxSnapshots {
+		deleteBackupList := newBackupList[:len(newBackupList)-maxSnapshots]
+		var fileNames []string
+		for i := range deleteBackupList {
+			log.Println("删除快照备份", deleteBackupList[i].FileName)
+			fileNames = append(fileNames, deleteBackupList[i].FileName)
+		}
+		if err := b.removeBackups(backupPath, fileNames); err != nil {
+			log.Println("[Snapshot]删除快照备份失败", err)
+		}
+	}
+
+}
+
+// DeleteExpiredSnapshots 删除超过保留时间的快照
+func (b *BackupService) DeleteExpiredSnapshots(prefix string, maxAge time.Duration, clusterName, backupPath string) {
+	deadline := time.Now().Add(-maxAge)
+	var fileNames []string
+	for _, snapshot := range b.snapshotList(prefix, clusterName) {
+		if snapshot.CreateTime.After(deadline) {
+			continue
+		}
+		log.Println("删除过期快照备份", snapshot.FileName)
+		fileNames = append(fileNames, snapshot.FileName)
+	}
+	if len(fileNames) == 0 {
+		return
+	}
+	if err := b.removeBackups(backupPath, fileNames); err != nil {
+		log.Println("[Snapshot]删除过期快照失败", err)
+	}
+}
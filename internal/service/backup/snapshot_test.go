@@ -0,0 +1,25 @@
+package backup
+
+import "testing"
+
+func TestSnapshotZipCluster(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster string
+		ok      bool
+	}{
+		{"(snapshot)2024年01月02日03点04分05秒_B.zip", "B", true},
+		{"(snapshot)2024年01月02日03点04分05秒_A_B.zip", "A_B", true},
+		{"(snapshot)2024年01月02日03点04分05秒_集群 1.zip", "集群 1", true},
+		{"2024年01月02日03点04分05秒_B.zip", "", false},
+		{"(snapshot)2024年01月02日03点04分05秒_B.snapshot", "", false},
+		{"(snapshot)my_B.zip", "", false},
+		{"(snapshot)2024年01月02日03点04分05秒.zip", "", false},
+	}
+	for _, tt := range tests {
+		cluster, ok := snapshotZipCluster(snapshotPrefix, tt.name)
+		if ok != tt.ok || cluster != tt.cluster {
+			t.Errorf("snapshotZipCluster(%q) = %q, %v, want %q, %v", tt.name, cluster, ok, tt.cluster, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package cluster
+
+import (
+	"dst-admin-go/internal/collect"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"dst-admin-go/internal/service/announce"
+	"dst-admin-go/internal/service/archive"
+	"dst-admin-go/internal/service/autoCheck"
+	"dst-admin-go/internal/service/backup"
+	"dst-admin-go/internal/service/game"
+	"dst-admin-go/internal/service/levelConfig"
+	"dst-admin-go/internal/service/schedule"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+// clusterNamePattern 集群名称同时是存档目录名，只允许字母、数字、下划线和中划线
+var clusterNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ClusterInfo 集群信息
+type ClusterInfo struct {
+	model.Cluster
+	Running bool `json:"running"`
+}
+
+// ClusterService 集群管理，负责集群记录、存档目录以及集群相关的后台任务
+type ClusterService struct {
+	db               *gorm.DB
+	archive          *archive.PathResolver
+	gameProcess      game.Process
+	levelConfigUtils *levelConfig.LevelConfigUtils
+	collectMap       *collect.CollectMap
+	backupService    *backup.BackupService
+	schedule         *schedule.Schedule
+	autoCheck        *autoCheck.AutoCheckService
+	announce         *announce.AnnounceService
+}
+
+func NewClusterService(db *gorm.DB, archive *archive.PathResolver, gameProcess game.Process, levelConfigUtils *levelConfig.LevelConfigUtils, collectMap *collect.CollectMap, backupService *backup.BackupService, schedule *schedule.Schedule, autoCheck *autoCheck.AutoCheckService, announce *announce.AnnounceService) *ClusterService {
+	return &ClusterService{
+		db:               db,
+		archive:          archive,
+		gameProcess:      gameProcess,
+		levelConfigUtils: levelConfigUtils,
+		collectMap:       collectMap,
+		backupService:    backupService,
+		schedule:         schedule,
+		autoCheck:        autoCheck,
+		announce:         announce,
+	}
+}
+
+// clusterModels 以 cluster_name 关联集群的数据，集群重命名时一起迁移，删除集群时一起删除
+// 审计日志保留原集群名称，不做修改
+var clusterModels = []interface{}{
+	&model.JobTask{},
+	&model.JobTaskRecord{},
+	&model.AutoCheck{},
+	&model.Announce{},
+	&model.BackupSnapshot{},
+	&model.BackupTarget{},
+	&model.UserCluster{},
+	&model.PlayerLog{},
+	&model.Spawn{},
+	&model.Connect{},
+	&model.Regenerate{},
+}
+
+// GetClusterNames 获取所有集群名称
+func (s *ClusterService) GetClusterNames() []string {
+	names := make([]string, 0)
+	s.db.Model(&model.Cluster{}).Order("id asc").Pluck("cluster_name", &names)
+	return names
+}
+
+// GetClusterList 获取集群列表
+func (s *ClusterService) GetClusterList() []ClusterInfo {
+	var clusters []model.Cluster
+	s.db.Order("id asc").Find(&clusters)
+
+	list := make([]ClusterInfo, 0, len(clusters))
+	for i := range clusters {
+		list = append(list, ClusterInfo{
+			Cluster: clusters[i],
+			Running: s.IsRunning(clusters[i].ClusterName),
+		})
+	}
+	return list
+}
+
+// IsRunning 集群是否有世界在运行
+func (s *ClusterService) IsRunning(clusterName string) bool {
+	return len(s.runningLevels(clusterName)) > 0
+}
+
+func (s *ClusterService) runningLevels(clusterName string) []string {
+	levels := make([]string, 0)
+	if !fileUtils.Exists(s.archive.ClusterPath(clusterName)) {
+		return levels
+	}
+	config, err := s.levelConfigUtils.GetLevelConfig(clusterName)
+	if err != nil {
+		return levels
+	}
+	for _, item := range config.LevelList {
+		if running, _ := s.gameProcess.Status(clusterName, item.File); running {
+			levels = append(levels, item.File)
+		}
+	}
+	return levels
+}
+
+func (s *ClusterService) exists(clusterName string) bool {
+	var count int64
+	s.db.Model(&model.Cluster{}).Where("cluster_name = ?", clusterName).Count(&count)
+	return count > 0
+}
+
+func (s *ClusterService) validateNewName(clusterName string) error {
+	if !clusterNamePattern.MatchString(clusterName) {
+		return errors.New("集群名称只能包含字母、数字、下划线和中划线: " + clusterName)
+	}
+	if s.exists(clusterName) {
+		return errors.New("集群已存在: " + clusterName)
+	}
+	return nil
+}
+
+// CreateCluster 创建集群，存档目录已存在时直接使用，不存在时初始化一个森林世界
+func (s *ClusterService) CreateCluster(cluster *model.Cluster) error {
+	if err := s.validateNewName(cluster.ClusterName); err != nil {
+		return err
+	}
+	cluster.ID = 0
+	if err := s.db.Create(cluster).Error; err != nil {
+		return err
+	}
+	if _, err := s.levelConfigUtils.GetLevelConfig(cluster.ClusterName); err != nil {
+		log.Println("[Cluster]初始化集群世界失败", cluster.ClusterName, err)
+	}
+	s.collectMap.AddNewCollect(cluster.ClusterName)
+	log.Println("[Cluster]创建集群", cluster.ClusterName)
+	return nil
+}
+
+// CloneCluster 复制集群的配置和存档目录到新集群，定时任务等后台任务不复制
+// 新集群的端口与原集群相同，需要修改端口后才能同时运行
+func (s *ClusterService) CloneCluster(sourceName, clusterName, description string) (*model.Cluster, error) {
+	source := model.Cluster{}
+	if s.db.Where("cluster_name = ?", sourceName).Limit(1).Find(&source).RowsAffected == 0 {
+		return nil, errors.New("集群不存在: " + sourceName)
+	}
+	if err := s.validateNewName(clusterName); err != nil {
+		return nil, err
+	}
+
+	// 新集群复制了原集群的配置，存档根目录相同
+	sourcePath := s.archive.ClusterPath(sourceName)
+	targetPath := filepath.Join(filepath.Dir(sourcePath), clusterName)
+	if fileUtils.Exists(targetPath) {
+		return nil, errors.New("存档目录已存在: " + targetPath)
+	}
+	cluster := source
+	cluster.Model = gorm.Model{}
+	cluster.ClusterName = clusterName
+	cluster.Description = description
+	if err := s.db.Create(&cluster).Error; err != nil {
+		return nil, err
+	}
+	if err := copyDir(sourcePath, targetPath); err != nil {
+		s.db.Unscoped().Delete(&cluster)
+		os.RemoveAll(targetPath)
+		return nil, errors.New("复制存档失败: " + err.Error())
+	}
+	s.collectMap.AddNewCollect(clusterName)
+	log.Println("[Cluster]复制集群", sourceName, "->", clusterName)
+	return &cluster, nil
+}
+
+// RenameCluster 重命名集群，同时重命名存档目录并迁移定时任务、自动检测、公告、快照设置和授权
+func (s *ClusterService) RenameCluster(oldName, newName string) error {
+	if oldName == newName {
+		return nil
+	}
+	if !s.exists(oldName) {
+		return errors.New("集群不存在: " + oldName)
+	}
+	if err := s.validateNewName(newName); err != nil {
+		return err
+	}
+	if s.IsRunning(oldName) {
+		return errors.New("请先停止集群再重命名")
+	}
+
+	oldPath := s.archive.ClusterPath(oldName)
+	newPath := filepath.Join(filepath.Dir(oldPath), newName)
+	if fileUtils.Exists(newPath) {
+		return errors.New("存档目录已存在: " + newPath)
+	}
+	s.collectMap.RemoveCollect(oldName)
+	s.announce.StopCluster(oldName)
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Cluster{}).Where("cluster_name = ?", oldName).Update("cluster_name", newName).Error; err != nil {
+			return err
+		}
+		for _, m := range clusterModels {
+			if err := tx.Model(m).Where("cluster_name = ?", oldName).Update("cluster_name", newName).Error; err != nil {
+				return err
+			}
+		}
+		if fileUtils.Exists(oldPath) {
+			return os.Rename(oldPath, newPath)
+		}
+		return nil
+	})
+	if err != nil {
+		s.collectMap.AddNewCollect(oldName)
+		s.announce.StartCluster(oldName)
+		return err
+	}
+
+	s.backupService.ReloadSnapshot(oldName)
+	s.backupService.ReloadSnapshot(newName)
+	s.autoCheck.ReloadCluster(newName)
+	s.announce.StartCluster(newName)
+	s.collectMap.AddNewCollect(newName)
+	log.Println("[Cluster]重命名集群", oldName, "->", newName)
+	return nil
+}
+
+// DeleteCluster 删除集群及其后台任务，removeFiles 为 true 时同时删除存档目录
+func (s *ClusterService) DeleteCluster(clusterName string, removeFiles bool) error {
+	if !s.exists(clusterName) {
+		return errors.New("集群不存在: " + clusterName)
+	}
+	var count int64
+	s.db.Model(&model.Cluster{}).Count(&count)
+	if count <= 1 {
+		return errors.New("至少需要保留一个集群")
+	}
+	if s.IsRunning(clusterName) {
+		return errors.New("请先停止集群再删除")
+	}
+
+	clusterPath := s.archive.ClusterPath(clusterName)
+	for _, task := range s.schedule.GetJobTaskList(clusterName) {
+		if err := s.schedule.DeleteJobTask(clusterName, task.ID); err != nil {
+			return err
+		}
+	}
+	for _, check := range s.autoCheck.GetAutoCheckList(clusterName) {
+		if err := s.autoCheck.DeleteAutoCheck(clusterName, check.ID); err != nil {
+			return err
+		}
+	}
+	for _, item := range s.announce.GetAnnounceList(clusterName) {
+		if err := s.announce.DeleteAnnounce(clusterName, item.ID); err != nil {
+			return err
+		}
+	}
+	s.collectMap.RemoveCollect(clusterName)
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, m := range clusterModels {
+			if err := tx.Unscoped().Where("cluster_name = ?", clusterName).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		// 集群名称有唯一索引，需要物理删除，才能再次创建同名集群
+		return tx.Unscoped().Where("cluster_name = ?", clusterName).Delete(&model.Cluster{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.backupService.ReloadSnapshot(clusterName)
+
+	if removeFiles {
+		if err := os.RemoveAll(clusterPath); err != nil {
+			return errors.New("删除存档目录失败: " + err.Error())
+		}
+	}
+	log.Println("[Cluster]删除集群", clusterName, "删除存档:", removeFiles)
+	return nil
+}
+
+// copyDir 递归复制目录，保留文件权限
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
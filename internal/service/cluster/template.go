@@ -0,0 +1,440 @@
+package cluster
+
+import (
+	"archive/zip"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"dst-admin-go/internal/service/levelConfig"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// templateMetaName 模板信息在压缩包中的文件名
+	templateMetaName = "template.json"
+	// templateRoot 集群文件在压缩包中的目录
+	templateRoot    = "cluster/"
+	templateVersion = 1
+)
+
+// clusterTemplateFiles 模板包含的集群目录下的文件
+var clusterTemplateFiles = []string{"cluster.ini", "level.json", "adminlist.txt", "whitelist.txt", "blocklist.txt"}
+
+// levelTemplateFiles 模板包含的世界目录下的文件
+var levelTemplateFiles = []string{"leveldataoverride.lua", "modoverrides.lua", "server.ini"}
+
+// TemplateMeta 集群模板信息
+type TemplateMeta struct {
+	Version     int                `json:"version"`
+	ClusterName string             `json:"clusterName"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	WithSaves   bool               `json:"withSaves"`
+	Levels      []levelConfig.Item `json:"levels"`
+	Files       []string           `json:"files"`
+}
+
+// ImportTemplateRequest 导入集群模板的参数，机器相关的配置（steamcmd、备份目录等）从 BaseCluster 复制
+type ImportTemplateRequest struct {
+	ClusterName string `form:"clusterName" json:"clusterName"`
+	Description string `form:"description" json:"description"`
+	BaseCluster string `form:"baseCluster" json:"baseCluster"`
+}
+
+// PortChange 导入时修改的端口
+type PortChange struct {
+	File string `json:"file"`
+	Key  string `json:"key"`
+	From uint   `json:"from"`
+	To   uint   `json:"to"`
+}
+
+// ImportTemplateResult 导入集群模板的结果
+type ImportTemplateResult struct {
+	Cluster   model.Cluster `json:"cluster"`
+	Template  TemplateMeta  `json:"template"`
+	Ports     []PortChange  `json:"ports"`
+	WithSaves bool          `json:"withSaves"`
+}
+
+// portKey 配置文件中的端口，Default 为未设置时饥荒使用的默认值
+type portKey struct {
+	Section string
+	Key     string
+	Default uint
+}
+
+var (
+	clusterPortKeys = []portKey{
+		{Section: "SHARD", Key: "master_port", Default: 10888},
+	}
+	serverPortKeys = []portKey{
+		{Section: "NETWORK", Key: "server_port", Default: 10999},
+		{Section: "STEAM", Key: "authentication_port", Default: 8766},
+		{Section: "STEAM", Key: "master_server_port", Default: 27016},
+	}
+)
+
+// ExportTemplate 将集群的配置导出为模板压缩包，withSaves 为 true 时同时包含各世界的 save 目录
+// 不包含 cluster_token.txt，导入后需要重新设置令牌
+func (s *ClusterService) ExportTemplate(clusterName string, withSaves bool, w io.Writer) error {
+	if !s.exists(clusterName) {
+		return errors.New("集群不存在: " + clusterName)
+	}
+	clusterPath := s.archive.ClusterPath(clusterName)
+	config, err := s.levelConfigUtils.GetLevelConfig(clusterName)
+	if err != nil {
+		return err
+	}
+	meta := TemplateMeta{
+		Version:     templateVersion,
+		ClusterName: clusterName,
+		CreatedAt:   time.Now(),
+		WithSaves:   withSaves,
+		Levels:      config.LevelList,
+	}
+
+	writer := zip.NewWriter(w)
+	addFile := func(rel string) error {
+		filePath := filepath.Join(clusterPath, filepath.FromSlash(rel))
+		if !fileUtils.Exists(filePath) {
+			return nil
+		}
+		if err := addTemplateFile(writer, filePath, rel); err != nil {
+			return err
+		}
+		meta.Files = append(meta.Files, rel)
+		return nil
+	}
+	for _, name := range clusterTemplateFiles {
+		if err := addFile(name); err != nil {
+			return err
+		}
+	}
+	for _, level := range config.LevelList {
+		for _, name := range levelTemplateFiles {
+			if err := addFile(path.Join(level.File, name)); err != nil {
+				return err
+			}
+		}
+		if !withSaves {
+			continue
+		}
+		savePath := filepath.Join(clusterPath, level.File, "save")
+		if !fileUtils.Exists(savePath) {
+			continue
+		}
+		err := filepath.WalkDir(savePath, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(clusterPath, filePath)
+			if err != nil {
+				return err
+			}
+			return addFile(filepath.ToSlash(rel))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	entry, err := writer.Create(templateMetaName)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(meta); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+func addTemplateFile(writer *zip.Writer, filePath, rel string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = templateRoot + rel
+	header.Method = zip.Deflate
+	entry, err := writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(entry, file)
+	return err
+}
+
+// readTemplate 读取模板信息并检查压缩包中的路径
+func readTemplate(reader *zip.Reader) (TemplateMeta, error) {
+	meta := TemplateMeta{}
+	found := false
+	for _, file := range reader.File {
+		if file.Name == templateMetaName {
+			entry, err := file.Open()
+			if err != nil {
+				return meta, err
+			}
+			err = json.NewDecoder(entry).Decode(&meta)
+			entry.Close()
+			if err != nil {
+				return meta, fmt.Errorf("解析模板信息失败: %w", err)
+			}
+			found = true
+			continue
+		}
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if templateRelative(file.Name) == "" {
+			return meta, errors.New("模板中有不安全的路径: " + file.Name)
+		}
+	}
+	if !found {
+		return meta, errors.New("不是集群模板，缺少 " + templateMetaName)
+	}
+	if meta.Version > templateVersion {
+		return meta, fmt.Errorf("不支持的模板版本: %d", meta.Version)
+	}
+	if len(meta.Levels) == 0 {
+		return meta, errors.New("模板中没有世界")
+	}
+	for _, level := range meta.Levels {
+		if level.File == "" || strings.ContainsAny(level.File, "/\\") || level.File == "." || level.File == ".." {
+			return meta, errors.New("模板中的世界目录无效: " + level.File)
+		}
+	}
+	return meta, nil
+}
+
+// templateRelative 压缩包中的文件相对集群目录的路径，不在 cluster/ 中或路径不安全时返回空
+func templateRelative(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if !strings.HasPrefix(name, templateRoot) {
+		return ""
+	}
+	rel := path.Clean(strings.TrimPrefix(name, templateRoot))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+		return ""
+	}
+	return rel
+}
+
+// ImportTemplate 从模板创建新集群，与已有集群冲突的端口改为未使用的端口
+func (s *ClusterService) ImportTemplate(reader *zip.Reader, request ImportTemplateRequest) (*ImportTemplateResult, error) {
+	meta, err := readTemplate(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateNewName(request.ClusterName); err != nil {
+		return nil, err
+	}
+	base := model.Cluster{}
+	if request.BaseCluster != "" {
+		if s.db.Where("cluster_name = ?", request.BaseCluster).Limit(1).Find(&base).RowsAffected == 0 {
+			return nil, errors.New("集群不存在: " + request.BaseCluster)
+		}
+	}
+	// 在创建新集群之前收集端口，避免读到新集群自己的
+	used := s.usedPorts()
+
+	cluster := base
+	cluster.Model = gorm.Model{}
+	cluster.ClusterName = request.ClusterName
+	cluster.Description = request.Description
+	if err := s.db.Create(&cluster).Error; err != nil {
+		return nil, err
+	}
+	targetPath := s.archive.ClusterPath(cluster.ClusterName)
+	rollback := func() {
+		s.db.Unscoped().Delete(&cluster)
+		os.RemoveAll(targetPath)
+	}
+	if fileUtils.Exists(targetPath) {
+		s.db.Unscoped().Delete(&cluster)
+		return nil, errors.New("存档目录已存在: " + targetPath)
+	}
+
+	for _, file := range reader.File {
+		rel := templateRelative(file.Name)
+		if rel == "" || file.FileInfo().IsDir() {
+			continue
+		}
+		if err := extractTemplateFile(file, filepath.Join(targetPath, filepath.FromSlash(rel))); err != nil {
+			rollback()
+			return nil, fmt.Errorf("解压模板失败: %w", err)
+		}
+	}
+	if !fileUtils.Exists(filepath.Join(targetPath, "level.json")) {
+		levels := levelConfig.LevelConfig{LevelList: meta.Levels}
+		if err := s.levelConfigUtils.SaveLevelConfig(cluster.ClusterName, &levels); err != nil {
+			rollback()
+			return nil, err
+		}
+	}
+
+	ports, err := remapPorts(targetPath, meta.Levels, used)
+	if err != nil {
+		rollback()
+		return nil, err
+	}
+	s.collectMap.AddNewCollect(cluster.ClusterName)
+	log.Println("[Cluster]从模板创建集群", meta.ClusterName, "->", cluster.ClusterName, "ports:", len(ports))
+	return &ImportTemplateResult{
+		Cluster:   cluster,
+		Template:  meta,
+		Ports:     ports,
+		WithSaves: meta.WithSaves,
+	}, nil
+}
+
+func extractTemplateFile(file *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	entry, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+	writer, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(writer, entry); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// usedPorts 已有集群使用的端口
+func (s *ClusterService) usedPorts() map[uint]bool {
+	used := map[uint]bool{}
+	for _, clusterName := range s.GetClusterNames() {
+		clusterPath := s.archive.ClusterPath(clusterName)
+		if !fileUtils.Exists(clusterPath) {
+			continue
+		}
+		for _, key := range clusterPortKeys {
+			used[readPort(filepath.Join(clusterPath, "cluster.ini"), key)] = true
+		}
+		config, err := s.levelConfigUtils.GetLevelConfig(clusterName)
+		if err != nil {
+			continue
+		}
+		for _, level := range config.LevelList {
+			for _, key := range serverPortKeys {
+				used[readPort(filepath.Join(clusterPath, level.File, "server.ini"), key)] = true
+			}
+		}
+	}
+	return used
+}
+
+// remapPorts 依次检查集群和各世界的端口，已被使用时改为之后第一个未使用的端口
+func remapPorts(clusterPath string, levels []levelConfig.Item, used map[uint]bool) ([]PortChange, error) {
+	changes := make([]PortChange, 0)
+	remap := func(rel string, keys []portKey) error {
+		filePath := filepath.Join(clusterPath, filepath.FromSlash(rel))
+		if !fileUtils.Exists(filePath) {
+			return nil
+		}
+		for _, key := range keys {
+			port := readPort(filePath, key)
+			to := port
+			for used[to] {
+				if to >= 65535 {
+					return fmt.Errorf("没有可用的端口: %s %s", rel, key.Key)
+				}
+				to++
+			}
+			used[to] = true
+			if to == port {
+				continue
+			}
+			if err := writePort(filePath, key, to); err != nil {
+				return err
+			}
+			changes = append(changes, PortChange{File: rel, Key: key.Key, From: port, To: to})
+		}
+		return nil
+	}
+	if err := remap("cluster.ini", clusterPortKeys); err != nil {
+		return nil, err
+	}
+	for _, level := range levels {
+		if err := remap(path.Join(level.File, "server.ini"), serverPortKeys); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+func sectionPattern(section string) *regexp.Regexp {
+	return regexp.MustCompile(`(?m)^\s*\[` + regexp.QuoteMeta(section) + `\]\s*$`)
+}
+
+func keyPattern(key string) *regexp.Regexp {
+	return regexp.MustCompile(`(?m)^(\s*` + regexp.QuoteMeta(key) + `\s*=\s*)(\d+)`)
+}
+
+// readPort 读取配置文件中的端口，未设置时返回默认值
+func readPort(filePath string, key portKey) uint {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return key.Default
+	}
+	match := keyPattern(key.Key).FindSubmatch(content)
+	if match == nil {
+		return key.Default
+	}
+	port, err := strconv.ParseUint(string(match[2]), 10, 16)
+	if err != nil {
+		return key.Default
+	}
+	return uint(port)
+}
+
+// writePort 只修改端口所在的行，保留文件中的其它内容；没有该项时添加到对应的段落中
+func writePort(filePath string, key portKey, port uint) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	value := strconv.FormatUint(uint64(port), 10)
+	pattern := keyPattern(key.Key)
+	switch {
+	case pattern.Match(content):
+		content = pattern.ReplaceAll(content, []byte("${1}"+value))
+	case sectionPattern(key.Section).Match(content):
+		loc := sectionPattern(key.Section).FindIndex(content)
+		line := []byte("\n" + key.Key + " = " + value)
+		content = append(content[:loc[1]:loc[1]], append(line, content[loc[1]:]...)...)
+	default:
+		content = append(content, []byte("\n["+key.Section+"]\n"+key.Key+" = "+value+"\n")...)
+	}
+	return os.WriteFile(filePath, content, 0644)
+}
@@ -0,0 +1,137 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"dst-admin-go/internal/service/archive"
+	"dst-admin-go/internal/service/game"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeout 默认等待结果的时间
+	DefaultTimeout = 5 * time.Second
+	// MaxTimeout 最长等待结果的时间
+	MaxTimeout = time.Minute
+)
+
+// resultMarker 结果行的前缀，后面跟关联 id
+const resultMarker = "[DST_ADMIN_RESULT:"
+
+// ErrNotRunning 世界未运行
+var ErrNotRunning = errors.New("世界未运行")
+
+// Console 在世界控制台执行 Lua 并等待结果
+// 脚本在 pcall 中执行，返回值通过 customcommands.lua 中的 ToJSONStr 序列化后带上关联 id 打印到 server_log.txt，
+// 再从日志尾部读取对应的结果行
+type Console struct {
+	gameProcess game.Process
+	archive     *archive.PathResolver
+}
+
+type result struct {
+	Ok    bool            `json:"ok"`
+	Data  json.RawMessage `json:"data"`
+	Error string          `json:"error"`
+}
+
+func NewConsole(gameProcess game.Process, archive *archive.PathResolver) *Console {
+	return &Console{
+		gameProcess: gameProcess,
+		archive:     archive,
+	}
+}
+
+func newCorrelationId() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format("150405.000000")))
+	}
+	return hex.EncodeToString(buf)
+}
+
+// Wrap 生成执行脚本并打印结果的单行 Lua 命令
+// script 是函数体，通过 return 返回结果；多行脚本会合并为一行，因此不能包含 -- 注释
+// 结果标记在 Lua 中拼接，控制台回显命令时不会被误认为结果
+func Wrap(id, script string) string {
+	script = strings.NewReplacer("\r\n", " ", "\n", " ").Replace(script)
+	marker := `"` + resultMarker + `" .. "` + id + `] "`
+	return "local __ok, __r = pcall(function() " + script + " end) " +
+		"if ToJSONStr == nil then print(" + marker + ` .. '{"ok":false,"error":"ToJSONStr not loaded, restart the level to load customcommands.lua"}') ` +
+		"elseif __ok then print(" + marker + " .. ToJSONStr({ok = true, data = __r})) " +
+		"else print(" + marker + " .. ToJSONStr({ok = false, error = tostring(__r)})) end"
+}
+
+// Exec 执行 Lua 脚本并等待结果，返回脚本返回值的 JSON
+func (c *Console) Exec(ctx context.Context, clusterName, levelName, script string, timeout time.Duration) (json.RawMessage, error) {
+	if strings.TrimSpace(script) == "" {
+		return nil, errors.New("脚本不能为空")
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if timeout > MaxTimeout {
+		timeout = MaxTimeout
+	}
+	if running, _ := c.gameProcess.Status(clusterName, levelName); !running {
+		return nil, ErrNotRunning
+	}
+
+	id := newCorrelationId()
+	logPath := c.archive.ServerLogPath(clusterName, levelName)
+	offset := fileUtils.FileSize(logPath)
+	if err := c.gameProcess.Command(clusterName, levelName, Wrap(id, script)); err != nil {
+		return nil, err
+	}
+
+	prefix := resultMarker + id + "] "
+	line, err := fileUtils.WaitForLine(ctx, logPath, offset, timeout, func(line string) bool {
+		return strings.Contains(line, prefix)
+	})
+	if err != nil {
+		return nil, err
+	}
+	payload := line[strings.Index(line, prefix)+len(prefix):]
+	var res result
+	if err := json.Unmarshal([]byte(payload), &res); err != nil {
+		return nil, errors.New("解析结果失败: " + err.Error())
+	}
+	if !res.Ok {
+		return nil, errors.New(res.Error)
+	}
+	if len(res.Data) == 0 {
+		return json.RawMessage("null"), nil
+	}
+	return res.Data, nil
+}
+
+// ExecInto 执行 Lua 脚本并将结果解析到 v，返回数组时 v 需要使用 Array
+func (c *Console) ExecInto(ctx context.Context, clusterName, levelName, script string, timeout time.Duration, v interface{}) error {
+	data, err := c.Exec(ctx, clusterName, levelName, script, timeout)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Array 脚本返回的数组，ToJSONStr 会把空数组序列化为 {}，解析时按空数组处理
+type Array[T any] []T
+
+func (a *Array[T]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("{}")) {
+		*a = Array[T]{}
+		return nil
+	}
+	var list []T
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
@@ -0,0 +1,147 @@
+package dstConfig
+
+import (
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"errors"
+	"log"
+	"os"
+
+	"gorm.io/gorm"
+)
+
+// DbDstConfig 每个集群一条 model.Cluster 记录，集群之间的配置相互独立
+type DbDstConfig struct {
+	db *gorm.DB
+}
+
+func NewDbDstConfig(db *gorm.DB) *DbDstConfig {
+	return &DbDstConfig{
+		db: db,
+	}
+}
+
+// FromCluster 将集群记录转换为 DstConfig，未设置的字段保持为空
+func FromCluster(cluster model.Cluster) DstConfig {
+	return DstConfig{
+		Steamcmd:                   cluster.SteamCmd,
+		Force_install_dir:          cluster.ForceInstallDir,
+		DoNotStarveServerDirectory: cluster.DoNotStarveServerDirectory,
+		Cluster:                    cluster.ClusterName,
+		Backup:                     cluster.Backup,
+		Mod_download_path:          cluster.ModDownloadPath,
+		Bin:                        cluster.Bin,
+		Beta:                       cluster.Beta,
+		Ugc_directory:              cluster.Ugc_directory,
+		Persistent_storage_root:    cluster.Persistent_storage_root,
+		Conf_dir:                   cluster.Conf_dir,
+	}
+}
+
+// ApplyTo 将配置写入集群记录，不修改集群名称
+func (c DstConfig) ApplyTo(cluster *model.Cluster) {
+	cluster.SteamCmd = c.Steamcmd
+	cluster.ForceInstallDir = c.Force_install_dir
+	cluster.DoNotStarveServerDirectory = c.DoNotStarveServerDirectory
+	cluster.Backup = c.Backup
+	cluster.ModDownloadPath = c.Mod_download_path
+	cluster.Bin = c.Bin
+	cluster.Beta = c.Beta
+	cluster.Ugc_directory = c.Ugc_directory
+	cluster.Persistent_storage_root = c.Persistent_storage_root
+	cluster.Conf_dir = c.Conf_dir
+}
+
+// GetCluster 获取集群记录，clusterName 为空时返回默认集群（最早创建的集群）
+func (d *DbDstConfig) GetCluster(clusterName string) (model.Cluster, error) {
+	cluster := model.Cluster{}
+	db := d.db.Order("id asc")
+	if clusterName != "" {
+		db = db.Where("cluster_name = ?", clusterName)
+	}
+	if db.Limit(1).Find(&cluster).RowsAffected == 0 {
+		if clusterName == "" {
+			return cluster, errors.New("还没有创建集群")
+		}
+		return cluster, errors.New("集群不存在: " + clusterName)
+	}
+	return cluster, nil
+}
+
+func (d *DbDstConfig) GetDstConfig(clusterName string) (DstConfig, error) {
+	cluster, err := d.GetCluster(clusterName)
+	if err != nil {
+		return DstConfig{}, err
+	}
+	dstConfig := FromCluster(cluster)
+	applyDefaults(&dstConfig)
+	return dstConfig, nil
+}
+
+// SaveDstConfig 保存集群配置，集群名称需要通过重命名接口修改
+func (d *DbDstConfig) SaveDstConfig(clusterName string, dstConfig DstConfig) error {
+	cluster, err := d.GetCluster(clusterName)
+	if err != nil {
+		return err
+	}
+	if dstConfig.Cluster != "" && dstConfig.Cluster != cluster.ClusterName {
+		return errors.New("不能在这里修改集群名称，请使用集群重命名")
+	}
+	if dstConfig.Steamcmd == "" {
+		dstConfig.Steamcmd = cluster.SteamCmd
+	}
+	if dstConfig.Force_install_dir == "" {
+		dstConfig.Force_install_dir = cluster.ForceInstallDir
+	}
+	if dstConfig.Backup == "" {
+		dstConfig.Backup = cluster.Backup
+	}
+	if dstConfig.Mod_download_path == "" {
+		dstConfig.Mod_download_path = cluster.ModDownloadPath
+	}
+	if dstConfig.Bin == 0 {
+		dstConfig.Bin = cluster.Bin
+	}
+	dstConfig.ApplyTo(&cluster)
+	return d.db.Save(&cluster).Error
+}
+
+// migrateLegacyConfig 首次使用时导入旧版本的 ./dst_config 文件，导入后重命名为 dst_config.bak
+// 没有旧配置时创建默认集群 Cluster1
+func (d *DbDstConfig) migrateLegacyConfig() {
+	var count int64
+	d.db.Model(&model.Cluster{}).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	dstConfig := DstConfig{}
+	legacy := fileUtils.Exists(dst_config_path)
+	if legacy {
+		oneDstConfig := NewOneDstConfig(d.db)
+		config, err := oneDstConfig.GetDstConfig("")
+		if err != nil {
+			log.Println("[DstConfig]读取旧的 dst_config 失败", err)
+			return
+		}
+		dstConfig = config
+	}
+	if dstConfig.Cluster == "" {
+		dstConfig.Cluster = "Cluster1"
+	}
+
+	cluster := model.Cluster{ClusterName: dstConfig.Cluster}
+	dstConfig.ApplyTo(&cluster)
+	if err := d.db.Create(&cluster).Error; err != nil {
+		log.Println("[DstConfig]创建集群失败", err)
+		return
+	}
+	if !legacy {
+		log.Println("[DstConfig]创建默认集群", cluster.ClusterName)
+		return
+	}
+	if err := os.Rename(dst_config_path, dst_config_path+".bak"); err != nil {
+		log.Println("[DstConfig]重命名旧的 dst_config 失败", err)
+	}
+	log.Println("[DstConfig]已导入旧的 dst_config 到集群", cluster.ClusterName)
+}
@@ -0,0 +1,58 @@
+package dstConfig
+
+import (
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// kleiBasePath 存档根目录，未配置 persistent_storage_root 时使用饥荒默认位置
+func kleiBasePath(config DstConfig) string {
+
+	home, _ := os.UserHomeDir()
+
+	persistentStorageRoot := config.Persistent_storage_root
+	confDir := config.Conf_dir
+	if persistentStorageRoot != "" {
+		if confDir == "" {
+			confDir = "DoNotStarveTogether"
+		}
+		kleiDstPath := filepath.Join(persistentStorageRoot, confDir)
+		return kleiDstPath
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(
+			home,
+			"Documents",
+			"klei",
+			"DoNotStarveTogether",
+		)
+	}
+
+	return filepath.Join(
+		home,
+		".klei",
+		"DoNotStarveTogether",
+	)
+}
+
+// applyDefaults 设置默认值
+func applyDefaults(dstConfig *DstConfig) {
+	if dstConfig.Cluster == "" {
+		dstConfig.Cluster = "Cluster1"
+	}
+	if dstConfig.Backup == "" {
+		defaultPath := filepath.Join(kleiBasePath(*dstConfig), "backup")
+		fileUtils.CreateDirIfNotExists(defaultPath)
+		dstConfig.Backup = defaultPath
+	}
+	if dstConfig.Mod_download_path == "" {
+		defaultPath := filepath.Join(kleiBasePath(*dstConfig), "mod_config_download")
+		fileUtils.CreateDirIfNotExists(defaultPath)
+		dstConfig.Mod_download_path = defaultPath
+	}
+	if dstConfig.Bin == 0 {
+		dstConfig.Bin = 32
+	}
+}
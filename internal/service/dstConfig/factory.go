@@ -5,6 +5,7 @@ import (
 )
 
 func NewDstConfig(db *gorm.DB) Config {
-	dstConfig := NewOneDstConfig(db)
-	return &dstConfig
+	dstConfig := NewDbDstConfig(db)
+	dstConfig.migrateLegacyConfig()
+	return dstConfig
 }
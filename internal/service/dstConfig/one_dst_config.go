@@ -2,9 +2,6 @@ package dstConfig
 
 import (
 	"dst-admin-go/internal/pkg/utils/fileUtils"
-	"os"
-	"path/filepath"
-	"runtime"
 	"strconv"
 	"strings"
 
@@ -23,35 +20,6 @@ func NewOneDstConfig(db *gorm.DB) OneDstConfig {
 	}
 }
 
-func (o *OneDstConfig) kleiBasePath(config DstConfig) string {
-
-	home, _ := os.UserHomeDir()
-
-	persistentStorageRoot := config.Persistent_storage_root
-	confDir := config.Conf_dir
-	if persistentStorageRoot != "" {
-		if confDir == "" {
-			confDir = "DoNotStarveTogether"
-		}
-		kleiDstPath := filepath.Join(persistentStorageRoot, confDir)
-		return kleiDstPath
-	}
-	if runtime.GOOS == "windows" {
-		return filepath.Join(
-			home,
-			"Documents",
-			"klei",
-			"DoNotStarveTogether",
-		)
-	}
-
-	return filepath.Join(
-		home,
-		".klei",
-		"DoNotStarveTogether",
-	)
-}
-
 func (o *OneDstConfig) GetDstConfig(clusterName string) (DstConfig, error) {
 	dstConfig := DstConfig{}
 
@@ -150,23 +118,7 @@ func (o *OneDstConfig) GetDstConfig(clusterName string) (DstConfig, error) {
 			}
 		}
 	}
-	// 设置默认值
-	if dstConfig.Cluster == "" {
-		dstConfig.Cluster = "Cluster1"
-	}
-	if dstConfig.Backup == "" {
-		defaultPath := filepath.Join(o.kleiBasePath(dstConfig), "backup")
-		fileUtils.CreateDirIfNotExists(defaultPath)
-		dstConfig.Backup = defaultPath
-	}
-	if dstConfig.Mod_download_path == "" {
-		defaultPath := filepath.Join(o.kleiBasePath(dstConfig), "mod_config_download")
-		fileUtils.CreateDirIfNotExists(defaultPath)
-		dstConfig.Mod_download_path = defaultPath
-	}
-	if dstConfig.Bin == 0 {
-		dstConfig.Bin = 32
-	}
+	applyDefaults(&dstConfig)
 	return dstConfig, nil
 }
 
@@ -1,19 +1,21 @@
 package game
 
 import (
-	"dst-admin-go/internal/pkg/utils/dstUtils"
 	"dst-admin-go/internal/pkg/utils/shellUtils"
 	"dst-admin-go/internal/service/dstConfig"
 	"dst-admin-go/internal/service/levelConfig"
+	"errors"
 	"log"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
-	"time"
 )
 
 type LinuxProcess struct {
 	dstConfig        dstConfig.Config
 	levelConfigUtils *levelConfig.LevelConfigUtils
+	supervisor       *Supervisor
 	mu               sync.Mutex // 保护启动/停止操作，防止并发执行
 }
 
@@ -21,6 +23,7 @@ func NewLinuxProcess(dstConfig dstConfig.Config, levelConfigUtils *levelConfig.L
 	return &LinuxProcess{
 		dstConfig:        dstConfig,
 		levelConfigUtils: levelConfigUtils,
+		supervisor:       NewSupervisor(processDir),
 	}
 }
 
@@ -28,6 +31,11 @@ func (p *LinuxProcess) SessionName(clusterName, levelName string) string {
 	return "DST_8level_" + levelName + "_" + clusterName
 }
 
+// ConsoleLogPath 世界控制台输出的文件
+func (p *LinuxProcess) ConsoleLogPath(clusterName, levelName string) string {
+	return p.supervisor.ConsoleLogPath(clusterName, levelName)
+}
+
 func (p *LinuxProcess) Start(clusterName, levelName string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -49,64 +57,47 @@ func (p *LinuxProcess) launchLevel(clusterName, levelName string) error {
 	if cluster.Beta == 1 {
 		dstInstallDir = dstInstallDir + "-beta"
 	}
-	ugcDirectory := cluster.Ugc_directory
-	persistent_storage_root := cluster.Persistent_storage_root
-	conf_dir := cluster.Conf_dir
-	var startCmd = ""
 
-	dstInstallDir = dstUtils.EscapePath(dstInstallDir)
-	log.Println(dstInstallDir)
-	sessionName := p.SessionName(clusterName, levelName)
-	if bin == 64 {
-		startCmd = "cd " + dstInstallDir + "/bin64 ; screen -d -m -S \"" + sessionName + "\"  ./dontstarve_dedicated_server_nullrenderer_x64 -console -cluster " + clusterName + " -shard " + levelName
-	} else if bin == 100 {
-		startCmd = "cd " + dstInstallDir + "/bin64 ; screen -d -m -S \"" + sessionName + "\"  ./dontstarve_dedicated_server_nullrenderer_x64_luajit -console -cluster " + clusterName + " -shard " + levelName
-	} else if bin == 86 {
-		startCmd = "cd " + dstInstallDir + "/bin64 ; screen -d -m -S \"" + sessionName + "\" box86 ./dontstarve_dedicated_server_nullrenderer_x64 -console -cluster " + clusterName + " -shard " + levelName
-	} else if bin == 2664 {
-		startCmd = "cd " + dstInstallDir + "/bin64 ; screen -d -m -S \"" + sessionName + "\" box64 ./dontstarve_dedicated_server_nullrenderer_x64 -console -cluster " + clusterName + " -shard " + levelName
+	var dir string
+	var args []string
+	switch bin {
+	case 64:
+		dir = filepath.Join(dstInstallDir, "bin64")
+		args = []string{"./dontstarve_dedicated_server_nullrenderer_x64"}
+	case 100:
+		dir = filepath.Join(dstInstallDir, "bin64")
+		args = []string{"./dontstarve_dedicated_server_nullrenderer_x64_luajit"}
+	case 86:
+		dir = filepath.Join(dstInstallDir, "bin64")
+		args = []string{"box86", "./dontstarve_dedicated_server_nullrenderer_x64"}
+	case 2664:
+		dir = filepath.Join(dstInstallDir, "bin64")
+		args = []string{"box64", "./dontstarve_dedicated_server_nullrenderer_x64"}
+	default:
+		dir = filepath.Join(dstInstallDir, "bin")
+		args = []string{"./dontstarve_dedicated_server_nullrenderer"}
+	}
+	if args[0] == "box86" || args[0] == "box64" {
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			return err
+		}
+		args[0] = path
 	} else {
-		startCmd = "cd " + dstInstallDir + "/bin ; screen -d -m -S \"" + sessionName + "\"  ./dontstarve_dedicated_server_nullrenderer -console -cluster " + clusterName + " -shard " + levelName
+		args[0] = filepath.Join(dir, args[0])
 	}
-
-	if ugcDirectory != "" {
-		startCmd += " -ugc_directory " + ugcDirectory
+	args = append(args, "-console", "-cluster", clusterName, "-shard", levelName)
+	if cluster.Ugc_directory != "" {
+		args = append(args, "-ugc_directory", cluster.Ugc_directory)
 	}
-	if persistent_storage_root != "" {
-		startCmd += " -persistent_storage_root " + persistent_storage_root
+	if cluster.Persistent_storage_root != "" {
+		args = append(args, "-persistent_storage_root", cluster.Persistent_storage_root)
 	}
-	if conf_dir != "" {
-		startCmd += " -conf_dir " + conf_dir
+	if cluster.Conf_dir != "" {
+		args = append(args, "-conf_dir", cluster.Conf_dir)
 	}
-	startCmd += "  ;"
-	log.Println("正在启动世界", "cluster: ", clusterName, "level: ", levelName, "command: ", startCmd)
-	_, err = shellUtils.Shell(startCmd)
-	return err
-}
-
-func (p *LinuxProcess) shutdownLevel(clusterName, levelName string) error {
-	ok, err := p.Status(clusterName, levelName)
-	if err != nil {
-		return err
-	}
-	if !ok {
-		return nil
-	}
-	shell := "screen -S \"" + p.SessionName(clusterName, levelName) + "\" -p 0 -X stuff \"c_shutdown(true)\\n\""
-	log.Println("正在shutdown世界", "cluster: ", clusterName, "level: ", levelName, "command: ", shell)
-	_, err = shellUtils.Shell(shell)
-	return err
-}
-
-func (p *LinuxProcess) killLevel(clusterName, level string) error {
-
-	if ok, err := p.Status(clusterName, level); err != nil || !ok {
-		return nil
-	}
-	cmd := " ps -ef | grep -v grep | grep -v tail |grep '" + clusterName + "'|grep " + level + " |sed -n '1P'|awk '{print $2}' |xargs kill -9"
-	log.Println("正在kill世界", "cluster: ", clusterName, "level: ", level, "command: ", cmd)
-	_, err := shellUtils.Shell(cmd)
-	return err
+	log.Println("正在启动世界", "cluster: ", clusterName, "level: ", levelName, "dir: ", dir, "command: ", strings.Join(args, " "))
+	return p.supervisor.Launch(clusterName, levelName, dir, args)
 }
 
 func (p *LinuxProcess) Stop(clusterName, levelName string) error {
@@ -116,29 +107,22 @@ func (p *LinuxProcess) Stop(clusterName, levelName string) error {
 }
 
 // stop 内部实现，不加锁，供 Start 等方法内部调用
+// 先发送 c_shutdown(true) 保存并退出，超时后强制结束
 func (p *LinuxProcess) stop(clusterName, levelName string) error {
-	p.shutdownLevel(clusterName, levelName)
-	time.Sleep(3 * time.Second)
-
-	if ok, err := p.Status(clusterName, levelName); err == nil && ok {
-		var i uint8 = 1
-		for {
-			if ok, err := p.Status(clusterName, levelName); err == nil && ok {
-				break
-			}
-			p.shutdownLevel(clusterName, levelName)
-			log.Println("正在第", i, "次stop世界", "cluster: ", clusterName, "level: ", levelName)
-			time.Sleep(1 * time.Second)
-			i++
-			if i > 3 {
-				break
-			}
-		}
+	if !p.supervisor.Running(clusterName, levelName) {
+		return nil
+	}
+	log.Println("正在shutdown世界", "cluster: ", clusterName, "level: ", levelName)
+	if err := p.Command(clusterName, levelName, "c_shutdown(true)"); err != nil {
+		log.Println("发送 c_shutdown 失败", "cluster: ", clusterName, "level: ", levelName, err)
+	} else if p.supervisor.WaitExit(clusterName, levelName, ShutdownTimeout) {
+		return nil
 	}
 	log.Println("使用kill命令强制结束世界", "cluster: ", clusterName, "level: ", levelName)
-	return p.killLevel(clusterName, levelName)
+	return p.supervisor.Kill(clusterName, levelName)
 }
 
+// StartAll 先启动主世界再启动从世界，返回所有启动失败的世界
 func (p *LinuxProcess) StartAll(clusterName string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -147,31 +131,13 @@ func (p *LinuxProcess) StartAll(clusterName string) error {
 	if err != nil {
 		return err
 	}
-	config, err := p.levelConfigUtils.GetLevelConfig(clusterName)
+	levels, err := p.levelConfigUtils.GetOrderedLevels(clusterName)
 	if err != nil {
 		return err
 	}
-	var wg sync.WaitGroup
-	wg.Add(len(config.LevelList))
-	for i := range config.LevelList {
-		go func(i int) {
-			defer func() {
-				wg.Done()
-				if r := recover(); r != nil {
-					log.Println(r)
-				}
-			}()
-			levelName := config.LevelList[i].File
-			err := p.launchLevel(clusterName, levelName)
-			if err != nil {
-				log.Println(err)
-				return
-			}
-		}(i)
-	}
-	ClearScreen()
-	wg.Wait()
-	return nil
+	return startOrdered(clusterName, levels, func(levelName string) error {
+		return p.launchLevel(clusterName, levelName)
+	})
 }
 
 func (p *LinuxProcess) StopAll(clusterName string) error {
@@ -180,81 +146,38 @@ func (p *LinuxProcess) StopAll(clusterName string) error {
 	return p.stopAll(clusterName)
 }
 
-// stopAll 内部实现，不加锁，供 StartAll 等方法内部调用
+// stopAll 内部实现，不加锁，供 StartAll 等方法内部调用，先停止从世界再停止主世界
 func (p *LinuxProcess) stopAll(clusterName string) error {
-	config, err := p.levelConfigUtils.GetLevelConfig(clusterName)
+	levels, err := p.levelConfigUtils.GetOrderedLevels(clusterName)
 	if err != nil {
-		log.Panicln(err)
-	}
-	var wg sync.WaitGroup
-	wg.Add(len(config.LevelList))
-	for i := range config.LevelList {
-		go func(i int) {
-			defer func() {
-				wg.Done()
-				if r := recover(); r != nil {
-					log.Println(r)
-				}
-			}()
-			levelName := config.LevelList[i].File
-			err := p.stop(clusterName, levelName)
-			if err != nil {
-				return
-			}
-		}(i)
+		return err
 	}
-	wg.Wait()
-	return nil
+	return stopOrdered(clusterName, levels, func(levelName string) error {
+		return p.stop(clusterName, levelName)
+	})
 }
 
 func (p *LinuxProcess) Status(clusterName, levelName string) (bool, error) {
-	cmd := " ps -ef | grep -v grep | grep -v tail |grep '" + clusterName + "'|grep " + levelName + " |sed -n '1P'|awk '{print $2}' "
-	result, err := shellUtils.Shell(cmd)
-	if err != nil {
-		return false, nil
-	}
-	res := strings.Split(result, "\n")[0]
-	return res != "", nil
+	return p.supervisor.Running(clusterName, levelName), nil
 }
 
 func (p *LinuxProcess) Command(clusterName, levelName, command string) error {
-	cmd := "screen -S \"" + p.SessionName(clusterName, levelName) + "\" -p 0 -X stuff \"" + command + "\\n\""
-	_, err := shellUtils.Shell(cmd)
+	err := p.supervisor.Send(clusterName, levelName, command)
+	if errors.Is(err, ErrNoConsole) {
+		// 旧版本通过 screen 启动的世界，重启前继续通过 screen 发送命令
+		cmd := "screen -S \"" + p.SessionName(clusterName, levelName) + "\" -p 0 -X stuff \"" + escapeStuff(command) + "\\n\""
+		_, err = shellUtils.Shell(cmd)
+	}
 	return err
 }
 
-func (p *LinuxProcess) PsAuxSpecified(clusterName, levelName string) DstPsAux {
-	dstPsAux := DstPsAux{}
-	cmd := "ps -aux | grep -v grep | grep -v tail | grep " + clusterName + "  | grep " + levelName + " | sed -n '2P' |awk '{print $3, $4, $5, $6}'"
-
-	info, err := shellUtils.Shell(cmd)
-	if err != nil {
-		log.Println(cmd + " error: " + err.Error())
-		return dstPsAux
-	}
-	if info == "" {
-		return dstPsAux
-	}
-
-	arr := strings.Split(info, " ")
-	dstPsAux.CpuUage = strings.Replace(arr[0], "\n", "", -1)
-	dstPsAux.MemUage = strings.Replace(arr[1], "\n", "", -1)
-	dstPsAux.VSZ = strings.Replace(arr[2], "\n", "", -1)
-	dstPsAux.RSS = strings.Replace(arr[3], "\n", "", -1)
-
-	return dstPsAux
+// escapeStuff 转义 screen stuff 的参数，调用方只需传入原始的 lua 命令
+// 先处理 screen 自身的 \ 和 ^ 转义，再处理 sh 双引号内的 \ " $ ` 转义
+func escapeStuff(command string) string {
+	command = strings.NewReplacer("\\", "\\\\", "^", "\\^").Replace(command)
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "$", "\\$", "`", "\\`").Replace(command)
 }
 
-const (
-	// ClearScreenCmd 检查目前所有的screen作业，并删除已经无法使用的screen作业
-	ClearScreenCmd = "screen -wipe "
-)
-
-func ClearScreen() bool {
-	result, err := shellUtils.Shell(ClearScreenCmd)
-	if err != nil {
-		return false
-	}
-	res := strings.Split(result, "\n")[0]
-	return res != ""
+func (p *LinuxProcess) PsAuxSpecified(clusterName, levelName string) DstPsAux {
+	return p.supervisor.PsAux(clusterName, levelName)
 }
@@ -0,0 +1,63 @@
+package game
+
+import (
+	"dst-admin-go/internal/service/levelConfig"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// startOrdered 按顺序启动世界，主世界启动失败时不再启动从世界
+func startOrdered(clusterName string, levels []levelConfig.OrderedLevel, start func(levelName string) error) error {
+	var errs []error
+	for _, level := range levels {
+		if err := start(level.File); err != nil {
+			log.Println("启动世界失败", "cluster: ", clusterName, "level: ", level.File, err)
+			errs = append(errs, fmt.Errorf("%s: %w", level.File, err))
+			if level.IsMaster {
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stopOrdered 先并行停止从世界，再停止主世界
+func stopOrdered(clusterName string, levels []levelConfig.OrderedLevel, stop func(levelName string) error) error {
+	var masters, secondaries []levelConfig.OrderedLevel
+	for _, level := range levels {
+		if level.IsMaster {
+			masters = append(masters, level)
+		} else {
+			secondaries = append(secondaries, level)
+		}
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	stopGroup := func(group []levelConfig.OrderedLevel) {
+		var wg sync.WaitGroup
+		wg.Add(len(group))
+		for _, level := range group {
+			go func(levelName string) {
+				defer func() {
+					wg.Done()
+					if r := recover(); r != nil {
+						log.Println(r)
+					}
+				}()
+				if err := stop(levelName); err != nil {
+					log.Println("停止世界失败", "cluster: ", clusterName, "level: ", levelName, err)
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("%s: %w", levelName, err))
+					mu.Unlock()
+				}
+			}(level.File)
+		}
+		wg.Wait()
+	}
+	stopGroup(secondaries)
+	stopGroup(masters)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package game
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// mkfifo 创建命名管道，已存在时直接使用
+func mkfifo(path string) error {
+	err := syscall.Mkfifo(path, 0600)
+	if errors.Is(err, syscall.EEXIST) {
+		return nil
+	}
+	return err
+}
+
+// setProcessGroup 世界进程使用独立的进程组，面板退出时不会收到终端信号，停止时可以结束整个进程组（例如 box64）
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup 强制结束进程所在的进程组
+func killProcessGroup(pid int) error {
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+		return syscall.Kill(-pid, syscall.SIGKILL)
+	}
+	return syscall.Kill(pid, syscall.SIGKILL)
+}
+
+// processAlive 进程是否存在
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,28 @@
+package game
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// Windows 使用 WindowProcess，以下实现只用于通过编译
+
+func mkfifo(path string) error {
+	return errors.New("windows 不支持命名管道: " + path)
+}
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
+
+func processAlive(pid int) bool {
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
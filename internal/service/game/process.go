@@ -21,3 +21,24 @@ type Process interface {
 
 	PsAuxSpecified(clusterName, levelName string) DstPsAux
 }
+
+// ConsoleLogger 世界进程的控制台输出写入文件时实现，用于实时查看控制台输出
+type ConsoleLogger interface {
+	ConsoleLogPath(clusterName, levelName string) string
+}
+
+// LevelResult 按顺序启动或停止集群时单个世界的结果
+type LevelResult struct {
+	LevelName string `json:"levelName"`
+	IsMaster  bool   `json:"isMaster"`
+	Success   bool   `json:"success"`
+	// State 操作完成后世界的生命周期状态
+	State   string `json:"state"`
+	Message string `json:"message"`
+}
+
+// ClusterRunner 按主从顺序启动和停止集群时实现，返回每个世界的结果
+type ClusterRunner interface {
+	StartCluster(clusterName string) ([]LevelResult, error)
+	StopCluster(clusterName string) ([]LevelResult, error)
+}
@@ -0,0 +1,389 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+const (
+	// processDir 保存世界的 PID 文件、控制台管道和控制台输出
+	processDir = "./process"
+	// ShutdownTimeout 发送 c_shutdown(true) 后等待世界保存并退出的时间，超时后强制结束
+	ShutdownTimeout = 60 * time.Second
+	// exitPollInterval 检查非面板子进程是否退出的间隔
+	exitPollInterval = 200 * time.Millisecond
+)
+
+// ErrNoConsole 世界不是由面板启动的，无法发送命令
+var ErrNoConsole = errors.New("世界不是由当前面板启动的，无法发送命令")
+
+// pidFile 持久化的世界进程信息，面板重启后用于重新接管
+type pidFile struct {
+	Pid        int       `json:"pid"`
+	CreateTime int64     `json:"createTime"`
+	Cluster    string    `json:"cluster"`
+	Level      string    `json:"level"`
+	Dir        string    `json:"dir"`
+	Args       []string  `json:"args"`
+	StartedAt  time.Time `json:"startedAt"`
+}
+
+// levelProcess 正在运行的世界进程
+// 标准输入是一个命名管道，进程自己持有读写两端，面板重启后重新打开即可继续发送命令；
+// 标准输出写入文件而不是管道，面板退出时世界不会因为管道断开而退出
+type levelProcess struct {
+	pidFile
+	console *os.File
+	done    chan struct{}
+	mu      sync.Mutex
+}
+
+func (l *levelProcess) exited() bool {
+	select {
+	case <-l.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Supervisor 管理 Linux 下的世界进程，按集群和世界名称精确匹配进程
+type Supervisor struct {
+	dir    string
+	mu     sync.Mutex
+	levels map[string]*levelProcess
+}
+
+func NewSupervisor(dir string) *Supervisor {
+	s := &Supervisor{
+		dir:    dir,
+		levels: map[string]*levelProcess{},
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Println("[Supervisor]创建进程目录失败", err)
+	}
+	s.reattach()
+	return s
+}
+
+func (s *Supervisor) key(clusterName, levelName string) string {
+	return clusterName + "/" + levelName
+}
+
+func (s *Supervisor) path(clusterName, levelName, ext string) string {
+	return filepath.Join(s.dir, clusterName, levelName+ext)
+}
+
+// ConsoleLogPath 世界控制台输出的文件
+func (s *Supervisor) ConsoleLogPath(clusterName, levelName string) string {
+	return s.path(clusterName, levelName, ".log")
+}
+
+// parseArgs 从命令行参数中解析 -cluster 和 -shard
+func parseArgs(args []string) (string, string) {
+	cluster, shard := "", ""
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "-cluster":
+			cluster = args[i+1]
+		case "-shard":
+			shard = args[i+1]
+		}
+	}
+	return cluster, shard
+}
+
+// isDedicatedServer 命令行是否是饥荒服务器进程，box86/box64 启动时服务器程序是第二个参数，screen 进程不算
+func isDedicatedServer(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	exe := filepath.Base(args[0])
+	if (exe == "box86" || exe == "box64") && len(args) > 1 {
+		exe = filepath.Base(args[1])
+	}
+	return strings.HasPrefix(exe, "dontstarve_dedicated_server")
+}
+
+// createTime 进程的创建时间，用于判断 PID 是否已被其他进程复用
+func createTime(pid int) int64 {
+	p, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return 0
+	}
+	t, _ := p.CreateTime()
+	return t
+}
+
+// Launch 启动世界进程
+func (s *Supervisor) Launch(clusterName, levelName, dir string, args []string) error {
+	if s.Running(clusterName, levelName) {
+		return errors.New("世界已在运行: " + s.key(clusterName, levelName))
+	}
+	if err := os.MkdirAll(filepath.Join(s.dir, clusterName), 0755); err != nil {
+		return err
+	}
+
+	fifoPath := s.path(clusterName, levelName, ".fifo")
+	if err := mkfifo(fifoPath); err != nil {
+		return err
+	}
+	// 以读写方式打开，不会阻塞，并且世界的标准输入始终有写入端，不会读到 EOF
+	console, err := os.OpenFile(fifoPath, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	output, err := os.OpenFile(s.ConsoleLogPath(clusterName, levelName), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		console.Close()
+		return err
+	}
+	defer output.Close()
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Stdin = console
+	cmd.Stdout = output
+	cmd.Stderr = output
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		console.Close()
+		return err
+	}
+
+	l := &levelProcess{
+		pidFile: pidFile{
+			Pid:        cmd.Process.Pid,
+			CreateTime: createTime(cmd.Process.Pid),
+			Cluster:    clusterName,
+			Level:      levelName,
+			Dir:        dir,
+			Args:       args,
+			StartedAt:  time.Now(),
+		},
+		console: console,
+		done:    make(chan struct{}),
+	}
+	s.save(l)
+	s.mu.Lock()
+	s.levels[s.key(clusterName, levelName)] = l
+	s.mu.Unlock()
+	log.Println("[Supervisor]世界已启动", "cluster:", clusterName, "level:", levelName, "pid:", l.Pid)
+
+	go func() {
+		err := cmd.Wait()
+		log.Println("[Supervisor]世界进程退出", "cluster:", clusterName, "level:", levelName, "pid:", l.Pid, "err:", err)
+		s.remove(l)
+	}()
+	return nil
+}
+
+// Running 世界是否在运行
+func (s *Supervisor) Running(clusterName, levelName string) bool {
+	return s.get(clusterName, levelName) != nil
+}
+
+// Pid 世界进程的 PID，未运行时返回 0
+func (s *Supervisor) Pid(clusterName, levelName string) int {
+	if l := s.get(clusterName, levelName); l != nil {
+		return l.Pid
+	}
+	return 0
+}
+
+func (s *Supervisor) get(clusterName, levelName string) *levelProcess {
+	s.mu.Lock()
+	l, ok := s.levels[s.key(clusterName, levelName)]
+	s.mu.Unlock()
+	if !ok || l.exited() {
+		return nil
+	}
+	return l
+}
+
+// Send 向世界控制台发送一行命令
+func (s *Supervisor) Send(clusterName, levelName, command string) error {
+	l := s.get(clusterName, levelName)
+	if l == nil {
+		return errors.New("世界未运行: " + s.key(clusterName, levelName))
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.console == nil {
+		return ErrNoConsole
+	}
+	command = strings.NewReplacer("\r", " ", "\n", " ").Replace(command)
+	_, err := l.console.WriteString(command + "\n")
+	return err
+}
+
+// WaitExit 等待世界退出，超时返回 false
+func (s *Supervisor) WaitExit(clusterName, levelName string, timeout time.Duration) bool {
+	l := s.get(clusterName, levelName)
+	if l == nil {
+		return true
+	}
+	select {
+	case <-l.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Kill 强制结束世界进程组并等待退出
+func (s *Supervisor) Kill(clusterName, levelName string) error {
+	l := s.get(clusterName, levelName)
+	if l == nil {
+		return nil
+	}
+	log.Println("[Supervisor]强制结束世界", "cluster:", clusterName, "level:", levelName, "pid:", l.Pid)
+	if err := killProcessGroup(l.Pid); err != nil && processAlive(l.Pid) {
+		return err
+	}
+	select {
+	case <-l.done:
+		return nil
+	case <-time.After(10 * time.Second):
+		return fmt.Errorf("结束世界进程失败, pid: %d", l.Pid)
+	}
+}
+
+// PsAux 世界进程的 CPU、内存占用，与 ps aux 的格式一致，VSZ 和 RSS 单位为 KB
+func (s *Supervisor) PsAux(clusterName, levelName string) DstPsAux {
+	dstPsAux := DstPsAux{}
+	l := s.get(clusterName, levelName)
+	if l == nil {
+		return dstPsAux
+	}
+	p, err := process.NewProcess(int32(l.Pid))
+	if err != nil {
+		return dstPsAux
+	}
+	if cpu, err := p.CPUPercent(); err == nil {
+		dstPsAux.CpuUage = fmt.Sprintf("%.1f", cpu)
+	}
+	if mem, err := p.MemoryPercent(); err == nil {
+		dstPsAux.MemUage = fmt.Sprintf("%.1f", mem)
+	}
+	if info, err := p.MemoryInfo(); err == nil {
+		dstPsAux.VSZ = fmt.Sprint(info.VMS / 1024)
+		dstPsAux.RSS = fmt.Sprint(info.RSS / 1024)
+	}
+	return dstPsAux
+}
+
+func (s *Supervisor) save(l *levelProcess) {
+	data, err := json.MarshalIndent(l.pidFile, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(s.path(l.Cluster, l.Level, ".pid"), data, 0644); err != nil {
+		log.Println("[Supervisor]保存 PID 文件失败", err)
+	}
+}
+
+// remove 世界退出后清理注册表、PID 文件和控制台管道
+func (s *Supervisor) remove(l *levelProcess) {
+	key := s.key(l.Cluster, l.Level)
+	s.mu.Lock()
+	if s.levels[key] == l {
+		delete(s.levels, key)
+		os.Remove(s.path(l.Cluster, l.Level, ".pid"))
+	}
+	s.mu.Unlock()
+
+	l.mu.Lock()
+	if l.console != nil {
+		l.console.Close()
+		l.console = nil
+	}
+	l.mu.Unlock()
+	close(l.done)
+}
+
+// watch 监控不是当前面板子进程的世界，退出后清理
+func (s *Supervisor) watch(l *levelProcess) {
+	for {
+		time.Sleep(exitPollInterval)
+		if !processAlive(l.Pid) || createTime(l.Pid) != l.CreateTime {
+			log.Println("[Supervisor]世界进程退出", "cluster:", l.Cluster, "level:", l.Level, "pid:", l.Pid)
+			s.remove(l)
+			return
+		}
+	}
+}
+
+// reattach 面板启动时根据 PID 文件重新接管仍在运行的世界
+// 同时接管旧版本通过 screen 启动的世界，这些世界无法发送命令，只能强制结束
+func (s *Supervisor) reattach() {
+	files, _ := filepath.Glob(filepath.Join(s.dir, "*", "*.pid"))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		info := pidFile{}
+		if err := json.Unmarshal(data, &info); err != nil || info.Pid <= 0 {
+			os.Remove(file)
+			continue
+		}
+		if !processAlive(info.Pid) || createTime(info.Pid) != info.CreateTime {
+			os.Remove(file)
+			continue
+		}
+		l := &levelProcess{pidFile: info, done: make(chan struct{})}
+		if console, err := os.OpenFile(s.path(info.Cluster, info.Level, ".fifo"), os.O_RDWR, 0); err == nil {
+			l.console = console
+		}
+		s.levels[s.key(info.Cluster, info.Level)] = l
+		go s.watch(l)
+		log.Println("[Supervisor]重新接管世界", "cluster:", info.Cluster, "level:", info.Level, "pid:", info.Pid)
+	}
+	s.adoptLegacy()
+}
+
+// adoptLegacy 接管没有 PID 文件的饥荒服务器进程，按 -cluster 和 -shard 参数精确匹配
+func (s *Supervisor) adoptLegacy() {
+	processes, err := process.Processes()
+	if err != nil {
+		return
+	}
+	for _, p := range processes {
+		args, err := p.CmdlineSlice()
+		if err != nil || !isDedicatedServer(args) {
+			continue
+		}
+		cluster, shard := parseArgs(args)
+		if cluster == "" || shard == "" {
+			continue
+		}
+		key := s.key(cluster, shard)
+		if _, ok := s.levels[key]; ok {
+			continue
+		}
+		l := &levelProcess{
+			pidFile: pidFile{
+				Pid:        int(p.Pid),
+				CreateTime: createTime(int(p.Pid)),
+				Cluster:    cluster,
+				Level:      shard,
+				Args:       args,
+			},
+			done: make(chan struct{}),
+		}
+		s.levels[key] = l
+		go s.watch(l)
+		log.Println("[Supervisor]接管旧版本启动的世界", "cluster:", cluster, "level:", shard, "pid:", p.Pid)
+	}
+}
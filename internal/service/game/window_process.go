@@ -4,8 +4,6 @@ import (
 	"dst-admin-go/internal/service/dstConfig"
 	"dst-admin-go/internal/service/levelConfig"
 	"fmt"
-	"log"
-	"sync"
 )
 
 type WindowProcess struct {
@@ -43,63 +41,32 @@ func (p *WindowProcess) Stop(clusterName, levelName string) error {
 	return nil
 }
 
+// StartAll 先启动主世界再启动从世界
 func (p *WindowProcess) StartAll(clusterName string) error {
 
 	err := p.StopAll(clusterName)
 	if err != nil {
 		return err
 	}
-	config, err := p.levelConfigUtils.GetLevelConfig(clusterName)
+	levels, err := p.levelConfigUtils.GetOrderedLevels(clusterName)
 	if err != nil {
-		log.Panicln(err)
-	}
-	var wg sync.WaitGroup
-	wg.Add(len(config.LevelList))
-	for i := range config.LevelList {
-		go func(i int) {
-			defer func() {
-				wg.Done()
-				if r := recover(); r != nil {
-					log.Println(r)
-				}
-			}()
-			levelName := config.LevelList[i].File
-			err := p.Start(clusterName, levelName)
-			if err != nil {
-				log.Println(err)
-				return
-			}
-		}(i)
+		return err
 	}
-	wg.Wait()
-	return nil
+	return startOrdered(clusterName, levels, func(levelName string) error {
+		return p.Start(clusterName, levelName)
+	})
 }
 
+// StopAll 先停止从世界再停止主世界
 func (p *WindowProcess) StopAll(clusterName string) error {
 
-	config, err := p.levelConfigUtils.GetLevelConfig(clusterName)
+	levels, err := p.levelConfigUtils.GetOrderedLevels(clusterName)
 	if err != nil {
-		log.Panicln(err)
-	}
-	var wg sync.WaitGroup
-	wg.Add(len(config.LevelList))
-	for i := range config.LevelList {
-		go func(i int) {
-			defer func() {
-				wg.Done()
-				if r := recover(); r != nil {
-					log.Println(r)
-				}
-			}()
-			levelName := config.LevelList[i].File
-			err := p.Stop(clusterName, levelName)
-			if err != nil {
-				return
-			}
-		}(i)
+		return err
 	}
-	wg.Wait()
-	return nil
+	return stopOrdered(clusterName, levels, func(levelName string) error {
+		return p.Stop(clusterName, levelName)
+	})
 }
 
 func (p *WindowProcess) Status(clusterName, levelName string) (bool, error) {
@@ -119,3 +86,8 @@ func (p *WindowProcess) PsAuxSpecified(clusterName, levelName string) DstPsAux {
 	dstPsAux.CpuUage = fmt.Sprintf("%f", cpuUsage)
 	return dstPsAux
 }
+
+// ConsoleLogPath 世界控制台输出的文件，由 LevelInstance 写入
+func (p *WindowProcess) ConsoleLogPath(clusterName, levelName string) string {
+	return clusterName + "_" + levelName + "_log"
+}
@@ -1,6 +1,7 @@
 package level
 
 import (
+	"dst-admin-go/internal/collect"
 	"dst-admin-go/internal/pkg/utils/dstUtils"
 	"dst-admin-go/internal/pkg/utils/fileUtils"
 	"dst-admin-go/internal/service/archive"
@@ -22,15 +23,17 @@ type LevelService struct {
 	dstConfig        dstConfig.Config
 	resolver         *archive.PathResolver
 	levelConfigUtils *levelConfig.LevelConfigUtils
+	collectMap       *collect.CollectMap
 }
 
 // NewLevelService 创建关卡服务实例
-func NewLevelService(gameProcess game.Process, dstConfig dstConfig.Config, resolver *archive.PathResolver, levelConfigUtils *levelConfig.LevelConfigUtils) *LevelService {
+func NewLevelService(gameProcess game.Process, dstConfig dstConfig.Config, resolver *archive.PathResolver, levelConfigUtils *levelConfig.LevelConfigUtils, collectMap *collect.CollectMap) *LevelService {
 	return &LevelService{
 		gameProcess:      gameProcess,
 		dstConfig:        dstConfig,
 		resolver:         resolver,
 		levelConfigUtils: levelConfigUtils,
+		collectMap:       collectMap,
 	}
 }
 
@@ -243,6 +246,7 @@ func (l *LevelService) CreateLevel(clusterName string, level *levelConfig.LevelI
 		return err
 	}
 	level.Uuid = uuid
+	l.collectMap.RefreshCollect(clusterName)
 	return nil
 }
 
@@ -269,6 +273,7 @@ func (l *LevelService) DeleteLevel(clusterName string, levelName string) error {
 		}
 	}
 	err = l.levelConfigUtils.SaveLevelConfig(clusterName, &newLevelsConfig)
+	l.collectMap.RefreshCollect(clusterName)
 
 	// TODO 同时删除定时任务和自动维护
 
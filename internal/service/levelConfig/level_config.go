@@ -8,6 +8,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+
+	"github.com/go-ini/ini"
 )
 
 type Item struct {
@@ -185,3 +187,38 @@ func (p *LevelConfigUtils) SaveLevelConfig(clusterName string, levelConfig *Leve
 	fileUtils.WriterTXT(jsonPath, string(bytes))
 	return err
 }
+
+// IsMasterLevel 读取世界 server.ini 中的 [SHARD] is_master，没有配置时名为 Master 的世界是主世界
+func (p *LevelConfigUtils) IsMasterLevel(clusterName, levelName string) bool {
+	isMaster := levelName == "Master"
+	cfg, err := ini.Load(p.archive.ServerIniPath(clusterName, levelName))
+	if err != nil {
+		return isMaster
+	}
+	return cfg.Section("SHARD").Key("is_master").MustBool(isMaster)
+}
+
+// OrderedLevel 按启动顺序排列的世界
+type OrderedLevel struct {
+	Item
+	IsMaster bool
+}
+
+// GetOrderedLevels 按启动顺序返回世界，主世界在前，其余世界保持 level.json 中的顺序
+// 从世界需要连接主世界的 master_port，停止时按相反的顺序
+func (p *LevelConfigUtils) GetOrderedLevels(clusterName string) ([]OrderedLevel, error) {
+	config, err := p.GetLevelConfig(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	masters := make([]OrderedLevel, 0, 1)
+	secondaries := make([]OrderedLevel, 0, len(config.LevelList))
+	for _, item := range config.LevelList {
+		if p.IsMasterLevel(clusterName, item.File) {
+			masters = append(masters, OrderedLevel{Item: item, IsMaster: true})
+		} else {
+			secondaries = append(secondaries, OrderedLevel{Item: item})
+		}
+	}
+	return append(masters, secondaries...), nil
+}
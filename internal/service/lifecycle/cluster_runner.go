@@ -0,0 +1,127 @@
+package lifecycle
+
+import (
+	"context"
+	"dst-admin-go/internal/service/game"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// masterReadyTimeout 等待主世界就绪的最长时间，新世界需要先生成地图
+const masterReadyTimeout = 10 * time.Minute
+
+// StartCluster 先停止集群，再启动主世界并等待它进入 running 后启动从世界
+// 主世界启动失败或未就绪时不再启动从世界，从世界连接不上主世界的 master_port 也无法运行
+// 整个过程持有集群锁，其他启动和停止该集群的操作需要等待
+func (p *trackedProcess) StartCluster(clusterName string) ([]game.LevelResult, error) {
+	unlock := p.lifecycle.lockCluster(clusterName)
+	defer unlock()
+
+	levels, err := p.lifecycle.levelConfigUtils.GetOrderedLevels(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if results, err := p.stopCluster(clusterName); err != nil {
+		return results, err
+	}
+
+	results := make([]game.LevelResult, 0, len(levels))
+	masterReady := true
+	for _, level := range levels {
+		result := game.LevelResult{
+			LevelName: level.File,
+			IsMaster:  level.IsMaster,
+		}
+		switch {
+		case !masterReady:
+			result.Message = "主世界未就绪，跳过启动"
+		case level.IsMaster:
+			if err := p.start(clusterName, level.File); err != nil {
+				result.Message = err.Error()
+			} else if _, err := p.lifecycle.WaitFor(context.Background(), clusterName, level.File, masterReadyTimeout, Running); err != nil {
+				result.Message = "主世界未就绪: " + err.Error()
+			} else {
+				result.Success = true
+				result.Message = "主世界已就绪"
+			}
+			masterReady = result.Success
+		default:
+			if err := p.start(clusterName, level.File); err != nil {
+				result.Message = err.Error()
+			} else {
+				result.Success = true
+				result.Message = "已启动"
+			}
+		}
+		result.State = string(p.lifecycle.GetState(clusterName, level.File).State)
+		results = append(results, result)
+	}
+	return results, resultsError("启动", results)
+}
+
+// StopCluster 先并行停止从世界，全部退出后再停止主世界
+func (p *trackedProcess) StopCluster(clusterName string) ([]game.LevelResult, error) {
+	unlock := p.lifecycle.lockCluster(clusterName)
+	defer unlock()
+	return p.stopCluster(clusterName)
+}
+
+func (p *trackedProcess) stopCluster(clusterName string) ([]game.LevelResult, error) {
+	levels, err := p.lifecycle.levelConfigUtils.GetOrderedLevels(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]game.LevelResult, len(levels))
+	stop := func(i int) {
+		level := levels[i]
+		result := game.LevelResult{
+			LevelName: level.File,
+			IsMaster:  level.IsMaster,
+		}
+		if err := p.stop(clusterName, level.File); err != nil {
+			result.Message = err.Error()
+		} else if running, _ := p.Process.Status(clusterName, level.File); running {
+			result.Message = "世界进程仍在运行"
+		} else {
+			result.Success = true
+			result.Message = "已停止"
+		}
+		result.State = string(p.lifecycle.GetState(clusterName, level.File).State)
+		results[i] = result
+	}
+
+	var wg sync.WaitGroup
+	for i, level := range levels {
+		if level.IsMaster {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stop(i)
+		}(i)
+	}
+	wg.Wait()
+	for i, level := range levels {
+		if level.IsMaster {
+			stop(i)
+		}
+	}
+	return results, resultsError("停止", results)
+}
+
+func resultsError(action string, results []game.LevelResult) error {
+	var failed []string
+	for _, result := range results {
+		if !result.Success {
+			failed = append(failed, result.LevelName+": "+result.Message)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return errors.New("部分世界" + action + "失败: " + strings.Join(failed, "; "))
+}
@@ -0,0 +1,376 @@
+package lifecycle
+
+import (
+	"context"
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"dst-admin-go/internal/service/archive"
+	"dst-admin-go/internal/service/game"
+	"dst-admin-go/internal/service/levelConfig"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State 世界的生命周期状态
+type State string
+
+const (
+	Stopped    State = "stopped"
+	Starting   State = "starting"
+	Generating State = "generating"
+	Running    State = "running"
+	Saving     State = "saving"
+	Stopping   State = "stopping"
+	Crashed    State = "crashed"
+)
+
+const (
+	// monitorInterval 检查世界进程是否异常退出的间隔
+	monitorInterval = 2 * time.Second
+	// startGrace 启动后多久内进程不存在不算崩溃，Windows 下进程是异步启动的
+	startGrace = 30 * time.Second
+	// saveTimeout 保存状态持续多久后没有新的日志也认为保存完成
+	saveTimeout = 10 * time.Second
+	// subscriberBuffer 订阅者的事件缓冲，处理不过来的事件会被丢弃
+	subscriberBuffer = 64
+)
+
+// logMarkers server_log.txt 中的状态标记，按顺序匹配
+var logMarkers = []struct {
+	marker string
+	state  State
+}{
+	{"Shutting down", Stopping},
+	{"# Generating", Generating},
+	{"Serializing world", Saving},
+	{"Shard server started", Running},
+	{"Sim paused", Running},
+	{"Sim unpaused", Running},
+}
+
+// LevelState 世界的当前状态
+type LevelState struct {
+	ClusterName string    `json:"clusterName"`
+	LevelName   string    `json:"levelName"`
+	State       State     `json:"state"`
+	Since       time.Time `json:"since"`
+	Message     string    `json:"message"`
+}
+
+// Event 状态变化事件
+type Event struct {
+	ClusterName string    `json:"clusterName"`
+	LevelName   string    `json:"levelName"`
+	From        State     `json:"from"`
+	To          State     `json:"to"`
+	Message     string    `json:"message"`
+	Time        time.Time `json:"time"`
+}
+
+type levelEntry struct {
+	LevelState
+	// cancel 停止读取 server_log.txt
+	cancel context.CancelFunc
+}
+
+// LifecycleService 每个世界一个状态机，由 Process 的操作和 server_log.txt 中的日志驱动
+type LifecycleService struct {
+	process          game.Process
+	archive          *archive.PathResolver
+	levelConfigUtils *levelConfig.LevelConfigUtils
+
+	mu          sync.Mutex
+	levels      map[string]*levelEntry
+	subscribers map[chan Event]struct{}
+	// clusterLocks 同一集群的启动和停止操作依次执行
+	clusterLocks map[string]*sync.Mutex
+}
+
+func NewLifecycleService(process game.Process, archive *archive.PathResolver, levelConfigUtils *levelConfig.LevelConfigUtils) *LifecycleService {
+	return &LifecycleService{
+		process:          process,
+		archive:          archive,
+		levelConfigUtils: levelConfigUtils,
+		levels:           map[string]*levelEntry{},
+		subscribers:      map[chan Event]struct{}{},
+		clusterLocks:     map[string]*sync.Mutex{},
+	}
+}
+
+// lockCluster 定时重启、自动检测、模组更新和手动操作可能同时启动或停止同一集群，加锁后依次执行，返回解锁函数
+func (s *LifecycleService) lockCluster(clusterName string) func() {
+	s.mu.Lock()
+	lock, ok := s.clusterLocks[clusterName]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.clusterLocks[clusterName] = lock
+	}
+	s.mu.Unlock()
+	lock.Lock()
+	return lock.Unlock
+}
+
+// Process 返回会更新状态的 Process，其他服务都应该使用它启动和停止世界
+func (s *LifecycleService) Process() game.Process {
+	return &trackedProcess{
+		Process:   s.process,
+		lifecycle: s,
+	}
+}
+
+// Start 同步已运行世界的状态并开始检测崩溃，面板重启后已运行的世界直接认为是 running
+func (s *LifecycleService) Start(clusterNames []string) {
+	for _, clusterName := range clusterNames {
+		for _, levelName := range s.levelNames(clusterName) {
+			if running, _ := s.process.Status(clusterName, levelName); running {
+				s.transition(clusterName, levelName, Running, "面板启动时世界已在运行")
+			}
+		}
+	}
+	go s.monitor()
+}
+
+func key(clusterName, levelName string) string {
+	return clusterName + "/" + levelName
+}
+
+func (s *LifecycleService) levelNames(clusterName string) []string {
+	config, err := s.levelConfigUtils.GetLevelConfig(clusterName)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(config.LevelList))
+	for _, item := range config.LevelList {
+		names = append(names, item.File)
+	}
+	return names
+}
+
+// active 世界进程应该存在的状态
+func active(state State) bool {
+	return state == Starting || state == Generating || state == Running || state == Saving
+}
+
+// GetState 获取世界的状态，未记录过的世界根据进程是否存在判断
+func (s *LifecycleService) GetState(clusterName, levelName string) LevelState {
+	s.mu.Lock()
+	entry, ok := s.levels[key(clusterName, levelName)]
+	var state LevelState
+	if ok {
+		state = entry.LevelState
+	}
+	s.mu.Unlock()
+	if ok {
+		return state
+	}
+	state = LevelState{
+		ClusterName: clusterName,
+		LevelName:   levelName,
+		State:       Stopped,
+	}
+	if running, _ := s.process.Status(clusterName, levelName); running {
+		state.State = Running
+	}
+	return state
+}
+
+// GetClusterStates 获取集群所有世界的状态
+func (s *LifecycleService) GetClusterStates(clusterName string) []LevelState {
+	names := s.levelNames(clusterName)
+	states := make([]LevelState, 0, len(names))
+	for _, levelName := range names {
+		states = append(states, s.GetState(clusterName, levelName))
+	}
+	return states
+}
+
+// Subscribe 订阅状态变化事件，返回取消订阅的函数
+func (s *LifecycleService) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subscribers, ch)
+			s.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// WaitFor 等待世界进入指定状态之一，进入 crashed 或 stopped（未在等待列表中时）会提前返回错误
+func (s *LifecycleService) WaitFor(ctx context.Context, clusterName, levelName string, timeout time.Duration, states ...State) (LevelState, error) {
+	events, cancel := s.Subscribe()
+	defer cancel()
+
+	match := func(state LevelState) (bool, error) {
+		for _, target := range states {
+			if state.State == target {
+				return true, nil
+			}
+		}
+		if state.State == Crashed || state.State == Stopped {
+			return true, errors.New("世界已" + stateName(state.State) + ": " + state.Message)
+		}
+		return false, nil
+	}
+	current := s.GetState(clusterName, levelName)
+	if done, err := match(current); done {
+		return current, err
+	}
+
+	ctx, cancelTimeout := context.WithTimeout(ctx, timeout)
+	defer cancelTimeout()
+	for {
+		select {
+		case <-ctx.Done():
+			return s.GetState(clusterName, levelName), fileUtils.ErrWaitTimeout
+		case event := <-events:
+			if event.ClusterName != clusterName || event.LevelName != levelName {
+				continue
+			}
+			current = s.GetState(clusterName, levelName)
+			if done, err := match(current); done {
+				return current, err
+			}
+		}
+	}
+}
+
+func stateName(state State) string {
+	switch state {
+	case Crashed:
+		return "崩溃"
+	case Stopped:
+		return "停止"
+	}
+	return string(state)
+}
+
+// transition 切换状态并发布事件，状态没有变化时不发布
+func (s *LifecycleService) transition(clusterName, levelName string, to State, message string) {
+	logPath := s.archive.ServerLogPath(clusterName, levelName)
+
+	s.mu.Lock()
+	k := key(clusterName, levelName)
+	entry, ok := s.levels[k]
+	if !ok {
+		entry = &levelEntry{LevelState: LevelState{ClusterName: clusterName, LevelName: levelName, State: Stopped}}
+		s.levels[k] = entry
+	}
+	from := entry.State
+	if from == to {
+		s.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	entry.State = to
+	entry.Since = now
+	entry.Message = message
+
+	// 启动时重新从日志末尾读取，避免上一次运行的日志影响状态
+	if to == Starting && entry.cancel != nil {
+		entry.cancel()
+		entry.cancel = nil
+	}
+	if active(to) && entry.cancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		entry.cancel = cancel
+		go fileUtils.FollowLines(ctx, logPath, fileUtils.FileSize(logPath), func(line string) {
+			s.handleLog(clusterName, levelName, line)
+		})
+	}
+	if (to == Stopped || to == Crashed) && entry.cancel != nil {
+		entry.cancel()
+		entry.cancel = nil
+	}
+
+	event := Event{
+		ClusterName: clusterName,
+		LevelName:   levelName,
+		From:        from,
+		To:          to,
+		Message:     message,
+		Time:        now,
+	}
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	s.mu.Unlock()
+
+	if to == Crashed {
+		log.Println("[Lifecycle]世界异常退出", "cluster:", clusterName, "level:", levelName, message)
+	}
+}
+
+// handleLog 根据 server_log.txt 中的标记切换状态
+func (s *LifecycleService) handleLog(clusterName, levelName, line string) {
+	current := s.GetState(clusterName, levelName).State
+	for _, item := range logMarkers {
+		if !strings.Contains(line, item.marker) {
+			continue
+		}
+		// 正在停止时，后续的日志不再改变状态
+		if current == Stopping && item.state != Stopping {
+			return
+		}
+		s.transition(clusterName, levelName, item.state, strings.TrimSpace(line))
+		return
+	}
+	// 保存完成后有新的日志输出，回到运行状态
+	if current == Saving && !strings.Contains(line, "Serializing") {
+		s.transition(clusterName, levelName, Running, "保存完成")
+	}
+}
+
+// monitor 定时检查状态与进程是否一致
+func (s *LifecycleService) monitor() {
+	ticker := time.NewTicker(monitorInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.mu.Lock()
+		states := make([]LevelState, 0, len(s.levels))
+		for _, entry := range s.levels {
+			states = append(states, entry.LevelState)
+		}
+		s.mu.Unlock()
+
+		for _, state := range states {
+			s.check(state)
+		}
+	}
+}
+
+func (s *LifecycleService) check(state LevelState) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("[Lifecycle]检查世界状态异常", "cluster:", state.ClusterName, "level:", state.LevelName, r)
+		}
+	}()
+	if state.State == Stopped || state.State == Crashed {
+		return
+	}
+	if state.State == Saving && time.Since(state.Since) > saveTimeout {
+		s.transition(state.ClusterName, state.LevelName, Running, "保存完成")
+		return
+	}
+	if state.State == Starting && time.Since(state.Since) < startGrace {
+		return
+	}
+	if running, _ := s.process.Status(state.ClusterName, state.LevelName); running {
+		return
+	}
+	if state.State == Stopping {
+		s.transition(state.ClusterName, state.LevelName, Stopped, "世界已停止")
+		return
+	}
+	s.transition(state.ClusterName, state.LevelName, Crashed, "世界进程不存在，上一个状态: "+string(state.State))
+}
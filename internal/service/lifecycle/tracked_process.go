@@ -0,0 +1,95 @@
+package lifecycle
+
+import (
+	"dst-admin-go/internal/service/game"
+	"strings"
+)
+
+// trackedProcess 包装 game.Process，在启动、停止和发送命令时更新世界状态
+type trackedProcess struct {
+	game.Process
+	lifecycle *LifecycleService
+}
+
+// Start 会先停止正在运行的世界，启动完成后才切换为 starting，避免读取到上一次运行的关闭日志
+func (p *trackedProcess) Start(clusterName, levelName string) error {
+	unlock := p.lifecycle.lockCluster(clusterName)
+	defer unlock()
+	return p.start(clusterName, levelName)
+}
+
+func (p *trackedProcess) start(clusterName, levelName string) error {
+	p.beforeStart(clusterName, levelName)
+	err := p.Process.Start(clusterName, levelName)
+	if err != nil {
+		p.lifecycle.transition(clusterName, levelName, Crashed, "启动失败: "+err.Error())
+		return err
+	}
+	p.lifecycle.transition(clusterName, levelName, Starting, "正在启动")
+	return nil
+}
+
+func (p *trackedProcess) beforeStart(clusterName, levelName string) {
+	if active(p.lifecycle.GetState(clusterName, levelName).State) {
+		p.lifecycle.transition(clusterName, levelName, Stopping, "正在重启")
+	}
+}
+
+func (p *trackedProcess) Stop(clusterName, levelName string) error {
+	unlock := p.lifecycle.lockCluster(clusterName)
+	defer unlock()
+	return p.stop(clusterName, levelName)
+}
+
+func (p *trackedProcess) stop(clusterName, levelName string) error {
+	if active(p.lifecycle.GetState(clusterName, levelName).State) {
+		p.lifecycle.transition(clusterName, levelName, Stopping, "正在停止")
+	}
+	err := p.Process.Stop(clusterName, levelName)
+	p.afterStop(clusterName, levelName)
+	return err
+}
+
+// StartAll 按主从顺序启动，见 StartCluster
+func (p *trackedProcess) StartAll(clusterName string) error {
+	_, err := p.StartCluster(clusterName)
+	return err
+}
+
+// StopAll 按主从相反的顺序停止，见 StopCluster
+func (p *trackedProcess) StopAll(clusterName string) error {
+	_, err := p.StopCluster(clusterName)
+	return err
+}
+
+// afterStop 停止操作完成后，进程已退出的世界切换为 stopped，仍在运行的等待 monitor 检测
+func (p *trackedProcess) afterStop(clusterName, levelName string) {
+	if running, _ := p.Process.Status(clusterName, levelName); !running {
+		p.lifecycle.transition(clusterName, levelName, Stopped, "世界已停止")
+	}
+}
+
+// Command 通过控制台保存或关闭世界时同步更新状态
+func (p *trackedProcess) Command(clusterName, levelName, command string) error {
+	err := p.Process.Command(clusterName, levelName, command)
+	if err != nil {
+		return err
+	}
+	trimmed := strings.TrimSpace(command)
+	state := p.lifecycle.GetState(clusterName, levelName).State
+	switch {
+	case strings.HasPrefix(trimmed, "c_shutdown(") && active(state):
+		p.lifecycle.transition(clusterName, levelName, Stopping, "控制台执行 c_shutdown")
+	case strings.HasPrefix(trimmed, "c_save(") && state == Running:
+		p.lifecycle.transition(clusterName, levelName, Saving, "控制台执行 c_save")
+	}
+	return nil
+}
+
+// ConsoleLogPath 内部的 Process 没有控制台输出文件时返回空
+func (p *trackedProcess) ConsoleLogPath(clusterName, levelName string) string {
+	if consoleLogger, ok := p.Process.(game.ConsoleLogger); ok {
+		return consoleLogger.ConsoleLogPath(clusterName, levelName)
+	}
+	return ""
+}
@@ -2,8 +2,10 @@ package login
 
 import (
 	"dst-admin-go/internal/config"
+	"dst-admin-go/internal/model"
 	"dst-admin-go/internal/pkg/response"
-	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"dst-admin-go/internal/service/user"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -12,14 +14,12 @@ import (
 	"github.com/gin-contrib/sessions"
 
 	"github.com/gin-gonic/gin"
-)
-
-const (
-	PasswordPath = "./password.txt"
+	"gorm.io/gorm"
 )
 
 type LoginService struct {
-	config *config.Config
+	config      *config.Config
+	userService *user.UserService
 }
 
 type UserInfo struct {
@@ -27,30 +27,36 @@ type UserInfo struct {
 	Password    string `json:"password"`
 	DisplayName string `json:"displayName"`
 	PhotoURL    string `json:"photoURL"`
+	Role        string `json:"role"`
 }
 
-func NewLoginService(config *config.Config) *LoginService {
+func NewLoginService(config *config.Config, userService *user.UserService) *LoginService {
 	return &LoginService{
-		config: config,
+		config:      config,
+		userService: userService,
 	}
 }
 
-func (l *LoginService) GetUserInfo() UserInfo {
-	user, err := fileUtils.ReadLnFile(PasswordPath)
+// CurrentUser 获取当前登录的用户
+func (l *LoginService) CurrentUser(ctx *gin.Context) (*model.User, error) {
+	session := sessions.Default(ctx)
+	username, ok := session.Get("username").(string)
+	if !ok || username == "" {
+		return nil, errors.New("未登录")
+	}
+	return l.userService.GetUser(username)
+}
 
+func (l *LoginService) GetUserInfo(ctx *gin.Context) UserInfo {
+	user, err := l.CurrentUser(ctx)
 	if err != nil {
-		log.Panicln("Not find password file error: " + err.Error())
+		log.Panicln("获取用户信息失败: " + err.Error())
 	}
-
-	username := strings.TrimSpace(strings.Split(user[0], "=")[1])
-	// password := strings.TrimSpace(strings.Split(user[1], "=")[1])
-	displayName := strings.TrimSpace(strings.Split(user[2], "=")[1])
-	photoURL := strings.TrimSpace(strings.Split(user[3], "=")[1])
-
 	return UserInfo{
-		Username:    username,
-		DisplayName: displayName,
-		PhotoURL:    photoURL,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		PhotoURL:    user.PhotoURL,
+		Role:        user.Role,
 	}
 }
 
@@ -58,26 +64,24 @@ func (l *LoginService) Login(userInfo UserInfo, ctx *gin.Context) *response.Resp
 
 	response := &response.Response{}
 
-	user, err := fileUtils.ReadLnFile(PasswordPath)
-	if err != nil {
-		log.Panicln("Not find password file error: " + err.Error())
-	}
-
-	username := strings.TrimSpace(strings.Split(user[0], "=")[1])
-	password := strings.TrimSpace(strings.Split(user[1], "=")[1])
-	displayName := strings.TrimSpace(strings.Split(user[2], "=")[1])
-	photoURL := strings.TrimSpace(strings.Split(user[3], "=")[1])
-	white := l.IsWhiteIP(ctx)
-	if !white {
-		if username != userInfo.Username || password != userInfo.Password {
-			log.Panicln("User authentication failed")
-			response.Code = 401
-			response.Msg = "User authentication failed"
-			return response
+	var loginUser *model.User
+	var err error
+	if l.IsWhiteIP(ctx) {
+		loginUser, err = l.userService.GetUser(userInfo.Username)
+		if err != nil {
+			loginUser, err = l.userService.GetOwner()
 		}
+	} else {
+		loginUser, err = l.userService.Authenticate(userInfo.Username, userInfo.Password)
+	}
+	if err != nil {
+		log.Println("User authentication failed", userInfo.Username)
+		response.Code = 401
+		response.Msg = "User authentication failed"
+		return response
 	}
 	session := sessions.Default(ctx)
-	session.Set("username", username)
+	session.Set("username", loginUser.Username)
 	err = session.Save()
 	if err != nil {
 		log.Panicln(err)
@@ -86,9 +90,10 @@ func (l *LoginService) Login(userInfo UserInfo, ctx *gin.Context) *response.Resp
 	response.Code = 200
 	response.Msg = "Login success"
 	response.Data = map[string]interface{}{
-		"username":    username,
-		"displayName": displayName,
-		"photoURL":    photoURL,
+		"username":    loginUser.Username,
+		"displayName": loginUser.DisplayName,
+		"photoURL":    loginUser.PhotoURL,
+		"role":        loginUser.Role,
 	}
 
 	return response
@@ -103,48 +108,47 @@ func (l *LoginService) Logout(ctx *gin.Context) {
 	}
 }
 
+// DirectLogin 白名单 IP 免密登录，使用 owner 用户
 func (l *LoginService) DirectLogin(ctx *gin.Context) {
-	user, err := fileUtils.ReadLnFile(PasswordPath)
+	owner, err := l.userService.GetOwner()
 	if err != nil {
-		log.Panicln("Not find password file error: " + err.Error())
+		log.Panicln("Not find owner user error: " + err.Error())
 	}
-	username := strings.TrimSpace(strings.Split(user[0], "=")[1])
 	session := sessions.Default(ctx)
-	session.Set("username", username)
+	session.Set("username", owner.Username)
 }
 
-func (l *LoginService) ChangeUser(username, password string) {
-	user, err := fileUtils.ReadLnFile(PasswordPath)
+// ChangeUser 修改当前用户的用户名和密码
+func (l *LoginService) ChangeUser(ctx *gin.Context, userInfo UserInfo) error {
+	current, err := l.CurrentUser(ctx)
 	if err != nil {
-		log.Panicln("Not find password file error: " + err.Error())
-	}
-	displayName := strings.TrimSpace(strings.Split(user[2], "=")[1])
-	photoURL := strings.TrimSpace(strings.Split(user[3], "=")[1])
-	fileUtils.WriterLnFile(PasswordPath, []string{
-		"username = " + username,
-		"password = " + password,
-		"displayName=" + displayName,
-		"photoURL=" + photoURL,
-	})
+		return err
+	}
+	current.Username = userInfo.Username
+	current.DisplayName = userInfo.DisplayName
+	current.PhotoURL = userInfo.PhotoURL
+	// 不允许修改自己的角色
+	current.Role = ""
+	session := sessions.Default(ctx)
+	if err := l.userService.UpdateUser(current, userInfo.Password, session.ID()); err != nil {
+		return err
+	}
+	session.Set("username", current.Username)
+	return session.Save()
 }
 
-func (l *LoginService) ChangePassword(newPassword string) *response.Response {
+func (l *LoginService) ChangePassword(ctx *gin.Context, newPassword string) *response.Response {
 
 	response := &response.Response{}
-	user, err := fileUtils.ReadLnFile(PasswordPath)
-
+	current, err := l.CurrentUser(ctx)
+	if err == nil {
+		err = l.userService.ChangePassword(current.Username, newPassword, sessions.Default(ctx).ID())
+	}
 	if err != nil {
-		log.Panicln("Not find password file error: " + err.Error())
-	}
-	username := strings.TrimSpace(strings.Split(user[0], "=")[1])
-	displayName := strings.TrimSpace(strings.Split(user[2], "=")[1])
-	photoURL := strings.TrimSpace(strings.Split(user[3], "=")[1])
-	fileUtils.WriterLnFile(PasswordPath, []string{
-		"username = " + username,
-		"password = " + newPassword,
-		"displayName=" + displayName,
-		"photoURL=" + photoURL,
-	})
+		response.Code = 500
+		response.Msg = "Update user new password failed: " + err.Error()
+		return response
+	}
 
 	response.Code = 200
 	response.Msg = "Update user new password success"
@@ -152,12 +156,20 @@ func (l *LoginService) ChangePassword(newPassword string) *response.Response {
 	return response
 }
 
-func (l *LoginService) InitUserInfo(userInfo UserInfo) {
-	username := "username=" + userInfo.Username
-	password := "password=" + userInfo.Password
-	displayName := "displayName=" + userInfo.DisplayName
-	photoURL := "photoURL=" + userInfo.PhotoURL
-	fileUtils.WriterLnFile(PasswordPath, []string{username, password, displayName, photoURL})
+// HasUser 是否已创建用户
+func (l *LoginService) HasUser() bool {
+	return l.userService.HasUser()
+}
+
+// InitUserInfo 首次初始化时在事务 tx 中创建 owner 用户
+func (l *LoginService) InitUserInfo(tx *gorm.DB, userInfo UserInfo) error {
+	owner := model.User{
+		Username:    userInfo.Username,
+		DisplayName: userInfo.DisplayName,
+		PhotoURL:    userInfo.PhotoURL,
+		Role:        user.RoleOwner,
+	}
+	return l.userService.CreateUserTx(tx, &owner, userInfo.Password)
 }
 
 func (l *LoginService) IsWhiteIP(ctx *gin.Context) bool {
@@ -0,0 +1,303 @@
+package mod
+
+import (
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/service/levelConfig"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// ProblemMissingDependency 依赖的模组没有在同一个世界启用
+	ProblemMissingDependency = "missing_dependency"
+	// ProblemClientOnly 仅客户端模组在服务器启用
+	ProblemClientOnly = "client_only"
+	// ProblemLevelMismatch 模组只在部分世界启用
+	ProblemLevelMismatch = "level_mismatch"
+	// ProblemUnknownMod 没有模组信息，无法检查
+	ProblemUnknownMod = "unknown_mod"
+	// ProblemParseError modoverrides.lua 无法解析
+	ProblemParseError = "parse_error"
+)
+
+// ModDependency 模组的依赖信息，来自创意工坊的依赖项和 modinfo.lua
+type ModDependency struct {
+	ModId             string   `json:"modId"`
+	Name              string   `json:"name"`
+	Known             bool     `json:"known"`
+	ClientOnly        bool     `json:"clientOnly"`
+	AllClientsRequire bool     `json:"allClientsRequire"`
+	ServerFilterTags  []string `json:"serverFilterTags"`
+	// Requires 依赖项，每一项中的模组任意启用一个即可
+	Requires [][]string `json:"requires"`
+}
+
+// ModProblem 模组检查发现的问题，Severity 为 error 时世界启动后模组可能无法正常工作
+type ModProblem struct {
+	Type       string `json:"type"`
+	Severity   string `json:"severity"`
+	LevelName  string `json:"levelName,omitempty"`
+	ModId      string `json:"modId,omitempty"`
+	Dependency string `json:"dependency,omitempty"`
+	Message    string `json:"message"`
+}
+
+// ModDependencyReport 世界模组的依赖和冲突检查结果
+type ModDependencyReport struct {
+	Ok       bool            `json:"ok"`
+	Mods     []ModDependency `json:"mods"`
+	Problems []ModProblem    `json:"problems"`
+}
+
+// levelMods 世界中启用的模组
+type levelMods struct {
+	name    string
+	enabled map[string]bool
+}
+
+// CheckModDependencies 检查世界启用的模组：缺少的依赖、在服务器启用的仅客户端模组、只在部分世界启用的模组
+func (s *ModService) CheckModDependencies(levels []levelConfig.LevelInfo) *ModDependencyReport {
+	report := &ModDependencyReport{
+		Ok:       true,
+		Mods:     make([]ModDependency, 0),
+		Problems: make([]ModProblem, 0),
+	}
+	add := func(problem ModProblem) {
+		if problem.Severity == IssueError {
+			report.Ok = false
+		}
+		report.Problems = append(report.Problems, problem)
+	}
+
+	parsed := make([]levelMods, 0, len(levels))
+	allMods := make(map[string]bool)
+	for _, level := range levels {
+		name := level.LevelName
+		if name == "" {
+			name = level.Uuid
+		}
+		overrides, err := ParseModOverrides(level.Modoverrides)
+		if err != nil {
+			add(ModProblem{Type: ProblemParseError, Severity: IssueError, LevelName: name, Message: err.Error()})
+			continue
+		}
+		enabled := make(map[string]bool)
+		for _, override := range overrides {
+			if override.Enabled {
+				enabled[override.Id] = true
+				allMods[override.Id] = true
+			}
+		}
+		parsed = append(parsed, levelMods{name: name, enabled: enabled})
+	}
+
+	modIds := make([]string, 0, len(allMods))
+	for modId := range allMods {
+		modIds = append(modIds, modId)
+	}
+	sort.Strings(modIds)
+	deps := make(map[string]ModDependency, len(modIds))
+	for _, modId := range modIds {
+		dep := s.modDependency(modId)
+		deps[modId] = dep
+		report.Mods = append(report.Mods, dep)
+		if !dep.Known {
+			add(ModProblem{Type: ProblemUnknownMod, Severity: IssueWarning, ModId: modId,
+				Message: "没有模组信息，无法检查依赖: " + modId})
+		}
+	}
+
+	for _, level := range parsed {
+		for _, modId := range modIds {
+			if !level.enabled[modId] {
+				continue
+			}
+			dep := deps[modId]
+			if dep.ClientOnly {
+				add(ModProblem{Type: ProblemClientOnly, Severity: IssueError, LevelName: level.name, ModId: modId,
+					Message: fmt.Sprintf("%s 是仅客户端模组，不能在服务器启用", dep.displayName())})
+			}
+			for _, candidates := range dep.Requires {
+				if anyEnabled(level.enabled, candidates) {
+					continue
+				}
+				add(ModProblem{Type: ProblemMissingDependency, Severity: IssueError, LevelName: level.name, ModId: modId,
+					Dependency: candidates[0],
+					Message:    fmt.Sprintf("%s 依赖的模组 %s 没有启用%s", dep.displayName(), s.modDisplayName(candidates[0]), s.subscribedHint(candidates[0]))})
+			}
+		}
+	}
+
+	// 需要客户端下载的模组在各个世界不一致时，玩家切换世界会断开连接
+	if len(parsed) > 1 {
+		for _, modId := range modIds {
+			var on, off []string
+			for _, level := range parsed {
+				if level.enabled[modId] {
+					on = append(on, level.name)
+				} else {
+					off = append(off, level.name)
+				}
+			}
+			if len(off) == 0 {
+				continue
+			}
+			dep := deps[modId]
+			if dep.ClientOnly {
+				continue
+			}
+			severity := IssueWarning
+			if dep.AllClientsRequire {
+				severity = IssueError
+			}
+			add(ModProblem{Type: ProblemLevelMismatch, Severity: severity, ModId: modId,
+				Message: fmt.Sprintf("%s 在 %s 启用，但在 %s 没有启用", dep.displayName(), strings.Join(on, ", "), strings.Join(off, ", "))})
+		}
+	}
+	return report
+}
+
+func (d ModDependency) displayName() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return d.ModId
+}
+
+func anyEnabled(enabled map[string]bool, candidates []string) bool {
+	for _, candidate := range candidates {
+		if enabled[candidate] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ModService) findModInfo(modId string) (*model.ModInfo, bool) {
+	id := strings.TrimPrefix(modId, "workshop-")
+	modInfo, err := s.GetModByModId(id)
+	if err != nil || modInfo.Modid == "" {
+		return nil, false
+	}
+	return modInfo, true
+}
+
+func (s *ModService) modDisplayName(modId string) string {
+	if modInfo, ok := s.findModInfo(modId); ok && modInfo.Name != "" {
+		return modInfo.Name + "(" + modId + ")"
+	}
+	return modId
+}
+
+func (s *ModService) subscribedHint(modId string) string {
+	if _, ok := s.findModInfo(modId); ok {
+		return ""
+	}
+	return "，且没有订阅"
+}
+
+// modDependency 从数据库中的模组信息读取依赖，创意工坊的依赖项和 modinfo.lua 的 mod_dependencies 合并
+func (s *ModService) modDependency(modId string) ModDependency {
+	dep := ModDependency{
+		ModId:            modId,
+		ServerFilterTags: make([]string, 0),
+		Requires:         make([][]string, 0),
+	}
+	modInfo, ok := s.findModInfo(modId)
+	if !ok {
+		return dep
+	}
+	dep.Known = true
+	dep.Name = modInfo.Name
+
+	seen := make(map[string]bool)
+	addRequire := func(candidates []string) {
+		if len(candidates) == 0 {
+			return
+		}
+		for _, candidate := range candidates {
+			if candidate == modId || seen[candidate] {
+				return
+			}
+		}
+		for _, candidate := range candidates {
+			seen[candidate] = true
+		}
+		dep.Requires = append(dep.Requires, candidates)
+	}
+	for _, child := range strings.Split(modInfo.Child, ",") {
+		if child = strings.TrimSpace(child); child != "" {
+			addRequire([]string{"workshop-" + child})
+		}
+	}
+
+	var config map[string]interface{}
+	if modInfo.ModConfig == "" || json.Unmarshal([]byte(modInfo.ModConfig), &config) != nil {
+		return dep
+	}
+	dep.ClientOnly, _ = config["client_only_mod"].(bool)
+	dep.AllClientsRequire, _ = config["all_clients_require_mod"].(bool)
+	if tags, ok := config["server_filter_tags"].([]interface{}); ok {
+		for _, tag := range tags {
+			dep.ServerFilterTags = append(dep.ServerFilterTags, stringOf(tag))
+		}
+	}
+	for _, entry := range asList(config["mod_dependencies"]) {
+		addRequire(dependencyCandidates(entry))
+	}
+	return dep
+}
+
+// asList Lua 数组转换后可能是数组，也可能是以序号为键的 map
+func asList(v interface{}) []interface{} {
+	switch list := v.(type) {
+	case []interface{}:
+		return list
+	case map[string]interface{}:
+		keys := make([]string, 0, len(list))
+		for k := range list {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		values := make([]interface{}, 0, len(list))
+		for _, k := range keys {
+			values = append(values, list[k])
+		}
+		return values
+	}
+	return nil
+}
+
+// dependencyCandidates mod_dependencies 的一项，如 { workshop = "workshop-xxx" } 或 { ["workshop-xxx"] = true, "ModName" }
+// 只识别创意工坊模组，其中任意一个启用即满足依赖
+func dependencyCandidates(entry interface{}) []string {
+	candidates := make([]string, 0)
+	addCandidate := func(id string) {
+		if strings.HasPrefix(id, "workshop-") && isWorkshopId(strings.TrimPrefix(id, "workshop-")) {
+			candidates = append(candidates, id)
+		}
+	}
+	switch e := entry.(type) {
+	case string:
+		addCandidate(e)
+	case []interface{}:
+		for _, v := range e {
+			addCandidate(stringOf(v))
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(e))
+		for k := range e {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			addCandidate(k)
+			if s, ok := e[k].(string); ok {
+				addCandidate(s)
+			}
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,258 @@
+package mod
+
+import (
+	"dst-admin-go/internal/pkg/utils/dstUtils"
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidModConfig 模组配置没有通过校验
+var ErrInvalidModConfig = errors.New("模组配置校验失败")
+
+// LevelModOverrides 世界的模组配置
+type LevelModOverrides struct {
+	LevelName string          `json:"levelName"`
+	Mods      []LevelModView  `json:"mods"`
+	Issues    []OverrideIssue `json:"issues"`
+}
+
+// LevelModView 世界中的一个模组，Schema 为空且 Known 为 false 时表示没有模组信息，无法校验
+type LevelModView struct {
+	ModOverride
+	Name   string      `json:"name"`
+	Known  bool        `json:"known"`
+	Schema []ModOption `json:"schema"`
+}
+
+// ConfigureModRequest 修改模组配置，Replace 为 true 时用 Options 替换全部配置，否则只修改提交的配置项
+// 值为 null 的配置项会被删除，恢复为模组的默认值
+type ConfigureModRequest struct {
+	LevelName string                 `json:"levelName"`
+	ModId     string                 `json:"modId"`
+	Options   map[string]interface{} `json:"options"`
+	Replace   bool                   `json:"replace"`
+	Force     bool                   `json:"force"`
+}
+
+// NormalizeModId 纯数字的 id 补全为 workshop-<id>
+func NormalizeModId(modId string) (string, error) {
+	modId = strings.TrimSpace(modId)
+	if modId == "" {
+		return "", errors.New("模组 id 不能为空")
+	}
+	if isWorkshopId(modId) {
+		return "workshop-" + modId, nil
+	}
+	return modId, nil
+}
+
+// modSchema 模组的配置项定义，数据库中没有模组信息时 known 为 false
+func (s *ModService) modSchema(override ModOverride) ([]ModOption, string, bool) {
+	modId := override.WorkshopId()
+	if modId == "" {
+		modId = override.Id
+	}
+	modInfo, err := s.GetModByModId(modId)
+	if err != nil {
+		return []ModOption{}, "", false
+	}
+	schema, err := ParseModOptions(modInfo.ModConfig)
+	if err != nil {
+		log.Println("[Mod]解析模组配置项失败", modId, err)
+		return []ModOption{}, modInfo.Name, false
+	}
+	return schema, modInfo.Name, true
+}
+
+// validateOverrides 校验所有模组的配置，没有模组信息的模组只给出警告
+func (s *ModService) validateOverrides(overrides []ModOverride) ([]LevelModView, []OverrideIssue) {
+	views := make([]LevelModView, 0, len(overrides))
+	issues := make([]OverrideIssue, 0)
+	for _, override := range overrides {
+		schema, name, known := s.modSchema(override)
+		views = append(views, LevelModView{
+			ModOverride: override,
+			Name:        name,
+			Known:       known,
+			Schema:      schema,
+		})
+		if !known {
+			issues = append(issues, OverrideIssue{
+				ModId:   override.Id,
+				Level:   IssueWarning,
+				Message: "没有模组信息，无法校验配置，请先订阅模组",
+			})
+			continue
+		}
+		issues = append(issues, ValidateModOverride(override, schema)...)
+	}
+	return views, issues
+}
+
+// ValidateModOverrides 校验 modoverrides.lua 的内容
+func (s *ModService) ValidateModOverrides(content string) ([]OverrideIssue, error) {
+	overrides, err := ParseModOverrides(content)
+	if err != nil {
+		return nil, err
+	}
+	_, issues := s.validateOverrides(overrides)
+	return issues, nil
+}
+
+func (s *ModService) readLevelOverrides(clusterName, levelName string) (string, []ModOverride, error) {
+	if levelName == "" || levelName != filepath.Base(levelName) || levelName == ".." {
+		return "", nil, errors.New("世界名称不合法: " + levelName)
+	}
+	path := s.pathResolver.ModoverridesPath(clusterName, levelName)
+	if !fileUtils.Exists(filepath.Dir(path)) {
+		return "", nil, errors.New("世界不存在: " + levelName)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", nil, err
+	}
+	overrides, err := ParseModOverrides(string(content))
+	return path, overrides, err
+}
+
+// GetLevelModOverrides 读取世界的模组配置并校验
+func (s *ModService) GetLevelModOverrides(clusterName, levelName string) (*LevelModOverrides, error) {
+	_, overrides, err := s.readLevelOverrides(clusterName, levelName)
+	if err != nil {
+		return nil, err
+	}
+	views, issues := s.validateOverrides(overrides)
+	return &LevelModOverrides{
+		LevelName: levelName,
+		Mods:      views,
+		Issues:    issues,
+	}, nil
+}
+
+// updateLevelOverrides 读取世界的模组配置，修改后重新生成 modoverrides.lua
+func (s *ModService) updateLevelOverrides(clusterName, levelName string, update func([]ModOverride) ([]ModOverride, error)) (*LevelModOverrides, error) {
+	s.overridesMu.Lock()
+	defer s.overridesMu.Unlock()
+
+	path, overrides, err := s.readLevelOverrides(clusterName, levelName)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err = update(overrides)
+	if err != nil {
+		return nil, err
+	}
+	content := FormatModOverrides(overrides)
+	if err := fileUtils.WriterTXT(path, content); err != nil {
+		return nil, err
+	}
+	// 新启用的模组需要加入 dedicated_server_mods_setup.lua 才会被下载
+	if config, err := s.dstConfig.GetDstConfig(clusterName); err == nil {
+		if err := dstUtils.DedicatedServerModsSetup(config, content); err != nil {
+			log.Println("[Mod]更新 dedicated_server_mods_setup.lua 失败", err)
+		}
+	}
+	return s.GetLevelModOverrides(clusterName, levelName)
+}
+
+func findOverride(overrides []ModOverride, modId string) int {
+	for i := range overrides {
+		if overrides[i].Id == modId {
+			return i
+		}
+	}
+	return -1
+}
+
+// EnableLevelMod 在世界中启用模组，模组未添加时按默认配置添加
+func (s *ModService) EnableLevelMod(clusterName, levelName, modId string) (*LevelModOverrides, error) {
+	modId, err := NormalizeModId(modId)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateLevelOverrides(clusterName, levelName, func(overrides []ModOverride) ([]ModOverride, error) {
+		if i := findOverride(overrides, modId); i >= 0 {
+			overrides[i].Enabled = true
+			return overrides, nil
+		}
+		override := ModOverride{Id: modId, Enabled: true}
+		schema, _, _ := s.modSchema(override)
+		override.ConfigurationOptions = DefaultModOptions(schema)
+		return append(overrides, override), nil
+	})
+}
+
+// DisableLevelMod 在世界中禁用模组，保留模组的配置
+func (s *ModService) DisableLevelMod(clusterName, levelName, modId string) (*LevelModOverrides, error) {
+	modId, err := NormalizeModId(modId)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateLevelOverrides(clusterName, levelName, func(overrides []ModOverride) ([]ModOverride, error) {
+		i := findOverride(overrides, modId)
+		if i < 0 {
+			return nil, errors.New("世界中没有添加模组: " + modId)
+		}
+		overrides[i].Enabled = false
+		return overrides, nil
+	})
+}
+
+// RemoveLevelMod 从世界中移除模组及其配置
+func (s *ModService) RemoveLevelMod(clusterName, levelName, modId string) (*LevelModOverrides, error) {
+	modId, err := NormalizeModId(modId)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateLevelOverrides(clusterName, levelName, func(overrides []ModOverride) ([]ModOverride, error) {
+		i := findOverride(overrides, modId)
+		if i < 0 {
+			return nil, errors.New("世界中没有添加模组: " + modId)
+		}
+		return append(overrides[:i], overrides[i+1:]...), nil
+	})
+}
+
+// ConfigureLevelMod 修改世界中模组的配置，校验有错误时返回校验结果和 ErrInvalidModConfig，只有警告时正常保存，Force 为 true 时仍然保存
+func (s *ModService) ConfigureLevelMod(clusterName string, request ConfigureModRequest) (*LevelModOverrides, []OverrideIssue, error) {
+	modId, err := NormalizeModId(request.ModId)
+	if err != nil {
+		return nil, nil, err
+	}
+	var issues []OverrideIssue
+	result, err := s.updateLevelOverrides(clusterName, request.LevelName, func(overrides []ModOverride) ([]ModOverride, error) {
+		i := findOverride(overrides, modId)
+		if i < 0 {
+			return nil, errors.New("世界中没有添加模组，请先启用: " + modId)
+		}
+		options := make(map[string]interface{})
+		if !request.Replace {
+			for k, v := range overrides[i].ConfigurationOptions {
+				options[k] = v
+			}
+		}
+		for k, v := range request.Options {
+			if v == nil {
+				delete(options, k)
+			} else {
+				options[k] = v
+			}
+		}
+		override := overrides[i]
+		override.ConfigurationOptions = options
+
+		if schema, _, known := s.modSchema(override); known {
+			issues = ValidateModOverride(override, schema)
+		}
+		if hasIssueError(issues) && !request.Force {
+			return nil, ErrInvalidModConfig
+		}
+		overrides[i] = override
+		return overrides, nil
+	})
+	return result, issues, err
+}
@@ -0,0 +1,207 @@
+package mod
+
+import (
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"dst-admin-go/internal/service/mod/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// modContentPath steamcmd 下载的模组目录
+func (s *ModService) modContentPath(clusterName, modId string) (string, error) {
+	config, err := s.dstConfig.GetDstConfig(clusterName)
+	if err != nil {
+		return "", err
+	}
+	if config.Mod_download_path == "" {
+		return "", errors.New("没有配置模组下载目录")
+	}
+	return filepath.Join(config.Mod_download_path, "steamapps", "workshop", "content", "322330", modId), nil
+}
+
+// installedModPath 本机已下载的模组目录，version 为创意工坊的版本标签
+// 饥荒服务器 ugc_mods 中的模组可能还是旧版本，优先使用 steamcmd 下载目录，并且要求 modinfo.lua 中的 version 与 version 相同
+// 创意工坊没有版本标签和本地模组无法校验，使用找到的第一个目录
+func (s *ModService) installedModPath(clusterName, modId, version string) (string, error) {
+	paths := make([]string, 0, 2)
+	if path, err := s.modContentPath(clusterName, modId); err == nil && fileUtils.Exists(path) {
+		paths = append(paths, path)
+	}
+	if path, ok := s.getDstUcgsModsInstalledPath(clusterName, modId); ok {
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		return "", errors.New("模组还没有下载: " + modId)
+	}
+	if version == "" {
+		return paths[0], nil
+	}
+	for _, path := range paths {
+		if s.installedModVersion(modId, path) == strings.TrimSpace(version) {
+			return path, nil
+		}
+	}
+	return "", errors.New("本机已下载的模组不是最新版本 " + version + "，请先更新模组: " + modId)
+}
+
+// installedModVersion 模组目录中 modinfo.lua 的 version
+func (s *ModService) installedModVersion(modId, path string) string {
+	script, err := os.ReadFile(filepath.Join(path, "modinfo.lua"))
+	if err != nil {
+		return ""
+	}
+	version, ok := s.parseModInfoLua("", modId, string(script))["version"]
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(version))
+}
+
+func metaOf(modInfo *model.ModInfo) repository.Meta {
+	return repository.Meta{
+		ModId:       modInfo.Modid,
+		Name:        modInfo.Name,
+		Auth:        modInfo.Auth,
+		Description: modInfo.Description,
+		Img:         modInfo.Img,
+		V:           modInfo.V,
+		LastTime:    modInfo.LastTime,
+		Child:       modInfo.Child,
+		ModConfig:   modInfo.ModConfig,
+	}
+}
+
+// GetRepositoryMods 模组仓库中的所有模组版本
+func (s *ModService) GetRepositoryMods() ([]repository.Meta, error) {
+	return s.repository.List()
+}
+
+// StoreModToRepository 将本机已下载的模组保存到模组仓库，版本为模组的更新时间
+func (s *ModService) StoreModToRepository(clusterName, modId string) (repository.Meta, error) {
+	modInfo, err := s.GetModByModId(modId)
+	if err != nil {
+		return repository.Meta{}, errors.New("没有订阅模组: " + modId)
+	}
+	path, err := s.installedModPath(clusterName, modId, modInfo.V)
+	if err != nil {
+		return repository.Meta{}, err
+	}
+	meta, err := s.repository.Store(metaOf(modInfo), path)
+	if err != nil {
+		return repository.Meta{}, err
+	}
+	log.Println("[Mod]模组已保存到模组仓库", modId, meta.Version)
+	return meta, nil
+}
+
+// cacheModAsync 模组下载后在后台保存到模组仓库，仓库中已有相同版本时跳过
+func (s *ModService) cacheModAsync(clusterName string, modInfo *model.ModInfo) {
+	if modInfo == nil || modInfo.Modid == "" {
+		return
+	}
+	if _, err := s.repository.Get(modInfo.Modid, repository.VersionOf(modInfo.LastTime)); err == nil {
+		return
+	}
+	modId := modInfo.Modid
+	go func() {
+		if _, err := s.StoreModToRepository(clusterName, modId); err != nil {
+			log.Println("[Mod]保存模组到模组仓库失败", modId, err)
+		}
+	}()
+}
+
+// installFromRepository 模组仓库中有不旧于 lastTime 的版本时从仓库安装，替换本机已下载的版本，不再从 Steam 下载
+func (s *ModService) installFromRepository(clusterName, modId string, lastTime float64) bool {
+	meta, err := s.repository.Latest(modId)
+	if err != nil || meta.LastTime < lastTime {
+		return false
+	}
+	path, err := s.modContentPath(clusterName, modId)
+	if err != nil {
+		return false
+	}
+	if _, err := s.repository.Install(modId, meta.Version, path); err != nil {
+		log.Println("[Mod]从模组仓库安装模组失败", modId, err)
+		return false
+	}
+	log.Println("[Mod]从模组仓库安装模组", modId, meta.Version, "->", path)
+	return true
+}
+
+// InstallModFromRepository 从模组仓库安装模组并添加到已订阅的模组，version 为空时安装最新版本
+// 不需要访问 Steam，可以在没有网络的主机上使用
+func (s *ModService) InstallModFromRepository(clusterName, modId, version, lang string) (*model.ModInfo, error) {
+	path, err := s.modContentPath(clusterName, modId)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := s.repository.Install(modId, version, path)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("[Mod]从模组仓库安装模组", modId, meta.Version, "->", path)
+
+	modConfig := meta.ModConfig
+	if config := s.readModInfo(lang, modId, filepath.Join(path, "modinfo.lua")); len(config) > 0 {
+		modConfigJson, _ := json.Marshal(config)
+		modConfig = string(modConfigJson)
+	}
+
+	modInfo := &model.ModInfo{}
+	s.db.Where("modid = ?", modId).Limit(1).Find(modInfo)
+	modInfo.Modid = modId
+	modInfo.Name = meta.Name
+	modInfo.Auth = meta.Auth
+	modInfo.Description = meta.Description
+	modInfo.Img = meta.Img
+	modInfo.V = meta.V
+	modInfo.LastTime = meta.LastTime
+	modInfo.Child = meta.Child
+	modInfo.ModConfig = modConfig
+	modInfo.Update = false
+	if modInfo.Name == "" {
+		modInfo.Name = modId
+	}
+	return modInfo, s.db.Save(modInfo).Error
+}
+
+// subscribeOffline 访问 Steam 失败时从模组仓库安装，仓库中没有该模组时返回原来的错误
+func (s *ModService) subscribeOffline(clusterName, modId, lang string, cause error) (*model.ModInfo, error) {
+	if _, err := s.repository.Latest(modId); err != nil {
+		return nil, cause
+	}
+	log.Println("[Mod]访问 Steam 失败，从模组仓库安装模组", modId, cause)
+	return s.InstallModFromRepository(clusterName, modId, "", lang)
+}
+
+// DeleteRepositoryMod 删除模组仓库中的模组，version 为空时删除所有版本
+func (s *ModService) DeleteRepositoryMod(modId, version string) error {
+	return s.repository.Delete(modId, version)
+}
+
+// ExportRepository 导出模组仓库，modIds 为空时导出所有模组
+func (s *ModService) ExportRepository(w io.Writer, modIds []string, latestOnly bool) error {
+	metas, err := s.repository.Export(w, modIds, latestOnly)
+	if err != nil {
+		return err
+	}
+	log.Println("[Mod]导出模组仓库，模组版本数:", len(metas))
+	return nil
+}
+
+// ImportRepository 导入其他主机导出的模组仓库
+func (s *ModService) ImportRepository(r io.Reader) ([]repository.Meta, error) {
+	metas, err := s.repository.Import(r)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("[Mod]导入模组仓库，模组版本数:", len(metas))
+	return metas, nil
+}
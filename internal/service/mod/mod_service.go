@@ -3,12 +3,14 @@ package mod
 import (
 	"archive/zip"
 	"bytes"
-	"crypto/tls"
+	"context"
 	"dst-admin-go/internal/model"
 	"dst-admin-go/internal/pkg/utils/fileUtils"
 	"dst-admin-go/internal/pkg/utils/shellUtils"
 	"dst-admin-go/internal/service/archive"
 	"dst-admin-go/internal/service/dstConfig"
+	"dst-admin-go/internal/service/mod/repository"
+	"dst-admin-go/internal/service/mod/workshop"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,9 +18,6 @@ import (
 	"io/ioutil"
 	"log"
 	"math"
-	"mime/multipart"
-	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -27,29 +26,28 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"time"
 
 	lua "github.com/yuin/gopher-lua"
 	"gorm.io/gorm"
 )
 
-const (
-	steamAPIKey = "73DF9F781D195DFD3D19DED1CB72EEE6"
-	appID       = 322330
-	language    = 6
-)
-
 type ModService struct {
 	db           *gorm.DB
 	dstConfig    dstConfig.Config
 	pathResolver *archive.PathResolver
+	repository   *repository.Repository
+	workshop     workshop.Client
+	// overridesMu 修改 modoverrides.lua 时加锁，避免并发修改互相覆盖
+	overridesMu sync.Mutex
 }
 
-func NewModService(db *gorm.DB, config dstConfig.Config, pathResolver *archive.PathResolver) *ModService {
+func NewModService(db *gorm.DB, config dstConfig.Config, pathResolver *archive.PathResolver, repository *repository.Repository, workshop workshop.Client) *ModService {
 	return &ModService{
 		db:           db,
 		dstConfig:    config,
 		pathResolver: pathResolver,
+		repository:   repository,
+		workshop:     workshop,
 	}
 }
 
@@ -84,32 +82,7 @@ type ModInfo struct {
 }
 
 // Publishedfiledetail Steam API 返回的模组详情
-type Publishedfiledetail struct {
-	Publishedfileid       string  `json:"publishedfileid"`
-	Result                int     `json:"result"`
-	Creator               string  `json:"creator"`
-	CreatorAppID          int     `json:"creator_app_id"`
-	ConsumerAppID         int     `json:"consumer_app_id"`
-	Filename              string  `json:"filename"`
-	FileURL               string  `json:"file_url"`
-	HcontentFile          string  `json:"hcontent_file"`
-	PreviewURL            string  `json:"preview_url"`
-	HcontentPreview       string  `json:"hcontent_preview"`
-	Title                 string  `json:"title"`
-	Description           string  `json:"description"`
-	TimeCreated           float64 `json:"time_created"`
-	TimeUpdated           float64 `json:"time_updated"`
-	Visibility            int     `json:"visibility"`
-	BanReason             string  `json:"ban_reason"`
-	Subscriptions         int     `json:"subscriptions"`
-	Favorited             int     `json:"favorited"`
-	LifetimeSubscriptions int     `json:"lifetime_subscriptions"`
-	LifetimeFavorited     int     `json:"lifetime_favorited"`
-	Views                 int     `json:"views"`
-	Tags                  []struct {
-		Tag string `json:"tag"`
-	} `json:"tags"`
-}
+type Publishedfiledetail = workshop.Item
 
 // WorkshopItemDetail UGC mod详情
 type WorkshopItemDetail struct {
@@ -128,11 +101,11 @@ type WorkshopItem struct {
 }
 
 // SearchModList 搜索模组列表
-func (s *ModService) SearchModList(text string, page, size int, lang string) (*SearchResult, error) {
+func (s *ModService) SearchModList(ctx context.Context, text string, page, size int, lang string) (*SearchResult, error) {
 	// 判断是否是modID搜索
 	modId, ok := isModId(text)
 	if ok {
-		modInfo := s.searchModInfoByWorkshopId(modId)
+		modInfo := s.searchModInfoByWorkshopId(ctx, modId)
 		data := []ModInfo{}
 		if modInfo.ID != "" {
 			data = append(data, modInfo)
@@ -147,167 +120,90 @@ func (s *ModService) SearchModList(text string, page, size int, lang string) (*S
 	}
 
 	// 调用 Steam API 搜索
-	urlStr := "http://api.steampowered.com/IPublishedFileService/QueryFiles/v1/"
-	data := url.Values{
-		"page":             {fmt.Sprintf("%d", page)},
-		"key":              {steamAPIKey},
-		"appid":            {"322330"},
-		"language":         {"6"},
-		"return_tags":      {"true"},
-		"numperpage":       {fmt.Sprintf("%d", size)},
-		"search_text":      {text},
-		"return_vote_data": {"true"},
-		"return_children":  {"true"},
-	}
+	language := workshop.LanguageDefault
 	if lang == "zh" {
-		data.Set("language", "6")
-	} else {
-		data.Set("language", "")
-	}
-	urlStr = urlStr + "?" + data.Encode()
-
-	var modData map[string]interface{}
-	for i := 0; i < 2; i++ {
-		resp, err := http.Get(urlStr)
-		if err != nil {
-			return nil, fmt.Errorf("搜索mod失败: %w", err)
-		}
-		defer resp.Body.Close()
-		err = json.NewDecoder(resp.Body).Decode(&modData)
-		if err != nil {
-			return nil, fmt.Errorf("解析mod数据失败: %w", err)
-		}
-		if modData["response"] != nil {
-			break
-		}
+		language = workshop.LanguageChinese
 	}
-
-	if modData["response"] == nil {
-		return nil, errors.New("no response found in mod data")
+	result, err := s.workshop.Query(ctx, workshop.Query{
+		Text:     text,
+		Page:     page,
+		Size:     size,
+		Language: language,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("搜索mod失败: %w", err)
 	}
 
-	modResponse := modData["response"].(map[string]interface{})
-	total := int(modResponse["total"].(float64))
-	modInfoRaw := modResponse["publishedfiledetails"].([]interface{})
-
-	modList := make([]ModInfo, 0)
-	if len(modInfoRaw) > 0 {
-		for _, modInfoRaw := range modInfoRaw {
-			modInfo := modInfoRaw.(map[string]interface{})
-			img := modInfo["preview_url"].(string)
-			voteData := modInfo["vote_data"].(map[string]interface{})
-			auth := modInfo["creator"].(string)
-			var authorURL string
-			if auth != "" {
-				authorURL = fmt.Sprintf("https://steamcommunity.com/profiles/%s/?xml=1", auth)
-			}
-			mod := ModInfo{
-				ID:     fmt.Sprintf("%v", modInfo["publishedfileid"]),
-				Name:   fmt.Sprintf("%v", modInfo["title"]),
-				Author: authorURL,
-				Desc:   fmt.Sprintf("%v", modInfo["file_description"]),
-				Time:   int(modInfo["time_updated"].(float64)),
-				Sub:    int(modInfo["subscriptions"].(float64)),
-				Img:    img,
-				Vote: struct {
-					Star int `json:"star"`
-					Num  int `json:"num"`
-				}{
-					Star: int(voteData["score"].(float64)*5) + 1,
-					Num:  int(voteData["votes_up"].(float64) + voteData["votes_down"].(float64)),
-				},
-			}
-			if modInfo["num_children"].(float64) != 0 {
-				children := modInfo["children"].([]interface{})
-				child := make([]string, len(children))
-				for i, c := range children {
-					child[i] = fmt.Sprintf("%v", c.(map[string]interface{})["publishedfileid"])
-				}
-				mod.Child = child
+	modList := make([]ModInfo, 0, len(result.Items))
+	for _, item := range result.Items {
+		mod := searchModInfo(item)
+		if item.VoteData != nil {
+			mod.Vote.Star = int(item.VoteData.Score*5) + 1
+			mod.Vote.Num = item.VoteData.VotesUp + item.VoteData.VotesDown
+		}
+		if len(item.Children) > 0 {
+			mod.Child = make([]string, len(item.Children))
+			for i, c := range item.Children {
+				mod.Child[i] = c.Publishedfileid
 			}
-			modList = append(modList, mod)
 		}
+		modList = append(modList, mod)
 	}
 
 	return &SearchResult{
 		Page:      page,
 		Size:      size,
-		Total:     total,
-		TotalPage: int(math.Ceil(float64(total) / float64(size))),
+		Total:     result.Total,
+		TotalPage: int(math.Ceil(float64(result.Total) / float64(size))),
 		Data:      modList,
 	}, nil
 }
 
 // SubscribeModByModId 订阅并下载模组
-func (s *ModService) SubscribeModByModId(clusterName, modId, lang string) (*model.ModInfo, error) {
+func (s *ModService) SubscribeModByModId(ctx context.Context, clusterName, modId, lang string) (*model.ModInfo, error) {
 	if !isWorkshopId(modId) {
 		// 非workshop mod，从本地读取
 		return s.getLocalModInfo(clusterName, lang, modId)
 	}
 
 	// 从Steam API获取mod信息
-	urlStr := "http://api.steampowered.com/IPublishedFileService/GetDetails/v1/"
-	data := url.Values{}
-	data.Set("key", steamAPIKey)
-	data.Set("language", "6")
-	data.Set("publishedfileids[0]", modId)
-	urlStr = urlStr + "?" + data.Encode()
-
-	req, err := http.NewRequest("GET", urlStr, nil)
+	items, err := s.workshop.GetDetails(ctx, []string{modId}, workshop.LanguageChinese)
 	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
+		return s.subscribeOffline(clusterName, modId, lang, err)
 	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("请求Steam API失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	var result map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&result)
-	if err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
-	}
-
-	dataList, ok := result["response"].(map[string]interface{})["publishedfiledetails"].([]interface{})
-	if !ok || len(dataList) == 0 {
+	if len(items) == 0 || items[0].Result != 1 {
 		return nil, errors.New("获取mod信息失败")
 	}
 
-	data2 := dataList[0].(map[string]interface{})
-	img := data2["preview_url"].(string)
-	auth := data2["creator"].(string)
-	var authorURL string
-	if auth != "" {
-		authorURL = fmt.Sprintf("https://steamcommunity.com/profiles/%s/?xml=1", auth)
-	}
-
-	name := data2["title"].(string)
-	lastTime := data2["time_updated"].(float64)
-	description := data2["file_description"].(string)
-	auth = authorURL
-	fileUrl := data2["file_url"]
-	img = fmt.Sprintf("%s?imw=64&imh=64&ima=fit&impolicy=Letterbox&imcolor=%%23000000&letterbox=true", img)
-	v := s.getVersion(data2["tags"])
-	creatorAppid := data2["creator_appid"].(float64)
-	consumerAppid := data2["consumer_appid"].(float64)
+	item := items[0]
+	name := item.Title
+	lastTime := item.TimeUpdated
+	description := item.FileDescription
+	auth := authorURL(item.Creator)
+	fileUrlStr := item.FileURL
+	img := thumbnail(item.PreviewURL)
+	v := item.Version()
+	creatorAppid := item.CreatorAppid
+	consumerAppid := item.ConsumerAppid
+	child := childIds(item.Children)
 
 	// 检查数据库中是否已存在
 	existingMod, err := s.GetModByModId(modId)
 	if err == nil && existingMod.Modid != "" {
+		if existingMod.Child != child {
+			existingMod.Child = child
+			s.db.Model(existingMod).Update("child", child)
+		}
 		if lastTime == existingMod.LastTime {
 			return existingMod, nil
 		}
 		// 需要更新
 		var modConfig string
-		var fileUrlStr = ""
-		if fileUrl != nil {
-			fileUrlStr = fileUrl.(string)
-		}
-		if fileUrlStr != "" {
-			modConfigJson, _ := json.Marshal(s.getV1ModInfoConfig(clusterName, lang, modId, fileUrlStr))
+		if s.installFromRepository(clusterName, modId, lastTime) {
+			modConfigJson, _ := json.Marshal(s.getModInfoConfig(clusterName, lang, modId))
+			modConfig = string(modConfigJson)
+		} else if fileUrlStr != "" {
+			modConfigJson, _ := json.Marshal(s.getV1ModInfoConfig(ctx, clusterName, lang, modId, fileUrlStr))
 			modConfig = string(modConfigJson)
 		} else {
 			modConfigJson, _ := json.Marshal(s.getModInfoConfig(clusterName, lang, modId))
@@ -323,18 +219,18 @@ func (s *ModService) SubscribeModByModId(clusterName, modId, lang string) (*mode
 		existingMod.ModConfig = modConfig
 		existingMod.Update = false
 		s.db.Save(existingMod)
+		s.cacheModAsync(clusterName, existingMod)
 		return existingMod, nil
 	}
 
 	// 新增mod
-	var fileUrlStr = ""
-	if fileUrl != nil {
-		fileUrlStr = fileUrl.(string)
-	}
-
+	// 模组仓库中有相同版本时不再从 Steam 下载
 	var modConfig string
-	if fileUrlStr != "" {
-		modConfigJson, _ := json.Marshal(s.getV1ModInfoConfig(clusterName, lang, modId, fileUrlStr))
+	if s.installFromRepository(clusterName, modId, lastTime) {
+		modConfigJson, _ := json.Marshal(s.getModInfoConfig(clusterName, lang, modId))
+		modConfig = string(modConfigJson)
+	} else if fileUrlStr != "" {
+		modConfigJson, _ := json.Marshal(s.getV1ModInfoConfig(ctx, clusterName, lang, modId, fileUrlStr))
 		modConfig = string(modConfigJson)
 	} else {
 		modConfigJson, _ := json.Marshal(s.getModInfoConfig(clusterName, lang, modId))
@@ -353,9 +249,13 @@ func (s *ModService) SubscribeModByModId(clusterName, modId, lang string) (*mode
 		Name:          name,
 		V:             v,
 		ModConfig:     modConfig,
+		Child:         child,
 	}
 
 	err = s.db.Create(newModInfo).Error
+	if err == nil {
+		s.cacheModAsync(clusterName, newModInfo)
+	}
 	return newModInfo, err
 }
 
@@ -388,51 +288,28 @@ func (s *ModService) DeleteMod(clusterName, modId string) error {
 	return fileUtils.DeleteDir(modPath)
 }
 
-// UpdateAllModInfos 批量更新所有模组信息
-func (s *ModService) UpdateAllModInfos(clusterName, lang string) error {
-	var modInfos []model.ModInfo
-	var needUpdateList []model.ModInfo
-	var workshopIds []string
-
-	s.db.Find(&modInfos)
-
-	for i := range modInfos {
-		workshopIds = append(workshopIds, modInfos[i].Modid)
-	}
-
-	publishedFileDetails, err := s.getPublishedFileDetailsBatched(workshopIds, 20)
+// UpdateAllModInfos 批量更新所有模组信息，固定版本的模组只标记不更新
+func (s *ModService) UpdateAllModInfos(ctx context.Context, clusterName, lang string) error {
+	needUpdateList, err := s.CheckModUpdates(ctx)
 	if err != nil {
 		return err
 	}
 
-	for i := range publishedFileDetails {
-		publishedfiledetail := publishedFileDetails[i]
-		for j := range modInfos {
-			if modInfos[j].Modid == publishedfiledetail.Publishedfileid && modInfos[j].LastTime < publishedfiledetail.TimeUpdated {
-				needUpdateList = append(needUpdateList, modInfos[i])
-			}
-		}
-	}
-
 	var wg sync.WaitGroup
 	wg.Add(len(needUpdateList))
 
 	for i := range needUpdateList {
-		go func(i int) {
+		go func(modId string) {
 			defer func() {
 				if r := recover(); r != nil {
 					log.Println(r)
 				}
 				wg.Done()
 			}()
-			modId := needUpdateList[i].Modid
-			// 删除之前的数据
-			config, _ := s.dstConfig.GetDstConfig(clusterName)
-			modDownloadPath := config.Mod_download_path
-			modPath := filepath.Join(modDownloadPath, "/steamapps/workshop/content/322330/", modId)
-			_ = fileUtils.DeleteDir(modPath)
-			_, _ = s.SubscribeModByModId(clusterName, modId, lang)
-		}(i)
+			if _, err := s.UpdateMod(ctx, clusterName, modId, lang); err != nil {
+				log.Println("[Mod]更新模组失败", modId, err)
+			}
+		}(needUpdateList[i].Modid)
 	}
 	wg.Wait()
 
@@ -477,7 +354,7 @@ func (s *ModService) SaveModInfo(modInfo *model.ModInfo) error {
 }
 
 // AddModInfo 手动添加模组
-func (s *ModService) AddModInfo(clusterName, lang, modid, modinfo, modDownloadPath string) error {
+func (s *ModService) AddModInfo(ctx context.Context, clusterName, lang, modid, modinfo, modDownloadPath string) error {
 	// 创建workshop文件
 	workshopDirPath := filepath.Join(modDownloadPath, "/steamapps/workshop/content/322330", modid)
 	fileUtils.CreateDirIfNotExists(workshopDirPath)
@@ -494,11 +371,11 @@ func (s *ModService) AddModInfo(clusterName, lang, modid, modinfo, modDownloadPa
 	}
 
 	// 添加到数据库
-	return s.addModInfoToDb(clusterName, lang, modid)
+	return s.addModInfoToDb(ctx, clusterName, lang, modid)
 }
 
 // GetUgcModInfo 获取UGC模组信息
-func (s *ModService) GetUgcModInfo(clusterName, levelName string) ([]WorkshopItemDetail, error) {
+func (s *ModService) GetUgcModInfo(ctx context.Context, clusterName, levelName string) ([]WorkshopItemDetail, error) {
 	acfPath := s.pathResolver.GetUgcAcfPath(clusterName, levelName)
 	acfWorkshops := s.parseACFFile(acfPath)
 
@@ -508,56 +385,23 @@ func (s *ModService) GetUgcModInfo(clusterName, levelName string) ([]WorkshopIte
 		modIds = append(modIds, key)
 	}
 
-	urlStr := "http://api.steampowered.com/IPublishedFileService/GetDetails/v1/"
-	data := url.Values{}
-	data.Set("key", steamAPIKey)
-	data.Set("language", "6")
-	for i := range modIds {
-		data.Set("publishedfileids["+strconv.Itoa(i)+"]", modIds[i])
-	}
-	urlStr = urlStr + "?" + data.Encode()
-
-	req, err := http.NewRequest("GET", urlStr, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var result map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&result)
+	items, err := s.workshop.GetDetails(ctx, modIds, workshop.LanguageChinese)
 	if err != nil {
 		return nil, err
 	}
 
-	dataList, ok := result["response"].(map[string]interface{})["publishedfiledetails"].([]interface{})
-	if !ok {
-		return nil, errors.New("解析响应失败")
-	}
-
-	for i := range dataList {
-		workshop := dataList[i].(map[string]interface{})
-		_, find := workshop["time_updated"]
-		if find {
-			timeUpdated := workshop["time_updated"].(float64)
-			modId := workshop["publishedfileid"].(string)
-			value, ok := acfWorkshops[modId]
-			if ok {
-				img := workshop["preview_url"].(string)
-				img = fmt.Sprintf("%s?imw=64&imh=64&ima=fit&impolicy=Letterbox&imcolor=%%23000000&letterbox=true", img)
-				workshopItemDetails = append(workshopItemDetails, WorkshopItemDetail{
-					WorkShopId:  modId,
-					Timeupdated: value.TimeUpdated,
-					Timelast:    timeUpdated,
-					Img:         img,
-					Name:        workshop["title"].(string),
-				})
-			}
+	for _, item := range items {
+		if item.Result != 1 {
+			continue
+		}
+		if value, ok := acfWorkshops[item.Publishedfileid]; ok {
+			workshopItemDetails = append(workshopItemDetails, WorkshopItemDetail{
+				WorkShopId:  item.Publishedfileid,
+				Timeupdated: value.TimeUpdated,
+				Timelast:    item.TimeUpdated,
+				Img:         thumbnail(item.PreviewURL),
+				Name:        item.Title,
+			})
 		}
 	}
 
@@ -705,41 +549,18 @@ func (s *ModService) getModInfoConfig(clusterName, lang, modId string) map[strin
 }
 
 // getV1ModInfoConfig 从v1 mod中获取配置
-func (s *ModService) getV1ModInfoConfig(clusterName, lang, modid, fileUrl string) map[string]interface{} {
+func (s *ModService) getV1ModInfoConfig(ctx context.Context, clusterName, lang, modid, fileUrl string) map[string]interface{} {
 	log.Println("开始下载 v1 mod，并提取 modinfo.lua 文件")
 	modinfo := map[string][]byte{"modinfo": nil, "modinfo_chs": nil}
-	var tmp bytes.Buffer
-
-	for i := 0; i < 3; i++ {
-		req, err := http.NewRequest("GET", fileUrl, nil)
-		if err != nil {
-			log.Println(fileUrl, "下载失败", err)
-			continue
-		}
-		client := http.Client{
-			Timeout: time.Duration(10 * time.Second),
-		}
-		res, err := client.Do(req)
-		if err != nil {
-			log.Println(fileUrl, "下载失败", err)
-			continue
-		}
-		defer res.Body.Close()
-		_, err = tmp.ReadFrom(res.Body)
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-		break
-	}
-
-	if tmp.Len() == 0 {
-		log.Println(fileUrl, "下载失败 3 次，不再尝试")
+	// 下载失败时由创意工坊客户端重试
+	modZip, err := s.workshop.Download(ctx, fileUrl)
+	if err != nil || len(modZip) == 0 {
+		log.Println(fileUrl, "下载失败", err)
 		return make(map[string]interface{})
 	}
 
 	log.Println(fileUrl, "下载成功，开始解压")
-	zipReader, err := zip.NewReader(bytes.NewReader(tmp.Bytes()), int64(tmp.Len()))
+	zipReader, err := zip.NewReader(bytes.NewReader(modZip), int64(len(modZip)))
 	if err != nil {
 		log.Println("模组zip解压失败", err)
 		return make(map[string]interface{})
@@ -837,85 +658,27 @@ func (s *ModService) parseModInfoLua(lang, modId, script string) map[string]inte
 	return m
 }
 
-// getVersion 从tags中获取版本号
-func (s *ModService) getVersion(tags interface{}) string {
-	tagList, ok := tags.([]interface{})
-	if !ok {
-		return ""
-	}
-	for _, tag := range tagList {
-		tagMap, ok := tag.(map[string]interface{})
-		if !ok {
-			continue
-		}
-		tagStr, ok := tagMap["tag"].(string)
-		if !ok {
-			continue
-		}
-		if len(tagStr) > 8 && tagStr[:8] == "version:" {
-			return tagStr[8:]
-		}
-	}
-	return ""
-}
-
 // searchModInfoByWorkshopId 通过workshopId搜索mod信息
-func (s *ModService) searchModInfoByWorkshopId(modID int) ModInfo {
-	urlStr := "http://api.steampowered.com/IPublishedFileService/GetDetails/v1/"
-	data := url.Values{}
-	data.Set("key", steamAPIKey)
-	data.Set("language", "6")
-	data.Set("publishedfileids[0]", strconv.Itoa(modID))
-	urlStr = urlStr + "?" + data.Encode()
-
-	req, err := http.NewRequest("GET", urlStr, nil)
-	if err != nil {
+func (s *ModService) searchModInfoByWorkshopId(ctx context.Context, modID int) ModInfo {
+	items, err := s.workshop.GetDetails(ctx, []string{strconv.Itoa(modID)}, workshop.LanguageChinese)
+	if err != nil || len(items) == 0 || items[0].Result != 1 || items[0].ConsumerAppid != workshop.AppID {
 		return ModInfo{}
 	}
+	mod := searchModInfo(items[0])
+	mod.Img = thumbnail(mod.Img)
+	return mod
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return ModInfo{}
-	}
-	defer resp.Body.Close()
-
-	var result map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&result)
-	if err != nil {
-		return ModInfo{}
-	}
-
-	dataList, ok := result["response"].(map[string]interface{})["publishedfiledetails"].([]interface{})
-	if !ok || len(dataList) == 0 {
-		return ModInfo{}
-	}
-
-	data2 := dataList[0].(map[string]interface{})
-	if data2["consumer_appid"] == nil || data2["consumer_appid"].(float64) != 322330 {
-		return ModInfo{}
-	}
-
-	img := data2["preview_url"].(string)
-	auth := data2["creator"].(string)
-	var authorURL string
-	if auth != "" {
-		authorURL = fmt.Sprintf("https://steamcommunity.com/profiles/%s/?xml=1", auth)
-	}
-
-	modId := data2["publishedfileid"].(string)
-	name := data2["title"].(string)
-	description := data2["file_description"].(string)
-	img = fmt.Sprintf("%s?imw=64&imh=64&ima=fit&impolicy=Letterbox&imcolor=%%23000000&letterbox=true", img)
-
+// searchModInfo 搜索结果中的模组信息
+func searchModInfo(item workshop.Item) ModInfo {
 	return ModInfo{
-		ID:     modId,
-		Name:   name,
-		Author: authorURL,
-		Desc:   description,
-		Time:   int(data2["time_updated"].(float64)),
-		Sub:    int(data2["subscriptions"].(float64)),
-		Img:    img,
+		ID:     item.Publishedfileid,
+		Name:   item.Title,
+		Author: authorURL(item.Creator),
+		Desc:   item.FileDescription,
+		Time:   int(item.TimeUpdated),
+		Sub:    item.Subscriptions,
+		Img:    item.PreviewURL,
 	}
 }
 
@@ -942,7 +705,7 @@ func (s *ModService) getLocalModInfo(clusterName, lang, modId string) (*model.Mo
 }
 
 // addModInfoToDb 添加mod信息到数据库
-func (s *ModService) addModInfoToDb(clusterName, lang, modid string) error {
+func (s *ModService) addModInfoToDb(ctx context.Context, clusterName, lang, modid string) error {
 	var modInfo *model.ModInfo
 	var err error
 
@@ -950,7 +713,7 @@ func (s *ModService) addModInfoToDb(clusterName, lang, modid string) error {
 		modInfo, err = s.getLocalModInfo(clusterName, lang, modid)
 	} else {
 		// 从Steam获取mod基本信息
-		modInfo, err = s.getModInfo2(modid)
+		modInfo, err = s.getModInfo2(ctx, modid)
 	}
 
 	if err != nil {
@@ -972,6 +735,7 @@ func (s *ModService) addModInfoToDb(clusterName, lang, modid string) error {
 		oldModinfo.Img = modInfo.Img
 		oldModinfo.V = modInfo.V
 		oldModinfo.ModConfig = modConfig
+		oldModinfo.Child = modInfo.Child
 		return s.db.Save(oldModinfo).Error
 	}
 
@@ -981,76 +745,33 @@ func (s *ModService) addModInfoToDb(clusterName, lang, modid string) error {
 }
 
 // getModInfo2 从Steam API获取mod基本信息
-func (s *ModService) getModInfo2(modID string) (*model.ModInfo, error) {
-	urlStr := "http://api.steampowered.com/IPublishedFileService/GetDetails/v1/"
-	data := url.Values{}
-	data.Set("key", steamAPIKey)
-	data.Set("language", "6")
-	data.Set("publishedfileids[0]", modID)
-	urlStr = urlStr + "?" + data.Encode()
-
-	req, err := http.NewRequest("GET", urlStr, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var result map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&result)
+func (s *ModService) getModInfo2(ctx context.Context, modID string) (*model.ModInfo, error) {
+	items, err := s.workshop.GetDetails(ctx, []string{modID}, workshop.LanguageChinese)
 	if err != nil {
 		return nil, err
 	}
-
-	dataList, ok := result["response"].(map[string]interface{})["publishedfiledetails"].([]interface{})
-	if !ok || len(dataList) == 0 {
+	if len(items) == 0 || items[0].Result != 1 {
 		return nil, errors.New("获取mod信息失败")
 	}
 
-	data2 := dataList[0].(map[string]interface{})
-	img := data2["preview_url"].(string)
-	auth := data2["creator"].(string)
-	var authorURL string
-	if auth != "" {
-		authorURL = fmt.Sprintf("https://steamcommunity.com/profiles/%s/?xml=1", auth)
-	}
-
-	modId := data2["publishedfileid"].(string)
-	name := data2["title"].(string)
-	lastTime := data2["time_updated"].(float64)
-	description := data2["file_description"].(string)
-	fileUrl := data2["file_url"]
-	img = fmt.Sprintf("%s?imw=64&imh=64&ima=fit&impolicy=Letterbox&imcolor=%%23000000&letterbox=true", img)
-	v := s.getVersion(data2["tags"])
-	creatorAppid := data2["creator_appid"].(float64)
-	consumerAppid := data2["consumer_appid"].(float64)
-
-	var fileUrlStr = ""
-	if fileUrl != nil {
-		fileUrlStr = fileUrl.(string)
-	}
-
+	item := items[0]
 	return &model.ModInfo{
-		Auth:          authorURL,
-		ConsumerAppid: consumerAppid,
-		CreatorAppid:  creatorAppid,
-		Description:   description,
-		FileUrl:       fileUrlStr,
-		Modid:         modId,
-		Img:           img,
-		LastTime:      lastTime,
-		Name:          name,
-		V:             v,
+		Auth:          authorURL(item.Creator),
+		ConsumerAppid: item.ConsumerAppid,
+		CreatorAppid:  item.CreatorAppid,
+		Description:   item.FileDescription,
+		FileUrl:       item.FileURL,
+		Modid:         item.Publishedfileid,
+		Img:           thumbnail(item.PreviewURL),
+		LastTime:      item.TimeUpdated,
+		Name:          item.Title,
+		V:             item.Version(),
+		Child:         childIds(item.Children),
 	}, nil
 }
 
 // getPublishedFileDetailsBatched 批量获取mod详情
-func (s *ModService) getPublishedFileDetailsBatched(workshopIds []string, batchSize int) ([]Publishedfiledetail, error) {
+func (s *ModService) getPublishedFileDetailsBatched(ctx context.Context, workshopIds []string, batchSize int) ([]Publishedfiledetail, error) {
 	var allPublishedFileDetails []Publishedfiledetail
 
 	for i := 0; i < len(workshopIds); i += batchSize {
@@ -1060,7 +781,7 @@ func (s *ModService) getPublishedFileDetailsBatched(workshopIds []string, batchS
 		}
 
 		batch := workshopIds[i:end]
-		publishedFileDetails, err := s.getPublishedFileDetailsWithGet(batch)
+		publishedFileDetails, err := s.workshop.GetDetails(ctx, batch, workshop.LanguageChinese)
 		if err != nil {
 			return nil, err
 		}
@@ -1071,95 +792,6 @@ func (s *ModService) getPublishedFileDetailsBatched(workshopIds []string, batchS
 	return allPublishedFileDetails, nil
 }
 
-// getPublishedFileDetailsWithGet 通过GET方式获取mod详情
-func (s *ModService) getPublishedFileDetailsWithGet(workshopIds []string) ([]Publishedfiledetail, error) {
-	urlStr := "http://api.steampowered.com/IPublishedFileService/GetDetails/v1/"
-	data := url.Values{}
-	data.Set("key", steamAPIKey)
-	data.Set("language", "6")
-	for i := range workshopIds {
-		data.Set("publishedfileids["+strconv.Itoa(i)+"]", workshopIds[i])
-	}
-	urlStr = urlStr + "?" + data.Encode()
-
-	req, err := http.NewRequest("GET", urlStr, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	client := &http.Client{}
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	var publishedFileDetailsData struct {
-		Response struct {
-			Publishedfiledetails []Publishedfiledetail `json:"publishedfiledetails"`
-		} `json:"response"`
-	}
-
-	err = json.NewDecoder(res.Body).Decode(&publishedFileDetailsData)
-	if err != nil {
-		return nil, err
-	}
-
-	return publishedFileDetailsData.Response.Publishedfiledetails, nil
-}
-
-// getPublishedFileDetails 通过POST方式获取mod详情
-func (s *ModService) getPublishedFileDetails(workshopIds []string) ([]Publishedfiledetail, error) {
-	url := "https://api.steampowered.com/ISteamRemoteStorage/GetPublishedFileDetails/v1/"
-	payload := &bytes.Buffer{}
-	writer := multipart.NewWriter(payload)
-
-	_ = writer.WriteField("itemcount", strconv.Itoa(len(workshopIds)))
-	for i := range workshopIds {
-		_ = writer.WriteField("publishedfileids["+strconv.Itoa(i)+"]", workshopIds[i])
-	}
-
-	err := writer.Close()
-	if err != nil {
-		return nil, err
-	}
-
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{Transport: tr}
-	req, err := http.NewRequest("POST", url, payload)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	var publishedFileDetailsData struct {
-		Response struct {
-			Result               int                   `json:"result"`
-			Resultcount          int                   `json:"resultcount"`
-			Publishedfiledetails []Publishedfiledetail `json:"publishedfiledetails"`
-		} `json:"response"`
-	}
-
-	err = json.NewDecoder(res.Body).Decode(&publishedFileDetailsData)
-	if err != nil {
-		return nil, err
-	}
-
-	if publishedFileDetailsData.Response.Result == 1 {
-		return publishedFileDetailsData.Response.Publishedfiledetails, nil
-	}
-
-	return nil, errors.New("请求失败")
-}
-
 // unzipToDir 解压zip文件到指定目录
 func (s *ModService) unzipToDir(zipReader *zip.Reader, destDir string) error {
 	for _, file := range zipReader.File {
@@ -1279,6 +911,28 @@ func isTableArray(t *lua.LTable) bool {
 	return isSequential && maxIndex == t.Len()
 }
 
+// childIds Steam API 返回的依赖模组 id，用逗号分隔
+func childIds(children []workshop.Child) string {
+	ids := make([]string, 0, len(children))
+	for _, c := range children {
+		ids = append(ids, c.Publishedfileid)
+	}
+	return strings.Join(ids, ",")
+}
+
+// authorURL 作者的 Steam 个人资料地址
+func authorURL(creator string) string {
+	if creator == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://steamcommunity.com/profiles/%s/?xml=1", creator)
+}
+
+// thumbnail 模组预览图的缩略图地址
+func thumbnail(previewURL string) string {
+	return fmt.Sprintf("%s?imw=64&imh=64&ima=fit&impolicy=Letterbox&imcolor=%%23000000&letterbox=true", previewURL)
+}
+
 // isWorkshopId 判断是否为workshop ID
 func isWorkshopId(id string) bool {
 	_, err := strconv.Atoi(id)
@@ -0,0 +1,476 @@
+package mod
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/service/archive"
+	"dst-admin-go/internal/service/dstConfig"
+	"dst-admin-go/internal/service/mod/repository"
+	"dst-admin-go/internal/service/mod/workshop"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeDstConfig 所有集群共用同一个饥荒配置
+type fakeDstConfig struct {
+	config dstConfig.DstConfig
+}
+
+func (c *fakeDstConfig) GetDstConfig(clusterName string) (dstConfig.DstConfig, error) {
+	return c.config, nil
+}
+
+func (c *fakeDstConfig) SaveDstConfig(clusterName string, config dstConfig.DstConfig) error {
+	c.config = config
+	return nil
+}
+
+const testCluster = "Cluster1"
+
+type testEnv struct {
+	*ModService
+	fake   *workshop.FakeClient
+	repo   *repository.Repository
+	config dstConfig.DstConfig
+}
+
+func newTestEnv(t *testing.T, items ...workshop.Item) *testEnv {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "dst-db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.ModInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	cfg := &fakeDstConfig{config: dstConfig.DstConfig{
+		Steamcmd:          filepath.Join(dir, "steamcmd"),
+		Force_install_dir: filepath.Join(dir, "dst"),
+		Mod_download_path: filepath.Join(dir, "download"),
+		Ugc_directory:     filepath.Join(dir, "ugc"),
+	}}
+	pathResolver, err := archive.NewPathResolver(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.New(filepath.Join(dir, "repository"))
+	fake := workshop.NewFakeClient(items...)
+	return &testEnv{
+		ModService: NewModService(db, cfg, pathResolver, repo, fake),
+		fake:       fake,
+		repo:       repo,
+		config:     cfg.config,
+	}
+}
+
+func (e *testEnv) ugcPath(modId string) string {
+	return filepath.Join(e.config.Ugc_directory, "content", "322330", modId)
+}
+
+func (e *testEnv) contentPath(modId string) string {
+	return filepath.Join(e.config.Mod_download_path, "steamapps", "workshop", "content", "322330", modId)
+}
+
+// modinfo 带一个配置项的 modinfo.lua
+func modinfo(version string) string {
+	return fmt.Sprintf(`name = "测试模组"
+version = %q
+configuration_options = {
+	{ name = "difficulty", label = "难度", options = { { description = "简单", data = 1 }, { description = "困难", data = 2 } }, default = 1 },
+}
+`, version)
+}
+
+// writeMod 在 dir 中写入 version 版本的模组
+func writeMod(t *testing.T, dir, version string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "modinfo.lua"), []byte(modinfo(version)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "modmain.lua"), []byte("-- "+version), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func modZip(t *testing.T, version string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{"modinfo.lua": modinfo(version), "modmain.lua": "-- " + version} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// publishV1Mod 在创意工坊中发布有 file_url 的模组，不需要 steamcmd 即可下载
+func (e *testEnv) publishV1Mod(t *testing.T, modId, version string, timeUpdated float64, children ...string) {
+	t.Helper()
+	fileUrl := fmt.Sprintf("https://workshop.example/%s/%s.zip", modId, version)
+	item := workshop.Item{
+		Publishedfileid: modId,
+		Title:           "mod " + modId,
+		Creator:         "76561198000000000",
+		FileURL:         fileUrl,
+		PreviewURL:      "https://workshop.example/" + modId + ".png",
+		TimeUpdated:     timeUpdated,
+		Tags:            []workshop.Tag{{Tag: "version:" + version}},
+	}
+	for _, child := range children {
+		item.Children = append(item.Children, workshop.Child{Publishedfileid: child})
+	}
+	e.fake.Add(item)
+	e.fake.AddFile(fileUrl, modZip(t, version))
+}
+
+// waitCached 等待订阅后在后台保存到模组仓库的版本
+func (e *testEnv) waitCached(t *testing.T, modId string, lastTime float64) repository.Meta {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		meta, err := e.repo.Get(modId, repository.VersionOf(lastTime))
+		if err == nil {
+			return meta
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("模组 %s@%v 没有保存到模组仓库: %v", modId, lastTime, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (e *testEnv) subscribe(t *testing.T, modId string) *model.ModInfo {
+	t.Helper()
+	modInfo, err := e.SubscribeModByModId(context.Background(), testCluster, modId, "zh")
+	if err != nil {
+		t.Fatalf("订阅模组 %s 失败: %v", modId, err)
+	}
+	e.waitCached(t, modId, modInfo.LastTime)
+	return modInfo
+}
+
+func assertModVersion(t *testing.T, dir, want string) {
+	t.Helper()
+	script, err := os.ReadFile(filepath.Join(dir, "modinfo.lua"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(script), fmt.Sprintf("version = %q", want)) {
+		t.Fatalf("%s 中的模组版本不是 %s:\n%s", dir, want, script)
+	}
+}
+
+func modIds(mods []ModInfo) string {
+	ids := make([]string, 0, len(mods))
+	for _, mod := range mods {
+		ids = append(ids, mod.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSearchModList(t *testing.T) {
+	env := newTestEnv(t,
+		workshop.Item{Publishedfileid: "1001", Title: "Global Positions", Subscriptions: 300, PreviewURL: "https://workshop.example/1001.png",
+			VoteData: &workshop.VoteData{Score: 0.8, VotesUp: 90, VotesDown: 10}, Children: []workshop.Child{{Publishedfileid: "1002"}}},
+		workshop.Item{Publishedfileid: "1002", Title: "Global Pause", Subscriptions: 200},
+		workshop.Item{Publishedfileid: "1003", Title: "Health Info", FileDescription: "显示血量 global", Subscriptions: 100},
+		workshop.Item{Publishedfileid: "2001", Title: "Other Game", ConsumerAppid: 1},
+	)
+	tests := []struct {
+		name      string
+		text      string
+		page      int
+		size      int
+		total     int
+		totalPage int
+		ids       string
+	}{
+		{"关键词搜索", "global", 1, 10, 3, 1, "1001,1002,1003"},
+		{"分页", "global", 2, 2, 3, 2, "1003"},
+		{"没有结果", "nothing", 1, 10, 0, 0, ""},
+		{"模组 id", "1003", 1, 10, 1, 1, "1003"},
+		{"不存在的模组 id", "404", 1, 10, 1, 1, ""},
+		{"其他游戏的模组 id", "2001", 1, 10, 1, 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := env.SearchModList(context.Background(), tt.text, tt.page, tt.size, "zh")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := modIds(result.Data); got != tt.ids || result.Total != tt.total || result.TotalPage != tt.totalPage {
+				t.Fatalf("got ids=%s total=%d totalPage=%d, want ids=%s total=%d totalPage=%d",
+					got, result.Total, result.TotalPage, tt.ids, tt.total, tt.totalPage)
+			}
+		})
+	}
+
+	result, err := env.SearchModList(context.Background(), "positions", 1, 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod := result.Data[0]
+	if mod.Vote.Star != 5 || mod.Vote.Num != 100 || strings.Join(mod.Child, ",") != "1002" || mod.Img != "https://workshop.example/1001.png" {
+		t.Fatalf("搜索结果中的模组信息不正确: %+v", mod)
+	}
+
+	env.fake.Err = errors.New("network is unreachable")
+	if _, err := env.SearchModList(context.Background(), "global", 1, 10, ""); err == nil || !errors.Is(err, env.fake.Err) {
+		t.Fatalf("创意工坊不可用时应返回错误，err = %v", err)
+	}
+	if result, err := env.SearchModList(context.Background(), "1001", 1, 10, ""); err != nil || len(result.Data) != 0 {
+		t.Fatalf("创意工坊不可用时按 id 搜索应返回空结果: %+v, %v", result, err)
+	}
+}
+
+func TestSubscribeModByModId(t *testing.T) {
+	env := newTestEnv(t)
+	env.publishV1Mod(t, "1001", "1.0", 100, "1002")
+
+	modInfo := env.subscribe(t, "1001")
+	if modInfo.Name != "mod 1001" || modInfo.V != "1.0" || modInfo.LastTime != 100 || modInfo.Child != "1002" || modInfo.FileUrl == "" {
+		t.Fatalf("订阅的模组信息不正确: %+v", modInfo)
+	}
+	if !strings.Contains(modInfo.ModConfig, `"configuration_options"`) || !strings.Contains(modInfo.ModConfig, `"difficulty"`) {
+		t.Fatalf("没有解析 modinfo.lua 中的配置项: %s", modInfo.ModConfig)
+	}
+	assertModVersion(t, env.ugcPath("1001"), "1.0")
+
+	// 没有更新时不重复下载
+	again, err := env.SubscribeModByModId(context.Background(), testCluster, "1001", "zh")
+	if err != nil || again.ID != modInfo.ID {
+		t.Fatalf("重复订阅应返回已有的模组: %+v, %v", again, err)
+	}
+	if mods, _ := env.GetMyModList(); len(mods) != 1 {
+		t.Fatalf("重复订阅不应新增模组: %d", len(mods))
+	}
+
+	if _, err := env.SubscribeModByModId(context.Background(), testCluster, "404", "zh"); err == nil {
+		t.Fatal("订阅不存在的模组应返回错误")
+	}
+
+	// 非创意工坊模组从饥荒服务器的模组目录读取
+	writeMod(t, env.ugcPath("my-mod"), "0.1")
+	local, err := env.SubscribeModByModId(context.Background(), testCluster, "my-mod", "zh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local.Name != "my-mod" || !strings.Contains(local.ModConfig, `"difficulty"`) {
+		t.Fatalf("本地模组信息不正确: %+v", local)
+	}
+}
+
+func TestSubscribeFromRepository(t *testing.T) {
+	env := newTestEnv(t, workshop.Item{
+		Publishedfileid: "1001",
+		Title:           "mod 1001",
+		TimeUpdated:     100,
+		FileURL:         "https://workshop.example/1001/missing.zip",
+		Tags:            []workshop.Tag{{Tag: "version:1.0"}},
+	})
+	src := filepath.Join(t.TempDir(), "1001")
+	writeMod(t, src, "1.0")
+	if _, err := env.repo.Store(repository.Meta{ModId: "1001", Name: "mod 1001", V: "1.0", LastTime: 100}, src); err != nil {
+		t.Fatal(err)
+	}
+
+	// 仓库中有相同版本时不从创意工坊下载
+	modInfo, err := env.SubscribeModByModId(context.Background(), testCluster, "1001", "zh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(modInfo.ModConfig, `"difficulty"`) {
+		t.Fatalf("没有读取从仓库安装的模组配置: %s", modInfo.ModConfig)
+	}
+	assertModVersion(t, env.contentPath("1001"), "1.0")
+}
+
+func TestSubscribeOffline(t *testing.T) {
+	tests := []struct {
+		name     string
+		inRepo   bool
+		wantName string
+	}{
+		{"仓库中有模组", true, "mod 1001"},
+		{"仓库中没有模组", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			if tt.inRepo {
+				src := filepath.Join(t.TempDir(), "1001")
+				writeMod(t, src, "1.0")
+				if _, err := env.repo.Store(repository.Meta{ModId: "1001", Name: "mod 1001", V: "1.0", LastTime: 100}, src); err != nil {
+					t.Fatal(err)
+				}
+			}
+			env.fake.Err = errors.New("network is unreachable")
+
+			modInfo, err := env.SubscribeModByModId(context.Background(), testCluster, "1001", "zh")
+			if !tt.inRepo {
+				if !errors.Is(err, env.fake.Err) {
+					t.Fatalf("仓库中没有模组时应返回访问 Steam 的错误，err = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if modInfo.Name != tt.wantName || modInfo.V != "1.0" || modInfo.LastTime != 100 || !strings.Contains(modInfo.ModConfig, `"difficulty"`) {
+				t.Fatalf("离线订阅的模组信息不正确: %+v", modInfo)
+			}
+			assertModVersion(t, env.contentPath("1001"), "1.0")
+		})
+	}
+}
+
+func TestCheckModUpdatesAndPin(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	env.publishV1Mod(t, "1001", "1.0", 100)
+	env.publishV1Mod(t, "1002", "2.0", 200)
+	env.subscribe(t, "1001")
+	env.subscribe(t, "1002")
+
+	pinned, err := env.SetModPinned(testCluster, "1002", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pinned.Pinned || pinned.PinnedVersion != "200" {
+		t.Fatalf("固定版本不正确: %+v", pinned)
+	}
+	if _, err := env.SetModPinned(testCluster, "404", true); err == nil {
+		t.Fatal("固定没有订阅的模组应返回错误")
+	}
+
+	if pending, err := env.CheckModUpdates(ctx); err != nil || len(pending) != 0 {
+		t.Fatalf("没有更新时待更新列表应为空: %v, %v", pending, err)
+	}
+
+	env.publishV1Mod(t, "1001", "1.1", 150, "1003")
+	env.publishV1Mod(t, "1002", "2.1", 250)
+	pending, err := env.CheckModUpdates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Modid != "1001" || pending[0].Child != "1003" {
+		t.Fatalf("待更新列表应只有没有固定的模组: %+v", pending)
+	}
+	if modInfo, _ := env.GetModByModId("1002"); !modInfo.Update {
+		t.Fatal("固定版本的模组也应标记为有更新")
+	}
+
+	if _, err := env.UpdateMod(ctx, testCluster, "1002", "zh"); !errors.Is(err, ErrModPinned) {
+		t.Fatalf("不能更新固定版本的模组，err = %v", err)
+	}
+	updated, err := env.UpdateMod(ctx, testCluster, "1001", "zh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.V != "1.1" || updated.LastTime != 150 || updated.Update {
+		t.Fatalf("更新后的模组信息不正确: %+v", updated)
+	}
+	assertModVersion(t, env.ugcPath("1001"), "1.1")
+	env.waitCached(t, "1001", 150)
+	if pending := env.GetPendingModUpdates(); len(pending) != 0 {
+		t.Fatalf("更新后待更新列表应为空: %+v", pending)
+	}
+
+	// 饥荒服务器启动时从创意工坊更新了固定的模组，重启前恢复固定的版本
+	writeMod(t, env.ugcPath("1002"), "2.1")
+	if err := env.InstallPinnedMod(testCluster, "1002"); err != nil {
+		t.Fatal(err)
+	}
+	assertModVersion(t, env.ugcPath("1002"), "2.0")
+	assertModVersion(t, env.contentPath("1002"), "2.0")
+
+	unpinned, err := env.SetModPinned(testCluster, "1002", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unpinned.Pinned || unpinned.PinnedVersion != "" {
+		t.Fatalf("取消固定后不应保留固定版本: %+v", unpinned)
+	}
+	if pending := env.GetPendingModUpdates(); len(pending) != 1 || pending[0].Modid != "1002" {
+		t.Fatalf("取消固定后应出现在待更新列表中: %+v", pending)
+	}
+}
+
+func TestStoreModToRepository(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       string
+		ugc     string
+		content string
+		want    string
+		err     string
+	}{
+		{"下载目录是最新版本", "1.1", "1.0", "1.1", "1.1", ""},
+		{"ugc_mods 是最新版本", "1.1", "1.1", "1.0", "1.1", ""},
+		{"本机只有旧版本", "1.1", "1.0", "", "", "不是最新版本"},
+		{"没有版本标签时使用下载目录", "", "1.0", "0.9", "0.9", ""},
+		{"没有下载", "1.1", "", "", "", "还没有下载"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			if err := env.db.Create(&model.ModInfo{Modid: "1001", Name: "mod 1001", V: tt.v, LastTime: 150}).Error; err != nil {
+				t.Fatal(err)
+			}
+			if tt.ugc != "" {
+				writeMod(t, env.ugcPath("1001"), tt.ugc)
+			}
+			if tt.content != "" {
+				writeMod(t, env.contentPath("1001"), tt.content)
+			}
+
+			meta, err := env.StoreModToRepository(testCluster, "1001")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				if metas, _ := env.repo.List(); len(metas) != 0 {
+					t.Fatalf("失败时不应保存版本: %+v", metas)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta.Version != "150" {
+				t.Fatalf("版本应为模组的更新时间: %+v", meta)
+			}
+			dest := filepath.Join(t.TempDir(), "1001")
+			if _, err := env.repo.Install("1001", meta.Version, dest); err != nil {
+				t.Fatal(err)
+			}
+			assertModVersion(t, dest, tt.want)
+		})
+	}
+}
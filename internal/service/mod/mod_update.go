@@ -0,0 +1,117 @@
+package mod
+
+import (
+	"context"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"dst-admin-go/internal/service/mod/repository"
+	"errors"
+	"log"
+)
+
+// ErrModPinned 模组已固定版本
+var ErrModPinned = errors.New("模组已固定版本，请先取消固定")
+
+// CheckModUpdates 对比创意工坊的更新时间，有新版本的模组标记为需要更新，同时记录创意工坊中声明的依赖模组
+// 固定版本的模组也会标记，但不会出现在返回的待更新列表中
+func (s *ModService) CheckModUpdates(ctx context.Context) ([]model.ModInfo, error) {
+	var modInfos []model.ModInfo
+	if err := s.db.Find(&modInfos).Error; err != nil {
+		return nil, err
+	}
+	byId := make(map[string]*model.ModInfo, len(modInfos))
+	workshopIds := make([]string, 0, len(modInfos))
+	for i := range modInfos {
+		if isWorkshopId(modInfos[i].Modid) {
+			byId[modInfos[i].Modid] = &modInfos[i]
+			workshopIds = append(workshopIds, modInfos[i].Modid)
+		}
+	}
+
+	publishedFileDetails, err := s.getPublishedFileDetailsBatched(ctx, workshopIds, 20)
+	if err != nil {
+		return nil, err
+	}
+	for _, detail := range publishedFileDetails {
+		modInfo, ok := byId[detail.Publishedfileid]
+		if !ok || detail.Result != 1 {
+			continue
+		}
+		if child := childIds(detail.Children); modInfo.Child != child {
+			s.db.Model(modInfo).Update("child", child)
+		}
+		if !modInfo.Update && modInfo.LastTime < detail.TimeUpdated {
+			s.db.Model(modInfo).Update("update", true)
+			log.Println("[Mod]检测到模组更新", modInfo.Name, modInfo.Modid, "pinned:", modInfo.Pinned)
+		}
+	}
+	return s.GetPendingModUpdates(), nil
+}
+
+// GetPendingModUpdates 已标记需要更新且没有固定版本的模组
+func (s *ModService) GetPendingModUpdates() []model.ModInfo {
+	modInfos := make([]model.ModInfo, 0)
+	s.db.Where("`update` = ? AND pinned = ?", true, false).Find(&modInfos)
+	return modInfos
+}
+
+// UpdateMod 删除已下载的旧版本，重新获取模组信息和配置并下载新版本
+func (s *ModService) UpdateMod(ctx context.Context, clusterName, modId, lang string) (*model.ModInfo, error) {
+	modInfo, err := s.GetModByModId(modId)
+	if err == nil && modInfo.Pinned {
+		return nil, ErrModPinned
+	}
+	if path, err := s.modContentPath(clusterName, modId); err == nil {
+		_ = fileUtils.DeleteDir(path)
+	}
+	return s.SubscribeModByModId(ctx, clusterName, modId, lang)
+}
+
+// SetModPinned 固定或取消固定模组版本，固定后自动更新和批量更新都会跳过该模组
+// 固定时将本机已下载的版本保存到模组仓库并记录版本号，自动更新重启集群前通过 InstallPinnedMod 恢复该版本
+// 饥荒服务器启动时仍会按 dedicated_server_mods_setup.lua 从创意工坊更新模组，需要严格固定时请从该文件中移除模组
+func (s *ModService) SetModPinned(clusterName, modId string, pinned bool) (*model.ModInfo, error) {
+	modInfo, err := s.GetModByModId(modId)
+	if err != nil {
+		return nil, errors.New("没有订阅模组: " + modId)
+	}
+	version := ""
+	if pinned {
+		meta, err := s.repository.Get(modId, repository.VersionOf(modInfo.LastTime))
+		if err != nil {
+			if meta, err = s.StoreModToRepository(clusterName, modId); err != nil {
+				return nil, errors.New("保存固定版本到模组仓库失败: " + err.Error())
+			}
+		}
+		version = meta.Version
+	}
+	if err := s.db.Model(modInfo).Updates(map[string]interface{}{"pinned": pinned, "pinned_version": version}).Error; err != nil {
+		return nil, err
+	}
+	modInfo.Pinned = pinned
+	modInfo.PinnedVersion = version
+	return modInfo, nil
+}
+
+// InstallPinnedMod 从模组仓库安装固定的版本，覆盖 steamcmd 下载目录和饥荒服务器 ugc_mods 中的模组
+// 模组没有固定版本时不处理
+func (s *ModService) InstallPinnedMod(clusterName, modId string) error {
+	modInfo, err := s.GetModByModId(modId)
+	if err != nil || !modInfo.Pinned || modInfo.PinnedVersion == "" {
+		return nil
+	}
+	paths := make([]string, 0, 2)
+	if path, err := s.modContentPath(clusterName, modId); err == nil {
+		paths = append(paths, path)
+	}
+	if path, ok := s.getDstUcgsModsInstalledPath(clusterName, modId); ok {
+		paths = append(paths, path)
+	}
+	for _, path := range paths {
+		if _, err := s.repository.Install(modId, modInfo.PinnedVersion, path); err != nil {
+			return err
+		}
+		log.Println("[Mod]安装固定版本的模组", modId, modInfo.PinnedVersion, "->", path)
+	}
+	return nil
+}
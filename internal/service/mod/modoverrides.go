@@ -0,0 +1,442 @@
+package mod
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// ModOverride modoverrides.lua 中一个模组的配置
+type ModOverride struct {
+	// Id 模组目录名，创意工坊模组为 workshop-<id>
+	Id                   string                 `json:"id"`
+	Enabled              bool                   `json:"enabled"`
+	ConfigurationOptions map[string]interface{} `json:"configurationOptions"`
+	// Extra enabled 和 configuration_options 以外的字段，原样保留
+	Extra map[string]interface{} `json:"extra,omitempty"`
+}
+
+// WorkshopId 创意工坊模组的 id，不是创意工坊模组时返回空
+func (o ModOverride) WorkshopId() string {
+	if id, ok := strings.CutPrefix(o.Id, "workshop-"); ok {
+		return id
+	}
+	return ""
+}
+
+// ModOption 模组的配置项定义，来自 modinfo.lua 的 configuration_options
+type ModOption struct {
+	Name    string            `json:"name"`
+	Label   string            `json:"label"`
+	Hover   string            `json:"hover"`
+	Default interface{}       `json:"default"`
+	Options []ModOptionChoice `json:"options"`
+}
+
+// ModOptionChoice 配置项的一个可选值
+type ModOptionChoice struct {
+	Description string      `json:"description"`
+	Data        interface{} `json:"data"`
+	Hover       string      `json:"hover"`
+}
+
+// OverrideIssue 模组配置的校验结果，Level 为 error 时不能保存
+type OverrideIssue struct {
+	ModId   string `json:"modId"`
+	Key     string `json:"key,omitempty"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+const (
+	IssueError   = "error"
+	IssueWarning = "warning"
+)
+
+var luaIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var luaKeywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true, "end": true,
+	"false": true, "for": true, "function": true, "if": true, "in": true, "local": true,
+	"nil": true, "not": true, "or": true, "repeat": true, "return": true, "then": true,
+	"true": true, "until": true, "while": true,
+}
+
+// ParseModOverrides 解析 modoverrides.lua，结果按模组 id 排序
+// 脚本在不加载任何标准库的 Lua 环境中执行，只允许返回一个 table
+func ParseModOverrides(content string) ([]ModOverride, error) {
+	if strings.TrimSpace(content) == "" {
+		return []ModOverride{}, nil
+	}
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer L.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	L.SetContext(ctx)
+
+	if err := L.DoString(content); err != nil {
+		return nil, fmt.Errorf("解析 modoverrides.lua 失败: %w", err)
+	}
+	root, ok := L.Get(-1).(*lua.LTable)
+	if !ok {
+		return nil, errors.New("modoverrides.lua 需要返回一个 table")
+	}
+
+	overrides := make([]ModOverride, 0)
+	var err error
+	root.ForEach(func(k lua.LValue, v lua.LValue) {
+		if err != nil {
+			return
+		}
+		if k.Type() != lua.LTString {
+			err = fmt.Errorf("模组 id 必须是字符串: %v", k)
+			return
+		}
+		t, ok := v.(*lua.LTable)
+		if !ok {
+			err = fmt.Errorf("模组 %s 的配置必须是 table", k.String())
+			return
+		}
+		override := ModOverride{
+			Id:                   k.String(),
+			ConfigurationOptions: make(map[string]interface{}),
+		}
+		t.ForEach(func(field lua.LValue, value lua.LValue) {
+			switch field.String() {
+			case "enabled":
+				override.Enabled = lua.LVAsBool(value)
+			case "configuration_options":
+				if options, ok := value.(*lua.LTable); ok {
+					override.ConfigurationOptions = toMap(options)
+				}
+			default:
+				if override.Extra == nil {
+					override.Extra = make(map[string]interface{})
+				}
+				override.Extra[field.String()] = toInterface(value)
+			}
+		})
+		overrides = append(overrides, override)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].Id < overrides[j].Id
+	})
+	return overrides, nil
+}
+
+// FormatModOverrides 生成 modoverrides.lua，模组和配置项按名称排序，相同的配置总是生成相同的内容
+func FormatModOverrides(overrides []ModOverride) string {
+	sorted := make([]ModOverride, len(overrides))
+	copy(sorted, overrides)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Id < sorted[j].Id
+	})
+
+	var b strings.Builder
+	b.WriteString("return {\n")
+	for _, override := range sorted {
+		fields := make(map[string]interface{}, len(override.Extra)+2)
+		for k, v := range override.Extra {
+			fields[k] = v
+		}
+		options := override.ConfigurationOptions
+		if options == nil {
+			options = map[string]interface{}{}
+		}
+		fields["configuration_options"] = options
+		fields["enabled"] = override.Enabled
+
+		b.WriteString("  ")
+		b.WriteString(luaKey(override.Id))
+		b.WriteString("=")
+		writeLuaTable(&b, fields, 1)
+		b.WriteString(",\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func luaKey(key string) string {
+	if luaIdentifier.MatchString(key) && !luaKeywords[key] {
+		return key
+	}
+	return "[" + luaString(key) + "]"
+}
+
+// luaString Lua 5.1 的字符串字面量，非 ASCII 字符原样输出
+func luaString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				b.WriteString(fmt.Sprintf(`\%03d`, c))
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func luaNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "0/0"
+	case math.IsInf(f, 1):
+		return "1/0"
+	case math.IsInf(f, -1):
+		return "-1/0"
+	case f == math.Trunc(f) && math.Abs(f) < 1e15:
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func writeLuaValue(b *strings.Builder, value interface{}, depth int) {
+	switch v := value.(type) {
+	case nil:
+		b.WriteString("nil")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case float64:
+		b.WriteString(luaNumber(v))
+	case float32:
+		b.WriteString(luaNumber(float64(v)))
+	case int:
+		b.WriteString(strconv.Itoa(v))
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case json.Number:
+		f, _ := v.Float64()
+		b.WriteString(luaNumber(f))
+	case string:
+		b.WriteString(luaString(v))
+	case map[string]interface{}:
+		writeLuaTable(b, v, depth)
+	case []interface{}:
+		writeLuaArray(b, v, depth)
+	default:
+		b.WriteString(luaString(fmt.Sprint(v)))
+	}
+}
+
+func writeLuaTable(b *strings.Builder, table map[string]interface{}, depth int) {
+	if len(table) == 0 {
+		b.WriteString("{}")
+		return
+	}
+	keys := make([]string, 0, len(table))
+	for k := range table {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	indent := strings.Repeat("  ", depth+1)
+	b.WriteString("{\n")
+	for _, k := range keys {
+		if table[k] == nil {
+			continue
+		}
+		b.WriteString(indent)
+		b.WriteString(luaKey(k))
+		b.WriteString("=")
+		writeLuaValue(b, table[k], depth+1)
+		b.WriteString(",\n")
+	}
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString("}")
+}
+
+func writeLuaArray(b *strings.Builder, array []interface{}, depth int) {
+	if len(array) == 0 {
+		b.WriteString("{}")
+		return
+	}
+	b.WriteString("{ ")
+	for i, v := range array {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeLuaValue(b, v, depth)
+	}
+	b.WriteString(" }")
+}
+
+// ParseModOptions 从模组信息的 ModConfig 中读取配置项定义，没有名称的配置项是分组标题，不返回
+func ParseModOptions(modConfig string) ([]ModOption, error) {
+	if modConfig == "" {
+		return []ModOption{}, nil
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal([]byte(modConfig), &config); err != nil {
+		return nil, err
+	}
+	items, _ := config["configuration_options"].([]interface{})
+	options := make([]ModOption, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		option := ModOption{
+			Name:    stringOf(m["name"]),
+			Label:   stringOf(m["label"]),
+			Hover:   stringOf(m["hover"]),
+			Default: m["default"],
+			Options: make([]ModOptionChoice, 0),
+		}
+		if option.Name == "" {
+			continue
+		}
+		choices, _ := m["options"].([]interface{})
+		for _, choice := range choices {
+			c, ok := choice.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			option.Options = append(option.Options, ModOptionChoice{
+				Description: stringOf(c["description"]),
+				Data:        c["data"],
+				Hover:       stringOf(c["hover"]),
+			})
+		}
+		options = append(options, option)
+	}
+	return options, nil
+}
+
+func stringOf(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// DefaultModOptions 配置项的默认值
+func DefaultModOptions(schema []ModOption) map[string]interface{} {
+	defaults := make(map[string]interface{})
+	for _, option := range schema {
+		if option.Default != nil {
+			defaults[option.Name] = option.Default
+		}
+	}
+	return defaults
+}
+
+// ValidateModOverride 按配置项定义校验模组配置，报告未知的配置项和不在可选值中的值
+func ValidateModOverride(override ModOverride, schema []ModOption) []OverrideIssue {
+	issues := make([]OverrideIssue, 0)
+	byName := make(map[string]ModOption, len(schema))
+	for _, option := range schema {
+		byName[option.Name] = option
+	}
+
+	keys := make([]string, 0, len(override.ConfigurationOptions))
+	for k := range override.ConfigurationOptions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		option, ok := byName[key]
+		// 模组更新后可能删除配置项，游戏会忽略未知的配置项，只作为警告
+		if !ok {
+			issues = append(issues, OverrideIssue{
+				ModId:   override.Id,
+				Key:     key,
+				Level:   IssueWarning,
+				Message: "未知的配置项: " + key,
+			})
+			continue
+		}
+		// 没有可选值的配置项不限制取值
+		if len(option.Options) == 0 {
+			continue
+		}
+		value := override.ConfigurationOptions[key]
+		valid := false
+		choices := make([]string, 0, len(option.Options))
+		for _, choice := range option.Options {
+			if optionValueEqual(value, choice.Data) {
+				valid = true
+				break
+			}
+			choices = append(choices, fmt.Sprintf("%v", choice.Data))
+		}
+		if !valid {
+			issues = append(issues, OverrideIssue{
+				ModId:   override.Id,
+				Key:     key,
+				Level:   IssueError,
+				Message: fmt.Sprintf("配置项 %s 的值 %v 无效，可选值: %s", key, value, strings.Join(choices, ", ")),
+			})
+		}
+	}
+	return issues
+}
+
+// hasIssueError 是否有错误级别的问题，只有警告时可以保存
+func hasIssueError(issues []OverrideIssue) bool {
+	for _, issue := range issues {
+		if issue.Level == IssueError {
+			return true
+		}
+	}
+	return false
+}
+
+// optionValueEqual 比较配置值，数字不区分整数和浮点数
+func optionValueEqual(a, b interface{}) bool {
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if aok || bok {
+		return aok && bok && af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
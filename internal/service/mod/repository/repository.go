@@ -0,0 +1,463 @@
+package repository
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 仓库目录结构，可以直接作为测试用的模组目录:
+//
+//	<root>/<modId>/<version>/mod.zip    模组目录的压缩包
+//	<root>/<modId>/<version>/meta.json  模组信息
+//
+// version 为创意工坊的更新时间，同一个模组保留多个版本
+const (
+	archiveName = "mod.zip"
+	metaName    = "meta.json"
+)
+
+var (
+	ErrNotFound = errors.New("模组仓库中没有该模组")
+
+	// 以 . 开头的目录是仓库的临时目录
+	modIdPattern   = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]{0,127}$`)
+	versionPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// Meta 仓库中一个模组版本的信息
+type Meta struct {
+	ModId       string  `json:"modId"`
+	Version     string  `json:"version"`
+	Name        string  `json:"name"`
+	Auth        string  `json:"auth"`
+	Description string  `json:"description"`
+	Img         string  `json:"img"`
+	V           string  `json:"v"`
+	LastTime    float64 `json:"lastTime"`
+	Child       string  `json:"child"`
+	ModConfig   string  `json:"modConfig"`
+
+	Size      int64     `json:"size"`
+	Sha256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// VersionOf 按创意工坊的更新时间生成版本号，本地模组没有更新时间时为 0
+func VersionOf(lastTime float64) string {
+	return strconv.FormatInt(int64(lastTime), 10)
+}
+
+// Repository 本地模组仓库，所有集群共用一份
+type Repository struct {
+	root string
+	mu   sync.RWMutex
+}
+
+func New(root string) *Repository {
+	return &Repository{root: root}
+}
+
+// Root 仓库目录
+func (r *Repository) Root() string {
+	return r.root
+}
+
+func validate(modId, version string) error {
+	if !modIdPattern.MatchString(modId) {
+		return fmt.Errorf("模组 id 不合法: %s", modId)
+	}
+	if !versionPattern.MatchString(version) {
+		return fmt.Errorf("模组版本不合法: %s", version)
+	}
+	return nil
+}
+
+func (r *Repository) versionDir(modId, version string) string {
+	return filepath.Join(r.root, modId, version)
+}
+
+func readMeta(dir string) (Meta, error) {
+	var meta Meta
+	data, err := os.ReadFile(filepath.Join(dir, metaName))
+	if err != nil {
+		return meta, err
+	}
+	err = json.Unmarshal(data, &meta)
+	return meta, err
+}
+
+func writeMeta(dir string, meta Meta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, metaName), data, 0644)
+}
+
+// List 仓库中所有模组版本，按模组 id 升序、版本从新到旧排列
+func (r *Repository) List() ([]Meta, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.list("")
+}
+
+// Versions 模组的所有版本，从新到旧排列
+func (r *Repository) Versions(modId string) ([]Meta, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if err := validate(modId, "0"); err != nil {
+		return nil, err
+	}
+	return r.list(modId)
+}
+
+func (r *Repository) list(modId string) ([]Meta, error) {
+	metas := make([]Meta, 0)
+	modDirs, err := os.ReadDir(r.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return metas, nil
+		}
+		return nil, err
+	}
+	for _, modDir := range modDirs {
+		if !modDir.IsDir() || (modId != "" && modDir.Name() != modId) {
+			continue
+		}
+		versions, err := os.ReadDir(filepath.Join(r.root, modDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			if !version.IsDir() || validate(modDir.Name(), version.Name()) != nil {
+				continue
+			}
+			dir := filepath.Join(r.root, modDir.Name(), version.Name())
+			meta, err := readMeta(dir)
+			if err != nil || !fileExists(filepath.Join(dir, archiveName)) {
+				continue
+			}
+			meta.ModId = modDir.Name()
+			meta.Version = version.Name()
+			metas = append(metas, meta)
+		}
+	}
+	sort.SliceStable(metas, func(i, j int) bool {
+		if metas[i].ModId != metas[j].ModId {
+			return metas[i].ModId < metas[j].ModId
+		}
+		return metas[i].LastTime > metas[j].LastTime
+	})
+	return metas, nil
+}
+
+// Latest 模组最新的版本
+func (r *Repository) Latest(modId string) (Meta, error) {
+	versions, err := r.Versions(modId)
+	if err != nil {
+		return Meta{}, err
+	}
+	if len(versions) == 0 {
+		return Meta{}, ErrNotFound
+	}
+	return versions[0], nil
+}
+
+// Get 模组的指定版本，version 为空时返回最新版本
+func (r *Repository) Get(modId, version string) (Meta, error) {
+	if version == "" {
+		return r.Latest(modId)
+	}
+	if err := validate(modId, version); err != nil {
+		return Meta{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	dir := r.versionDir(modId, version)
+	if !fileExists(filepath.Join(dir, archiveName)) {
+		return Meta{}, ErrNotFound
+	}
+	meta, err := readMeta(dir)
+	if err != nil {
+		return Meta{}, err
+	}
+	meta.ModId, meta.Version = modId, version
+	return meta, nil
+}
+
+// Store 将模组目录打包保存到仓库，相同版本已存在时覆盖
+func (r *Repository) Store(meta Meta, srcDir string) (Meta, error) {
+	if meta.Version == "" {
+		meta.Version = VersionOf(meta.LastTime)
+	}
+	if err := validate(meta.ModId, meta.Version); err != nil {
+		return Meta{}, err
+	}
+	if info, err := os.Stat(srcDir); err != nil || !info.IsDir() {
+		return Meta{}, fmt.Errorf("模组目录不存在: %s", srcDir)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	staging, err := os.MkdirTemp(r.ensureRoot(), ".store-")
+	if err != nil {
+		return Meta{}, err
+	}
+	defer os.RemoveAll(staging)
+
+	archivePath := filepath.Join(staging, archiveName)
+	if err := zipDir(srcDir, archivePath); err != nil {
+		return Meta{}, err
+	}
+	meta.Size, meta.Sha256, err = hashFile(archivePath)
+	if err != nil {
+		return Meta{}, err
+	}
+	meta.CreatedAt = time.Now()
+	if err := writeMeta(staging, meta); err != nil {
+		return Meta{}, err
+	}
+	return meta, r.commit(staging, meta.ModId, meta.Version)
+}
+
+// ensureRoot 创建仓库目录，返回仓库目录
+func (r *Repository) ensureRoot() string {
+	os.MkdirAll(r.root, 0755)
+	return r.root
+}
+
+// commit 将准备好的版本目录移动到仓库中，替换已有的相同版本
+func (r *Repository) commit(staging, modId, version string) error {
+	dst := r.versionDir(modId, version)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	old := dst + ".old"
+	os.RemoveAll(old)
+	if fileExists(dst) {
+		if err := os.Rename(dst, old); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(staging, dst); err != nil {
+		os.Rename(old, dst)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// Install 解压模组的指定版本到目标目录，目标目录中原有的文件会被替换
+func (r *Repository) Install(modId, version, destDir string) (Meta, error) {
+	meta, err := r.Get(modId, version)
+	if err != nil {
+		return Meta{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	archivePath := filepath.Join(r.versionDir(meta.ModId, meta.Version), archiveName)
+	if _, sum, err := hashFile(archivePath); err != nil {
+		return Meta{}, err
+	} else if meta.Sha256 != "" && sum != meta.Sha256 {
+		return Meta{}, fmt.Errorf("模组压缩包校验失败: %s@%s", meta.ModId, meta.Version)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destDir), 0755); err != nil {
+		return Meta{}, err
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(destDir), "."+filepath.Base(destDir)+"-")
+	if err != nil {
+		return Meta{}, err
+	}
+	defer os.RemoveAll(staging)
+	if err := unzipFile(archivePath, staging); err != nil {
+		return Meta{}, err
+	}
+
+	old := staging + ".old"
+	if fileExists(destDir) {
+		if err := os.Rename(destDir, old); err != nil {
+			return Meta{}, err
+		}
+	}
+	if err := os.Rename(staging, destDir); err != nil {
+		os.Rename(old, destDir)
+		return Meta{}, err
+	}
+	os.RemoveAll(old)
+	return meta, nil
+}
+
+// Delete 删除模组的指定版本，version 为空时删除所有版本
+func (r *Repository) Delete(modId, version string) error {
+	if version == "" {
+		version = "0"
+		if err := validate(modId, version); err != nil {
+			return err
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return os.RemoveAll(filepath.Join(r.root, modId))
+	}
+	if err := validate(modId, version); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dir := r.versionDir(modId, version)
+	if !fileExists(dir) {
+		return ErrNotFound
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	// 最后一个版本删除后删除模组目录
+	os.Remove(filepath.Join(r.root, modId))
+	return nil
+}
+
+// Prune 每个模组只保留最新的 keep 个版本
+func (r *Repository) Prune(modId string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	versions, err := r.Versions(modId)
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(versions); i++ {
+		if err := r.Delete(modId, versions[i].Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func hashFile(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// zipDir 将目录中的文件打包，压缩包中的路径相对于目录
+func zipDir(srcDir, dst string) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	writer := zip.NewWriter(out)
+	err = filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		header.Method = zip.Deflate
+		w, err := writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(w, file)
+		return err
+	})
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// unzipFile 解压到目录，拒绝目录以外的路径
+func unzipFile(archivePath, destDir string) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	base := filepath.Clean(destDir) + string(os.PathSeparator)
+	for _, file := range reader.File {
+		target := filepath.Join(destDir, filepath.FromSlash(file.Name))
+		if !strings.HasPrefix(target, base) {
+			return fmt.Errorf("非法文件路径: %s", file.Name)
+		}
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := extractFile(file, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(file *zip.File, target string) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, file.Mode().Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
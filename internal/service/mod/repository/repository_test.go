@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fixtureMods testdata/mods 中的模组目录，目录名为 workshop-<modId>
+var fixtureMods = map[string]string{
+	"1216718131": filepath.Join("testdata", "mods", "workshop-1216718131"),
+	"378160973":  filepath.Join("testdata", "mods", "workshop-378160973"),
+}
+
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+	return New(filepath.Join(t.TempDir(), "repository"))
+}
+
+// storeFixture 将测试模组保存为 lastTime 对应的版本
+func storeFixture(t *testing.T, r *Repository, modId string, lastTime float64) Meta {
+	t.Helper()
+	meta, err := r.Store(Meta{ModId: modId, Name: "mod " + modId, LastTime: lastTime}, fixtureMods[modId])
+	if err != nil {
+		t.Fatalf("Store %s@%v: %v", modId, lastTime, err)
+	}
+	return meta
+}
+
+// readTree 读取目录中的所有文件，key 为相对路径
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func assertSameTree(t *testing.T, got, want string) {
+	t.Helper()
+	gotFiles, wantFiles := readTree(t, got), readTree(t, want)
+	if len(gotFiles) != len(wantFiles) {
+		t.Fatalf("文件数不一致: got %v, want %v", keys(gotFiles), keys(wantFiles))
+	}
+	for name, content := range wantFiles {
+		if gotFiles[name] != content {
+			t.Fatalf("文件 %s 内容不一致", name)
+		}
+	}
+}
+
+func keys(m map[string]string) []string {
+	list := make([]string, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		modId   string
+		version string
+		ok      bool
+	}{
+		{"1216718131", "1700000000", true},
+		{"workshop-1216718131", "0", true},
+		{"my_mod.v2", "v1-beta_2", true},
+		{"", "1", false},
+		{".store-1", "1", false},
+		{"..", "1", false},
+		{"../1", "1", false},
+		{"a/b", "1", false},
+		{`a\b`, "1", false},
+		{"123", "", false},
+		{"123", "..", false},
+		{"123", "1.0", false},
+		{"123", "1/2", false},
+		{strings.Repeat("a", 129), "1", false},
+	}
+	for _, tt := range tests {
+		if err := validate(tt.modId, tt.version); (err == nil) != tt.ok {
+			t.Errorf("validate(%q, %q) err = %v", tt.modId, tt.version, err)
+		}
+	}
+}
+
+func TestVersionOf(t *testing.T) {
+	tests := []struct {
+		lastTime float64
+		want     string
+	}{
+		{0, "0"},
+		{1700000000, "1700000000"},
+		{1700000000.9, "1700000000"},
+	}
+	for _, tt := range tests {
+		if got := VersionOf(tt.lastTime); got != tt.want {
+			t.Errorf("VersionOf(%v) = %s, want %s", tt.lastTime, got, tt.want)
+		}
+	}
+}
+
+func TestStoreAndInstall(t *testing.T) {
+	r := newTestRepository(t)
+	storeFixture(t, r, "1216718131", 1600000000)
+	latest := storeFixture(t, r, "1216718131", 1700000000)
+	storeFixture(t, r, "378160973", 1500000000)
+
+	if latest.Version != "1700000000" || latest.Size == 0 || latest.Sha256 == "" {
+		t.Fatalf("Store 返回的版本信息不正确: %+v", latest)
+	}
+
+	metas, err := r.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, meta := range metas {
+		got = append(got, meta.ModId+"@"+meta.Version)
+	}
+	want := "1216718131@1700000000 1216718131@1600000000 378160973@1500000000"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("List = %v, want %s", got, want)
+	}
+
+	if meta, err := r.Latest("1216718131"); err != nil || meta.Version != "1700000000" {
+		t.Fatalf("Latest = %+v, %v", meta, err)
+	}
+	if _, err := r.Get("1216718131", "1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("不存在的版本应返回 ErrNotFound，err = %v", err)
+	}
+	if _, err := r.Latest("404"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("不存在的模组应返回 ErrNotFound，err = %v", err)
+	}
+
+	// 安装时替换目标目录中原有的文件
+	dest := filepath.Join(t.TempDir(), "content", "322330", "1216718131")
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, "stale.lua"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := r.Install("1216718131", "", dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Version != "1700000000" {
+		t.Fatalf("version 为空时应安装最新版本: %s", meta.Version)
+	}
+	assertSameTree(t, dest, fixtureMods["1216718131"])
+
+	// 目标目录所在的目录中不留下临时目录
+	entries, _ := os.ReadDir(filepath.Dir(dest))
+	if len(entries) != 1 {
+		t.Fatalf("安装后留下了临时目录: %v", entries)
+	}
+}
+
+func TestStoreInvalid(t *testing.T) {
+	r := newTestRepository(t)
+	tests := []struct {
+		name string
+		meta Meta
+		src  string
+	}{
+		{"模组 id 不合法", Meta{ModId: "../evil", LastTime: 1}, fixtureMods["378160973"]},
+		{"版本不合法", Meta{ModId: "378160973", Version: "../1"}, fixtureMods["378160973"]},
+		{"目录不存在", Meta{ModId: "378160973", LastTime: 1}, filepath.Join("testdata", "mods", "missing")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := r.Store(tt.meta, tt.src); err == nil {
+				t.Fatal("Store 应返回错误")
+			}
+		})
+	}
+	if metas, _ := r.List(); len(metas) != 0 {
+		t.Fatalf("失败的 Store 不应保存版本: %v", metas)
+	}
+}
+
+func TestInstallRejectsCorruptArchive(t *testing.T) {
+	r := newTestRepository(t)
+	meta := storeFixture(t, r, "378160973", 1500000000)
+	archivePath := filepath.Join(r.Root(), "378160973", meta.Version, archiveName)
+	if err := os.WriteFile(archivePath, []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(t.TempDir(), "378160973")
+	if _, err := r.Install("378160973", meta.Version, dest); err == nil || !strings.Contains(err.Error(), "校验失败") {
+		t.Fatalf("压缩包被修改时应校验失败，err = %v", err)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatal("校验失败时不应创建目标目录")
+	}
+}
+
+func TestInstallRejectsZipTraversal(t *testing.T) {
+	r := newTestRepository(t)
+	dir := filepath.Join(r.Root(), "378160973", "1")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(filepath.Join(dir, archiveName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(file)
+	for _, name := range []string{"modinfo.lua", "../../evil.lua"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("evil"))
+	}
+	zw.Close()
+	file.Close()
+	if err := writeMeta(dir, Meta{ModId: "378160973", Version: "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	dest := filepath.Join(root, "mods", "378160973")
+	if _, err := r.Install("378160973", "1", dest); err == nil || !strings.Contains(err.Error(), "非法文件路径") {
+		t.Fatalf("压缩包中目录以外的路径应被拒绝，err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "evil.lua")); !os.IsNotExist(err) {
+		t.Fatal("不应写入目标目录以外的文件")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatal("解压失败时不应创建目标目录")
+	}
+}
+
+func TestDeleteAndPrune(t *testing.T) {
+	r := newTestRepository(t)
+	for _, lastTime := range []float64{1, 2, 3, 4} {
+		storeFixture(t, r, "378160973", lastTime)
+	}
+	storeFixture(t, r, "1216718131", 1)
+
+	if err := r.Prune("378160973", 2); err != nil {
+		t.Fatal(err)
+	}
+	versions, _ := r.Versions("378160973")
+	if len(versions) != 2 || versions[0].Version != "4" || versions[1].Version != "3" {
+		t.Fatalf("Prune 后的版本不正确: %+v", versions)
+	}
+
+	if err := r.Delete("378160973", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete("378160973", "3"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("重复删除应返回 ErrNotFound，err = %v", err)
+	}
+	if err := r.Delete("378160973", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(r.Root(), "378160973")); !os.IsNotExist(err) {
+		t.Fatal("删除所有版本后应删除模组目录")
+	}
+	if err := r.Delete("..", ""); err == nil {
+		t.Fatal("Delete 应拒绝不合法的模组 id")
+	}
+	if metas, _ := r.List(); len(metas) != 1 || metas[0].ModId != "1216718131" {
+		t.Fatalf("删除后 List 不正确: %+v", metas)
+	}
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Export 将模组打包为 tar.gz，目录结构与仓库相同，modIds 为空时导出所有模组
+// latestOnly 为 true 时每个模组只导出最新版本
+func (r *Repository) Export(w io.Writer, modIds []string, latestOnly bool) ([]Meta, error) {
+	metas, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(modIds))
+	for _, modId := range modIds {
+		wanted[modId] = true
+	}
+	selected := make([]Meta, 0, len(metas))
+	for _, meta := range metas {
+		if len(wanted) > 0 && !wanted[meta.ModId] {
+			continue
+		}
+		if latestOnly && len(selected) > 0 && selected[len(selected)-1].ModId == meta.ModId {
+			continue
+		}
+		selected = append(selected, meta)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, meta := range selected {
+		dir := r.versionDir(meta.ModId, meta.Version)
+		for _, name := range []string{metaName, archiveName} {
+			if err := addTarFile(tw, filepath.Join(dir, name), path.Join(meta.ModId, meta.Version, name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return selected, gz.Close()
+}
+
+func addTarFile(tw *tar.Writer, src, name string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// Import 导入 Export 生成的 tar.gz，校验每个版本的压缩包后再放入仓库，已有的相同版本会被替换
+func (r *Repository) Import(reader io.Reader) ([]Meta, error) {
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("不是有效的 tar.gz 文件: %w", err)
+	}
+	defer gz.Close()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	staging, err := os.MkdirTemp(r.ensureRoot(), ".import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	// 先全部解压到临时目录，校验通过后再移动到仓库
+	versions := make([][2]string, 0)
+	seen := make(map[[2]string]bool)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("不支持的文件类型: %s", header.Name)
+		}
+		parts := strings.Split(strings.TrimPrefix(path.Clean(header.Name), "./"), "/")
+		if len(parts) != 3 || (parts[2] != metaName && parts[2] != archiveName) {
+			return nil, fmt.Errorf("不是模组仓库的文件: %s", header.Name)
+		}
+		if err := validate(parts[0], parts[1]); err != nil {
+			return nil, err
+		}
+		dir := filepath.Join(staging, parts[0], parts[1])
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		if err := writeFile(filepath.Join(dir, parts[2]), tr); err != nil {
+			return nil, err
+		}
+		key := [2]string{parts[0], parts[1]}
+		if !seen[key] {
+			seen[key] = true
+			versions = append(versions, key)
+		}
+	}
+
+	imported := make([]Meta, 0, len(versions))
+	for _, key := range versions {
+		dir := filepath.Join(staging, key[0], key[1])
+		meta, err := readMeta(dir)
+		if err != nil {
+			return nil, fmt.Errorf("模组信息读取失败 %s@%s: %w", key[0], key[1], err)
+		}
+		size, sum, err := hashFile(filepath.Join(dir, archiveName))
+		if err != nil {
+			return nil, fmt.Errorf("缺少模组压缩包 %s@%s: %w", key[0], key[1], err)
+		}
+		if meta.Sha256 != "" && meta.Sha256 != sum {
+			return nil, fmt.Errorf("模组压缩包校验失败 %s@%s", key[0], key[1])
+		}
+		if zr, err := zip.OpenReader(filepath.Join(dir, archiveName)); err != nil {
+			return nil, fmt.Errorf("模组压缩包损坏 %s@%s: %w", key[0], key[1], err)
+		} else {
+			zr.Close()
+		}
+		meta.ModId, meta.Version, meta.Size, meta.Sha256 = key[0], key[1], size, sum
+		if err := writeMeta(dir, meta); err != nil {
+			return nil, err
+		}
+		imported = append(imported, meta)
+	}
+	for _, meta := range imported {
+		if err := r.commit(filepath.Join(staging, meta.ModId, meta.Version), meta.ModId, meta.Version); err != nil {
+			return nil, err
+		}
+	}
+	return imported, nil
+}
+
+func writeFile(dst string, r io.Reader) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	src := newTestRepository(t)
+	storeFixture(t, src, "1216718131", 1600000000)
+	storeFixture(t, src, "1216718131", 1700000000)
+	storeFixture(t, src, "378160973", 1500000000)
+
+	tests := []struct {
+		name       string
+		modIds     []string
+		latestOnly bool
+		want       []string
+	}{
+		{"所有版本", nil, false, []string{"1216718131@1700000000", "1216718131@1600000000", "378160973@1500000000"}},
+		{"只导出最新版本", nil, true, []string{"1216718131@1700000000", "378160973@1500000000"}},
+		{"指定模组", []string{"378160973"}, false, []string{"378160973@1500000000"}},
+		{"指定模组的最新版本", []string{"1216718131"}, true, []string{"1216718131@1700000000"}},
+		{"不存在的模组", []string{"404"}, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			exported, err := src.Export(&buf, tt.modIds, tt.latestOnly)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := versionList(exported); got != strings.Join(tt.want, " ") {
+				t.Fatalf("Export = %s, want %v", got, tt.want)
+			}
+
+			dst := newTestRepository(t)
+			imported, err := dst.Import(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := versionList(imported); got != strings.Join(tt.want, " ") {
+				t.Fatalf("Import = %s, want %v", got, tt.want)
+			}
+			metas, err := dst.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := versionList(metas); got != strings.Join(tt.want, " ") {
+				t.Fatalf("导入后 List = %s, want %v", got, tt.want)
+			}
+			for _, meta := range metas {
+				origin, err := src.Get(meta.ModId, meta.Version)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if meta.Sha256 != origin.Sha256 || meta.Size != origin.Size || meta.Name != origin.Name || meta.LastTime != origin.LastTime {
+					t.Fatalf("导入的版本信息不一致: %+v != %+v", meta, origin)
+				}
+				dest := filepath.Join(t.TempDir(), meta.ModId)
+				if _, err := dst.Install(meta.ModId, meta.Version, dest); err != nil {
+					t.Fatal(err)
+				}
+				assertSameTree(t, dest, fixtureMods[meta.ModId])
+			}
+		})
+	}
+}
+
+func TestImportReplacesVersion(t *testing.T) {
+	src := newTestRepository(t)
+	storeFixture(t, src, "378160973", 1500000000)
+	var buf bytes.Buffer
+	if _, err := src.Export(&buf, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// 目标仓库中已有相同版本，但内容不同
+	dst := newTestRepository(t)
+	if _, err := dst.Store(Meta{ModId: "378160973", LastTime: 1500000000, Name: "old"}, fixtureMods["1216718131"]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.Import(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(t.TempDir(), "378160973")
+	meta, err := dst.Install("378160973", "1500000000", dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Name != "mod 378160973" {
+		t.Fatalf("导入后应替换已有的版本: %+v", meta)
+	}
+	assertSameTree(t, dest, fixtureMods["378160973"])
+}
+
+// tarEntry 构造导入文件的一个条目
+type tarEntry struct {
+	name     string
+	typeflag byte
+	body     []byte
+	linkname string
+}
+
+func buildTarball(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		typeflag := entry.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		header := &tar.Header{Name: entry.name, Typeflag: typeflag, Mode: 0644, Size: int64(len(entry.body)), Linkname: entry.linkname}
+		if typeflag != tar.TypeReg {
+			header.Size = 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if typeflag == tar.TypeReg {
+			if _, err := tw.Write(entry.body); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+// validVersion 一个可以导入的版本：meta.json 和 mod.zip
+func validVersion(t *testing.T, modId, version string) []tarEntry {
+	t.Helper()
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	w, err := zw.Create("modinfo.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(`name = "imported"`))
+	zw.Close()
+	meta, _ := json.Marshal(Meta{ModId: modId, Version: version, Name: "imported"})
+	return []tarEntry{
+		{name: modId + "/" + version + "/" + metaName, body: meta},
+		{name: modId + "/" + version + "/" + archiveName, body: zipBuf.Bytes()},
+	}
+}
+
+func TestImportRejects(t *testing.T) {
+	valid := validVersion(t, "378160973", "1")
+	badSha, _ := json.Marshal(Meta{ModId: "378160973", Version: "1", Sha256: strings.Repeat("0", 64)})
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		err     string
+	}{
+		{"上级目录", append(validVersion(t, "378160973", "1"), tarEntry{name: "../378160973/1/meta.json", body: []byte("{}")}), "不是模组仓库的文件"},
+		{"清理后的上级目录", append(validVersion(t, "378160973", "1"), tarEntry{name: "378160973/../../1/meta.json", body: []byte("{}")}), "模组 id 不合法"},
+		{"绝对路径", append(validVersion(t, "378160973", "1"), tarEntry{name: "/378160973/1/meta.json", body: []byte("{}")}), "不是模组仓库的文件"},
+		{"临时目录", []tarEntry{{name: ".import-1/1/meta.json", body: []byte("{}")}}, "模组 id 不合法"},
+		{"版本中的上级目录", []tarEntry{{name: "378160973/../meta.json", body: []byte("{}")}}, "不是模组仓库的文件"},
+		{"符号链接", append(validVersion(t, "378160973", "1"), tarEntry{name: "378160973/2/mod.zip", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}), "不支持的文件类型"},
+		{"硬链接", []tarEntry{{name: "378160973/1/mod.zip", typeflag: tar.TypeLink, linkname: "../../../etc/passwd"}}, "不支持的文件类型"},
+		{"其他文件", append(validVersion(t, "378160973", "1"), tarEntry{name: "378160973/1/evil.sh", body: []byte("rm -rf /")}), "不是模组仓库的文件"},
+		{"缺少压缩包", valid[:1], "缺少模组压缩包"},
+		{"缺少模组信息", valid[1:], "模组信息读取失败"},
+		{"压缩包校验失败", []tarEntry{{name: "378160973/1/meta.json", body: badSha}, valid[1]}, "校验失败"},
+		{"压缩包损坏", []tarEntry{valid[0], {name: "378160973/1/mod.zip", body: []byte("not a zip")}}, "模组压缩包损坏"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			r := New(filepath.Join(base, "repository"))
+			_, err := r.Import(buildTarball(t, tt.entries))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+			// 导入失败时仓库中没有任何版本，也没有写入仓库以外的文件
+			if metas, _ := r.List(); len(metas) != 0 {
+				t.Fatalf("导入失败时不应保存版本: %+v", metas)
+			}
+			entries, _ := os.ReadDir(r.Root())
+			if len(entries) != 0 {
+				t.Fatalf("导入失败后仓库目录中有残留: %v", entries)
+			}
+			if entries, _ := os.ReadDir(base); len(entries) != 1 {
+				t.Fatalf("写入了仓库以外的文件: %v", entries)
+			}
+		})
+	}
+}
+
+func TestImportNotGzip(t *testing.T) {
+	r := newTestRepository(t)
+	if _, err := r.Import(strings.NewReader("not a tarball")); err == nil || !strings.Contains(err.Error(), "tar.gz") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func versionList(metas []Meta) string {
+	list := make([]string, 0, len(metas))
+	for _, meta := range metas {
+		list = append(list, meta.ModId+"@"+meta.Version)
+	}
+	return strings.Join(list, " ")
+}
@@ -0,0 +1,19 @@
+name = "Fixture Mod"
+description = "模组仓库测试用的模组"
+author = "dst-admin-go"
+version = "1.2.0"
+api_version = 10
+dst_compatible = true
+all_clients_require_mod = true
+
+configuration_options = {
+	{
+		name = "difficulty",
+		label = "难度",
+		options = {
+			{ description = "简单", data = "easy" },
+			{ description = "困难", data = "hard" },
+		},
+		default = "easy",
+	},
+}
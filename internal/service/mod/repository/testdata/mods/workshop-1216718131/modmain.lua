@@ -0,0 +1,2 @@
+local difficulty = GetModConfigData("difficulty")
+modimport("scripts/fixture.lua")
@@ -0,0 +1,7 @@
+name = "Fixture Server Mod"
+description = "只在服务器运行的测试模组"
+author = "dst-admin-go"
+version = "3.0"
+api_version = 10
+dst_compatible = true
+server_only_mod = true
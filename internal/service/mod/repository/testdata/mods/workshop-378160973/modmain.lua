@@ -0,0 +1 @@
+print("fixture server mod")
@@ -0,0 +1,120 @@
+package workshop
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FakeClient 内存中的创意工坊，用于测试和没有网络的环境
+type FakeClient struct {
+	mu    sync.RWMutex
+	items map[string]Item
+	files map[string][]byte
+	// Err 不为空时所有请求都返回该错误，用于模拟网络故障
+	Err error
+}
+
+func NewFakeClient(items ...Item) *FakeClient {
+	f := &FakeClient{
+		items: make(map[string]Item),
+		files: make(map[string][]byte),
+	}
+	f.Add(items...)
+	return f
+}
+
+// Add 添加或替换模组，Result 为 0 时视为存在
+func (f *FakeClient) Add(items ...Item) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, item := range items {
+		if item.Result == 0 {
+			item.Result = 1
+		}
+		if item.ConsumerAppid == 0 {
+			item.ConsumerAppid = AppID
+		}
+		item.NumChildren = len(item.Children)
+		f.items[item.Publishedfileid] = item
+	}
+}
+
+// AddFile 添加 Download 可以下载的文件
+func (f *FakeClient) AddFile(fileUrl string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[fileUrl] = data
+}
+
+// GetDetails 不存在的模组返回 Result 为 9 的空模组，与 Steam 相同
+func (f *FakeClient) GetDetails(ctx context.Context, ids []string, language string) ([]Item, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	items := make([]Item, 0, len(ids))
+	for _, id := range ids {
+		item, ok := f.items[id]
+		if !ok {
+			item = Item{Publishedfileid: id, Result: 9}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Query 按标题和描述匹配关键词，结果按订阅数排序
+func (f *FakeClient) Query(ctx context.Context, query Query) (*QueryResult, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	text := strings.ToLower(query.Text)
+	matched := make([]Item, 0)
+	for _, item := range f.items {
+		if text == "" || strings.Contains(strings.ToLower(item.Title), text) || strings.Contains(strings.ToLower(item.FileDescription), text) {
+			matched = append(matched, item)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].Subscriptions != matched[j].Subscriptions {
+			return matched[i].Subscriptions > matched[j].Subscriptions
+		}
+		return matched[i].Publishedfileid < matched[j].Publishedfileid
+	})
+
+	page, size := query.Page, query.Size
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = len(matched)
+	}
+	start := (page - 1) * size
+	if start > len(matched) {
+		start = len(matched)
+	}
+	end := start + size
+	if end > len(matched) {
+		end = len(matched)
+	}
+	return &QueryResult{Total: len(matched), Items: matched[start:end]}, nil
+}
+
+func (f *FakeClient) Download(ctx context.Context, fileUrl string) ([]byte, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	data, ok := f.files[fileUrl]
+	if !ok {
+		return nil, errors.New("文件不存在: " + fileUrl)
+	}
+	return data, nil
+}
@@ -0,0 +1,295 @@
+package workshop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBaseURL         = "https://api.steampowered.com"
+	DefaultTimeout         = 15 * time.Second
+	DefaultDownloadTimeout = 2 * time.Minute
+	DefaultRetries         = 2
+)
+
+// Options Steam 创意工坊客户端配置，零值使用默认配置
+type Options struct {
+	APIKey          string
+	BaseURL         string
+	Timeout         time.Duration
+	DownloadTimeout time.Duration
+	// Retries 网络错误、429 和 5xx 时的重试次数
+	Retries int
+	// RateLimit 每秒最多请求次数，0 为不限制
+	RateLimit float64
+}
+
+// SteamClient 通过 Steam Web API 访问创意工坊
+type SteamClient struct {
+	opts     Options
+	api      *http.Client
+	download *http.Client
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func NewSteamClient(opts Options) *SteamClient {
+	if opts.BaseURL == "" {
+		opts.BaseURL = DefaultBaseURL
+	}
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.DownloadTimeout <= 0 {
+		opts.DownloadTimeout = DefaultDownloadTimeout
+	}
+	if opts.Retries < 0 {
+		opts.Retries = 0
+	}
+	return &SteamClient{
+		opts:     opts,
+		api:      &http.Client{Timeout: opts.Timeout},
+		download: &http.Client{Timeout: opts.DownloadTimeout},
+	}
+}
+
+// GetDetails 有 APIKey 时使用 IPublishedFileService，否则使用不需要 key 的 ISteamRemoteStorage，后者不返回依赖项
+func (c *SteamClient) GetDetails(ctx context.Context, ids []string, language string) ([]Item, error) {
+	if len(ids) == 0 {
+		return []Item{}, nil
+	}
+	if c.opts.APIKey == "" {
+		return c.getRemoteStorageDetails(ctx, ids)
+	}
+	params := url.Values{}
+	params.Set("key", c.opts.APIKey)
+	params.Set("language", language)
+	params.Set("includetags", "true")
+	params.Set("includechildren", "true")
+	for i, id := range ids {
+		params.Set("publishedfileids["+strconv.Itoa(i)+"]", id)
+	}
+	var result struct {
+		Response struct {
+			Publishedfiledetails []Item `json:"publishedfiledetails"`
+		} `json:"response"`
+	}
+	if err := c.getJSON(ctx, "/IPublishedFileService/GetDetails/v1/", params, &result); err != nil {
+		return nil, err
+	}
+	return result.Response.Publishedfiledetails, nil
+}
+
+// remoteStorageItem ISteamRemoteStorage 返回的模组详情，字段名与 IPublishedFileService 不同
+type remoteStorageItem struct {
+	Publishedfileid string  `json:"publishedfileid"`
+	Result          int     `json:"result"`
+	Creator         string  `json:"creator"`
+	CreatorAppID    float64 `json:"creator_app_id"`
+	ConsumerAppID   float64 `json:"consumer_app_id"`
+	Filename        string  `json:"filename"`
+	FileURL         string  `json:"file_url"`
+	PreviewURL      string  `json:"preview_url"`
+	Title           string  `json:"title"`
+	Description     string  `json:"description"`
+	TimeCreated     float64 `json:"time_created"`
+	TimeUpdated     float64 `json:"time_updated"`
+	Visibility      int     `json:"visibility"`
+	Subscriptions   int     `json:"subscriptions"`
+	Favorited       int     `json:"favorited"`
+	Views           int     `json:"views"`
+	Tags            []Tag   `json:"tags"`
+}
+
+func (c *SteamClient) getRemoteStorageDetails(ctx context.Context, ids []string) ([]Item, error) {
+	form := url.Values{}
+	form.Set("itemcount", strconv.Itoa(len(ids)))
+	for i, id := range ids {
+		form.Set("publishedfileids["+strconv.Itoa(i)+"]", id)
+	}
+	body, err := c.do(ctx, c.api, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.opts.BaseURL+"/ISteamRemoteStorage/GetPublishedFileDetails/v1/", strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Response struct {
+			Publishedfiledetails []remoteStorageItem `json:"publishedfiledetails"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析创意工坊响应失败: %w", err)
+	}
+	items := make([]Item, 0, len(result.Response.Publishedfiledetails))
+	for _, d := range result.Response.Publishedfiledetails {
+		items = append(items, Item{
+			Publishedfileid: d.Publishedfileid,
+			Result:          d.Result,
+			Creator:         d.Creator,
+			CreatorAppid:    d.CreatorAppID,
+			ConsumerAppid:   d.ConsumerAppID,
+			Filename:        d.Filename,
+			FileURL:         d.FileURL,
+			PreviewURL:      d.PreviewURL,
+			Title:           d.Title,
+			FileDescription: d.Description,
+			TimeCreated:     d.TimeCreated,
+			TimeUpdated:     d.TimeUpdated,
+			Visibility:      d.Visibility,
+			Subscriptions:   d.Subscriptions,
+			Favorited:       d.Favorited,
+			Views:           d.Views,
+			Tags:            d.Tags,
+		})
+	}
+	return items, nil
+}
+
+// Query 搜索需要 APIKey
+func (c *SteamClient) Query(ctx context.Context, query Query) (*QueryResult, error) {
+	if c.opts.APIKey == "" {
+		return nil, ErrNoAPIKey
+	}
+	params := url.Values{
+		"key":              {c.opts.APIKey},
+		"appid":            {strconv.Itoa(AppID)},
+		"page":             {strconv.Itoa(query.Page)},
+		"numperpage":       {strconv.Itoa(query.Size)},
+		"search_text":      {query.Text},
+		"language":         {query.Language},
+		"return_tags":      {"true"},
+		"return_vote_data": {"true"},
+		"return_children":  {"true"},
+	}
+	var result struct {
+		Response *struct {
+			Total                int    `json:"total"`
+			Publishedfiledetails []Item `json:"publishedfiledetails"`
+		} `json:"response"`
+	}
+	if err := c.getJSON(ctx, "/IPublishedFileService/QueryFiles/v1/", params, &result); err != nil {
+		return nil, err
+	}
+	if result.Response == nil {
+		return nil, errors.New("创意工坊没有返回搜索结果")
+	}
+	items := result.Response.Publishedfiledetails
+	if items == nil {
+		items = []Item{}
+	}
+	return &QueryResult{Total: result.Response.Total, Items: items}, nil
+}
+
+func (c *SteamClient) Download(ctx context.Context, fileUrl string) ([]byte, error) {
+	return c.do(ctx, c.download, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, fileUrl, nil)
+	})
+}
+
+func (c *SteamClient) getJSON(ctx context.Context, path string, params url.Values, v interface{}) error {
+	urlStr := c.opts.BaseURL + path + "?" + params.Encode()
+	body, err := c.do(ctx, c.api, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("解析创意工坊响应失败: %w", err)
+	}
+	return nil
+}
+
+// do 发送请求并读取响应，网络错误、429 和 5xx 时按指数退避重试
+func (c *SteamClient) do(ctx context.Context, client *http.Client, newRequest func() (*http.Request, error)) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= c.opts.Retries; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, time.Duration(1<<(attempt-1))*500*time.Millisecond); err != nil {
+				return nil, err
+			}
+		}
+		if err := c.wait(ctx); err != nil {
+			return nil, err
+		}
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		body, retry, err := send(client, req)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+		if !retry || ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("请求创意工坊失败: %w", lastErr)
+}
+
+func send(client *http.Client, req *http.Request) ([]byte, bool, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return nil, retry, fmt.Errorf("%s %s", req.URL.Path, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, err
+	}
+	return body, false, nil
+}
+
+// wait 限制请求频率，所有请求共用同一个间隔
+func (c *SteamClient) wait(ctx context.Context) error {
+	if c.opts.RateLimit <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / c.opts.RateLimit)
+	c.mu.Lock()
+	now := time.Now()
+	at := c.next
+	if at.Before(now) {
+		at = now
+	}
+	c.next = at.Add(interval)
+	c.mu.Unlock()
+	return sleep(ctx, time.Until(at))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,106 @@
+package workshop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSteamClientRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		calls    int32
+		ok       bool
+	}{
+		{"5xx 后重试成功", []int{http.StatusServiceUnavailable, http.StatusOK}, 2, 2, true},
+		{"429 后重试成功", []int{http.StatusTooManyRequests, http.StatusOK}, 1, 2, true},
+		{"不重试", []int{http.StatusServiceUnavailable, http.StatusOK}, 0, 1, false},
+		{"404 不重试", []int{http.StatusNotFound, http.StatusOK}, 2, 1, false},
+		{"重试次数用完", []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusOK}, 1, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&calls, 1)
+				w.WriteHeader(tt.statuses[n-1])
+				w.Write([]byte("mod.zip"))
+			}))
+			defer server.Close()
+
+			client := NewSteamClient(Options{Retries: tt.retries})
+			data, err := client.Download(context.Background(), server.URL+"/mod.zip")
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v", err)
+			}
+			if tt.ok && string(data) != "mod.zip" {
+				t.Fatalf("data = %q", data)
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.calls {
+				t.Fatalf("请求次数 = %d, want %d", got, tt.calls)
+			}
+		})
+	}
+}
+
+func TestSteamClientGetDetails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/IPublishedFileService/GetDetails/v1/":
+			if r.Method != http.MethodGet || r.URL.Query().Get("key") != "secret" || r.URL.Query().Get("publishedfileids[0]") != "1001" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"response":{"publishedfiledetails":[{"publishedfileid":"1001","result":1,"consumer_appid":322330,
+				"title":"mod 1001","file_description":"desc","tags":[{"tag":"version:1.0"}],"children":[{"publishedfileid":"1002"}]}]}}`))
+		case "/ISteamRemoteStorage/GetPublishedFileDetails/v1/":
+			if r.Method != http.MethodPost || r.FormValue("itemcount") != "1" || r.FormValue("publishedfileids[0]") != "1001" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"response":{"publishedfiledetails":[{"publishedfileid":"1001","result":1,"consumer_app_id":322330,
+				"title":"mod 1001","description":"desc","tags":[{"tag":"version:1.0"}]}]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		apiKey   string
+		children int
+	}{
+		{"IPublishedFileService", "secret", 1},
+		{"没有 key 时使用 ISteamRemoteStorage", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewSteamClient(Options{APIKey: tt.apiKey, BaseURL: server.URL + "/", Retries: 0})
+			items, err := client.GetDetails(context.Background(), []string{"1001"}, LanguageChinese)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != 1 {
+				t.Fatalf("items = %+v", items)
+			}
+			item := items[0]
+			if item.Result != 1 || item.ConsumerAppid != AppID || item.Title != "mod 1001" || item.FileDescription != "desc" ||
+				item.Version() != "1.0" || len(item.Children) != tt.children {
+				t.Fatalf("模组详情不正确: %+v", item)
+			}
+		})
+	}
+}
+
+func TestSteamClientQueryNoAPIKey(t *testing.T) {
+	client := NewSteamClient(Options{})
+	if _, err := client.Query(context.Background(), Query{Text: "global"}); !errors.Is(err, ErrNoAPIKey) {
+		t.Fatalf("没有 key 时搜索应返回 ErrNoAPIKey，err = %v", err)
+	}
+}
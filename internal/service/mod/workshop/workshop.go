@@ -0,0 +1,90 @@
+package workshop
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// AppID 饥荒联机版的创意工坊 appid
+const AppID = 322330
+
+// Steam 的语言参数，6 为简体中文，为空时返回默认语言
+const (
+	LanguageChinese = "6"
+	LanguageDefault = ""
+)
+
+var ErrNoAPIKey = errors.New("没有配置 steamAPIKey，无法搜索创意工坊")
+
+// Client 创意工坊接口，Steam 的实现见 NewSteamClient，测试和离线环境可以使用 NewFakeClient
+type Client interface {
+	// GetDetails 批量获取模组详情，不存在的模组 Result 不为 1
+	GetDetails(ctx context.Context, ids []string, language string) ([]Item, error)
+	// Query 按关键词搜索模组
+	Query(ctx context.Context, query Query) (*QueryResult, error)
+	// Download 下载模组文件，用于有 file_url 的旧版模组
+	Download(ctx context.Context, fileUrl string) ([]byte, error)
+}
+
+// Item 创意工坊中的一个模组
+type Item struct {
+	Publishedfileid string    `json:"publishedfileid"`
+	Result          int       `json:"result"`
+	Creator         string    `json:"creator"`
+	CreatorAppid    float64   `json:"creator_appid"`
+	ConsumerAppid   float64   `json:"consumer_appid"`
+	Filename        string    `json:"filename"`
+	FileURL         string    `json:"file_url"`
+	PreviewURL      string    `json:"preview_url"`
+	Title           string    `json:"title"`
+	FileDescription string    `json:"file_description"`
+	TimeCreated     float64   `json:"time_created"`
+	TimeUpdated     float64   `json:"time_updated"`
+	Visibility      int       `json:"visibility"`
+	Subscriptions   int       `json:"subscriptions"`
+	Favorited       int       `json:"favorited"`
+	Views           int       `json:"views"`
+	Tags            []Tag     `json:"tags"`
+	NumChildren     int       `json:"num_children"`
+	Children        []Child   `json:"children"`
+	VoteData        *VoteData `json:"vote_data,omitempty"`
+}
+
+type Tag struct {
+	Tag string `json:"tag"`
+}
+
+// Child 模组声明的依赖项
+type Child struct {
+	Publishedfileid string `json:"publishedfileid"`
+}
+
+type VoteData struct {
+	Score     float64 `json:"score"`
+	VotesUp   int     `json:"votes_up"`
+	VotesDown int     `json:"votes_down"`
+}
+
+// Version tags 中 version: 开头的版本号
+func (i Item) Version() string {
+	for _, tag := range i.Tags {
+		if v, ok := strings.CutPrefix(tag.Tag, "version:"); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Query 搜索条件，Page 从 1 开始
+type Query struct {
+	Text     string
+	Page     int
+	Size     int
+	Language string
+}
+
+type QueryResult struct {
+	Total int
+	Items []Item
+}
@@ -0,0 +1,187 @@
+package modUpdate
+
+import (
+	"context"
+	"dst-admin-go/internal/config"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/service/audit"
+	"dst-admin-go/internal/service/autoCheck"
+	"dst-admin-go/internal/service/game"
+	"dst-admin-go/internal/service/levelConfig"
+	"dst-admin-go/internal/service/mod"
+	"dst-admin-go/internal/service/restart"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// systemOperator 模组自动更新在审计日志中的操作人
+var systemOperator = audit.System("modUpdate")
+
+// restartMessage 模组更新后重启的公告，{seconds} 替换为剩余秒数
+const restartMessage = "模组更新，服务器将在 {seconds} 秒后重启，请注意保存进度"
+
+// ModUpdateService 模组自动更新，按 autoUpdateModinfo 配置定时检测创意工坊的模组更新，
+// 下载新版本并重启使用该模组的集群
+type ModUpdateService struct {
+	db               *gorm.DB
+	config           *config.Config
+	gameProcess      game.Process
+	levelConfigUtils *levelConfig.LevelConfigUtils
+	modService       *mod.ModService
+	autoCheck        *autoCheck.AutoCheckService
+	restartService   *restart.RestartService
+
+	// mu 检测和更新不同时执行
+	mu sync.Mutex
+}
+
+func NewModUpdateService(db *gorm.DB, config *config.Config, gameProcess game.Process, levelConfigUtils *levelConfig.LevelConfigUtils, modService *mod.ModService, autoCheck *autoCheck.AutoCheckService, restartService *restart.RestartService) *ModUpdateService {
+	return &ModUpdateService{
+		db:               db,
+		config:           config,
+		gameProcess:      gameProcess,
+		levelConfigUtils: levelConfigUtils,
+		modService:       modService,
+		autoCheck:        autoCheck,
+		restartService:   restartService,
+	}
+}
+
+// Start 启动模组自动更新，没有开启时不执行
+func (s *ModUpdateService) Start() {
+	cfg := s.config.AutoUpdateModinfo
+	if !cfg.Enable {
+		return
+	}
+	if cfg.Restart && !cfg.Download {
+		log.Println("[ModUpdate]restart 需要同时开启 download，模组更新后不会自动重启")
+	}
+	go s.loop(time.Duration(cfg.CheckInterval)*time.Minute, s.Check)
+	if cfg.Download {
+		go s.loop(time.Duration(cfg.UpdateCheckInterval)*time.Minute, s.Update)
+	}
+	log.Println("[ModUpdate]模组自动更新已启动", "check:", cfg.CheckInterval, "update:", cfg.UpdateCheckInterval, "download:", cfg.Download, "restart:", cfg.Restart)
+	if cfg.Download {
+		log.Println("[ModUpdate]模组更新由自动更新统一下载和重启，集群的 MOD_UPDATE 自动检测不再更新模组")
+	}
+}
+
+func (s *ModUpdateService) loop(interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		fn()
+	}
+}
+
+// Check 检测模组更新，有新版本的模组标记为需要更新
+func (s *ModUpdateService) Check() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("[ModUpdate]检测模组更新异常", r)
+		}
+	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, err := s.modService.CheckModUpdates(context.Background())
+	if err != nil {
+		log.Println("[ModUpdate]检测模组更新失败", err)
+		return
+	}
+	if len(pending) > 0 {
+		log.Println("[ModUpdate]待更新的模组数:", len(pending))
+	}
+}
+
+// Update 更新已标记的模组，固定版本的模组跳过；开启 restart 时公告倒计时并重启使用这些模组的运行中集群
+func (s *ModUpdateService) Update() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("[ModUpdate]更新模组异常", r)
+		}
+	}()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := s.modService.GetPendingModUpdates()
+	if len(pending) == 0 {
+		return
+	}
+	clusterNames := make([]string, 0)
+	s.db.Model(&model.Cluster{}).Order("id asc").Pluck("cluster_name", &clusterNames)
+	if len(clusterNames) == 0 {
+		return
+	}
+	usedBy := make(map[string][]string)
+	for _, clusterName := range clusterNames {
+		for _, workshopId := range s.autoCheck.WorkshopIds(clusterName) {
+			usedBy[workshopId] = append(usedBy[workshopId], clusterName)
+		}
+	}
+
+	// 模组信息所有集群共用，只需要在一个集群的下载目录中更新，其他集群在重启时由游戏自动下载
+	updated := make(map[string][]string)
+	for _, modInfo := range pending {
+		clusters := usedBy[modInfo.Modid]
+		clusterName := clusterNames[0]
+		if len(clusters) > 0 {
+			clusterName = clusters[0]
+		}
+		if _, err := s.modService.UpdateMod(context.Background(), clusterName, modInfo.Modid, "zh"); err != nil {
+			log.Println("[ModUpdate]更新模组失败", modInfo.Modid, err)
+			continue
+		}
+		log.Println("[ModUpdate]模组已更新", modInfo.Name, modInfo.Modid)
+		for _, name := range clusters {
+			updated[name] = append(updated[name], modInfo.Name+"("+modInfo.Modid+")")
+		}
+	}
+
+	for _, clusterName := range clusterNames {
+		mods, ok := updated[clusterName]
+		if !ok {
+			continue
+		}
+		message := "模组自动更新: " + strings.Join(mods, " ")
+		var err error
+		if s.config.AutoUpdateModinfo.Restart && s.running(clusterName) {
+			s.installPinnedMods(clusterName)
+			_, err = s.restartService.Restart(systemOperator, clusterName, restart.Options{
+				Countdown: s.config.AutoUpdateModinfo.Countdown,
+				Message:   restartMessage,
+			})
+			if err == nil {
+				message = message + "，已开始重启"
+			}
+		}
+		s.autoCheck.RecordLog(systemOperator, clusterName, "", model.UPDATE_MOD, message, err)
+	}
+}
+
+// installPinnedMods 重启前从模组仓库恢复集群使用的固定版本模组，避免重启时加载其他集群更新后的版本
+func (s *ModUpdateService) installPinnedMods(clusterName string) {
+	for _, workshopId := range s.autoCheck.WorkshopIds(clusterName) {
+		if err := s.modService.InstallPinnedMod(clusterName, workshopId); err != nil {
+			log.Println("[ModUpdate]安装固定版本的模组失败", clusterName, workshopId, err)
+		}
+	}
+}
+
+// running 集群是否有运行中的世界，没有运行的集群不需要重启
+func (s *ModUpdateService) running(clusterName string) bool {
+	config, err := s.levelConfigUtils.GetLevelConfig(clusterName)
+	if err != nil {
+		return false
+	}
+	for _, item := range config.LevelList {
+		if running, _ := s.gameProcess.Status(clusterName, item.File); running {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,12 @@
 package player
 
 import (
-	"dst-admin-go/internal/pkg/utils/fileUtils"
-	"dst-admin-go/internal/service/archive"
-	"dst-admin-go/internal/service/game"
+	"context"
+	"dst-admin-go/internal/pkg/utils/dstUtils"
+	"dst-admin-go/internal/service/console"
+	"errors"
 	"log"
-	"regexp"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -20,102 +19,125 @@ type PlayerInfo struct {
 	Role string `json:"role"`
 }
 
+// PlayerStatus 生命、饥饿、理智
+type PlayerStatus struct {
+	Current float64 `json:"current"`
+	Max     float64 `json:"max"`
+	Percent int     `json:"percent"`
+}
+
+// PlayerItem 装备和物品，不存在的属性为 nil
+type PlayerItem struct {
+	Type       string `json:"type,omitempty"`
+	Slot       int    `json:"slot,omitempty"`
+	Slots      int    `json:"slots,omitempty"`
+	Prefab     string `json:"prefab"`
+	Name       string `json:"name"`
+	Count      int    `json:"count,omitempty"`
+	Durability *int   `json:"durability,omitempty"`
+	Freshness  *int   `json:"freshness,omitempty"`
+	Fuel       *int   `json:"fuel,omitempty"`
+	Armor      *int   `json:"armor,omitempty"`
+}
+
+type PlayerStats struct {
+	InventoryCount int `json:"inventory_count"`
+	BackpackCount  int `json:"backpack_count"`
+	TotalItems     int `json:"total_items"`
+}
+
+// PlayerDetail 玩家详情，对应 customcommands.lua 中 GetPlayerData 返回的 data
+type PlayerDetail struct {
+	KuId           string                    `json:"kuId"`
+	LevelName      string                    `json:"levelName"`
+	Name           string                    `json:"name"`
+	Prefab         string                    `json:"prefab"`
+	Health         PlayerStatus              `json:"health"`
+	Hunger         PlayerStatus              `json:"hunger"`
+	Sanity         PlayerStatus              `json:"sanity"`
+	Temperature    float64                   `json:"temperature"`
+	Moisture       float64                   `json:"moisture"`
+	HandEquipment  *PlayerItem               `json:"hand_equipment"`
+	HeadEquipment  *PlayerItem               `json:"head_equipment"`
+	BodyEquipment  *PlayerItem               `json:"body_equipment"`
+	BackpackItems  console.Array[PlayerItem] `json:"backpack_items"`
+	InventoryItems console.Array[PlayerItem] `json:"inventory_items"`
+	Stats          PlayerStats               `json:"stats"`
+}
+
+// playerListTimeout 查询玩家列表的超时时间
+const playerListTimeout = 3 * time.Second
+
+// allPlayersScript 通过 TheNet:GetClientTable 查询所有世界的玩家，performance 不为空的是服务器本身
+const allPlayersScript = `local list = {} for i, v in ipairs(TheNet:GetClientTable()) do if v.performance == nil then table.insert(list, {key = tostring(i - 1), day = string.format("%03d", v.playerage or 0), kuId = v.userid, name = v.name, role = v.prefab}) end end return list`
+
+// levelPlayersScript 通过 AllPlayers 查询当前世界的玩家
+const levelPlayersScript = `local list = {} for i, v in ipairs(AllPlayers) do table.insert(list, {key = tostring(i), day = tostring(v.components.age and v.components.age:GetAgeInDays() or 0), kuId = v.userid, name = v.name, role = v.prefab}) end return list`
+
 type PlayerService struct {
-	archive *archive.PathResolver
+	console *console.Console
 }
 
-func NewPlayerService(archive *archive.PathResolver) *PlayerService {
+func NewPlayerService(console *console.Console) *PlayerService {
 	return &PlayerService{
-		archive: archive,
+		console: console,
 	}
 }
 
-func (p *PlayerService) GetPlayerList(clusterName string, levelName string, gameProcess game.Process) []PlayerInfo {
+func (p *PlayerService) GetPlayerList(clusterName string, levelName string) []PlayerInfo {
+	script := levelPlayersScript
 	// 处理 #ALL_LEVEL 情况
-	queryName := ""
-	if levelName == "#ALL_LEVEL" {
-		queryName = "Master"
-	} else {
-		queryName = levelName
-	}
-	status, _ := gameProcess.Status(clusterName, queryName)
-	if !status {
-		return make([]PlayerInfo, 0)
-	}
-
-	id := strconv.FormatInt(time.Now().Unix(), 10)
-
-	command := ""
 	if levelName == "#ALL_LEVEL" {
 		levelName = "Master"
-		command = "for i, v in ipairs(TheNet:GetClientTable()) do  print(string.format(\\\"player: {[%s] [%d] [%s] [%s] [%s] [%s]} \\\", " + "'" + id + "'" + ",i-1, string.format('%03d', v.playerage), v.userid, v.name, v.prefab)) end"
-	} else {
-		command = "for i, v in ipairs(AllPlayers) do print(string.format(\\\"player: {[%d] [%d] [%d] [%s] [%s] [%s]} \\\", " + id + ",i,v.components.age:GetAgeInDays(), v.userid, v.name, v.prefab)) end"
+		script = allPlayersScript
 	}
 
-	err := gameProcess.Command(clusterName, levelName, command)
-	log.Println("clusterName:", clusterName, "levelName:", levelName, "command:", command)
+	var players console.Array[PlayerInfo]
+	err := p.console.ExecInto(context.Background(), clusterName, levelName, script, playerListTimeout, &players)
 	if err != nil {
-		log.Println("Error sending command:", err)
+		if !errors.Is(err, console.ErrNotRunning) {
+			log.Println("查询玩家列表失败", "clusterName:", clusterName, "levelName:", levelName, err)
+		}
 		return make([]PlayerInfo, 0)
 	}
 
-	time.Sleep(time.Duration(1) * time.Second)
-
-	// 读取日志
-	serverLogPath := p.archive.ServerLogPath(clusterName, levelName)
-	dstLogs, err := fileUtils.ReverseRead(serverLogPath, 1000)
-	playerInfoList := make([]PlayerInfo, 0)
-
-	for _, line := range dstLogs {
-		if strings.Contains(line, id) && strings.Contains(line, "KU") && !strings.Contains(line, "Host") {
-
-			log.Println(line)
-
-			// 提取 {} 中的内容
-			reCurlyBraces := regexp.MustCompile(`\{([^}]*)\}`)
-			curlyBracesMatches := reCurlyBraces.FindStringSubmatch(line)
-
-			if len(curlyBracesMatches) > 1 {
-				// curlyBracesMatches[1] 包含 {} 中的内容
-				contentInsideCurlyBraces := curlyBracesMatches[1]
-
-				// 提取 [] 中的内容
-				reSquareBrackets := regexp.MustCompile(`\[([^\]]*)\]`)
-				squareBracketsMatches := reSquareBrackets.FindAllStringSubmatch(contentInsideCurlyBraces, -1)
-				var result []string
-				for _, match := range squareBracketsMatches {
-					// match[1] 包含 [] 中的内容
-					contentInsideSquareBrackets := match[1]
-					result = append(result, contentInsideSquareBrackets)
-				}
-				if len(result) >= 6 {
-					playerInfo := PlayerInfo{Key: result[1], Day: result[2], KuId: result[3], Name: result[4], Role: result[5]}
-					playerInfoList = append(playerInfoList, playerInfo)
-				}
-			}
+	// 按 KuId 去重
+	uniquePlayers := make(map[string]bool)
+	filteredPlayers := make([]PlayerInfo, 0, len(players))
+	for _, player := range players {
+		if player.KuId == "" || uniquePlayers[player.KuId] {
+			continue
 		}
-	}
-
-	// 创建一个map，用于存储不重复的KuId和对应的PlayerInfo对象
-	uniquePlayers := make(map[string]PlayerInfo)
-
-	// 遍历players切片
-	for _, player := range playerInfoList {
-		// 将PlayerInfo对象添加到map中，以KuId作为键
-		uniquePlayers[player.KuId] = player
-	}
-
-	// 将不重复的PlayerInfo对象从map中提取到新的切片中
-	filteredPlayers := make([]PlayerInfo, 0, len(uniquePlayers))
-	for _, player := range uniquePlayers {
+		uniquePlayers[player.KuId] = true
 		filteredPlayers = append(filteredPlayers, player)
 	}
-
 	return filteredPlayers
 }
 
-func (p *PlayerService) GetPlayerAllList(clusterName string, gameProcess game.Process) []PlayerInfo {
+func (p *PlayerService) GetPlayerAllList(clusterName string) []PlayerInfo {
 	// 使用 #ALL_LEVEL 调用 GetPlayerList，获取所有玩家
-	return p.GetPlayerList(clusterName, "#ALL_LEVEL", gameProcess)
+	return p.GetPlayerList(clusterName, "#ALL_LEVEL")
+}
+
+// GetPlayerDetail 查询玩家的状态、装备和物品，玩家需要在该世界中
+func (p *PlayerService) GetPlayerDetail(clusterName, levelName, kuId string) (*PlayerDetail, error) {
+	if kuId == "" {
+		return nil, errors.New("kuId 不能为空")
+	}
+	uuid := strconv.FormatInt(time.Now().UnixNano(), 10)
+	script := `return GetPlayerData("` + dstUtils.EscapeLuaString(kuId) + `", "` + uuid + `")`
+	var result struct {
+		Success bool         `json:"success"`
+		Error   string       `json:"error"`
+		Data    PlayerDetail `json:"data"`
+	}
+	if err := p.console.ExecInto(context.Background(), clusterName, levelName, script, playerListTimeout, &result); err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, errors.New(result.Error)
+	}
+	result.Data.KuId = kuId
+	result.Data.LevelName = levelName
+	return &result.Data, nil
 }
@@ -0,0 +1,462 @@
+package restart
+
+import (
+	"context"
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/utils/dstUtils"
+	"dst-admin-go/internal/service/archive"
+	"dst-admin-go/internal/service/audit"
+	"dst-admin-go/internal/service/autoCheck"
+	"dst-admin-go/internal/service/game"
+	"dst-admin-go/internal/service/levelConfig"
+	"dst-admin-go/internal/service/lifecycle"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCountdown 默认倒计时（秒）
+	DefaultCountdown = 60
+	// DefaultMessage 默认公告内容，{seconds} 替换为剩余秒数
+	DefaultMessage = "服务器将在 {seconds} 秒后重启，请注意保存进度"
+	// maxCountdown 倒计时上限（秒）
+	maxCountdown = 3600
+	// saveTimeout 等待 c_save() 写入存档的最长时间
+	saveTimeout = 2 * time.Minute
+	// readyTimeout 单个世界重启后等待进入 running 的最长时间
+	readyTimeout = 10 * time.Minute
+	// savePollInterval 检查存档是否写入的间隔
+	savePollInterval = 500 * time.Millisecond
+	// subscriberBuffer 订阅者的进度缓冲，处理不过来的进度会被丢弃
+	subscriberBuffer = 64
+)
+
+// DefaultIntervals 默认在剩余这些秒数时发送公告
+var DefaultIntervals = []int{300, 120, 60, 30, 10, 5, 4, 3, 2, 1}
+
+const (
+	StatusRunning   = "running"
+	StatusSuccess   = "success"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+const (
+	StageCountdown = "countdown"
+	StageSave      = "save"
+	StageRestart   = "restart"
+	StageState     = "state"
+	StageDone      = "done"
+)
+
+// Options 重启参数
+type Options struct {
+	// LevelName 为空时按主从顺序重启整个集群
+	LevelName string `json:"levelName"`
+	// Countdown 倒计时秒数，为 0 时使用默认值，小于 0 时立即重启
+	Countdown int `json:"countdown"`
+	// Intervals 剩余多少秒时发送公告，为空时使用默认值
+	Intervals []int `json:"intervals"`
+	// Message 公告内容，{seconds} 替换为剩余秒数
+	Message string `json:"message"`
+}
+
+// Progress 重启进度
+type Progress struct {
+	TaskId    string    `json:"taskId"`
+	Stage     string    `json:"stage"`
+	LevelName string    `json:"levelName"`
+	Message   string    `json:"message"`
+	Time      time.Time `json:"time"`
+}
+
+// Task 一次重启任务，每个集群同时只能有一个进行中的任务
+type Task struct {
+	Id          string             `json:"id"`
+	ClusterName string             `json:"clusterName"`
+	Options     Options            `json:"options"`
+	Status      string             `json:"status"`
+	Progress    []Progress         `json:"progress"`
+	Results     []game.LevelResult `json:"results"`
+	Error       string             `json:"error"`
+	StartedAt   time.Time          `json:"startedAt"`
+	FinishedAt  *time.Time         `json:"finishedAt"`
+	cancel      context.CancelFunc
+	subscribers map[chan Progress]struct{}
+}
+
+// RestartService 带倒计时公告的平滑重启：公告倒计时、保存存档、按主从顺序重启
+type RestartService struct {
+	process          game.Process
+	lifecycle        *lifecycle.LifecycleService
+	archive          *archive.PathResolver
+	levelConfigUtils *levelConfig.LevelConfigUtils
+	autoCheck        *autoCheck.AutoCheckService
+
+	mu    sync.Mutex
+	tasks map[string]*Task
+}
+
+func NewRestartService(process game.Process, lifecycle *lifecycle.LifecycleService, archive *archive.PathResolver, levelConfigUtils *levelConfig.LevelConfigUtils, autoCheck *autoCheck.AutoCheckService) *RestartService {
+	return &RestartService{
+		process:          process,
+		lifecycle:        lifecycle,
+		archive:          archive,
+		levelConfigUtils: levelConfigUtils,
+		autoCheck:        autoCheck,
+		tasks:            map[string]*Task{},
+	}
+}
+
+// Restart 开始重启，重启在后台执行，通过 GetTask 或 Subscribe 获取进度
+func (s *RestartService) Restart(operator audit.Operator, clusterName string, options Options) (Task, error) {
+	if options.Countdown == 0 {
+		options.Countdown = DefaultCountdown
+	}
+	if options.Countdown < 0 {
+		options.Countdown = 0
+	}
+	if options.Countdown > maxCountdown {
+		return Task{}, errors.New("倒计时不能超过 " + strconv.Itoa(maxCountdown) + " 秒")
+	}
+	if len(options.Intervals) == 0 {
+		options.Intervals = DefaultIntervals
+	}
+	if options.Message == "" {
+		options.Message = DefaultMessage
+	}
+	levels, err := s.levels(clusterName, options.LevelName)
+	if err != nil {
+		return Task{}, err
+	}
+
+	s.mu.Lock()
+	if task, ok := s.tasks[clusterName]; ok && task.Status == StatusRunning {
+		s.mu.Unlock()
+		return Task{}, errors.New("集群正在重启中")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	task := &Task{
+		Id:          strconv.FormatInt(time.Now().UnixNano(), 36),
+		ClusterName: clusterName,
+		Options:     options,
+		Status:      StatusRunning,
+		StartedAt:   time.Now(),
+		cancel:      cancel,
+		subscribers: map[chan Progress]struct{}{},
+	}
+	s.tasks[clusterName] = task
+	snapshot := task.snapshot()
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		s.run(ctx, operator, task, levels)
+	}()
+	return snapshot, nil
+}
+
+// Cancel 取消倒计时，已经开始保存或重启时无法取消
+func (s *RestartService) Cancel(clusterName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[clusterName]
+	if !ok || task.Status != StatusRunning {
+		return errors.New("没有进行中的重启")
+	}
+	if task.cancel == nil {
+		return errors.New("已经开始重启，无法取消")
+	}
+	task.cancel()
+	return nil
+}
+
+// GetTask 获取集群最近一次重启任务
+func (s *RestartService) GetTask(clusterName string) (Task, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[clusterName]
+	if !ok {
+		return Task{}, false
+	}
+	return task.snapshot(), true
+}
+
+// Subscribe 订阅集群重启进度，返回当前任务快照；任务结束后通道关闭
+func (s *RestartService) Subscribe(clusterName string) (Task, <-chan Progress, func(), bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[clusterName]
+	if !ok {
+		return Task{}, nil, func() {}, false
+	}
+	ch := make(chan Progress, subscriberBuffer)
+	if task.Status != StatusRunning {
+		close(ch)
+		return task.snapshot(), ch, func() {}, true
+	}
+	task.subscribers[ch] = struct{}{}
+	var once sync.Once
+	return task.snapshot(), ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if _, ok := task.subscribers[ch]; ok {
+				delete(task.subscribers, ch)
+				close(ch)
+			}
+		})
+	}, true
+}
+
+func (t *Task) snapshot() Task {
+	snapshot := *t
+	snapshot.Progress = append([]Progress(nil), t.Progress...)
+	snapshot.Results = append([]game.LevelResult(nil), t.Results...)
+	snapshot.cancel = nil
+	snapshot.subscribers = nil
+	return snapshot
+}
+
+// levels 需要重启的世界，集群重启时按主从顺序
+func (s *RestartService) levels(clusterName, levelName string) ([]levelConfig.OrderedLevel, error) {
+	levels, err := s.levelConfigUtils.GetOrderedLevels(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	if levelName == "" {
+		if len(levels) == 0 {
+			return nil, errors.New("集群没有世界")
+		}
+		return levels, nil
+	}
+	for _, level := range levels {
+		if level.File == levelName {
+			return []levelConfig.OrderedLevel{level}, nil
+		}
+	}
+	return nil, errors.New("世界不存在: " + levelName)
+}
+
+func (s *RestartService) report(task *Task, stage, levelName, message string) {
+	progress := Progress{
+		TaskId:    task.Id,
+		Stage:     stage,
+		LevelName: levelName,
+		Message:   message,
+		Time:      time.Now(),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task.Progress = append(task.Progress, progress)
+	for ch := range task.subscribers {
+		select {
+		case ch <- progress:
+		default:
+		}
+	}
+}
+
+func (s *RestartService) finish(task *Task, status string, results []game.LevelResult, err error) {
+	message := "重启完成"
+	if err != nil {
+		message = err.Error()
+	}
+	s.report(task, StageDone, task.Options.LevelName, message)
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task.Status = status
+	task.Results = results
+	task.FinishedAt = &now
+	task.cancel = nil
+	if err != nil {
+		task.Error = err.Error()
+	}
+	for ch := range task.subscribers {
+		close(ch)
+	}
+	task.subscribers = map[chan Progress]struct{}{}
+}
+
+func (s *RestartService) run(ctx context.Context, operator audit.Operator, task *Task, levels []levelConfig.OrderedLevel) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("[Restart]重启异常", "cluster:", task.ClusterName, r)
+			s.finish(task, StatusFailed, nil, fmt.Errorf("重启异常: %v", r))
+		}
+	}()
+	clusterName := task.ClusterName
+
+	var running []string
+	for _, level := range levels {
+		if ok, _ := s.process.Status(clusterName, level.File); ok {
+			running = append(running, level.File)
+		}
+	}
+
+	if len(running) > 0 {
+		if err := s.countdown(ctx, task, running); err != nil {
+			s.finish(task, StatusCancelled, nil, err)
+			return
+		}
+	}
+
+	// 倒计时结束后不再允许取消
+	s.mu.Lock()
+	task.cancel = nil
+	s.mu.Unlock()
+
+	// 重启期间暂停自动检测，避免被当作宕机重复启动
+	unlock := s.autoCheck.LockCluster(clusterName)
+	defer unlock()
+
+	for _, levelName := range running {
+		if err := s.save(task, levelName); err != nil {
+			s.finish(task, StatusFailed, nil, err)
+			return
+		}
+	}
+
+	results, err := s.restart(task, levels)
+	action := model.RESTART
+	if task.Options.LevelName == "" {
+		s.autoCheck.RecordClusterLog(operator, clusterName, action, "平滑重启", err)
+	} else {
+		s.autoCheck.RecordLog(operator, clusterName, task.Options.LevelName, action, "平滑重启", err)
+	}
+	if err != nil {
+		s.finish(task, StatusFailed, results, err)
+		return
+	}
+	s.finish(task, StatusSuccess, results, nil)
+}
+
+// countdown 倒计时，在剩余秒数命中 Intervals 时向运行中的世界发送公告
+func (s *RestartService) countdown(ctx context.Context, task *Task, running []string) error {
+	intervals := map[int]bool{}
+	for _, seconds := range task.Options.Intervals {
+		intervals[seconds] = true
+	}
+	announce := func(remaining int) {
+		content := strings.ReplaceAll(task.Options.Message, "{seconds}", strconv.Itoa(remaining))
+		for _, levelName := range running {
+			if err := s.process.Command(task.ClusterName, levelName, dstUtils.AnnounceCommand(content)); err != nil {
+				log.Println("[Restart]发送公告失败", "cluster:", task.ClusterName, "level:", levelName, err)
+			}
+		}
+		s.report(task, StageCountdown, "", content)
+	}
+
+	remaining := task.Options.Countdown
+	if remaining > 0 && !intervals[remaining] {
+		// 倒计时开始时总是先公告一次
+		announce(remaining)
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for ; remaining > 0; remaining-- {
+		if intervals[remaining] {
+			announce(remaining)
+		}
+		select {
+		case <-ctx.Done():
+			for _, levelName := range running {
+				s.process.Command(task.ClusterName, levelName, dstUtils.AnnounceCommand("重启已取消"))
+			}
+			return errors.New("重启已取消")
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// save 执行 c_save() 并等待 session 目录中最新的 .meta 文件更新
+func (s *RestartService) save(task *Task, levelName string) error {
+	sessionPath := s.archive.SessionPath(task.ClusterName, levelName)
+	before := latestMetaModTime(sessionPath)
+	s.report(task, StageSave, levelName, "正在保存存档")
+	if err := s.process.Command(task.ClusterName, levelName, "c_save()"); err != nil {
+		return fmt.Errorf("%s 保存失败: %w", levelName, err)
+	}
+	deadline := time.Now().Add(saveTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(savePollInterval)
+		if latestMetaModTime(sessionPath).After(before) {
+			s.report(task, StageSave, levelName, "存档已保存")
+			return nil
+		}
+	}
+	return errors.New(levelName + " 等待存档保存超时，已取消重启")
+}
+
+// restart 重启世界，并把重启期间的状态变化作为进度推送
+func (s *RestartService) restart(task *Task, levels []levelConfig.OrderedLevel) ([]game.LevelResult, error) {
+	events, cancel := s.lifecycle.Subscribe()
+	done := make(chan struct{})
+	defer func() {
+		cancel()
+		<-done
+	}()
+	go func() {
+		defer close(done)
+		for event := range events {
+			if event.ClusterName != task.ClusterName {
+				continue
+			}
+			s.report(task, StageState, event.LevelName, string(event.From)+" -> "+string(event.To)+" "+event.Message)
+		}
+	}()
+
+	clusterName := task.ClusterName
+	if task.Options.LevelName == "" {
+		s.report(task, StageRestart, "", "正在按主从顺序重启集群")
+		if runner, ok := s.process.(game.ClusterRunner); ok {
+			return runner.StartCluster(clusterName)
+		}
+		return nil, s.process.StartAll(clusterName)
+	}
+
+	level := levels[0]
+	s.report(task, StageRestart, level.File, "正在重启世界")
+	result := game.LevelResult{
+		LevelName: level.File,
+		IsMaster:  level.IsMaster,
+	}
+	err := s.process.Start(clusterName, level.File)
+	if err == nil {
+		_, err = s.lifecycle.WaitFor(context.Background(), clusterName, level.File, readyTimeout, lifecycle.Running)
+	}
+	if err != nil {
+		result.Message = err.Error()
+	} else {
+		result.Success = true
+		result.Message = "已重启"
+	}
+	result.State = string(s.lifecycle.GetState(clusterName, level.File).State)
+	return []game.LevelResult{result}, err
+}
+
+// latestMetaModTime session 目录中最新的 .meta 文件修改时间，没有时返回零值
+func latestMetaModTime(sessionPath string) time.Time {
+	var latest time.Time
+	filepath.WalkDir(sessionPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".meta") {
+			return nil
+		}
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return latest
+}
@@ -0,0 +1,294 @@
+package schedule
+
+import (
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/service/autoCheck"
+	"dst-admin-go/internal/service/backup"
+	"dst-admin-go/internal/service/game"
+	"dst-admin-go/internal/service/levelConfig"
+	"dst-admin-go/internal/service/update"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// cronParser 兼容 5 位和 6 位（带秒）的 cron 表达式，以及 @every 1h 这类描述符
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Schedule 定时任务调度服务，从 JobTask 表加载任务并按 cron 表达式执行
+type Schedule struct {
+	db               *gorm.DB
+	cron             *cron.Cron
+	gameProcess      game.Process
+	backupService    *backup.BackupService
+	updateService    update.Update
+	levelConfigUtils *levelConfig.LevelConfigUtils
+	autoCheck        *autoCheck.AutoCheckService
+	strategies       map[string]Strategy
+	entries          map[uint]cron.EntryID
+	mu               sync.Mutex
+}
+
+// JobTaskInfo 定时任务及其下一次、上一次执行时间
+type JobTaskInfo struct {
+	model.JobTask
+	Next time.Time `json:"next"`
+	Prev time.Time `json:"prev"`
+}
+
+func NewSchedule(db *gorm.DB, gameProcess game.Process, backupService *backup.BackupService, updateService update.Update, levelConfigUtils *levelConfig.LevelConfigUtils, autoCheck *autoCheck.AutoCheckService) *Schedule {
+	s := &Schedule{
+		db:               db,
+		gameProcess:      gameProcess,
+		backupService:    backupService,
+		updateService:    updateService,
+		levelConfigUtils: levelConfigUtils,
+		autoCheck:        autoCheck,
+		entries:          map[uint]cron.EntryID{},
+	}
+	s.cron = cron.New(
+		cron.WithParser(cronParser),
+		cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)),
+	)
+	s.strategies = s.newStrategies()
+	return s
+}
+
+// Start 加载数据库中的所有定时任务并启动调度
+func (s *Schedule) Start() {
+	var tasks []model.JobTask
+	if err := s.db.Find(&tasks).Error; err != nil {
+		log.Println("[Schedule]加载定时任务失败", err)
+	}
+	for i := range tasks {
+		if err := s.addJob(tasks[i]); err != nil {
+			log.Println("[Schedule]添加定时任务失败", "id:", tasks[i].ID, "cron:", tasks[i].Cron, err)
+		}
+	}
+	s.cron.Start()
+	log.Println("[Schedule]定时任务调度已启动，任务数:", len(tasks))
+}
+
+// Stop 停止调度，等待正在执行的任务结束
+func (s *Schedule) Stop() {
+	<-s.cron.Stop().Done()
+}
+
+func (s *Schedule) addJob(task model.JobTask) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entryId, ok := s.entries[task.ID]; ok {
+		s.cron.Remove(entryId)
+		delete(s.entries, task.ID)
+	}
+	taskId := task.ID
+	entryId, err := s.cron.AddFunc(task.Cron, func() {
+		s.run(taskId)
+	})
+	if err != nil {
+		return err
+	}
+	s.entries[task.ID] = entryId
+	return nil
+}
+
+func (s *Schedule) removeJob(taskId uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entryId, ok := s.entries[taskId]; ok {
+		s.cron.Remove(entryId)
+		delete(s.entries, taskId)
+	}
+}
+
+func (s *Schedule) validate(task *model.JobTask) error {
+	if _, err := cronParser.Parse(task.Cron); err != nil {
+		return fmt.Errorf("cron 表达式错误: %w", err)
+	}
+	if _, ok := s.strategies[task.Category]; !ok {
+		return errors.New("不支持的任务类型: " + task.Category)
+	}
+	if task.Category == CommandCategory && task.Command == "" {
+		return errors.New("command 任务的命令不能为空")
+	}
+	return nil
+}
+
+// GetJobTaskList 获取集群的定时任务列表
+func (s *Schedule) GetJobTaskList(clusterName string) []JobTaskInfo {
+	var tasks []model.JobTask
+	s.db.Where("cluster_name = ?", clusterName).Order("id asc").Find(&tasks)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]JobTaskInfo, 0, len(tasks))
+	for i := range tasks {
+		info := JobTaskInfo{JobTask: tasks[i]}
+		if entryId, ok := s.entries[tasks[i].ID]; ok {
+			entry := s.cron.Entry(entryId)
+			info.Next = entry.Next
+			info.Prev = entry.Prev
+		}
+		list = append(list, info)
+	}
+	return list
+}
+
+// CreateJobTask 创建定时任务并加入调度
+func (s *Schedule) CreateJobTask(task *model.JobTask) error {
+	if err := s.validate(task); err != nil {
+		return err
+	}
+	task.ID = 0
+	task.Uuid = "task_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := s.db.Create(task).Error; err != nil {
+		return err
+	}
+	return s.addJob(*task)
+}
+
+// UpdateJobTask 更新集群的定时任务并重新调度
+func (s *Schedule) UpdateJobTask(clusterName string, task *model.JobTask) error {
+	if err := s.validate(task); err != nil {
+		return err
+	}
+	oldTask := model.JobTask{}
+	if err := s.db.Where("cluster_name = ?", clusterName).First(&oldTask, task.ID).Error; err != nil {
+		return errors.New("定时任务不存在")
+	}
+	oldTask.LevelName = task.LevelName
+	oldTask.Cron = task.Cron
+	oldTask.Category = task.Category
+	oldTask.Comment = task.Comment
+	oldTask.Announcement = task.Announcement
+	oldTask.Sleep = task.Sleep
+	oldTask.Times = task.Times
+	oldTask.Script = task.Script
+	oldTask.Command = task.Command
+	if err := s.db.Save(&oldTask).Error; err != nil {
+		return err
+	}
+	*task = oldTask
+	return s.addJob(oldTask)
+}
+
+// DeleteJobTask 删除集群的定时任务并移出调度
+func (s *Schedule) DeleteJobTask(clusterName string, taskId uint) error {
+	task := model.JobTask{}
+	if err := s.db.Where("cluster_name = ?", clusterName).First(&task, taskId).Error; err != nil {
+		return errors.New("定时任务不存在")
+	}
+	s.removeJob(taskId)
+	return s.db.Where("cluster_name = ?", clusterName).Delete(&model.JobTask{}, taskId).Error
+}
+
+// GetJobTaskRecordPage 分页查询任务执行记录
+func (s *Schedule) GetJobTaskRecordPage(clusterName string, taskId uint, page, size int) ([]model.JobTaskRecord, int64) {
+	db := s.db.Model(&model.JobTaskRecord{}).Where("cluster_name = ?", clusterName)
+	if taskId != 0 {
+		db = db.Where("job_task_id = ?", taskId)
+	}
+	var total int64
+	db.Count(&total)
+
+	records := make([]model.JobTaskRecord, 0)
+	db.Order("created_at desc").Limit(size).Offset((page - 1) * size).Find(&records)
+	return records, total
+}
+
+// run 执行定时任务，并记录执行结果
+func (s *Schedule) run(taskId uint) {
+	task := model.JobTask{}
+	if err := s.db.First(&task, taskId).Error; err != nil {
+		log.Println("[Schedule]定时任务不存在，移出调度", "id:", taskId)
+		s.removeJob(taskId)
+		return
+	}
+
+	log.Println("[Schedule]开始执行定时任务", "id:", task.ID, "cluster:", task.ClusterName, "level:", task.LevelName, "category:", task.Category)
+	start := time.Now()
+	err := s.execute(task)
+
+	record := model.JobTaskRecord{
+		JobTaskId:   task.ID,
+		ClusterName: task.ClusterName,
+		LevelName:   task.LevelName,
+		Category:    task.Category,
+		Comment:     task.Comment,
+		Success:     err == nil,
+		Message:     "success",
+		Duration:    time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		record.Message = err.Error()
+		log.Println("[Schedule]定时任务执行失败", "id:", task.ID, err)
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		log.Println("[Schedule]保存任务执行记录失败", err)
+	}
+}
+
+func (s *Schedule) execute(task model.JobTask) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	strategy, ok := s.strategies[task.Category]
+	if !ok {
+		return errors.New("不支持的任务类型: " + task.Category)
+	}
+	s.announce(task)
+	return strategy.Execute(task)
+}
+
+// announce 执行前发送公告，重复 Times 次，每次间隔 Sleep 秒
+func (s *Schedule) announce(task model.JobTask) {
+	if task.Announcement == "" || task.Times <= 0 {
+		return
+	}
+	for i := 0; i < task.Times; i++ {
+		for _, levelName := range s.runningLevels(task) {
+			if err := s.gameProcess.Command(task.ClusterName, levelName, announceCommand(task.Announcement)); err != nil {
+				log.Println("[Schedule]发送公告失败", "cluster:", task.ClusterName, "level:", levelName, err)
+			}
+		}
+		time.Sleep(time.Duration(task.Sleep) * time.Second)
+	}
+}
+
+// runningLevels 返回任务作用范围内正在运行的世界
+func (s *Schedule) runningLevels(task model.JobTask) []string {
+	var levels []string
+	for _, levelName := range s.levels(task) {
+		if ok, _ := s.gameProcess.Status(task.ClusterName, levelName); ok {
+			levels = append(levels, levelName)
+		}
+	}
+	return levels
+}
+
+// levels 返回任务作用范围内的世界，LevelName 为空时表示集群的所有世界
+func (s *Schedule) levels(task model.JobTask) []string {
+	if task.LevelName != "" {
+		return []string{task.LevelName}
+	}
+	config, err := s.levelConfigUtils.GetLevelConfig(task.ClusterName)
+	if err != nil {
+		return []string{}
+	}
+	levels := make([]string, 0, len(config.LevelList))
+	for _, item := range config.LevelList {
+		levels = append(levels, item.File)
+	}
+	return levels
+}
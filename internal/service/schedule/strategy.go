@@ -0,0 +1,130 @@
+package schedule
+
+import (
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/utils/dstUtils"
+	"dst-admin-go/internal/service/audit"
+	"errors"
+	"log"
+)
+
+const (
+	StartCategory      = "start"
+	StopCategory       = "stop"
+	RestartCategory    = "restart"
+	UpdateCategory     = "update"
+	BackupCategory     = "backup"
+	RegenerateCategory = "regenerate"
+	CommandCategory    = "command"
+)
+
+// Strategy 定时任务的执行策略，每种任务类型对应一个实现
+type Strategy interface {
+	Execute(task model.JobTask) error
+}
+
+type StrategyFunc func(task model.JobTask) error
+
+func (f StrategyFunc) Execute(task model.JobTask) error {
+	return f(task)
+}
+
+func (s *Schedule) newStrategies() map[string]Strategy {
+	return map[string]Strategy{
+		StartCategory:      StrategyFunc(s.start),
+		StopCategory:       StrategyFunc(s.stop),
+		RestartCategory:    StrategyFunc(s.restart),
+		UpdateCategory:     StrategyFunc(s.update),
+		BackupCategory:     StrategyFunc(s.backup),
+		RegenerateCategory: StrategyFunc(s.regenerate),
+		CommandCategory:    StrategyFunc(s.command),
+	}
+}
+
+// systemOperator 定时任务在审计日志中的操作人
+var systemOperator = audit.System("schedule")
+
+func announceCommand(content string) string {
+	return dstUtils.AnnounceCommand(content)
+}
+
+// start 启动指定世界，未指定世界时启动整个集群
+func (s *Schedule) start(task model.JobTask) error {
+	if task.LevelName == "" {
+		err := s.gameProcess.StartAll(task.ClusterName)
+		s.autoCheck.RecordClusterLog(systemOperator, task.ClusterName, model.RUN, "定时任务启动", err)
+		return err
+	}
+	err := s.gameProcess.Start(task.ClusterName, task.LevelName)
+	s.autoCheck.RecordLog(systemOperator, task.ClusterName, task.LevelName, model.RUN, "定时任务启动", err)
+	return err
+}
+
+// stop 停止指定世界，未指定世界时停止整个集群
+func (s *Schedule) stop(task model.JobTask) error {
+	if task.LevelName == "" {
+		err := s.gameProcess.StopAll(task.ClusterName)
+		s.autoCheck.RecordClusterLog(systemOperator, task.ClusterName, model.STOP, "定时任务停止", err)
+		return err
+	}
+	err := s.gameProcess.Stop(task.ClusterName, task.LevelName)
+	s.autoCheck.RecordLog(systemOperator, task.ClusterName, task.LevelName, model.STOP, "定时任务停止", err)
+	return err
+}
+
+// restart 重启世界，Start 本身会先停止已运行的世界
+func (s *Schedule) restart(task model.JobTask) error {
+	unlock := s.autoCheck.LockCluster(task.ClusterName)
+	defer unlock()
+	if task.LevelName == "" {
+		if err := s.gameProcess.StopAll(task.ClusterName); err != nil {
+			log.Println("[Schedule]停止集群失败", err)
+		}
+		return s.gameProcess.StartAll(task.ClusterName)
+	}
+	return s.gameProcess.Start(task.ClusterName, task.LevelName)
+}
+
+// update 停止集群，更新游戏后重新启动
+func (s *Schedule) update(task model.JobTask) error {
+	unlock := s.autoCheck.LockCluster(task.ClusterName)
+	defer unlock()
+	if err := s.gameProcess.StopAll(task.ClusterName); err != nil {
+		log.Println("[Schedule]停止集群失败", err)
+	}
+	if err := s.updateService.Update(task.ClusterName); err != nil {
+		return err
+	}
+	return s.gameProcess.StartAll(task.ClusterName)
+}
+
+// backup 创建集群存档备份
+func (s *Schedule) backup(task model.JobTask) error {
+	s.backupService.CreateBackup(task.ClusterName, "")
+	return nil
+}
+
+// regenerate 重新生成世界，需要在主世界执行
+func (s *Schedule) regenerate(task model.JobTask) error {
+	levelName := task.LevelName
+	if levelName == "" {
+		levelName = "Master"
+	}
+	return s.gameProcess.Command(task.ClusterName, levelName, "c_regenerateworld()")
+}
+
+// command 向世界发送自定义命令，未指定世界时发送给所有运行中的世界
+func (s *Schedule) command(task model.JobTask) error {
+	levels := s.runningLevels(task)
+	if len(levels) == 0 {
+		return errors.New("没有运行中的世界")
+	}
+	var lastErr error
+	for _, levelName := range levels {
+		if err := s.gameProcess.Command(task.ClusterName, levelName, task.Command); err != nil {
+			log.Println("[Schedule]发送命令失败", "level:", levelName, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,57 @@
+package session
+
+import (
+	"dst-admin-go/internal/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SessionService struct {
+	db *gorm.DB
+}
+
+// SessionInfo 会话信息，Current 表示是否为当前请求的会话
+type SessionInfo struct {
+	model.Session
+	Current bool `json:"current"`
+}
+
+func NewSessionService(db *gorm.DB) *SessionService {
+	return &SessionService{
+		db: db,
+	}
+}
+
+// GetSessionList 获取有效的会话列表，username 为空时返回所有用户的会话
+func (s *SessionService) GetSessionList(username, currentId string) []SessionInfo {
+	var rows []model.Session
+	db := s.db.Where("expires_at > ?", time.Now())
+	if username != "" {
+		db = db.Where("username = ?", username)
+	}
+	db.Order("last_seen desc").Find(&rows)
+	list := make([]SessionInfo, 0, len(rows))
+	for i := range rows {
+		list = append(list, SessionInfo{Session: rows[i], Current: rows[i].ID == currentId})
+	}
+	return list
+}
+
+// RevokeSession 撤销会话，被撤销的会话需要重新登录，username 为空时可以撤销任意用户的会话
+func (s *SessionService) RevokeSession(username, id string) error {
+	row := model.Session{}
+	if err := s.db.Where("id = ?", id).First(&row).Error; err != nil {
+		return errors.New("会话不存在")
+	}
+	if username != "" && row.Username != username {
+		return errors.New("会话不存在")
+	}
+	return s.db.Delete(&row).Error
+}
+
+// RevokeUserSessions 撤销用户的所有会话，keepId 不为空时保留该会话
+func (s *SessionService) RevokeUserSessions(username, keepId string) error {
+	return s.db.Where("username = ? AND id <> ?", username, keepId).Delete(&model.Session{}).Error
+}
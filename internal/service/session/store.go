@@ -0,0 +1,238 @@
+package session
+
+import (
+	"context"
+	"dst-admin-go/internal/model"
+	"encoding/base32"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+	"gorm.io/gorm"
+)
+
+// secretKey 会话签名密钥在 KV 表中的 key
+const secretKey = "session_secret"
+
+// touchInterval 最近访问时间的更新间隔，避免每个请求都写数据库
+const touchInterval = time.Minute
+
+// Store 基于 SQLite 的会话存储，实现 gin-contrib/sessions 的 Store 接口
+type Store struct {
+	db      *gorm.DB
+	codecs  []securecookie.Codec
+	options *gsessions.Options
+}
+
+func NewStore(db *gorm.DB) *Store {
+	store := &Store{
+		db:     db,
+		codecs: securecookie.CodecsFromPairs(loadSecret(db)),
+		options: &gsessions.Options{
+			Path:   "/",
+			MaxAge: 86400 * 7,
+		},
+	}
+	go store.cleanup()
+	return store
+}
+
+// loadSecret 读取会话签名密钥，首次启动时随机生成并保存
+func loadSecret(db *gorm.DB) []byte {
+	kv := model.KV{}
+	db.Where("key = ?", secretKey).Find(&kv)
+	if secret, err := hex.DecodeString(kv.Value); err == nil && len(secret) == 64 {
+		return secret
+	}
+	secret := securecookie.GenerateRandomKey(64)
+	kv.Key = secretKey
+	kv.Value = hex.EncodeToString(secret)
+	if err := db.Save(&kv).Error; err != nil {
+		log.Println("[Session]保存会话密钥失败", err)
+	}
+	log.Println("[Session]已生成新的会话密钥")
+	return secret
+}
+
+func (s *Store) Options(options sessions.Options) {
+	s.options = options.ToGorillaOptions()
+}
+
+func (s *Store) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New 从 cookie 中的会话 ID 加载会话，会话不存在或已过期时返回新的会话
+func (s *Store) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	options := *s.options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var id string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &id, s.codecs...); err != nil {
+		return session, nil
+	}
+	row := model.Session{}
+	if s.db.Where("id = ?", id).Limit(1).Find(&row).RowsAffected == 0 {
+		return session, nil
+	}
+	if time.Now().After(row.ExpiresAt) {
+		s.db.Delete(&row)
+		return session, nil
+	}
+	if err := securecookie.DecodeMulti(name, row.Data, &session.Values, s.codecs...); err != nil {
+		return session, nil
+	}
+	session.ID = id
+	session.IsNew = false
+	s.touch(&row, r)
+	return session, nil
+}
+
+// Save 保存会话，会话为空或 MaxAge 小于 0 时删除会话
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.Options.MaxAge < 0 || len(session.Values) == 0 {
+		if session.ID != "" {
+			s.db.Where("id = ?", session.ID).Delete(&model.Session{})
+		}
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", &gsessions.Options{
+			Path:     session.Options.Path,
+			Domain:   session.Options.Domain,
+			MaxAge:   -1,
+			Secure:   session.Options.Secure,
+			HttpOnly: session.Options.HttpOnly,
+			SameSite: session.Options.SameSite,
+		}))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+	}
+	data, err := securecookie.EncodeMulti(session.Name(), session.Values, s.codecs...)
+	if err != nil {
+		return err
+	}
+	maxAge := session.Options.MaxAge
+	if maxAge == 0 {
+		maxAge = s.options.MaxAge
+	}
+	now := time.Now()
+	username, _ := session.Values["username"].(string)
+	userAgent := r.UserAgent()
+	row := model.Session{
+		ID:        session.ID,
+		Username:  username,
+		Data:      data,
+		Device:    parseDevice(userAgent),
+		UserAgent: userAgent,
+		IP:        clientIP(r),
+		CreatedAt: now,
+		LastSeen:  now,
+		ExpiresAt: now.Add(time.Duration(maxAge) * time.Second),
+	}
+	old := model.Session{}
+	if s.db.Where("id = ?", session.ID).Limit(1).Find(&old).RowsAffected > 0 {
+		row.CreatedAt = old.CreatedAt
+	}
+	if err := s.db.Save(&row).Error; err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// touch 更新会话的最近访问时间和 IP
+func (s *Store) touch(row *model.Session, r *http.Request) {
+	ip := clientIP(r)
+	if time.Since(row.LastSeen) < touchInterval && row.IP == ip {
+		return
+	}
+	s.db.Model(&model.Session{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+		"last_seen": time.Now(),
+		"ip":        ip,
+	})
+}
+
+// cleanup 定时删除过期的会话
+func (s *Store) cleanup() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		s.db.Where("expires_at < ?", time.Now()).Delete(&model.Session{})
+		<-ticker.C
+	}
+}
+
+type clientIPKey struct{}
+
+// ClientIP 将 gin 按可信代理设置解析出的客户端 IP 传给会话存储，需要在 sessions.Sessions 之前注册
+func ClientIP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), clientIPKey{}, c.ClientIP()))
+		c.Next()
+	}
+}
+
+// clientIP 会话记录的客户端 IP，与审计日志一样使用 gin 的 ClientIP，不直接信任请求中的 X-Forwarded-For
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok && ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// parseDevice 从 User-Agent 解析出简单的设备描述，例如 Windows / Chrome
+func parseDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	system := "Unknown"
+	switch {
+	case strings.Contains(ua, "iphone"):
+		system = "iPhone"
+	case strings.Contains(ua, "ipad"):
+		system = "iPad"
+	case strings.Contains(ua, "android"):
+		system = "Android"
+	case strings.Contains(ua, "windows"):
+		system = "Windows"
+	case strings.Contains(ua, "mac os"):
+		system = "macOS"
+	case strings.Contains(ua, "linux"):
+		system = "Linux"
+	}
+	browser := "Unknown"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	}
+	return system + " / " + browser
+}
@@ -0,0 +1,46 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		trusted []string
+		want    string
+	}{
+		{"不信任代理时忽略 X-Forwarded-For", nil, "192.0.2.1"},
+		{"可信代理转发的请求", []string{"192.0.2.1"}, "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			if err := engine.SetTrustedProxies(tt.trusted); err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			engine.Use(ClientIP())
+			engine.GET("/", func(c *gin.Context) {
+				got = clientIP(c.Request)
+				if got != c.ClientIP() {
+					t.Errorf("会话中的 IP %s 与 gin 的 ClientIP %s 不一致", got, c.ClientIP())
+				}
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "192.0.2.1:51234"
+			req.Header.Set("X-Forwarded-For", "203.0.113.9")
+			req.Header.Set("X-Real-Ip", "203.0.113.10")
+			engine.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Fatalf("clientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
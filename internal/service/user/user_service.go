@@ -0,0 +1,324 @@
+package user
+
+import (
+	"dst-admin-go/internal/model"
+	"dst-admin-go/internal/pkg/utils/fileUtils"
+	"dst-admin-go/internal/service/session"
+	"errors"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	RoleOwner    = "owner"
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+)
+
+// roleLevel 角色等级，数值越大权限越高
+var roleLevel = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+	RoleOwner:    4,
+}
+
+// legacyPasswordPath 旧版本保存用户名密码的文件
+const legacyPasswordPath = "./password.txt"
+
+type UserService struct {
+	db             *gorm.DB
+	sessionService *session.SessionService
+}
+
+// UserDetail 用户及其集群授权
+type UserDetail struct {
+	model.User
+	Clusters []model.UserCluster `json:"clusters"`
+}
+
+func NewUserService(db *gorm.DB, sessionService *session.SessionService) *UserService {
+	return &UserService{
+		db:             db,
+		sessionService: sessionService,
+	}
+}
+
+// ValidRole 是否为支持的角色
+func ValidRole(role string) bool {
+	_, ok := roleLevel[role]
+	return ok
+}
+
+// HasRole role 是否拥有 required 及以上的权限
+func HasRole(role, required string) bool {
+	return roleLevel[role] >= roleLevel[required]
+}
+
+// HasUser 是否已存在用户
+func (s *UserService) HasUser() bool {
+	var count int64
+	s.db.Model(&model.User{}).Count(&count)
+	return count > 0
+}
+
+// MigrateFromPasswordFile 首次启动时将旧的 password.txt 迁移为 owner 用户，迁移后删除明文密码文件
+func (s *UserService) MigrateFromPasswordFile() {
+	if s.HasUser() || !fileUtils.Exists(legacyPasswordPath) {
+		return
+	}
+	lines, err := fileUtils.ReadLnFile(legacyPasswordPath)
+	if err != nil {
+		log.Println("[User]读取旧的密码文件失败", err)
+		return
+	}
+	values := map[string]string{}
+	for _, line := range lines {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		values[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	if values["username"] == "" {
+		log.Println("[User]旧的密码文件中没有用户名，跳过迁移")
+		return
+	}
+	user := model.User{
+		Username:    values["username"],
+		DisplayName: values["displayName"],
+		PhotoURL:    values["photoURL"],
+		Role:        RoleOwner,
+	}
+	if err := s.CreateUser(&user, values["password"]); err != nil {
+		log.Println("[User]迁移旧的用户失败", err)
+		return
+	}
+	if err := os.Remove(legacyPasswordPath); err != nil {
+		log.Println("[User]删除旧的密码文件失败", err)
+	}
+	log.Println("[User]已将 password.txt 迁移为 owner 用户", user.Username)
+}
+
+// Authenticate 校验用户名和密码
+func (s *UserService) Authenticate(username, password string) (*model.User, error) {
+	user, err := s.GetUser(username)
+	if err != nil {
+		return nil, errors.New("用户名或密码错误")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, errors.New("用户名或密码错误")
+	}
+	return user, nil
+}
+
+// GetUser 根据用户名获取用户
+func (s *UserService) GetUser(username string) (*model.User, error) {
+	user := model.User{}
+	err := s.db.Where("username = ?", username).First(&user).Error
+	return &user, err
+}
+
+// GetUserById 根据 id 获取用户
+func (s *UserService) GetUserById(id uint) (*model.User, error) {
+	user := model.User{}
+	err := s.db.First(&user, id).Error
+	return &user, err
+}
+
+// GetOwner 获取第一个 owner 用户，白名单 IP 免密登录时使用
+func (s *UserService) GetOwner() (*model.User, error) {
+	user := model.User{}
+	err := s.db.Where("role = ?", RoleOwner).Order("id asc").First(&user).Error
+	return &user, err
+}
+
+// GetUserList 获取所有用户及其集群授权
+func (s *UserService) GetUserList() []UserDetail {
+	var users []model.User
+	s.db.Order("id asc").Find(&users)
+	list := make([]UserDetail, 0, len(users))
+	for i := range users {
+		list = append(list, UserDetail{User: users[i], Clusters: s.GetGrants(users[i].ID)})
+	}
+	return list
+}
+
+// CreateUser 创建用户，密码使用 bcrypt 保存
+func (s *UserService) CreateUser(user *model.User, password string) error {
+	return s.CreateUserTx(s.db, user, password)
+}
+
+// CreateUserTx 在事务 tx 中创建用户
+func (s *UserService) CreateUserTx(tx *gorm.DB, user *model.User, password string) error {
+	if user.Username == "" || password == "" {
+		return errors.New("用户名和密码不能为空")
+	}
+	if user.Role == "" {
+		user.Role = RoleViewer
+	}
+	if !ValidRole(user.Role) {
+		return errors.New("不支持的角色: " + user.Role)
+	}
+	if err := tx.Where("username = ?", user.Username).First(&model.User{}).Error; err == nil {
+		return errors.New("用户已存在: " + user.Username)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.ID = 0
+	user.Password = string(hash)
+	return tx.Create(user).Error
+}
+
+// UpdateUser 更新用户信息，password 为空时不修改密码
+// 修改用户名或密码后撤销该用户的其他会话，keepSessionId 为当前请求的会话
+func (s *UserService) UpdateUser(user *model.User, password, keepSessionId string) error {
+	oldUser := model.User{}
+	if err := s.db.First(&oldUser, user.ID).Error; err != nil {
+		return err
+	}
+	username := oldUser.Username
+	if user.Role != "" && !ValidRole(user.Role) {
+		return errors.New("不支持的角色: " + user.Role)
+	}
+	if user.Username != "" && user.Username != oldUser.Username {
+		if _, err := s.GetUser(user.Username); err == nil {
+			return errors.New("用户已存在: " + user.Username)
+		}
+		oldUser.Username = user.Username
+	}
+	if user.Role != "" && user.Role != oldUser.Role {
+		if oldUser.Role == RoleOwner && s.ownerCount() <= 1 {
+			return errors.New("至少需要保留一个 owner 用户")
+		}
+		oldUser.Role = user.Role
+	}
+	oldUser.DisplayName = user.DisplayName
+	oldUser.PhotoURL = user.PhotoURL
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		oldUser.Password = string(hash)
+	}
+	if err := s.db.Save(&oldUser).Error; err != nil {
+		return err
+	}
+	if password != "" || username != oldUser.Username {
+		s.revokeSessions(username, keepSessionId)
+	}
+	*user = oldUser
+	return nil
+}
+
+// ChangePassword 修改用户密码，并撤销该用户除 keepSessionId 以外的会话
+func (s *UserService) ChangePassword(username, password, keepSessionId string) error {
+	if password == "" {
+		return errors.New("密码不能为空")
+	}
+	user, err := s.GetUser(username)
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Model(user).Update("password", string(hash)).Error; err != nil {
+		return err
+	}
+	s.revokeSessions(username, keepSessionId)
+	return nil
+}
+
+// DeleteUser 删除用户及其集群授权和 API 令牌
+func (s *UserService) DeleteUser(id uint) error {
+	user := model.User{}
+	if err := s.db.First(&user, id).Error; err != nil {
+		return err
+	}
+	if user.Role == RoleOwner && s.ownerCount() <= 1 {
+		return errors.New("至少需要保留一个 owner 用户")
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", id).Delete(&model.UserCluster{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&model.ApiToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&user).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.revokeSessions(user.Username, "")
+	return nil
+}
+
+// revokeSessions 撤销用户的会话，失败时只记录日志，不影响已保存的用户信息
+func (s *UserService) revokeSessions(username, keepSessionId string) {
+	if err := s.sessionService.RevokeUserSessions(username, keepSessionId); err != nil {
+		log.Println("撤销用户会话失败", username, err)
+	}
+}
+
+func (s *UserService) ownerCount() int64 {
+	var count int64
+	s.db.Model(&model.User{}).Where("role = ?", RoleOwner).Count(&count)
+	return count
+}
+
+// GetGrants 获取用户的集群授权
+func (s *UserService) GetGrants(userId uint) []model.UserCluster {
+	grants := make([]model.UserCluster, 0)
+	s.db.Where("user_id = ?", userId).Order("id asc").Find(&grants)
+	return grants
+}
+
+// SaveGrants 覆盖保存用户的集群授权
+func (s *UserService) SaveGrants(userId uint, grants []model.UserCluster) error {
+	for i := range grants {
+		if grants[i].ClusterName == "" {
+			return errors.New("集群名称不能为空")
+		}
+		if grants[i].Role != "" && !ValidRole(grants[i].Role) {
+			return errors.New("不支持的角色: " + grants[i].Role)
+		}
+		grants[i].ID = 0
+		grants[i].UserId = userId
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userId).Delete(&model.UserCluster{}).Error; err != nil {
+			return err
+		}
+		if len(grants) == 0 {
+			return nil
+		}
+		return tx.Create(&grants).Error
+	})
+}
+
+// ClusterRole 用户在集群上的角色，owner 和 admin 可以访问所有集群，其他角色需要集群授权
+func (s *UserService) ClusterRole(user *model.User, clusterName string) (string, bool) {
+	if HasRole(user.Role, RoleAdmin) {
+		return user.Role, true
+	}
+	grant := model.UserCluster{}
+	if err := s.db.Where("user_id = ? AND cluster_name = ?", user.ID, clusterName).First(&grant).Error; err != nil {
+		return "", false
+	}
+	// 集群授权的角色不能高于用户自身的角色
+	if grant.Role != "" && !HasRole(grant.Role, user.Role) {
+		return grant.Role, true
+	}
+	return user.Role, true
+}